@@ -4,10 +4,15 @@ import (
 	"context"
 	"gRPC-gateway/config"
 	"gRPC-gateway/internal/server"
+	protogen "gRPC-gateway/internal/services/genproto/logs"
+	metricProtogen "gRPC-gateway/internal/services/genproto/metrics"
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+
+	"google.golang.org/protobuf/proto"
 )
 
 func main() {
@@ -24,12 +29,48 @@ func main() {
 		errChan <- err
 	}
 
+	if protoSerializer != nil {
+		if cfg.SchemaAutoRegister {
+			err := protoSerializer.RegisterSchemas(map[string]proto.Message{
+				"Logs-value":    &protogen.Log{},
+				"Metrics-value": &metricProtogen.Metrics{},
+			})
+			if err != nil {
+				errChan <- err
+			}
+		}
+		protoSerializer.StartRefresh(ctx)
+	}
+
+	kfk := &server.Kfk{Producer: producer, ProtoSerializer: protoSerializer}
+
+	// servers is done once every server stopped serving, they return only after their shutdown
+	var servers sync.WaitGroup
+	report := func(err error) {
+		select {
+		case errChan <- err:
+		default: // an earlier error already stops the gateway
+		}
+	}
+
+	servers.Add(1)
 	go func() {
-		if err := server.StartNewgRPCServer(ctx, cfg, &server.Kfk{Producer: producer, ProtoSerializer: protoSerializer}); err != nil {
-			errChan <- err
+		defer servers.Done()
+		if err := server.StartNewgRPCServer(ctx, cfg, kfk); err != nil {
+			report(err)
 		}
 	}()
 
+	if cfg.HTTPPort != "" {
+		servers.Add(1)
+		go func() {
+			defer servers.Done()
+			if err := server.StartNewHTTPServer(ctx, cfg, kfk); err != nil {
+				report(err)
+			}
+		}()
+	}
+
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -41,4 +82,11 @@ func main() {
 	}
 
 	log.Println("Shutting down...")
+	cancel()
+	// The in-flight requests still produce, the producer is closed after the servers stopped
+	servers.Wait()
+	if producer != nil {
+		// Flush batched messages before exiting
+		producer.Close()
+	}
 }
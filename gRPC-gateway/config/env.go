@@ -7,12 +7,17 @@ import (
 )
 
 type AppConfig struct {
-	ServerPort        string
-	KafkaHost         string
-	PartitionStrategy string
-	SchemaRegistryURL string
-	PostgresDb        string
-	GRPCSecret        string
+	ServerPort         string
+	KafkaHost          string
+	PartitionStrategy  string
+	SchemaRegistryURL  string
+	PostgresDb         string
+	GRPCSecret         string
+	HTTPPort           string
+	KafkaCompression   string
+	ProducerQueueSize  string
+	SchemaCacheTTL     string
+	SchemaAutoRegister bool
 }
 
 func SetupEnv() (*AppConfig, error) {
@@ -22,12 +27,17 @@ func SetupEnv() (*AppConfig, error) {
 	}
 
 	config := AppConfig{
-		ServerPort:        os.Getenv("SERVER_PORT"),
-		KafkaHost:         os.Getenv("KAFKA_HOST"),
-		PartitionStrategy: os.Getenv("PARTITION_STRATEGY"),
-		SchemaRegistryURL: os.Getenv("SCHEMA_REGISTRY_URL"),
-		PostgresDb:        os.Getenv("POSTGRES_DB"),
-		GRPCSecret:        os.Getenv("GRPC_SECRET"),
+		ServerPort:         os.Getenv("SERVER_PORT"),
+		KafkaHost:          os.Getenv("KAFKA_HOST"),
+		PartitionStrategy:  os.Getenv("PARTITION_STRATEGY"),
+		SchemaRegistryURL:  os.Getenv("SCHEMA_REGISTRY_URL"),
+		PostgresDb:         os.Getenv("POSTGRES_DB"),
+		GRPCSecret:         os.Getenv("GRPC_SECRET"),
+		HTTPPort:           os.Getenv("HTTP_PORT"),
+		KafkaCompression:   os.Getenv("KAFKA_COMPRESSION"),
+		ProducerQueueSize:  os.Getenv("PRODUCER_QUEUE_SIZE"),
+		SchemaCacheTTL:     os.Getenv("SCHEMA_CACHE_TTL"),
+		SchemaAutoRegister: os.Getenv("SCHEMA_AUTO_REGISTER") == "true",
 	}
 	return &config, nil
 }
@@ -1,21 +1,123 @@
 package config
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/riferrei/srclient"
 	"google.golang.org/protobuf/proto"
 )
 
+// defaultSchemaCacheTTL is how long a subject's schema ID is served from cache before it is re-fetched.
+const defaultSchemaCacheTTL = 5 * time.Minute
+
 type ProtobufSerializer struct {
 	client *srclient.SchemaRegistryClient
+	ttl    time.Duration
+	mu     sync.RWMutex
+	ids    map[string]cachedSchemaID
+}
+
+type cachedSchemaID struct {
+	id        int
+	fetchedAt time.Time
+}
+
+func NewProtobufSerializer(client *srclient.SchemaRegistryClient, ttl time.Duration) *ProtobufSerializer {
+	if ttl <= 0 {
+		ttl = defaultSchemaCacheTTL
+	}
+	return &ProtobufSerializer{
+		client: client,
+		ttl:    ttl,
+		ids:    make(map[string]cachedSchemaID),
+	}
+}
+
+// RegisterSchemas registers the compiled descriptor of each message under its subject and
+// seeds the schema ID cache. Registering an unchanged schema returns the existing ID.
+func (s *ProtobufSerializer) RegisterSchemas(schemas map[string]proto.Message) error {
+	for subject, message := range schemas {
+		schema, err := s.client.CreateSchema(subject, schemaFromDescriptor(message.ProtoReflect().Descriptor()), srclient.Protobuf)
+		if err != nil {
+			return fmt.Errorf("could not register schema for subject %s: %w", subject, err)
+		}
+		s.storeSchemaID(subject, schema.ID())
+		log.Printf("Registered schema for subject '%s' with ID %d", subject, schema.ID())
+	}
+	return nil
+}
+
+// StartRefresh refreshes every cached subject in the background until ctx is done, so the
+// hot path keeps hitting the cache while new schema versions are still picked up.
+func (s *ProtobufSerializer) StartRefresh(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.ttl / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.mu.RLock()
+				subjects := make([]string, 0, len(s.ids))
+				for subject := range s.ids {
+					subjects = append(subjects, subject)
+				}
+				s.mu.RUnlock()
+
+				for _, subject := range subjects {
+					if _, err := s.fetchSchemaID(subject); err != nil {
+						log.Printf("Warning: Failed to refresh schema for subject '%s', keeping cached ID. %v", subject, err)
+					}
+				}
+			}
+		}
+	}()
 }
 
-func NewProtobufSerializer(client *srclient.SchemaRegistryClient) *ProtobufSerializer {
-	return &ProtobufSerializer{client: client}
+// schemaID returns the cached ID for the subject. Expired entries are re-fetched, falling
+// back to the stale ID when the registry is unavailable.
+func (s *ProtobufSerializer) schemaID(subject string) (int, error) {
+	s.mu.RLock()
+	cached, ok := s.ids[subject]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.fetchedAt) < s.ttl {
+		return cached.id, nil
+	}
+
+	id, err := s.fetchSchemaID(subject)
+	if err != nil {
+		if ok {
+			log.Printf("Warning: Using stale schema ID %d for subject '%s'. %v", cached.id, subject, err)
+			return cached.id, nil
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *ProtobufSerializer) fetchSchemaID(subject string) (int, error) {
+	schema, err := s.client.GetLatestSchema(subject)
+	if err != nil {
+		log.Printf("Error: Failed to get schema for subject '%s'. %v", subject, err)
+		return 0, fmt.Errorf("could not get schema for subject %s: %w", subject, err)
+	}
+	s.storeSchemaID(subject, schema.ID())
+	return schema.ID(), nil
+}
+
+func (s *ProtobufSerializer) storeSchemaID(subject string, id int) {
+	s.mu.Lock()
+	s.ids[subject] = cachedSchemaID{id: id, fetchedAt: time.Now()}
+	s.mu.Unlock()
 }
 
 // Serialize encodes a protobuf message using the Confluent wire format.
@@ -25,11 +127,9 @@ func (s *ProtobufSerializer) Serialize(subject string, message proto.Message) ([
 		return nil, fmt.Errorf("ProtobufSerializer or its client is not initialized")
 	}
 
-	schema, err := s.client.GetLatestSchema(subject)
+	schemaID, err := s.schemaID(subject)
 	if err != nil {
-
-		log.Printf("Error: Failed to get schema for subject '%s'. %v", subject, err)
-		return nil, fmt.Errorf("could not get schema for subject %s: %w", subject, err)
+		return nil, err
 	}
 
 	// Marshal the actual protobuf message to bytes.
@@ -43,7 +143,7 @@ func (s *ProtobufSerializer) Serialize(subject string, message proto.Message) ([
 	result = append(result, 0x0)
 
 	schemaIDBytes := make([]byte, 4)
-	binary.BigEndian.PutUint32(schemaIDBytes, uint32(schema.ID()))
+	binary.BigEndian.PutUint32(schemaIDBytes, uint32(schemaID))
 	result = append(result, schemaIDBytes...)
 
 	result = append(result, data...)
@@ -51,27 +151,63 @@ func (s *ProtobufSerializer) Serialize(subject string, message proto.Message) ([
 	return result, nil
 }
 
-// SetupKafka initializes the Sarama SyncProducer and the ProtobufSerializer.
-func SetupKafka(cfg *AppConfig) (sarama.SyncProducer, *ProtobufSerializer, error) {
+// SetupKafka initializes the batched AsyncProducer and the ProtobufSerializer.
+func SetupKafka(cfg *AppConfig) (*AsyncProducer, *ProtobufSerializer, error) {
 	// Create Schema Registry client
 	srClient := srclient.NewSchemaRegistryClient(cfg.SchemaRegistryURL)
 
+	schemaCacheTTL := defaultSchemaCacheTTL
+	if cfg.SchemaCacheTTL != "" {
+		ttl, err := time.ParseDuration(cfg.SchemaCacheTTL)
+		if err != nil || ttl <= 0 {
+			return nil, nil, fmt.Errorf("invalid SCHEMA_CACHE_TTL %q", cfg.SchemaCacheTTL)
+		}
+		schemaCacheTTL = ttl
+	}
+
 	// Create a Protobuf Serializer
-	protoSerializer := NewProtobufSerializer(srClient)
+	protoSerializer := NewProtobufSerializer(srClient, schemaCacheTTL)
+
+	queueSize := 10000
+	if cfg.ProducerQueueSize != "" {
+		size, err := strconv.Atoi(cfg.ProducerQueueSize)
+		if err != nil || size <= 0 {
+			return nil, nil, fmt.Errorf("invalid PRODUCER_QUEUE_SIZE %q", cfg.ProducerQueueSize)
+		}
+		queueSize = size
+	}
 
 	// Sarama configuration
 	config := sarama.NewConfig()
+	config.Version = sarama.V2_1_0_0                 // Required for zstd compression
 	config.Producer.RequiredAcks = sarama.WaitForAll // Wait for all in-sync replicas to ack
 	config.Producer.Retry.Max = 5                    // Retry up to 5 times
 	config.Producer.Return.Successes = true          // Return successes on the success channel
 	config.Producer.Return.Errors = true             // Return errors on the error channel
+	config.ChannelBufferSize = queueSize             // Never block on Input while a queue slot is held
+
+	// Batch messages per topic-partition, flushing on whichever limit is hit first
+	config.Producer.Flush.Frequency = 20 * time.Millisecond
+	config.Producer.Flush.Messages = 500
+	config.Producer.Flush.Bytes = 1 << 20
+
+	switch strings.ToLower(cfg.KafkaCompression) {
+	case "", "zstd":
+		config.Producer.Compression = sarama.CompressionZSTD
+	case "lz4":
+		config.Producer.Compression = sarama.CompressionLZ4
+	case "none":
+		config.Producer.Compression = sarama.CompressionNone
+	default:
+		return nil, nil, fmt.Errorf("unsupported KAFKA_COMPRESSION %q", cfg.KafkaCompression)
+	}
 
 	// Create Sarama producer
-	producer, err := sarama.NewSyncProducer([]string{cfg.KafkaHost}, config)
+	producer, err := sarama.NewAsyncProducer([]string{cfg.KafkaHost}, config)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	log.Println("Kafka producer created")
-	return producer, protoSerializer, nil
+	log.Printf("Kafka producer created (compression=%s, queue=%d)", config.Producer.Compression, queueSize)
+	return NewAsyncProducer(producer, queueSize), protoSerializer, nil
 }
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// ErrProducerClosed is returned when a message is produced after Close.
+var ErrProducerClosed = errors.New("kafka producer is closed")
+
+// DeliveryCallback is invoked once Kafka acknowledges or rejects a message.
+// It runs on the producer's dispatch goroutine and must not block.
+type DeliveryCallback func(partition int32, offset int64, err error)
+
+// AsyncProducer wraps a sarama AsyncProducer with a bounded queue of in-flight
+// messages. Produce blocks while the queue is full, which pushes back on the
+// caller instead of buffering without limit.
+type AsyncProducer struct {
+	producer sarama.AsyncProducer
+	slots    chan struct{}
+	closing  chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func NewAsyncProducer(producer sarama.AsyncProducer, queueSize int) *AsyncProducer {
+	p := &AsyncProducer{
+		producer: producer,
+		slots:    make(chan struct{}, queueSize),
+		closing:  make(chan struct{}),
+	}
+	p.wg.Add(2)
+	go p.dispatchSuccesses()
+	go p.dispatchErrors()
+	return p
+}
+
+// Produce enqueues the message and returns once it is accepted by the producer.
+// The callback is called exactly once with the delivery result, unless Produce
+// itself returns an error.
+func (p *AsyncProducer) Produce(ctx context.Context, msg *sarama.ProducerMessage, callback DeliveryCallback) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.closing:
+		return ErrProducerClosed
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		<-p.slots
+		return ErrProducerClosed
+	}
+	msg.Metadata = callback
+	p.producer.Input() <- msg
+	return nil
+}
+
+// SendMessage produces the message and waits for its delivery result.
+func (p *AsyncProducer) SendMessage(ctx context.Context, msg *sarama.ProducerMessage) (int32, int64, error) {
+	type result struct {
+		partition int32
+		offset    int64
+		err       error
+	}
+	done := make(chan result, 1)
+	err := p.Produce(ctx, msg, func(partition int32, offset int64, err error) {
+		done <- result{partition, offset, err}
+	})
+	if err != nil {
+		return -1, -1, err
+	}
+	res := <-done
+	return res.partition, res.offset, res.err
+}
+
+// Close flushes the buffered messages and waits for every pending callback.
+func (p *AsyncProducer) Close() {
+	close(p.closing)
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	p.producer.AsyncClose()
+	p.wg.Wait()
+}
+
+func (p *AsyncProducer) dispatchSuccesses() {
+	defer p.wg.Done()
+	for msg := range p.producer.Successes() {
+		p.deliver(msg, nil)
+	}
+}
+
+func (p *AsyncProducer) dispatchErrors() {
+	defer p.wg.Done()
+	for pErr := range p.producer.Errors() {
+		p.deliver(pErr.Msg, pErr.Err)
+	}
+}
+
+func (p *AsyncProducer) deliver(msg *sarama.ProducerMessage, err error) {
+	<-p.slots
+	if callback, ok := msg.Metadata.(DeliveryCallback); ok && callback != nil {
+		callback(msg.Partition, msg.Offset, err)
+	}
+}
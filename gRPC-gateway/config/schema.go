@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// schemaFromDescriptor renders the file of a compiled message as .proto source for the
+// Schema Registry. The message itself is printed first so it is index 0 of the schema,
+// services and options are left out since they are not part of the record format.
+func schemaFromDescriptor(md protoreflect.MessageDescriptor) string {
+	file := md.ParentFile()
+	pkg := file.Package()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "syntax = %q;\n", syntaxName(file.Syntax()))
+	if pkg != "" {
+		fmt.Fprintf(&b, "package %s;\n", pkg)
+	}
+	b.WriteString("\n")
+	imports := file.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		fmt.Fprintf(&b, "import %q;\n", imports.Get(i).Path())
+	}
+	if imports.Len() > 0 {
+		b.WriteString("\n")
+	}
+
+	writeMessage(&b, md, pkg, "")
+	messages := file.Messages()
+	for i := 0; i < messages.Len(); i++ {
+		if messages.Get(i).FullName() == md.FullName() {
+			continue
+		}
+		writeMessage(&b, messages.Get(i), pkg, "")
+	}
+	enums := file.Enums()
+	for i := 0; i < enums.Len(); i++ {
+		writeEnum(&b, enums.Get(i), "")
+	}
+	return b.String()
+}
+
+func syntaxName(syntax protoreflect.Syntax) string {
+	if syntax == protoreflect.Proto2 {
+		return "proto2"
+	}
+	return "proto3"
+}
+
+func writeMessage(b *strings.Builder, md protoreflect.MessageDescriptor, pkg protoreflect.FullName, indent string) {
+	fmt.Fprintf(b, "%smessage %s {\n", indent, md.Name())
+	inner := indent + "  "
+
+	nested := md.Messages()
+	for i := 0; i < nested.Len(); i++ {
+		if !nested.Get(i).IsMapEntry() {
+			writeMessage(b, nested.Get(i), pkg, inner)
+		}
+	}
+	enums := md.Enums()
+	for i := 0; i < enums.Len(); i++ {
+		writeEnum(b, enums.Get(i), inner)
+	}
+
+	fields := md.Fields()
+	printed := make(map[protoreflect.Name]bool)
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		oneof := fd.ContainingOneof()
+		if oneof == nil || oneof.IsSynthetic() {
+			writeField(b, fd, pkg, inner)
+			continue
+		}
+		if printed[oneof.Name()] {
+			continue
+		}
+		printed[oneof.Name()] = true
+		fmt.Fprintf(b, "%soneof %s {\n", inner, oneof.Name())
+		oneofFields := oneof.Fields()
+		for j := 0; j < oneofFields.Len(); j++ {
+			writeField(b, oneofFields.Get(j), pkg, inner+"  ")
+		}
+		fmt.Fprintf(b, "%s}\n", inner)
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+func writeField(b *strings.Builder, fd protoreflect.FieldDescriptor, pkg protoreflect.FullName, indent string) {
+	label := ""
+	switch {
+	case fd.IsMap():
+	case fd.Cardinality() == protoreflect.Repeated:
+		label = "repeated "
+	case fd.HasOptionalKeyword():
+		label = "optional "
+	case fd.Cardinality() == protoreflect.Required:
+		label = "required "
+	}
+	fmt.Fprintf(b, "%s%s%s %s = %d;\n", indent, label, fieldType(fd, pkg), fd.Name(), fd.Number())
+}
+
+func fieldType(fd protoreflect.FieldDescriptor, pkg protoreflect.FullName) string {
+	if fd.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", fieldType(fd.MapKey(), pkg), fieldType(fd.MapValue(), pkg))
+	}
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return typeName(fd.Message().FullName(), pkg)
+	case protoreflect.EnumKind:
+		return typeName(fd.Enum().FullName(), pkg)
+	default:
+		return fd.Kind().String()
+	}
+}
+
+// typeName returns the name relative to the schema package, or fully qualified for imported types.
+func typeName(name, pkg protoreflect.FullName) string {
+	if pkg != "" && strings.HasPrefix(string(name), string(pkg)+".") {
+		return strings.TrimPrefix(string(name), string(pkg)+".")
+	}
+	return "." + string(name)
+}
+
+func writeEnum(b *strings.Builder, ed protoreflect.EnumDescriptor, indent string) {
+	fmt.Fprintf(b, "%senum %s {\n", indent, ed.Name())
+	values := ed.Values()
+	for i := 0; i < values.Len(); i++ {
+		fmt.Fprintf(b, "%s  %s = %d;\n", indent, values.Get(i).Name(), values.Get(i).Number())
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
@@ -9,15 +9,18 @@ import (
 	metricProtogen "gRPC-gateway/internal/services/genproto/metrics"
 	"gRPC-gateway/internal/services/log_service"
 	"gRPC-gateway/internal/services/metric_service"
+	"gRPC-gateway/internal/services/otlp_service"
 	"log"
 	"net"
+	"time"
 
-	"github.com/IBM/sarama"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	"google.golang.org/grpc"
 )
 
 type Kfk struct {
-	Producer        sarama.SyncProducer
+	Producer        *config.AsyncProducer
 	ProtoSerializer *config.ProtobufSerializer
 }
 
@@ -34,6 +37,7 @@ func StartNewgRPCServer(ctx context.Context, cfg *config.AppConfig, kfk *Kfk) er
 	}
 	s := grpc.NewServer(
 		grpc.StreamInterceptor(services.NewAuthStreamInterceptor(pg, cfg.GRPCSecret)),
+		grpc.UnaryInterceptor(services.NewAuthUnaryInterceptor(pg, cfg.GRPCSecret)),
 	)
 
 	log.Println("Server started on port", cfg.ServerPort)
@@ -42,10 +46,22 @@ func StartNewgRPCServer(ctx context.Context, cfg *config.AppConfig, kfk *Kfk) er
 	protogen.RegisterLogServiceServer(s, logService)
 	metricService := metric_service.NewMetricsServiceServer(kfk.Producer, kfk.ProtoSerializer)
 	metricProtogen.RegisterMetricsServiceServer(s, metricService)
+	collogspb.RegisterLogsServiceServer(s, otlp_service.NewLogsServiceServer(logService))
+	colmetricspb.RegisterMetricsServiceServer(s, otlp_service.NewMetricsServiceServer(metricService))
+	stopped := make(chan struct{})
 	go func() {
 		<-ctx.Done()
 		log.Println("Shutting down gRPC server...")
+		// Streams still open after the grace period are cut
+		timer := time.AfterFunc(10*time.Second, s.Stop)
 		s.GracefulStop()
+		timer.Stop()
+		close(stopped)
 	}()
-	return s.Serve(lis)
+	if err := s.Serve(lis); err != nil {
+		return err
+	}
+	// Serve returns as soon as the shutdown starts, wait for the in-flight RPCs to finish
+	<-stopped
+	return nil
 }
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"gRPC-gateway/config"
+	"gRPC-gateway/internal/services"
+	"gRPC-gateway/internal/services/http_service"
+	"gRPC-gateway/internal/services/log_service"
+	"gRPC-gateway/internal/services/metric_service"
+	"log"
+	"net/http"
+	"time"
+)
+
+func StartNewHTTPServer(ctx context.Context, cfg *config.AppConfig, kfk *Kfk) error {
+	pg, err := config.NewPostgres(cfg.PostgresDb, 10, 10, "5m")
+
+	if err != nil {
+		return err
+	}
+
+	logService := log_service.NewLogServiceServer(kfk.Producer, kfk.ProtoSerializer)
+	metricService := metric_service.NewMetricsServiceServer(kfk.Producer, kfk.ProtoSerializer)
+	ingestService := http_service.NewIngestServiceServer(logService, metricService)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/logs", ingestService.IngestLogs)
+	mux.HandleFunc("/v1/metrics", ingestService.IngestMetrics)
+	mux.HandleFunc("/api/v1/write", ingestService.RemoteWrite)
+
+	srv := &http.Server{
+		Addr:              cfg.HTTPPort,
+		Handler:           services.NewHTTPAuthMiddleware(pg, cfg.GRPCSecret)(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Println("HTTP server started on port", cfg.HTTPPort)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		log.Println("Shutting down HTTP server...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	// ListenAndServe returns as soon as the shutdown starts, wait for the in-flight requests
+	<-stopped
+	return nil
+}
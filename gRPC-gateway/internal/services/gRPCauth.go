@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -17,55 +18,76 @@ import (
 
 func NewAuthStreamInterceptor(db *gorm.DB, privateKey string) grpc.StreamServerInterceptor {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-
-		md, ok := metadata.FromIncomingContext(ss.Context())
-		if !ok {
-			return status.Error(codes.Unauthenticated, "metadata is not provided")
+		if err := authenticate(ss.Context(), db, privateKey); err != nil {
+			return err
 		}
+		return handler(srv, ss)
+	}
+}
 
-		serviceNameValues := md.Get("servicename")
-		if len(serviceNameValues) == 0 {
-			return status.Error(codes.Unauthenticated, "servicename header is not provided")
+// NewAuthUnaryInterceptor applies the same project key check as NewAuthStreamInterceptor to unary RPCs.
+func NewAuthUnaryInterceptor(db *gorm.DB, privateKey string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authenticate(ctx, db, privateKey); err != nil {
+			return nil, err
 		}
-		projectKey := serviceNameValues[0]
+		return handler(ctx, req)
+	}
+}
 
-		authHeaders := md.Get("authorization")
-		if len(authHeaders) == 0 {
-			return status.Error(codes.Unauthenticated, "authorization token is not provided")
-		}
+// authenticate validates the servicename and authorization metadata against the stored project key.
+func authenticate(ctx context.Context, db *gorm.DB, privateKey string) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "metadata is not provided")
+	}
 
-		token := authHeaders[0]
+	serviceNameValues := md.Get("servicename")
+	if len(serviceNameValues) == 0 {
+		return status.Error(codes.Unauthenticated, "servicename header is not provided")
+	}
+	projectKey := serviceNameValues[0]
 
-		var storedKey struct {
-			Key       string `json:"key"`
-			Value     string `json:"value"`
-			Timestamp int64  `json:"timestamp"`
-		}
+	authHeaders := md.Get("authorization")
+	if len(authHeaders) == 0 {
+		return status.Error(codes.Unauthenticated, "authorization token is not provided")
+	}
 
-		result := db.WithContext(ss.Context()).
-			Table("key_stores").
-			Select("key", "value", "timestamp").
-			Where("key = ?", projectKey).
-			First(&storedKey)
+	return ValidateProjectKey(ctx, db, privateKey, projectKey, authHeaders[0])
+}
 
-		if result.Error != nil {
-			if result.Error == gorm.ErrRecordNotFound {
-				log.Printf("Authentication failed: project name not found: %s", projectKey)
-				return status.Error(codes.Unauthenticated, "invalid credentials")
-			}
-			log.Printf("Database error during auth: %v", result.Error)
-			return status.Error(codes.Internal, "database error")
-		}
-		token = strings.TrimPrefix(token, "Bearer ")
+// ValidateProjectKey checks a project key token against the HMAC of the stored key for the project.
+// It returns gRPC status errors so both gRPC and HTTP callers can map them to their own responses.
+func ValidateProjectKey(ctx context.Context, db *gorm.DB, privateKey string, projectKey string, token string) error {
+	var storedKey struct {
+		Key       string `json:"key"`
+		Value     string `json:"value"`
+		Timestamp int64  `json:"timestamp"`
+	}
 
-		payload := fmt.Sprintf("%s.%s.%d", projectKey, storedKey.Value, storedKey.Timestamp)
+	result := db.WithContext(ctx).
+		Table("key_stores").
+		Select("key", "value", "timestamp").
+		Where("key = ?", projectKey).
+		First(&storedKey)
 
-		if !validateToken(privateKey, token, payload) {
-			log.Printf("Authentication failed: invalid token: %s", token)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			log.Printf("Authentication failed: project name not found: %s", projectKey)
 			return status.Error(codes.Unauthenticated, "invalid credentials")
 		}
-		return handler(srv, ss)
+		log.Printf("Database error during auth: %v", result.Error)
+		return status.Error(codes.Internal, "database error")
+	}
+	token = strings.TrimPrefix(token, "Bearer ")
+
+	payload := fmt.Sprintf("%s.%s.%d", projectKey, storedKey.Value, storedKey.Timestamp)
+
+	if !validateToken(privateKey, token, payload) {
+		log.Printf("Authentication failed: invalid token: %s", token)
+		return status.Error(codes.Unauthenticated, "invalid credentials")
 	}
+	return nil
 }
 
 func validateToken(privateKey string, expectedToken string, payload string) bool {
@@ -9,6 +9,7 @@ package protogen
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
@@ -89,8 +90,18 @@ type Log struct {
 	ResponseStatus string                 `protobuf:"bytes,11,opt,name=responseStatus,proto3" json:"responseStatus,omitempty"`
 	ResponseTime   string                 `protobuf:"bytes,12,opt,name=responseTime,proto3" json:"responseTime,omitempty"`
 	Timestamp      *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	// Typed response fields, preferred over the responseTime and responseStatus strings when set
+	ResponseTimeMs *float64 `protobuf:"fixed64,14,opt,name=response_time_ms,json=responseTimeMs,proto3,oneof" json:"response_time_ms,omitempty"`
+	StatusCode     *int32   `protobuf:"varint,15,opt,name=status_code,json=statusCode,proto3,oneof" json:"status_code,omitempty"`
+	// Extra context such as user or tenant IDs, as strings or with their JSON types
+	Attributes      map[string]string `protobuf:"bytes,16,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	TypedAttributes *structpb.Struct  `protobuf:"bytes,17,opt,name=typed_attributes,json=typedAttributes,proto3" json:"typed_attributes,omitempty"`
+	// W3C trace context, hex encoded
+	TraceId       string `protobuf:"bytes,18,opt,name=traceId,proto3" json:"traceId,omitempty"`
+	SpanId        string `protobuf:"bytes,19,opt,name=spanId,proto3" json:"spanId,omitempty"`
+	ParentSpanId  string `protobuf:"bytes,20,opt,name=parentSpanId,proto3" json:"parentSpanId,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Log) Reset() {
@@ -214,6 +225,55 @@ func (x *Log) GetTimestamp() *timestamppb.Timestamp {
 	return nil
 }
 
+func (x *Log) GetResponseTimeMs() float64 {
+	if x != nil && x.ResponseTimeMs != nil {
+		return *x.ResponseTimeMs
+	}
+	return 0
+}
+
+func (x *Log) GetStatusCode() int32 {
+	if x != nil && x.StatusCode != nil {
+		return *x.StatusCode
+	}
+	return 0
+}
+
+func (x *Log) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *Log) GetTypedAttributes() *structpb.Struct {
+	if x != nil {
+		return x.TypedAttributes
+	}
+	return nil
+}
+
+func (x *Log) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *Log) GetSpanId() string {
+	if x != nil {
+		return x.SpanId
+	}
+	return ""
+}
+
+func (x *Log) GetParentSpanId() string {
+	if x != nil {
+		return x.ParentSpanId
+	}
+	return ""
+}
+
 type Response struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Ack           bool                   `protobuf:"varint,1,opt,name=ack,proto3" json:"ack,omitempty"`
@@ -258,16 +318,292 @@ func (x *Response) GetAck() bool {
 	return false
 }
 
+type LogBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Logs          []*Log                 `protobuf:"bytes,1,rep,name=logs,proto3" json:"logs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogBatch) Reset() {
+	*x = LogBatch{}
+	mi := &file_log_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogBatch) ProtoMessage() {}
+
+func (x *LogBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogBatch.ProtoReflect.Descriptor instead.
+func (*LogBatch) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LogBatch) GetLogs() []*Log {
+	if x != nil {
+		return x.Logs
+	}
+	return nil
+}
+
+type LogResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Accepted      bool                   `protobuf:"varint,2,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogResult) Reset() {
+	*x = LogResult{}
+	mi := &file_log_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogResult) ProtoMessage() {}
+
+func (x *LogResult) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogResult.ProtoReflect.Descriptor instead.
+func (*LogResult) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LogResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *LogResult) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *LogResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type BatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accepted      int32                  `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Rejected      int32                  `protobuf:"varint,2,opt,name=rejected,proto3" json:"rejected,omitempty"`
+	Results       []*LogResult           `protobuf:"bytes,3,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchResponse) Reset() {
+	*x = BatchResponse{}
+	mi := &file_log_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchResponse) ProtoMessage() {}
+
+func (x *BatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchResponse.ProtoReflect.Descriptor instead.
+func (*BatchResponse) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchResponse) GetAccepted() int32 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+func (x *BatchResponse) GetRejected() int32 {
+	if x != nil {
+		return x.Rejected
+	}
+	return 0
+}
+
+func (x *BatchResponse) GetResults() []*LogResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type SequencedLog struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sequence      int64                  `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Log           *Log                   `protobuf:"bytes,2,opt,name=log,proto3" json:"log,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SequencedLog) Reset() {
+	*x = SequencedLog{}
+	mi := &file_log_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SequencedLog) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SequencedLog) ProtoMessage() {}
+
+func (x *SequencedLog) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SequencedLog.ProtoReflect.Descriptor instead.
+func (*SequencedLog) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SequencedLog) GetSequence() int64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *SequencedLog) GetLog() *Log {
+	if x != nil {
+		return x.Log
+	}
+	return nil
+}
+
+type LogAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sequence      int64                  `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Ack           bool                   `protobuf:"varint,2,opt,name=ack,proto3" json:"ack,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogAck) Reset() {
+	*x = LogAck{}
+	mi := &file_log_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogAck) ProtoMessage() {}
+
+func (x *LogAck) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogAck.ProtoReflect.Descriptor instead.
+func (*LogAck) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *LogAck) GetSequence() int64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *LogAck) GetAck() bool {
+	if x != nil {
+		return x.Ack
+	}
+	return false
+}
+
+func (x *LogAck) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
 var File_log_proto protoreflect.FileDescriptor
 
 const file_log_proto_rawDesc = "" +
 	"\n" +
-	"\tlog.proto\x12\x06logboy\x1a\x1fgoogle/protobuf/timestamp.proto\"P\n" +
+	"\tlog.proto\x12\x06logboy\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1cgoogle/protobuf/struct.proto\"P\n" +
 	"\fBuildDetails\x12 \n" +
 	"\vnodeVersion\x18\x01 \x01(\tR\vnodeVersion\x12\x1e\n" +
 	"\n" +
 	"appVersion\x18\x02 \x01(\tR\n" +
-	"appVersion\"\xcb\x03\n" +
+	"appVersion\"\xdb\x06\n" +
 	"\x03Log\x12 \n" +
 	"\vserviceName\x18\x01 \x01(\tR\vserviceName\x128\n" +
 	"\fbuildDetails\x18\x02 \x01(\v2\x14.logboy.BuildDetailsR\fbuildDetails\x12\x14\n" +
@@ -284,12 +620,47 @@ const file_log_proto_rawDesc = "" +
 	" \x01(\tR\bremoteIp\x12&\n" +
 	"\x0eresponseStatus\x18\v \x01(\tR\x0eresponseStatus\x12\"\n" +
 	"\fresponseTime\x18\f \x01(\tR\fresponseTime\x128\n" +
-	"\ttimestamp\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"\x1c\n" +
+	"\ttimestamp\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12-\n" +
+	"\x10response_time_ms\x18\x0e \x01(\x01H\x00R\x0eresponseTimeMs\x88\x01\x01\x12$\n" +
+	"\vstatus_code\x18\x0f \x01(\x05H\x01R\n" +
+	"statusCode\x88\x01\x01\x12;\n" +
+	"\n" +
+	"attributes\x18\x10 \x03(\v2\x1b.logboy.Log.AttributesEntryR\n" +
+	"attributes\x12B\n" +
+	"\x10typed_attributes\x18\x11 \x01(\v2\x17.google.protobuf.StructR\x0ftypedAttributes\x12\x18\n" +
+	"\atraceId\x18\x12 \x01(\tR\atraceId\x12\x16\n" +
+	"\x06spanId\x18\x13 \x01(\tR\x06spanId\x12\"\n" +
+	"\fparentSpanId\x18\x14 \x01(\tR\fparentSpanId\x1a=\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x13\n" +
+	"\x11_response_time_msB\x0e\n" +
+	"\f_status_code\"\x1c\n" +
 	"\bResponse\x12\x10\n" +
-	"\x03ack\x18\x01 \x01(\bR\x03ack2B\n" +
+	"\x03ack\x18\x01 \x01(\bR\x03ack\"+\n" +
+	"\bLogBatch\x12\x1f\n" +
+	"\x04logs\x18\x01 \x03(\v2\v.logboy.LogR\x04logs\"S\n" +
+	"\tLogResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x1a\n" +
+	"\baccepted\x18\x02 \x01(\bR\baccepted\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"t\n" +
+	"\rBatchResponse\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\x05R\baccepted\x12\x1a\n" +
+	"\brejected\x18\x02 \x01(\x05R\brejected\x12+\n" +
+	"\aresults\x18\x03 \x03(\v2\x11.logboy.LogResultR\aresults\"I\n" +
+	"\fSequencedLog\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x03R\bsequence\x12\x1d\n" +
+	"\x03log\x18\x02 \x01(\v2\v.logboy.LogR\x03log\"L\n" +
+	"\x06LogAck\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x03R\bsequence\x12\x10\n" +
+	"\x03ack\x18\x02 \x01(\bR\x03ack\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error2\xe4\x01\n" +
 	"\n" +
 	"LogService\x124\n" +
-	"\x11ReceiveLogsStream\x12\v.logboy.Log\x1a\x10.logboy.Response(\x01B\x17Z\x15gRPC-gateway/protogenb\x06proto3"
+	"\x11ReceiveLogsStream\x12\v.logboy.Log\x1a\x10.logboy.Response(\x01\x12(\n" +
+	"\aSendLog\x12\v.logboy.Log\x1a\x10.logboy.Response\x127\n" +
+	"\fSendLogBatch\x12\x10.logboy.LogBatch\x1a\x15.logboy.BatchResponse\x12=\n" +
+	"\x11StreamLogsWithAck\x12\x14.logboy.SequencedLog\x1a\x0e.logboy.LogAck(\x010\x01B\x17Z\x15gRPC-gateway/protogenb\x06proto3"
 
 var (
 	file_log_proto_rawDescOnce sync.Once
@@ -303,23 +674,41 @@ func file_log_proto_rawDescGZIP() []byte {
 	return file_log_proto_rawDescData
 }
 
-var file_log_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_log_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
 var file_log_proto_goTypes = []any{
 	(*BuildDetails)(nil),          // 0: logboy.BuildDetails
 	(*Log)(nil),                   // 1: logboy.Log
 	(*Response)(nil),              // 2: logboy.Response
-	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+	(*LogBatch)(nil),              // 3: logboy.LogBatch
+	(*LogResult)(nil),             // 4: logboy.LogResult
+	(*BatchResponse)(nil),         // 5: logboy.BatchResponse
+	(*SequencedLog)(nil),          // 6: logboy.SequencedLog
+	(*LogAck)(nil),                // 7: logboy.LogAck
+	nil,                           // 8: logboy.Log.AttributesEntry
+	(*timestamppb.Timestamp)(nil), // 9: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),       // 10: google.protobuf.Struct
 }
 var file_log_proto_depIdxs = []int32{
-	0, // 0: logboy.Log.buildDetails:type_name -> logboy.BuildDetails
-	3, // 1: logboy.Log.timestamp:type_name -> google.protobuf.Timestamp
-	1, // 2: logboy.LogService.ReceiveLogsStream:input_type -> logboy.Log
-	2, // 3: logboy.LogService.ReceiveLogsStream:output_type -> logboy.Response
-	3, // [3:4] is the sub-list for method output_type
-	2, // [2:3] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	0,  // 0: logboy.Log.buildDetails:type_name -> logboy.BuildDetails
+	9,  // 1: logboy.Log.timestamp:type_name -> google.protobuf.Timestamp
+	8,  // 2: logboy.Log.attributes:type_name -> logboy.Log.AttributesEntry
+	10, // 3: logboy.Log.typed_attributes:type_name -> google.protobuf.Struct
+	1,  // 4: logboy.LogBatch.logs:type_name -> logboy.Log
+	4,  // 5: logboy.BatchResponse.results:type_name -> logboy.LogResult
+	1,  // 6: logboy.SequencedLog.log:type_name -> logboy.Log
+	1,  // 7: logboy.LogService.ReceiveLogsStream:input_type -> logboy.Log
+	1,  // 8: logboy.LogService.SendLog:input_type -> logboy.Log
+	3,  // 9: logboy.LogService.SendLogBatch:input_type -> logboy.LogBatch
+	6,  // 10: logboy.LogService.StreamLogsWithAck:input_type -> logboy.SequencedLog
+	2,  // 11: logboy.LogService.ReceiveLogsStream:output_type -> logboy.Response
+	2,  // 12: logboy.LogService.SendLog:output_type -> logboy.Response
+	5,  // 13: logboy.LogService.SendLogBatch:output_type -> logboy.BatchResponse
+	7,  // 14: logboy.LogService.StreamLogsWithAck:output_type -> logboy.LogAck
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_log_proto_init() }
@@ -327,13 +716,14 @@ func file_log_proto_init() {
 	if File_log_proto != nil {
 		return
 	}
+	file_log_proto_msgTypes[1].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_log_proto_rawDesc), len(file_log_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   9,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -20,6 +20,9 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	LogService_ReceiveLogsStream_FullMethodName = "/logboy.LogService/ReceiveLogsStream"
+	LogService_SendLog_FullMethodName           = "/logboy.LogService/SendLog"
+	LogService_SendLogBatch_FullMethodName      = "/logboy.LogService/SendLogBatch"
+	LogService_StreamLogsWithAck_FullMethodName = "/logboy.LogService/StreamLogsWithAck"
 )
 
 // LogServiceClient is the client API for LogService service.
@@ -27,6 +30,9 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type LogServiceClient interface {
 	ReceiveLogsStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Log, Response], error)
+	SendLog(ctx context.Context, in *Log, opts ...grpc.CallOption) (*Response, error)
+	SendLogBatch(ctx context.Context, in *LogBatch, opts ...grpc.CallOption) (*BatchResponse, error)
+	StreamLogsWithAck(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SequencedLog, LogAck], error)
 }
 
 type logServiceClient struct {
@@ -50,11 +56,47 @@ func (c *logServiceClient) ReceiveLogsStream(ctx context.Context, opts ...grpc.C
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type LogService_ReceiveLogsStreamClient = grpc.ClientStreamingClient[Log, Response]
 
+func (c *logServiceClient) SendLog(ctx context.Context, in *Log, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, LogService_SendLog_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *logServiceClient) SendLogBatch(ctx context.Context, in *LogBatch, opts ...grpc.CallOption) (*BatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchResponse)
+	err := c.cc.Invoke(ctx, LogService_SendLogBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *logServiceClient) StreamLogsWithAck(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[SequencedLog, LogAck], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LogService_ServiceDesc.Streams[1], LogService_StreamLogsWithAck_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SequencedLog, LogAck]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LogService_StreamLogsWithAckClient = grpc.BidiStreamingClient[SequencedLog, LogAck]
+
 // LogServiceServer is the server API for LogService service.
 // All implementations must embed UnimplementedLogServiceServer
 // for forward compatibility.
 type LogServiceServer interface {
 	ReceiveLogsStream(grpc.ClientStreamingServer[Log, Response]) error
+	SendLog(context.Context, *Log) (*Response, error)
+	SendLogBatch(context.Context, *LogBatch) (*BatchResponse, error)
+	StreamLogsWithAck(grpc.BidiStreamingServer[SequencedLog, LogAck]) error
 	mustEmbedUnimplementedLogServiceServer()
 }
 
@@ -68,6 +110,15 @@ type UnimplementedLogServiceServer struct{}
 func (UnimplementedLogServiceServer) ReceiveLogsStream(grpc.ClientStreamingServer[Log, Response]) error {
 	return status.Errorf(codes.Unimplemented, "method ReceiveLogsStream not implemented")
 }
+func (UnimplementedLogServiceServer) SendLog(context.Context, *Log) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendLog not implemented")
+}
+func (UnimplementedLogServiceServer) SendLogBatch(context.Context, *LogBatch) (*BatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendLogBatch not implemented")
+}
+func (UnimplementedLogServiceServer) StreamLogsWithAck(grpc.BidiStreamingServer[SequencedLog, LogAck]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLogsWithAck not implemented")
+}
 func (UnimplementedLogServiceServer) mustEmbedUnimplementedLogServiceServer() {}
 func (UnimplementedLogServiceServer) testEmbeddedByValue()                    {}
 
@@ -96,19 +147,77 @@ func _LogService_ReceiveLogsStream_Handler(srv interface{}, stream grpc.ServerSt
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type LogService_ReceiveLogsStreamServer = grpc.ClientStreamingServer[Log, Response]
 
+func _LogService_SendLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Log)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogServiceServer).SendLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LogService_SendLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogServiceServer).SendLog(ctx, req.(*Log))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LogService_SendLogBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogBatch)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LogServiceServer).SendLogBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LogService_SendLogBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LogServiceServer).SendLogBatch(ctx, req.(*LogBatch))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LogService_StreamLogsWithAck_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LogServiceServer).StreamLogsWithAck(&grpc.GenericServerStream[SequencedLog, LogAck]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LogService_StreamLogsWithAckServer = grpc.BidiStreamingServer[SequencedLog, LogAck]
+
 // LogService_ServiceDesc is the grpc.ServiceDesc for LogService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var LogService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "logboy.LogService",
 	HandlerType: (*LogServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendLog",
+			Handler:    _LogService_SendLog_Handler,
+		},
+		{
+			MethodName: "SendLogBatch",
+			Handler:    _LogService_SendLogBatch_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "ReceiveLogsStream",
 			Handler:       _LogService_ReceiveLogsStream_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "StreamLogsWithAck",
+			Handler:       _LogService_StreamLogsWithAck_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "log.proto",
 }
@@ -21,6 +21,58 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type MetricType int32
+
+const (
+	MetricType_METRIC_TYPE_UNSPECIFIED MetricType = 0
+	MetricType_COUNTER                 MetricType = 1
+	MetricType_GAUGE                   MetricType = 2
+	MetricType_HISTOGRAM               MetricType = 3
+)
+
+// Enum value maps for MetricType.
+var (
+	MetricType_name = map[int32]string{
+		0: "METRIC_TYPE_UNSPECIFIED",
+		1: "COUNTER",
+		2: "GAUGE",
+		3: "HISTOGRAM",
+	}
+	MetricType_value = map[string]int32{
+		"METRIC_TYPE_UNSPECIFIED": 0,
+		"COUNTER":                 1,
+		"GAUGE":                   2,
+		"HISTOGRAM":               3,
+	}
+)
+
+func (x MetricType) Enum() *MetricType {
+	p := new(MetricType)
+	*p = x
+	return p
+}
+
+func (x MetricType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MetricType) Descriptor() protoreflect.EnumDescriptor {
+	return file_metrics_proto_enumTypes[0].Descriptor()
+}
+
+func (MetricType) Type() protoreflect.EnumType {
+	return &file_metrics_proto_enumTypes[0]
+}
+
+func (x MetricType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MetricType.Descriptor instead.
+func (MetricType) EnumDescriptor() ([]byte, []int) {
+	return file_metrics_proto_rawDescGZIP(), []int{0}
+}
+
 type MemoryUsage struct {
 	state                 protoimpl.MessageState `protogen:"open.v1"`
 	Timestamp             int64                  `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
@@ -209,18 +261,175 @@ func (x *CpuUsage) GetCores() []*CoreUsage {
 	return nil
 }
 
+// HistogramBucket counts the observations less than or equal to upperBound,
+// the last bucket of a histogram has an infinite upper bound
+type HistogramBucket struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UpperBound    float64                `protobuf:"fixed64,1,opt,name=upperBound,proto3" json:"upperBound,omitempty"`
+	Count         uint64                 `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistogramBucket) Reset() {
+	*x = HistogramBucket{}
+	mi := &file_metrics_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistogramBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistogramBucket) ProtoMessage() {}
+
+func (x *HistogramBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_metrics_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistogramBucket.ProtoReflect.Descriptor instead.
+func (*HistogramBucket) Descriptor() ([]byte, []int) {
+	return file_metrics_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *HistogramBucket) GetUpperBound() float64 {
+	if x != nil {
+		return x.UpperBound
+	}
+	return 0
+}
+
+func (x *HistogramBucket) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// MetricPoint is a sample of a named metric. Counters and gauges carry value,
+// histograms carry cumulative buckets along with the sum and count of the observations.
+type MetricPoint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type          MetricType             `protobuf:"varint,2,opt,name=type,proto3,enum=logboy.MetricType" json:"type,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Timestamp     int64                  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Value         float64                `protobuf:"fixed64,5,opt,name=value,proto3" json:"value,omitempty"`
+	Buckets       []*HistogramBucket     `protobuf:"bytes,6,rep,name=buckets,proto3" json:"buckets,omitempty"`
+	Sum           float64                `protobuf:"fixed64,7,opt,name=sum,proto3" json:"sum,omitempty"`
+	Count         uint64                 `protobuf:"varint,8,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetricPoint) Reset() {
+	*x = MetricPoint{}
+	mi := &file_metrics_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricPoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricPoint) ProtoMessage() {}
+
+func (x *MetricPoint) ProtoReflect() protoreflect.Message {
+	mi := &file_metrics_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetricPoint.ProtoReflect.Descriptor instead.
+func (*MetricPoint) Descriptor() ([]byte, []int) {
+	return file_metrics_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *MetricPoint) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *MetricPoint) GetType() MetricType {
+	if x != nil {
+		return x.Type
+	}
+	return MetricType_METRIC_TYPE_UNSPECIFIED
+}
+
+func (x *MetricPoint) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *MetricPoint) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *MetricPoint) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *MetricPoint) GetBuckets() []*HistogramBucket {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+func (x *MetricPoint) GetSum() float64 {
+	if x != nil {
+		return x.Sum
+	}
+	return 0
+}
+
+func (x *MetricPoint) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
 type Metrics struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	MemoryUsage   *MemoryUsage           `protobuf:"bytes,1,opt,name=memoryUsage,proto3" json:"memoryUsage,omitempty"`
 	CpuUsage      *CpuUsage              `protobuf:"bytes,2,opt,name=cpuUsage,proto3" json:"cpuUsage,omitempty"`
 	ServiceName   string                 `protobuf:"bytes,3,opt,name=serviceName,proto3" json:"serviceName,omitempty"`
+	Points        []*MetricPoint         `protobuf:"bytes,4,rep,name=points,proto3" json:"points,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Metrics) Reset() {
 	*x = Metrics{}
-	mi := &file_metrics_proto_msgTypes[3]
+	mi := &file_metrics_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -232,7 +441,7 @@ func (x *Metrics) String() string {
 func (*Metrics) ProtoMessage() {}
 
 func (x *Metrics) ProtoReflect() protoreflect.Message {
-	mi := &file_metrics_proto_msgTypes[3]
+	mi := &file_metrics_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -245,7 +454,7 @@ func (x *Metrics) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Metrics.ProtoReflect.Descriptor instead.
 func (*Metrics) Descriptor() ([]byte, []int) {
-	return file_metrics_proto_rawDescGZIP(), []int{3}
+	return file_metrics_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *Metrics) GetMemoryUsage() *MemoryUsage {
@@ -269,6 +478,13 @@ func (x *Metrics) GetServiceName() string {
 	return ""
 }
 
+func (x *Metrics) GetPoints() []*MetricPoint {
+	if x != nil {
+		return x.Points
+	}
+	return nil
+}
+
 type Res struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Ack           bool                   `protobuf:"varint,1,opt,name=ack,proto3" json:"ack,omitempty"`
@@ -278,7 +494,7 @@ type Res struct {
 
 func (x *Res) Reset() {
 	*x = Res{}
-	mi := &file_metrics_proto_msgTypes[4]
+	mi := &file_metrics_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -290,7 +506,7 @@ func (x *Res) String() string {
 func (*Res) ProtoMessage() {}
 
 func (x *Res) ProtoReflect() protoreflect.Message {
-	mi := &file_metrics_proto_msgTypes[4]
+	mi := &file_metrics_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -303,7 +519,7 @@ func (x *Res) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Res.ProtoReflect.Descriptor instead.
 func (*Res) Descriptor() ([]byte, []int) {
-	return file_metrics_proto_rawDescGZIP(), []int{4}
+	return file_metrics_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *Res) GetAck() bool {
@@ -334,13 +550,37 @@ const file_metrics_proto_rawDesc = "" +
 	"\bCpuUsage\x12\x1c\n" +
 	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\x12\x18\n" +
 	"\aaverage\x18\x02 \x01(\x01R\aaverage\x12'\n" +
-	"\x05cores\x18\x03 \x03(\v2\x11.logboy.CoreUsageR\x05cores\"\x90\x01\n" +
+	"\x05cores\x18\x03 \x03(\v2\x11.logboy.CoreUsageR\x05cores\"G\n" +
+	"\x0fHistogramBucket\x12\x1e\n" +
+	"\n" +
+	"upperBound\x18\x01 \x01(\x01R\n" +
+	"upperBound\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x04R\x05count\"\xcc\x02\n" +
+	"\vMetricPoint\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12&\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x12.logboy.MetricTypeR\x04type\x127\n" +
+	"\x06labels\x18\x03 \x03(\v2\x1f.logboy.MetricPoint.LabelsEntryR\x06labels\x12\x1c\n" +
+	"\ttimestamp\x18\x04 \x01(\x03R\ttimestamp\x12\x14\n" +
+	"\x05value\x18\x05 \x01(\x01R\x05value\x121\n" +
+	"\abuckets\x18\x06 \x03(\v2\x17.logboy.HistogramBucketR\abuckets\x12\x10\n" +
+	"\x03sum\x18\a \x01(\x01R\x03sum\x12\x14\n" +
+	"\x05count\x18\b \x01(\x04R\x05count\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xbd\x01\n" +
 	"\aMetrics\x125\n" +
 	"\vmemoryUsage\x18\x01 \x01(\v2\x13.logboy.MemoryUsageR\vmemoryUsage\x12,\n" +
 	"\bcpuUsage\x18\x02 \x01(\v2\x10.logboy.CpuUsageR\bcpuUsage\x12 \n" +
-	"\vserviceName\x18\x03 \x01(\tR\vserviceName\"\x17\n" +
+	"\vserviceName\x18\x03 \x01(\tR\vserviceName\x12+\n" +
+	"\x06points\x18\x04 \x03(\v2\x13.logboy.MetricPointR\x06points\"\x17\n" +
 	"\x03Res\x12\x10\n" +
-	"\x03ack\x18\x01 \x01(\bR\x03ack2B\n" +
+	"\x03ack\x18\x01 \x01(\bR\x03ack*P\n" +
+	"\n" +
+	"MetricType\x12\x1b\n" +
+	"\x17METRIC_TYPE_UNSPECIFIED\x10\x00\x12\v\n" +
+	"\aCOUNTER\x10\x01\x12\t\n" +
+	"\x05GAUGE\x10\x02\x12\r\n" +
+	"\tHISTOGRAM\x10\x032B\n" +
 	"\x0eMetricsService\x120\n" +
 	"\x0eReceiveMetrics\x12\x0f.logboy.Metrics\x1a\v.logboy.Res(\x01B\x17Z\x15gRPC-gateway/protogenb\x06proto3"
 
@@ -356,25 +596,34 @@ func file_metrics_proto_rawDescGZIP() []byte {
 	return file_metrics_proto_rawDescData
 }
 
-var file_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_metrics_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_metrics_proto_goTypes = []any{
-	(*MemoryUsage)(nil), // 0: logboy.MemoryUsage
-	(*CoreUsage)(nil),   // 1: logboy.CoreUsage
-	(*CpuUsage)(nil),    // 2: logboy.CpuUsage
-	(*Metrics)(nil),     // 3: logboy.Metrics
-	(*Res)(nil),         // 4: logboy.Res
+	(MetricType)(0),         // 0: logboy.MetricType
+	(*MemoryUsage)(nil),     // 1: logboy.MemoryUsage
+	(*CoreUsage)(nil),       // 2: logboy.CoreUsage
+	(*CpuUsage)(nil),        // 3: logboy.CpuUsage
+	(*HistogramBucket)(nil), // 4: logboy.HistogramBucket
+	(*MetricPoint)(nil),     // 5: logboy.MetricPoint
+	(*Metrics)(nil),         // 6: logboy.Metrics
+	(*Res)(nil),             // 7: logboy.Res
+	nil,                     // 8: logboy.MetricPoint.LabelsEntry
 }
 var file_metrics_proto_depIdxs = []int32{
-	1, // 0: logboy.CpuUsage.cores:type_name -> logboy.CoreUsage
-	0, // 1: logboy.Metrics.memoryUsage:type_name -> logboy.MemoryUsage
-	2, // 2: logboy.Metrics.cpuUsage:type_name -> logboy.CpuUsage
-	3, // 3: logboy.MetricsService.ReceiveMetrics:input_type -> logboy.Metrics
-	4, // 4: logboy.MetricsService.ReceiveMetrics:output_type -> logboy.Res
-	4, // [4:5] is the sub-list for method output_type
-	3, // [3:4] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	2, // 0: logboy.CpuUsage.cores:type_name -> logboy.CoreUsage
+	0, // 1: logboy.MetricPoint.type:type_name -> logboy.MetricType
+	8, // 2: logboy.MetricPoint.labels:type_name -> logboy.MetricPoint.LabelsEntry
+	4, // 3: logboy.MetricPoint.buckets:type_name -> logboy.HistogramBucket
+	1, // 4: logboy.Metrics.memoryUsage:type_name -> logboy.MemoryUsage
+	3, // 5: logboy.Metrics.cpuUsage:type_name -> logboy.CpuUsage
+	5, // 6: logboy.Metrics.points:type_name -> logboy.MetricPoint
+	6, // 7: logboy.MetricsService.ReceiveMetrics:input_type -> logboy.Metrics
+	7, // 8: logboy.MetricsService.ReceiveMetrics:output_type -> logboy.Res
+	8, // [8:9] is the sub-list for method output_type
+	7, // [7:8] is the sub-list for method input_type
+	7, // [7:7] is the sub-list for extension type_name
+	7, // [7:7] is the sub-list for extension extendee
+	0, // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_metrics_proto_init() }
@@ -387,13 +636,14 @@ func file_metrics_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_metrics_proto_rawDesc), len(file_metrics_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   5,
+			NumEnums:      1,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_metrics_proto_goTypes,
 		DependencyIndexes: file_metrics_proto_depIdxs,
+		EnumInfos:         file_metrics_proto_enumTypes,
 		MessageInfos:      file_metrics_proto_msgTypes,
 	}.Build()
 	File_metrics_proto = out.File
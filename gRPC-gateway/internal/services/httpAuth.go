@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+type projectContextKey struct{}
+
+// NewHTTPAuthMiddleware validates the servicename and Authorization headers with the
+// same project key check used by the gRPC interceptors.
+func NewHTTPAuthMiddleware(db *gorm.DB, privateKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			projectKey := r.Header.Get("servicename")
+			if projectKey == "" {
+				writeAuthError(w, http.StatusUnauthorized, "servicename header is not provided")
+				return
+			}
+			token := r.Header.Get("Authorization")
+			if token == "" {
+				writeAuthError(w, http.StatusUnauthorized, "authorization token is not provided")
+				return
+			}
+
+			if err := ValidateProjectKey(r.Context(), db, privateKey, projectKey, token); err != nil {
+				code := http.StatusUnauthorized
+				if status.Code(err) == codes.Internal {
+					code = http.StatusInternalServerError
+				}
+				writeAuthError(w, code, status.Convert(err).Message())
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), projectContextKey{}, projectKey)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ProjectFromContext returns the project authenticated by NewHTTPAuthMiddleware.
+func ProjectFromContext(ctx context.Context) string {
+	project, _ := ctx.Value(projectContextKey{}).(string)
+	return project
+}
+
+func writeAuthError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
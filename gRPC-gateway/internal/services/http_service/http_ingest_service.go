@@ -0,0 +1,249 @@
+package http_service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gRPC-gateway/internal/services"
+	protogen "gRPC-gateway/internal/services/genproto/logs"
+	metricProtogen "gRPC-gateway/internal/services/genproto/metrics"
+	"gRPC-gateway/internal/services/log_service"
+	"gRPC-gateway/internal/services/metric_service"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxBodySize caps the decompressed request body accepted by the ingestion endpoints.
+const maxBodySize = 10 << 20
+
+// IngestServiceServer accepts newline-delimited JSON or JSON arrays of logs and metrics
+// and produces them through the same path as the gRPC services.
+type IngestServiceServer struct {
+	logService    *log_service.LogServiceServer
+	metricService *metric_service.MetricsServiceServer
+}
+
+type LineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type IngestResponse struct {
+	Accepted int         `json:"accepted"`
+	Rejected int         `json:"rejected"`
+	Errors   []LineError `json:"errors,omitempty"`
+}
+
+func NewIngestServiceServer(logService *log_service.LogServiceServer, metricService *metric_service.MetricsServiceServer) *IngestServiceServer {
+	return &IngestServiceServer{
+		logService:    logService,
+		metricService: metricService,
+	}
+}
+
+// IngestLogs handles POST /v1/logs.
+func (s *IngestServiceServer) IngestLogs(w http.ResponseWriter, r *http.Request) {
+	project := services.ProjectFromContext(r.Context())
+	s.ingest(w, r, func(ctx context.Context, entries []entry) []error {
+		errs := make([]error, len(entries))
+		var logs []*protogen.Log
+		var indexes []int
+		for i, entry := range entries {
+			logMessage := &protogen.Log{}
+			if err := unmarshalEntry(entry.data, logMessage); err != nil {
+				errs[i] = err
+				continue
+			}
+			if logMessage.ServiceName == "" {
+				logMessage.ServiceName = project
+			}
+			if logMessage.ServiceName != project {
+				errs[i] = fmt.Errorf("serviceName %q does not match the authenticated project", logMessage.ServiceName)
+				continue
+			}
+			logs = append(logs, logMessage)
+			indexes = append(indexes, i)
+		}
+		for i, err := range s.logService.ProduceLogBatch(ctx, logs) {
+			errs[indexes[i]] = err
+		}
+		return errs
+	})
+}
+
+// IngestMetrics handles POST /v1/metrics.
+func (s *IngestServiceServer) IngestMetrics(w http.ResponseWriter, r *http.Request) {
+	project := services.ProjectFromContext(r.Context())
+	s.ingest(w, r, func(ctx context.Context, entries []entry) []error {
+		errs := make([]error, len(entries))
+		var metrics []*metricProtogen.Metrics
+		var indexes []int
+		for i, entry := range entries {
+			metricsMessage := &metricProtogen.Metrics{}
+			if err := unmarshalEntry(entry.data, metricsMessage); err != nil {
+				errs[i] = err
+				continue
+			}
+			if metricsMessage.ServiceName == "" {
+				metricsMessage.ServiceName = project
+			}
+			if metricsMessage.ServiceName != project {
+				errs[i] = fmt.Errorf("serviceName %q does not match the authenticated project", metricsMessage.ServiceName)
+				continue
+			}
+			metrics = append(metrics, metricsMessage)
+			indexes = append(indexes, i)
+		}
+		for i, err := range s.metricService.ProduceMetricsBatch(ctx, metrics) {
+			errs[indexes[i]] = err
+		}
+		return errs
+	})
+}
+
+// ingest decodes the body into entries and reports the result of produce per entry.
+func (s *IngestServiceServer) ingest(w http.ResponseWriter, r *http.Request, produce func(ctx context.Context, entries []entry) []error) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"message": "method not allowed"})
+		return
+	}
+
+	body, err := requestBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+	defer body.Close()
+
+	entries, err := readEntries(http.MaxBytesReader(w, body, maxBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		// Entries past the limit were never read, reject the whole body rather than part of it
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"message": fmt.Sprintf("decompressed body is larger than %d bytes", maxBodySize)})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	res := IngestResponse{}
+	for i, err := range produce(r.Context(), entries) {
+		if err != nil {
+			res.Rejected++
+			res.Errors = append(res.Errors, LineError{Line: entries[i].line, Error: status.Convert(err).Message()})
+			continue
+		}
+		res.Accepted++
+	}
+
+	code := http.StatusOK
+	if res.Accepted == 0 && res.Rejected > 0 {
+		code = http.StatusUnprocessableEntity
+	}
+	if res.Rejected > 0 {
+		log.Printf("Rejected %d of %d HTTP entries on %s", res.Rejected, len(entries), r.URL.Path)
+	}
+	writeJSON(w, code, res)
+}
+
+// requestBody returns the request body, transparently decompressing gzip payloads.
+func requestBody(r *http.Request) (io.ReadCloser, error) {
+	if !strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		return r.Body, nil
+	}
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip body: %w", err)
+	}
+	return gz, nil
+}
+
+type entry struct {
+	line int
+	data []byte
+}
+
+// readEntries splits the body into entries. A body starting with '[' is read as a
+// JSON array and entries are numbered by position, otherwise every non-empty line
+// is an entry numbered by its line in the body.
+func readEntries(body io.Reader) ([]entry, error) {
+	reader := bufio.NewReader(body)
+	first, skipped, err := peekNonSpace(reader)
+	if err == io.EOF {
+		return nil, fmt.Errorf("request body is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	var entries []entry
+	if first == '[' {
+		var raw []json.RawMessage
+		if err := json.NewDecoder(reader).Decode(&raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		for i, item := range raw {
+			entries = append(entries, entry{line: i + 1, data: item})
+		}
+		return entries, nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBodySize)
+	// the blank lines before the first entry were already read, they count all the same
+	line := skipped
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		entries = append(entries, entry{line: line, data: append([]byte(nil), data...)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read line %d: %w", line+1, err)
+	}
+	return entries, nil
+}
+
+// peekNonSpace skips leading whitespace and returns the first other byte without consuming
+// it, along with the number of lines it skipped
+func peekNonSpace(reader *bufio.Reader) (byte, int, error) {
+	lines := 0
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, lines, err
+		}
+		if b == '\n' {
+			lines++
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, lines, reader.UnreadByte()
+		}
+	}
+}
+
+func unmarshalEntry(data []byte, message proto.Message) error {
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, message); err != nil {
+		return fmt.Errorf("invalid entry: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
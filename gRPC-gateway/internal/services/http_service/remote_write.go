@@ -0,0 +1,309 @@
+package http_service
+
+import (
+	"fmt"
+	"gRPC-gateway/internal/services"
+	metricProtogen "gRPC-gateway/internal/services/genproto/metrics"
+	promProtogen "gRPC-gateway/internal/services/genproto/prometheus"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/snappy"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxPointsPerMessage bounds the points produced in one Metrics message, keeping Kafka
+// messages well below the broker's size limit
+const maxPointsPerMessage = 1000
+
+// RemoteWrite handles POST /api/v1/write, the Prometheus remote write 1.0 protocol. Samples
+// are produced as metric points through the same path as the other metrics, classic
+// histograms sent as _bucket, _sum and _count series are joined back into histogram points.
+func (s *IngestServiceServer) RemoteWrite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"message": "method not allowed"})
+		return
+	}
+	project := services.ProjectFromContext(r.Context())
+
+	req, err := readWriteRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	points := toMetricPoints(req)
+	var metrics []*metricProtogen.Metrics
+	for start := 0; start < len(points); start += maxPointsPerMessage {
+		end := min(start+maxPointsPerMessage, len(points))
+		metrics = append(metrics, &metricProtogen.Metrics{ServiceName: project, Points: points[start:end]})
+	}
+
+	// Prometheus retries 5xx responses and drops the samples on 4xx, so only invalid samples
+	// are answered with a client error. Once part of the request was produced a retry would
+	// produce those samples twice, so the failed part is logged and dropped instead.
+	var firstErr error
+	failed, dropped := 0, 0
+	for i, err := range s.metricService.ProduceMetricsBatch(r.Context(), metrics) {
+		if err == nil {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		failed++
+		dropped += len(metrics[i].GetPoints())
+	}
+	if firstErr == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if failed < len(metrics) {
+		log.Printf("Dropped %d of %d remote write samples for project %s: %v", dropped, len(points), project, firstErr)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	log.Printf("Failed to produce remote write samples for project %s: %v", project, firstErr)
+	code := http.StatusServiceUnavailable
+	if status.Code(firstErr) == codes.InvalidArgument {
+		code = http.StatusBadRequest
+	}
+	writeJSON(w, code, map[string]string{"message": status.Convert(firstErr).Message()})
+}
+
+// readWriteRequest decodes the snappy compressed WriteRequest in the body
+func readWriteRequest(r *http.Request) (*promProtogen.WriteRequest, error) {
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "snappy") {
+		return nil, fmt.Errorf("unsupported content encoding %q, expected snappy", encoding)
+	}
+	compressed, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if len(compressed) > maxBodySize {
+		return nil, fmt.Errorf("request body is larger than %d bytes", maxBodySize)
+	}
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy body: %w", err)
+	}
+	if size > maxBodySize {
+		return nil, fmt.Errorf("decompressed body is larger than %d bytes", maxBodySize)
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy body: %w", err)
+	}
+
+	req := &promProtogen.WriteRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		return nil, fmt.Errorf("invalid write request: %w", err)
+	}
+	return req, nil
+}
+
+// histogramPoint collects the series of one classic histogram at one timestamp
+type histogramPoint struct {
+	point    *metricProtogen.MetricPoint
+	sum      *float64
+	count    *float64
+	hasBound bool
+}
+
+// toMetricPoints converts the samples to metric points. Types come from the metadata when
+// Prometheus sends it, otherwise series ending in _total are counters, _bucket series with an
+// le label are histograms and everything else is a gauge. Stale markers and other NaN samples
+// are skipped.
+func toMetricPoints(req *promProtogen.WriteRequest) []*metricProtogen.MetricPoint {
+	types := make(map[string]promProtogen.MetricMetadata_MetricType, len(req.GetMetadata()))
+	for _, metadata := range req.GetMetadata() {
+		types[metadata.GetMetricFamilyName()] = metadata.GetType()
+	}
+
+	// Histogram families are found first, so their _sum and _count series can be told apart
+	// from counters with the same suffix
+	histograms := make(map[string]bool)
+	for name, metricType := range types {
+		if metricType == promProtogen.MetricMetadata_HISTOGRAM {
+			histograms[name] = true
+		}
+	}
+	for _, series := range req.GetTimeseries() {
+		name, labels := splitLabels(series.GetLabels())
+		if base, ok := strings.CutSuffix(name, "_bucket"); ok && labels["le"] != "" {
+			if _, known := types[base]; !known {
+				histograms[base] = true
+			}
+		}
+	}
+
+	var points []*metricProtogen.MetricPoint
+	pending := make(map[string]*histogramPoint)
+	var order []string
+	for _, series := range req.GetTimeseries() {
+		name, labels := splitLabels(series.GetLabels())
+		if name == "" {
+			continue
+		}
+
+		base, part := histogramPart(name, histograms)
+		if part == "" {
+			metricType := pointType(types, name)
+			for _, sample := range series.GetSamples() {
+				if math.IsNaN(sample.GetValue()) {
+					continue
+				}
+				points = append(points, &metricProtogen.MetricPoint{
+					Name:      name,
+					Type:      metricType,
+					Labels:    labels,
+					Timestamp: sample.GetTimestamp(),
+					Value:     sample.GetValue(),
+				})
+			}
+			continue
+		}
+
+		bound := labels["le"]
+		delete(labels, "le")
+		for _, sample := range series.GetSamples() {
+			value := sample.GetValue()
+			if math.IsNaN(value) {
+				continue
+			}
+			key := histogramKey(base, labels, sample.GetTimestamp())
+			h, ok := pending[key]
+			if !ok {
+				h = &histogramPoint{point: &metricProtogen.MetricPoint{
+					Name:      base,
+					Type:      metricProtogen.MetricType_HISTOGRAM,
+					Labels:    labels,
+					Timestamp: sample.GetTimestamp(),
+				}}
+				pending[key] = h
+				order = append(order, key)
+			}
+			switch part {
+			case "bucket":
+				upperBound, err := strconv.ParseFloat(bound, 64)
+				if err != nil {
+					continue
+				}
+				h.hasBound = true
+				h.point.Buckets = append(h.point.Buckets, &metricProtogen.HistogramBucket{
+					UpperBound: upperBound,
+					Count:      uint64(math.Max(value, 0)),
+				})
+			case "sum":
+				h.sum = &value
+			case "count":
+				h.count = &value
+			}
+		}
+	}
+
+	for _, key := range order {
+		h := pending[key]
+		if !h.hasBound {
+			// _sum and _count without buckets are kept as the counters they are
+			points = append(points, h.counters()...)
+			continue
+		}
+		sort.Slice(h.point.Buckets, func(i, j int) bool {
+			return h.point.Buckets[i].UpperBound < h.point.Buckets[j].UpperBound
+		})
+		if h.sum != nil {
+			h.point.Sum = *h.sum
+		}
+		if h.count != nil {
+			h.point.Count = uint64(math.Max(*h.count, 0))
+		} else {
+			h.point.Count = h.point.Buckets[len(h.point.Buckets)-1].Count
+		}
+		points = append(points, h.point)
+	}
+	return points
+}
+
+func (h *histogramPoint) counters() []*metricProtogen.MetricPoint {
+	var points []*metricProtogen.MetricPoint
+	for i, value := range []*float64{h.sum, h.count} {
+		if value == nil {
+			continue
+		}
+		suffix := []string{"_sum", "_count"}[i]
+		points = append(points, &metricProtogen.MetricPoint{
+			Name:      h.point.Name + suffix,
+			Type:      metricProtogen.MetricType_COUNTER,
+			Labels:    h.point.Labels,
+			Timestamp: h.point.Timestamp,
+			Value:     *value,
+		})
+	}
+	return points
+}
+
+// splitLabels returns the metric name and the other labels of a series
+func splitLabels(labels []*promProtogen.Label) (string, map[string]string) {
+	var name string
+	rest := make(map[string]string, len(labels))
+	for _, label := range labels {
+		if label.GetName() == "__name__" {
+			name = label.GetValue()
+			continue
+		}
+		rest[label.GetName()] = label.GetValue()
+	}
+	return name, rest
+}
+
+// histogramPart tells whether the series is the bucket, sum or count series of a histogram
+func histogramPart(name string, histograms map[string]bool) (string, string) {
+	for _, part := range []string{"bucket", "sum", "count"} {
+		if base, ok := strings.CutSuffix(name, "_"+part); ok && histograms[base] {
+			return base, part
+		}
+	}
+	return "", ""
+}
+
+func histogramKey(name string, labels map[string]string, timestamp int64) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	for _, key := range keys {
+		b.WriteString("\xff" + key + "\xff" + labels[key])
+	}
+	b.WriteString("\xff" + strconv.FormatInt(timestamp, 10))
+	return b.String()
+}
+
+// pointType returns the type of a series that is not part of a histogram. The _sum and _count
+// series of summaries are counters, their quantiles gauges.
+func pointType(types map[string]promProtogen.MetricMetadata_MetricType, name string) metricProtogen.MetricType {
+	switch types[name] {
+	case promProtogen.MetricMetadata_COUNTER:
+		return metricProtogen.MetricType_COUNTER
+	case promProtogen.MetricMetadata_UNKNOWN:
+		for _, suffix := range []string{"_sum", "_count"} {
+			if base, ok := strings.CutSuffix(name, suffix); ok && types[base] == promProtogen.MetricMetadata_SUMMARY {
+				return metricProtogen.MetricType_COUNTER
+			}
+		}
+		if strings.HasSuffix(name, "_total") {
+			return metricProtogen.MetricType_COUNTER
+		}
+	}
+	return metricProtogen.MetricType_GAUGE
+}
@@ -1,10 +1,12 @@
 package log_service
 
 import (
+	"context"
 	"gRPC-gateway/config"
 	protogen "gRPC-gateway/internal/services/genproto/logs"
 	"io"
 	"log"
+	"sync"
 
 	"github.com/IBM/sarama"
 	"google.golang.org/grpc"
@@ -13,13 +15,16 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// ackWindow bounds the number of unacknowledged logs per StreamLogsWithAck stream.
+const ackWindow = 1000
+
 type LogServiceServer struct {
 	protogen.UnimplementedLogServiceServer
-	producer        sarama.SyncProducer
+	producer        *config.AsyncProducer
 	protoSerializer *config.ProtobufSerializer
 }
 
-func NewLogServiceServer(kafka sarama.SyncProducer, protoSerializer *config.ProtobufSerializer) *LogServiceServer {
+func NewLogServiceServer(kafka *config.AsyncProducer, protoSerializer *config.ProtobufSerializer) *LogServiceServer {
 	return &LogServiceServer{
 		protogen.UnimplementedLogServiceServer{},
 		kafka,
@@ -42,39 +47,191 @@ func (s *LogServiceServer) ReceiveLogsStream(stream grpc.ClientStreamingServer[p
 			return status.Errorf(codes.Unknown, "failed to receive log: %v", err)
 		}
 
-		topic := logMessage.GetServiceName()
-		if topic == "" {
-			log.Println("Received log with empty serviceName, skipping")
-			continue
-		}
-		topic = "logs-" + topic
-		log.Print("Producing log message to Kafka: ", logMessage)
+		// Blocks while the producer queue is full. Failures are already logged by ProduceLog,
+		// keep reading the stream
+		_ = s.ProduceLog(stream.Context(), logMessage, nil)
+	}
+}
+
+// StreamLogsWithAck acknowledges every sequenced log once its Kafka produce succeeds or fails,
+// so clients can retry exactly the entries that were nacked. Acks are sent in delivery order,
+// which may differ from the order the logs were received in.
+func (s *LogServiceServer) StreamLogsWithAck(stream grpc.BidiStreamingServer[protogen.SequencedLog, protogen.LogAck]) error {
+	log.Println("New acknowledged client stream connected")
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
 
-		if logMessage.Timestamp == nil {
-			logMessage.Timestamp = timestamppb.Now()
+	// A window slot is held from receive until the ack is sent, so acks never block the
+	// producer callbacks and a slow client pushes back on its own stream only.
+	window := make(chan struct{}, ackWindow)
+	acks := make(chan *protogen.LogAck, ackWindow)
+	sendErr := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case ack, ok := <-acks:
+				if !ok {
+					sendErr <- nil
+					return
+				}
+				if err := stream.Send(ack); err != nil {
+					log.Printf("Failed to send ack for sequence %d: %v", ack.Sequence, err)
+					sendErr <- err
+					cancel()
+					return
+				}
+				<-window
+			case <-ctx.Done():
+				sendErr <- ctx.Err()
+				return
+			}
+		}
+	}()
+	finish := func(err error) error {
+		// Wait for outstanding deliveries before closing the ack channel
+		for i := 0; i < ackWindow; i++ {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return err
+			}
 		}
+		close(acks)
+		if sErr := <-sendErr; sErr != nil && err == nil {
+			err = sErr
+		}
+		return err
+	}
 
-		// Serialize protobuf message
-		kafkaValue, err := s.protoSerializer.Serialize("Logs-value", logMessage)
+	for {
+		sequenced, err := stream.Recv()
 		if err != nil {
-			log.Printf("Failed to serialize protobuf message for topic %s: %v", topic, err)
+			if err == io.EOF {
+				log.Println("Acknowledged client stream finished")
+				return finish(nil)
+			}
+			log.Printf("Failed to receive log: %v", err)
+			return finish(status.Errorf(codes.Unknown, "failed to receive log: %v", err))
+		}
+
+		select {
+		case window <- struct{}{}:
+		case <-ctx.Done():
+			return <-sendErr
+		}
+
+		sequence := sequenced.GetSequence()
+		if sequenced.GetLog() == nil {
+			acks <- &protogen.LogAck{Sequence: sequence, Error: "log is required"}
 			continue
 		}
+		err = s.ProduceLog(ctx, sequenced.GetLog(), func(err error) {
+			ack := &protogen.LogAck{Sequence: sequence, Ack: err == nil}
+			if err != nil {
+				ack.Error = status.Convert(err).Message()
+			}
+			acks <- ack
+		})
+		if err != nil {
+			acks <- &protogen.LogAck{Sequence: sequence, Error: status.Convert(err).Message()}
+		}
+	}
+}
+
+// SendLog produces a single log entry for clients that cannot hold a stream open.
+func (s *LogServiceServer) SendLog(ctx context.Context, logMessage *protogen.Log) (*protogen.Response, error) {
+	if err := s.ProduceLogBatch(ctx, []*protogen.Log{logMessage})[0]; err != nil {
+		return nil, err
+	}
+	return &protogen.Response{Ack: true}, nil
+}
+
+// SendLogBatch produces every entry of the batch and reports acceptance or rejection per entry.
+func (s *LogServiceServer) SendLogBatch(ctx context.Context, batch *protogen.LogBatch) (*protogen.BatchResponse, error) {
+	logs := batch.GetLogs()
+	if len(logs) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "batch contains no logs")
+	}
+
+	res := &protogen.BatchResponse{
+		Results: make([]*protogen.LogResult, 0, len(logs)),
+	}
+	for i, err := range s.ProduceLogBatch(ctx, logs) {
+		result := &protogen.LogResult{Index: int32(i), Accepted: true}
+		if err != nil {
+			result.Accepted = false
+			result.Error = status.Convert(err).Message()
+			res.Rejected++
+		} else {
+			res.Accepted++
+		}
+		res.Results = append(res.Results, result)
+	}
+	return res, nil
+}
 
-		// Create a Sarama producer message
-		msg := &sarama.ProducerMessage{
-			Topic: topic,
-			Value: sarama.ByteEncoder(kafkaValue),
+// ProduceLogBatch produces every log and waits for all deliveries. The returned slice holds
+// the error for each log by index, nil when it was delivered.
+func (s *LogServiceServer) ProduceLogBatch(ctx context.Context, logs []*protogen.Log) []error {
+	errs := make([]error, len(logs))
+	var wg sync.WaitGroup
+	for i, logMessage := range logs {
+		wg.Add(1)
+		err := s.ProduceLog(ctx, logMessage, func(err error) {
+			errs[i] = err
+			wg.Done()
+		})
+		if err != nil {
+			errs[i] = err
+			wg.Done()
 		}
+	}
+	wg.Wait()
+	return errs
+}
+
+// ProduceLog serializes a log entry and enqueues it for the project's Kafka topic. An error is
+// returned when the log is invalid or could not be enqueued, otherwise onDelivery (which may be
+// nil) is called with the delivery result and must not block.
+func (s *LogServiceServer) ProduceLog(ctx context.Context, logMessage *protogen.Log, onDelivery func(err error)) error {
+	topic := logMessage.GetServiceName()
+	if topic == "" {
+		log.Println("Received log with empty serviceName, skipping")
+		return status.Error(codes.InvalidArgument, "serviceName is required")
+	}
+	topic = "logs-" + topic
+
+	if logMessage.Timestamp == nil {
+		logMessage.Timestamp = timestamppb.Now()
+	}
+
+	// Serialize protobuf message
+	kafkaValue, err := s.protoSerializer.Serialize("Logs-value", logMessage)
+	if err != nil {
+		log.Printf("Failed to serialize protobuf message for topic %s: %v", topic, err)
+		return status.Errorf(codes.Internal, "failed to serialize log: %v", err)
+	}
+
+	// Create a Sarama producer message
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(kafkaValue),
+	}
 
-		// Send message synchronously
-		partition, offset, err := s.producer.SendMessage(msg)
+	// Enqueue the message, the producer batches and compresses per topic
+	err = s.producer.Produce(ctx, msg, func(partition int32, offset int64, err error) {
 		if err != nil {
 			log.Printf("Failed to produce message to Kafka for topic %s: %v", topic, err)
-			continue
+			err = status.Errorf(codes.Unavailable, "failed to produce log: %v", err)
 		}
-
-		log.Printf("Delivered message to topic %s [%d] at offset %v\n",
-			topic, partition, offset)
+		if onDelivery != nil {
+			onDelivery(err)
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to enqueue message for topic %s: %v", topic, err)
+		return status.Errorf(codes.Unavailable, "failed to enqueue log: %v", err)
 	}
+	return nil
 }
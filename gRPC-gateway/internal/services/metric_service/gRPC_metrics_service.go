@@ -1,10 +1,12 @@
 package metric_service
 
 import (
+	"context"
 	"gRPC-gateway/config"
 	metricProtogen "gRPC-gateway/internal/services/genproto/metrics"
 	"io"
 	"log"
+	"sync"
 
 	"github.com/IBM/sarama"
 	"google.golang.org/grpc"
@@ -14,11 +16,11 @@ import (
 
 type MetricsServiceServer struct {
 	metricProtogen.UnimplementedMetricsServiceServer
-	producer        sarama.SyncProducer
+	producer        *config.AsyncProducer
 	protoSerializer *config.ProtobufSerializer
 }
 
-func NewMetricsServiceServer(kafka sarama.SyncProducer, protoSerializer *config.ProtobufSerializer) *MetricsServiceServer {
+func NewMetricsServiceServer(kafka *config.AsyncProducer, protoSerializer *config.ProtobufSerializer) *MetricsServiceServer {
 	return &MetricsServiceServer{
 		metricProtogen.UnimplementedMetricsServiceServer{},
 		kafka,
@@ -42,32 +44,65 @@ func (s *MetricsServiceServer) ReceiveMetrics(stream grpc.ClientStreamingServer[
 			return status.Errorf(codes.Unknown, "failed to receive metrics: %v", err)
 		}
 
-		log.Printf("Received metrics: Service=%s, CPU usage=%v, memory usage=%v\n", metricsMessage.GetServiceName(), metricsMessage.GetCpuUsage(), metricsMessage.GetMemoryUsage())
-		topic := metricsMessage.GetServiceName()
-		if topic == "" {
-			log.Println("Received log with empty serviceName, skipping")
-			continue
-		}
-		topic = "metrics-" + topic
-		kafkaValue, err := s.protoSerializer.Serialize("Metrics-value", metricsMessage)
+		// Blocks while the producer queue is full. Failures are already logged by ProduceMetrics,
+		// keep reading the stream
+		_ = s.ProduceMetrics(stream.Context(), metricsMessage, nil)
+	}
+}
+
+// ProduceMetricsBatch produces every sample and waits for all deliveries. The returned slice
+// holds the error for each sample by index, nil when it was delivered.
+func (s *MetricsServiceServer) ProduceMetricsBatch(ctx context.Context, metrics []*metricProtogen.Metrics) []error {
+	errs := make([]error, len(metrics))
+	var wg sync.WaitGroup
+	for i, metricsMessage := range metrics {
+		wg.Add(1)
+		err := s.ProduceMetrics(ctx, metricsMessage, func(err error) {
+			errs[i] = err
+			wg.Done()
+		})
 		if err != nil {
-			log.Printf("Failed to serialize protobuf message for topic %s: %v", topic, err)
-			continue
+			errs[i] = err
+			wg.Done()
 		}
-		// Create a Sarama producer message
-		msg := &sarama.ProducerMessage{
-			Topic: topic,
-			Value: sarama.ByteEncoder(kafkaValue),
-		}
-		// Send a message synchronously
-		partition, offset, err := s.producer.SendMessage(msg)
+	}
+	wg.Wait()
+	return errs
+}
+
+// ProduceMetrics serializes a metrics sample and enqueues it for the project's Kafka topic. An
+// error is returned when the sample is invalid or could not be enqueued, otherwise onDelivery
+// (which may be nil) is called with the delivery result and must not block.
+func (s *MetricsServiceServer) ProduceMetrics(ctx context.Context, metricsMessage *metricProtogen.Metrics, onDelivery func(err error)) error {
+	topic := metricsMessage.GetServiceName()
+	if topic == "" {
+		log.Println("Received log with empty serviceName, skipping")
+		return status.Error(codes.InvalidArgument, "serviceName is required")
+	}
+	topic = "metrics-" + topic
+	kafkaValue, err := s.protoSerializer.Serialize("Metrics-value", metricsMessage)
+	if err != nil {
+		log.Printf("Failed to serialize protobuf message for topic %s: %v", topic, err)
+		return status.Errorf(codes.Internal, "failed to serialize metrics: %v", err)
+	}
+	// Create a Sarama producer message
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(kafkaValue),
+	}
+	// Enqueue the message, the producer batches and compresses per topic
+	err = s.producer.Produce(ctx, msg, func(partition int32, offset int64, err error) {
 		if err != nil {
 			log.Printf("Failed to produce message to Kafka for topic %s: %v", topic, err)
-			continue
+			err = status.Errorf(codes.Unavailable, "failed to produce metrics: %v", err)
 		}
-		log.Printf("Delivered message to topic %s [%d] at offset %v\n",
-			topic, partition, offset)
-
+		if onDelivery != nil {
+			onDelivery(err)
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to enqueue message for topic %s: %v", topic, err)
+		return status.Errorf(codes.Unavailable, "failed to enqueue metrics: %v", err)
 	}
-
+	return nil
 }
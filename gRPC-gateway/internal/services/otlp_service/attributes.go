@@ -0,0 +1,110 @@
+package otlp_service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// projectFromContext returns the project name sent in the servicename metadata.
+// The auth interceptor has already validated it against the key store.
+func projectFromContext(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "metadata is not provided")
+	}
+	values := md.Get("servicename")
+	if len(values) == 0 || values[0] == "" {
+		return "", status.Error(codes.Unauthenticated, "servicename header is not provided")
+	}
+	return values[0], nil
+}
+
+// attributes is a flattened view over OTLP key values where the first set takes precedence.
+type attributes []map[string]*commonpb.AnyValue
+
+func newAttributes(sets ...[]*commonpb.KeyValue) attributes {
+	attrs := make(attributes, 0, len(sets))
+	for _, set := range sets {
+		m := make(map[string]*commonpb.AnyValue, len(set))
+		for _, kv := range set {
+			m[kv.GetKey()] = kv.GetValue()
+		}
+		attrs = append(attrs, m)
+	}
+	return attrs
+}
+
+// get returns the first value found for any of the keys, so current and legacy
+// semantic convention names can be passed together.
+func (a attributes) get(keys ...string) string {
+	for _, m := range a {
+		for _, key := range keys {
+			if v, ok := m[key]; ok {
+				return anyValueString(v)
+			}
+		}
+	}
+	return ""
+}
+
+// strings flattens every attribute to a string. Earlier sets win, so record attributes
+// take precedence over resource attributes.
+func (a attributes) strings() map[string]string {
+	values := map[string]string{}
+	for i := len(a) - 1; i >= 0; i-- {
+		for key, v := range a[i] {
+			values[key] = anyValueString(v)
+		}
+	}
+	return values
+}
+
+// anyValueString renders an OTLP AnyValue as a plain string.
+func anyValueString(v *commonpb.AnyValue) string {
+	if v == nil {
+		return ""
+	}
+	switch val := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return val.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(val.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(val.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(val.DoubleValue, 'f', -1, 64)
+	case *commonpb.AnyValue_BytesValue:
+		return string(val.BytesValue)
+	default:
+		b, err := json.Marshal(anyValueInterface(v))
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+}
+
+func anyValueInterface(v *commonpb.AnyValue) interface{} {
+	switch val := v.GetValue().(type) {
+	case *commonpb.AnyValue_ArrayValue:
+		items := make([]interface{}, 0, len(val.ArrayValue.GetValues()))
+		for _, item := range val.ArrayValue.GetValues() {
+			items = append(items, anyValueInterface(item))
+		}
+		return items
+	case *commonpb.AnyValue_KvlistValue:
+		m := make(map[string]interface{}, len(val.KvlistValue.GetValues()))
+		for _, kv := range val.KvlistValue.GetValues() {
+			m[kv.GetKey()] = anyValueInterface(kv.GetValue())
+		}
+		return m
+	default:
+		return anyValueString(v)
+	}
+}
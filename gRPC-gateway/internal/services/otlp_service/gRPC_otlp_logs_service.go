@@ -0,0 +1,135 @@
+package otlp_service
+
+import (
+	"context"
+	"encoding/hex"
+	protogen "gRPC-gateway/internal/services/genproto/logs"
+	"gRPC-gateway/internal/services/log_service"
+	"log"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// LogsServiceServer receives OTLP logs and produces them to the project's logs topic.
+type LogsServiceServer struct {
+	collogspb.UnimplementedLogsServiceServer
+	logService *log_service.LogServiceServer
+}
+
+func NewLogsServiceServer(logService *log_service.LogServiceServer) *LogsServiceServer {
+	return &LogsServiceServer{
+		collogspb.UnimplementedLogsServiceServer{},
+		logService,
+	}
+}
+
+func (s *LogsServiceServer) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	project, err := projectFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []*protogen.Log
+	for _, resourceLogs := range req.GetResourceLogs() {
+		resourceAttrs := resourceLogs.GetResource().GetAttributes()
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			for _, record := range scopeLogs.GetLogRecords() {
+				logs = append(logs, toLog(project, record, newAttributes(record.GetAttributes(), resourceAttrs)))
+			}
+		}
+	}
+
+	var rejected int64
+	var lastErr string
+	for _, err := range s.logService.ProduceLogBatch(ctx, logs) {
+		if err != nil {
+			rejected++
+			lastErr = status.Convert(err).Message()
+		}
+	}
+
+	res := &collogspb.ExportLogsServiceResponse{}
+	if rejected > 0 {
+		log.Printf("Rejected %d OTLP log records for project %s", rejected, project)
+		res.PartialSuccess = &collogspb.ExportLogsPartialSuccess{
+			RejectedLogRecords: rejected,
+			ErrorMessage:       lastErr,
+		}
+	}
+	return res, nil
+}
+
+// toLog maps an OTLP log record and its resource onto the LogBoy Log message.
+func toLog(project string, record *logspb.LogRecord, attrs attributes) *protogen.Log {
+	logMessage := &protogen.Log{
+		ServiceName: project,
+		BuildDetails: &protogen.BuildDetails{
+			NodeVersion: attrs.get("process.runtime.version"),
+			AppVersion:  attrs.get("service.version"),
+		},
+		Level:          severityToLevel(record.GetSeverityNumber(), record.GetSeverityText()),
+		Message:        anyValueString(record.GetBody()),
+		Stack:          attrs.get("exception.stacktrace"),
+		RequestId:      attrs.get("http.request.id", "request.id"),
+		RequestUrl:     attrs.get("url.full", "http.url", "url.path", "http.target"),
+		RequestMethod:  attrs.get("http.request.method", "http.method"),
+		UserAgent:      attrs.get("user_agent.original", "http.user_agent"),
+		RemoteIp:       attrs.get("client.address", "net.peer.ip", "http.client_ip"),
+		ResponseStatus: attrs.get("http.response.status_code", "http.status_code"),
+		ResponseTime:   attrs.get("http.server.duration", "http.response_time"),
+		Attributes:     attrs.strings(),
+	}
+	if len(record.GetTraceId()) > 0 {
+		logMessage.TraceId = hex.EncodeToString(record.GetTraceId())
+	}
+	if len(record.GetSpanId()) > 0 {
+		logMessage.SpanId = hex.EncodeToString(record.GetSpanId())
+	}
+	if logMessage.RequestId == "" {
+		logMessage.RequestId = logMessage.TraceId
+	}
+	if logMessage.Message == "" {
+		logMessage.Message = attrs.get("exception.message")
+	}
+
+	if ts := record.GetTimeUnixNano(); ts > 0 {
+		logMessage.Timestamp = timestamppb.New(time.Unix(0, int64(ts)))
+	} else if ts := record.GetObservedTimeUnixNano(); ts > 0 {
+		logMessage.Timestamp = timestamppb.New(time.Unix(0, int64(ts)))
+	}
+	return logMessage
+}
+
+// severityToLevel maps OTLP severity onto the winston levels used by LogBoy.
+func severityToLevel(number logspb.SeverityNumber, text string) string {
+	switch {
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return "error"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return "warn"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return "info"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG:
+		return "debug"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_TRACE:
+		return "silly"
+	}
+
+	switch level := strings.ToLower(text); level {
+	case "fatal", "critical", "error":
+		return "error"
+	case "warning", "warn":
+		return "warn"
+	case "trace":
+		return "silly"
+	case "debug", "http", "verbose", "silly":
+		return level
+	default:
+		return "info"
+	}
+}
@@ -0,0 +1,372 @@
+package otlp_service
+
+import (
+	"context"
+	"fmt"
+	metricProtogen "gRPC-gateway/internal/services/genproto/metrics"
+	"gRPC-gateway/internal/services/metric_service"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsServiceServer receives OTLP metrics and produces the CPU and memory
+// semantic convention metrics, and every other metric as generic points, to the
+// project's metrics topic.
+type MetricsServiceServer struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	metricService *metric_service.MetricsServiceServer
+}
+
+func NewMetricsServiceServer(metricService *metric_service.MetricsServiceServer) *MetricsServiceServer {
+	return &MetricsServiceServer{
+		colmetricspb.UnimplementedMetricsServiceServer{},
+		metricService,
+	}
+}
+
+func (s *MetricsServiceServer) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	project, err := projectFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []*metricProtogen.Metrics
+	var points []int64
+	var rejected int64
+	var lastErr string
+	for _, resourceMetrics := range req.GetResourceMetrics() {
+		converted := toMetrics(project, resourceMetrics)
+		metrics = append(metrics, converted.messages...)
+		points = append(points, converted.points...)
+		if converted.rejected > 0 {
+			rejected += converted.rejected
+			lastErr = converted.reason
+		}
+	}
+
+	for i, err := range s.metricService.ProduceMetricsBatch(ctx, metrics) {
+		if err != nil {
+			rejected += points[i]
+			lastErr = status.Convert(err).Message()
+		}
+	}
+
+	res := &colmetricspb.ExportMetricsServiceResponse{}
+	if rejected > 0 {
+		log.Printf("Rejected %d OTLP data points for project %s", rejected, project)
+		res.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{
+			RejectedDataPoints: rejected,
+			ErrorMessage:       lastErr,
+		}
+	}
+	return res, nil
+}
+
+// maxPointsPerMessage bounds the generic points of one Metrics message
+const maxPointsPerMessage = 1000
+
+var (
+	// metricName and labelName are the names the metrics consumer stores, other characters of
+	// OTLP names are replaced by invalidNameChar
+	metricName      = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:.]{0,199}$`)
+	labelName       = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]{0,99}$`)
+	invalidNameChar = regexp.MustCompile(`[^a-zA-Z0-9_:.]`)
+)
+
+// convertedMetrics holds the Metrics messages of one resource with the number of data points
+// of each, and the data points that cannot be stored
+type convertedMetrics struct {
+	messages []*metricProtogen.Metrics
+	points   []int64
+	rejected int64
+	reason   string // why the last data point was rejected
+}
+
+func (c *convertedMetrics) reject(points int, reason string) {
+	if points > 0 {
+		c.rejected += int64(points)
+		c.reason = reason
+	}
+}
+
+// toMetrics folds the CPU and memory metrics of one resource into a single Metrics message,
+// the other metrics are converted to generic counter, gauge and histogram points.
+func toMetrics(project string, resourceMetrics *metricspb.ResourceMetrics) *convertedMetrics {
+	converted := &convertedMetrics{}
+	var genericPoints []*metricProtogen.MetricPoint
+
+	cores := make(map[int32]float64)
+	memoryByState := make(map[string]int64)
+	var cpuAverage, memoryUtilization float64
+	var hasCpu, hasMemory, hasUtilization bool
+	var cpuTimestamp, memoryTimestamp int64
+	var points int64
+
+	for _, scopeMetrics := range resourceMetrics.GetScopeMetrics() {
+		for _, metric := range scopeMetrics.GetMetrics() {
+			dataPoints := numberDataPoints(metric)
+			switch metric.GetName() {
+			case "system.cpu.utilization", "process.cpu.utilization":
+				for _, dp := range dataPoints {
+					attrs := newAttributes(dp.GetAttributes())
+					if attrs.get("state", "cpu.mode") == "idle" {
+						continue
+					}
+					hasCpu = true
+					points++
+					cpuTimestamp = max(cpuTimestamp, int64(dp.GetTimeUnixNano()/1e6))
+					if core, ok := parseCore(attrs.get("cpu", "cpu.logical_number")); ok {
+						cores[core] += numberValue(dp) * 100
+					} else {
+						cpuAverage += numberValue(dp) * 100
+					}
+				}
+			case "system.memory.usage", "process.memory.usage":
+				for _, dp := range dataPoints {
+					hasMemory = true
+					points++
+					memoryTimestamp = max(memoryTimestamp, int64(dp.GetTimeUnixNano()/1e6))
+					state := newAttributes(dp.GetAttributes()).get("state", "system.memory.state")
+					if state == "" {
+						state = "used"
+					}
+					memoryByState[state] += int64(numberValue(dp))
+				}
+			case "system.memory.utilization":
+				for _, dp := range dataPoints {
+					state := newAttributes(dp.GetAttributes()).get("state", "system.memory.state")
+					if state != "" && state != "used" {
+						continue
+					}
+					hasUtilization = true
+					points++
+					memoryTimestamp = max(memoryTimestamp, int64(dp.GetTimeUnixNano()/1e6))
+					memoryUtilization = numberValue(dp) * 100
+				}
+			default:
+				genericPoints = append(genericPoints, toMetricPoints(metric, converted)...)
+			}
+		}
+	}
+
+	for start := 0; start < len(genericPoints); start += maxPointsPerMessage {
+		end := min(start+maxPointsPerMessage, len(genericPoints))
+		converted.messages = append(converted.messages, &metricProtogen.Metrics{ServiceName: project, Points: genericPoints[start:end]})
+		converted.points = append(converted.points, int64(end-start))
+	}
+	if !hasCpu && !hasMemory && !hasUtilization {
+		return converted
+	}
+
+	metricsMessage := &metricProtogen.Metrics{ServiceName: project}
+	if hasCpu {
+		cpuUsage := &metricProtogen.CpuUsage{Timestamp: cpuTimestamp, Average: cpuAverage}
+		if len(cores) > 0 {
+			var total float64
+			for core, usage := range cores {
+				cpuUsage.Cores = append(cpuUsage.Cores, &metricProtogen.CoreUsage{Core: core, Usage: usage})
+				total += usage
+			}
+			sort.Slice(cpuUsage.Cores, func(i, j int) bool { return cpuUsage.Cores[i].Core < cpuUsage.Cores[j].Core })
+			cpuUsage.Average = total / float64(len(cores))
+		}
+		metricsMessage.CpuUsage = cpuUsage
+	}
+	if hasMemory || hasUtilization {
+		memoryUsage := &metricProtogen.MemoryUsage{
+			Timestamp:  memoryTimestamp,
+			UsedMemory: memoryByState["used"],
+			FreeMemory: memoryByState["free"],
+		}
+		for _, bytes := range memoryByState {
+			memoryUsage.TotalMemory += bytes
+		}
+		memoryUsage.MemoryUsagePercentage = memoryUtilization
+		if !hasUtilization && memoryByState["free"] > 0 {
+			memoryUsage.MemoryUsagePercentage = float64(memoryUsage.UsedMemory) / float64(memoryUsage.TotalMemory) * 100
+		}
+		metricsMessage.MemoryUsage = memoryUsage
+	}
+	converted.messages = append(converted.messages, metricsMessage)
+	converted.points = append(converted.points, points)
+	return converted
+}
+
+// toMetricPoints converts the data points of a metric to generic points. Monotonic sums are
+// counters, other sums and gauges are gauges. Delta sums and histograms, exponential histograms
+// and summaries cannot be stored and are rejected, like data points without a finite value.
+func toMetricPoints(metric *metricspb.Metric, converted *convertedMetrics) []*metricProtogen.MetricPoint {
+	name := invalidNameChar.ReplaceAllString(metric.GetName(), "_")
+	metricType := metricProtogen.MetricType_GAUGE
+	var count int
+	switch data := metric.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		count = len(data.Gauge.GetDataPoints())
+	case *metricspb.Metric_Sum:
+		count = len(data.Sum.GetDataPoints())
+		if data.Sum.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+			converted.reject(count, fmt.Sprintf("metric %s: delta sums are not supported, export cumulative temporality", metric.GetName()))
+			return nil
+		}
+		if data.Sum.GetIsMonotonic() {
+			metricType = metricProtogen.MetricType_COUNTER
+		}
+	case *metricspb.Metric_Histogram:
+		count = len(data.Histogram.GetDataPoints())
+		if data.Histogram.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+			converted.reject(count, fmt.Sprintf("metric %s: delta histograms are not supported, export cumulative temporality", metric.GetName()))
+			return nil
+		}
+		return toHistogramPoints(name, metric, data.Histogram.GetDataPoints(), converted)
+	case *metricspb.Metric_ExponentialHistogram:
+		converted.reject(len(data.ExponentialHistogram.GetDataPoints()), fmt.Sprintf("metric %s: exponential histograms are not supported", metric.GetName()))
+		return nil
+	case *metricspb.Metric_Summary:
+		converted.reject(len(data.Summary.GetDataPoints()), fmt.Sprintf("metric %s: summaries are not supported", metric.GetName()))
+		return nil
+	}
+	if !metricName.MatchString(name) {
+		converted.reject(count, fmt.Sprintf("invalid metric name %q", metric.GetName()))
+		return nil
+	}
+
+	var points []*metricProtogen.MetricPoint
+	for _, dp := range numberDataPoints(metric) {
+		if dp.GetFlags()&uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0 {
+			continue
+		}
+		value := numberValue(dp)
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			converted.reject(1, fmt.Sprintf("metric %s has no finite value", metric.GetName()))
+			continue
+		}
+		labels, err := pointLabels(dp.GetAttributes())
+		if err != nil {
+			converted.reject(1, fmt.Sprintf("metric %s: %v", metric.GetName(), err))
+			continue
+		}
+		points = append(points, &metricProtogen.MetricPoint{
+			Name:      name,
+			Type:      metricType,
+			Labels:    labels,
+			Timestamp: int64(dp.GetTimeUnixNano() / 1e6),
+			Value:     value,
+		})
+	}
+	return points
+}
+
+// toHistogramPoints converts explicit bucket histograms, whose bucket counts are per bucket, to
+// histogram points with cumulative bucket counts
+func toHistogramPoints(name string, metric *metricspb.Metric, dataPoints []*metricspb.HistogramDataPoint, converted *convertedMetrics) []*metricProtogen.MetricPoint {
+	if !metricName.MatchString(name) {
+		converted.reject(len(dataPoints), fmt.Sprintf("invalid metric name %q", metric.GetName()))
+		return nil
+	}
+
+	var points []*metricProtogen.MetricPoint
+	for _, dp := range dataPoints {
+		if dp.GetFlags()&uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0 {
+			continue
+		}
+		buckets, err := cumulativeBuckets(dp.GetExplicitBounds(), dp.GetBucketCounts())
+		if err == nil && (math.IsNaN(dp.GetSum()) || math.IsInf(dp.GetSum(), 0)) {
+			err = fmt.Errorf("sum is not finite")
+		}
+		var labels map[string]string
+		if err == nil {
+			labels, err = pointLabels(dp.GetAttributes())
+		}
+		if err != nil {
+			converted.reject(1, fmt.Sprintf("metric %s: %v", metric.GetName(), err))
+			continue
+		}
+		points = append(points, &metricProtogen.MetricPoint{
+			Name:      name,
+			Type:      metricProtogen.MetricType_HISTOGRAM,
+			Labels:    labels,
+			Timestamp: int64(dp.GetTimeUnixNano() / 1e6),
+			Buckets:   buckets,
+			Sum:       dp.GetSum(),
+			Count:     dp.GetCount(),
+		})
+	}
+	return points
+}
+
+// cumulativeBuckets pairs the bounds with the running bucket counts, the last bucket has no
+// bound and counts every observation
+func cumulativeBuckets(bounds []float64, counts []uint64) ([]*metricProtogen.HistogramBucket, error) {
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("histogram has no buckets")
+	}
+	if len(bounds) != len(counts)-1 {
+		return nil, fmt.Errorf("histogram has %d bucket counts for %d bounds", len(counts), len(bounds))
+	}
+	buckets := make([]*metricProtogen.HistogramBucket, 0, len(counts))
+	var total uint64
+	for i, count := range counts {
+		total += count
+		upperBound := math.Inf(1)
+		if i < len(bounds) {
+			upperBound = bounds[i]
+			if math.IsNaN(upperBound) || math.IsInf(upperBound, 0) || (i > 0 && upperBound <= bounds[i-1]) {
+				return nil, fmt.Errorf("histogram bounds must be finite and increasing")
+			}
+		}
+		buckets = append(buckets, &metricProtogen.HistogramBucket{UpperBound: upperBound, Count: total})
+	}
+	return buckets, nil
+}
+
+// pointLabels turns the data point attributes into labels, with the characters the consumer
+// does not accept in label names replaced
+func pointLabels(attrs []*commonpb.KeyValue) (map[string]string, error) {
+	labels := make(map[string]string, len(attrs))
+	for key, value := range newAttributes(attrs).strings() {
+		label := strings.ReplaceAll(invalidNameChar.ReplaceAllString(key, "_"), ":", "_")
+		if !labelName.MatchString(label) {
+			return nil, fmt.Errorf("invalid attribute name %q", key)
+		}
+		labels[label] = value
+	}
+	return labels, nil
+}
+
+func numberDataPoints(metric *metricspb.Metric) []*metricspb.NumberDataPoint {
+	if gauge := metric.GetGauge(); gauge != nil {
+		return gauge.GetDataPoints()
+	}
+	if sum := metric.GetSum(); sum != nil {
+		return sum.GetDataPoints()
+	}
+	return nil
+}
+
+func numberValue(dp *metricspb.NumberDataPoint) float64 {
+	if v, ok := dp.GetValue().(*metricspb.NumberDataPoint_AsInt); ok {
+		return float64(v.AsInt)
+	}
+	return dp.GetAsDouble()
+}
+
+// parseCore accepts both "cpu3" and "3" style core identifiers.
+func parseCore(value string) (int32, bool) {
+	if value == "" {
+		return 0, false
+	}
+	core, err := strconv.ParseInt(strings.TrimPrefix(value, "cpu"), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int32(core), true
+}
@@ -13,6 +13,35 @@ import type {
 } from "./types.js";
 import { getProtobufPaths } from "./helper.js";
 
+// Fields of LogInfo sent as dedicated Log fields, any other meta is sent as attributes
+const logFields = new Set([
+  "serviceName",
+  "buildDetails",
+  "level",
+  "message",
+  "stack",
+  "timestamp",
+  "requestId",
+  "requestUrl",
+  "requestMethod",
+  "remoteIp",
+  "userAgent",
+  "responseStatus",
+  "responseTime",
+  "traceId",
+  "spanId",
+  "parentSpanId",
+]);
+
+function toAttributes(info: LogInfo): Record<string, string> {
+  const attributes: Record<string, string> = {};
+  for (const [key, value] of Object.entries(info)) {
+    if (logFields.has(key) || value === undefined || value === null) continue;
+    attributes[key] = typeof value === "string" ? value : JSON.stringify(value);
+  }
+  return attributes;
+}
+
 class GrpcTransport extends Transport {
   private serverAddress: string;
   private metadata: grpc.Metadata;
@@ -98,6 +127,10 @@ class GrpcTransport extends Transport {
       userAgent: info.userAgent || "",
       responseStatus: info.responseStatus || 0,
       responseTime: info.responseTime || 0,
+      attributes: toAttributes(info),
+      traceId: info.traceId || "",
+      spanId: info.spanId || "",
+      parentSpanId: info.parentSpanId || "",
     };
 
     if (this.stream) {
@@ -27,6 +27,10 @@ export interface LogInfo {
   userAgent?: string;
   responseStatus?: number;
   responseTime?: number;
+  traceId?: string;
+  spanId?: string;
+  parentSpanId?: string;
+  [meta: string]: unknown;
 }
 
 export interface LogMessage {
@@ -49,6 +53,10 @@ export interface LogMessage {
   userAgent: string;
   responseStatus: number;
   responseTime: number;
+  attributes: Record<string, string>;
+  traceId: string;
+  spanId: string;
+  parentSpanId: string;
 }
 
 export interface LogService {
@@ -11,6 +11,7 @@ import (
 	"server/internal/log_consumer"
 	"server/internal/metrics_consumer"
 	"server/internal/redis_pubsub"
+	indexstats "server/internal/services/index_stats"
 	serversentevents "server/internal/services/server_sent_events"
 	"strings"
 	"sync"
@@ -38,72 +39,100 @@ func main() {
 		log.Fatalf("Failed to load env variables: %v", err)
 	}
 
+	// Setup failures are reported through errChan like service errors, so they shut down the
+	// same way
 	errChan := make(chan error, 2)
 	ktm, err := config.NewKafkaTopicManager(brokers)
-	defer func(ktm *config.KafkaTopicManager) {
-		err := ktm.Close()
-		if err != nil {
-			errChan <- fmt.Errorf("failed to close kafka topic manager : %v", err)
-		}
-	}(ktm)
 	if err != nil {
 		errChan <- fmt.Errorf("failed to Start kafka topic manager : %v", err)
+	} else {
+		defer func(ktm *config.KafkaTopicManager) {
+			err := ktm.Close()
+			if err != nil {
+				log.Printf("failed to close kafka topic manager : %v", err)
+			}
+		}(ktm)
+	}
+	dlq, err := config.NewDeadLetterQueue(brokers)
+	if err != nil {
+		errChan <- fmt.Errorf("failed to create dead letter queue: %w", err)
+	} else {
+		defer func(dlq *config.DeadLetterQueue) {
+			err := dlq.Close()
+			if err != nil {
+				log.Printf("failed to close dead letter queue : %v", err)
+			}
+		}(dlq)
+	}
+	// The services need the topic manager and the dead letter queue
+	if len(errChan) == 0 {
+		sse := serversentevents.NewSSEService()
+		stats := indexstats.NewIndexStats()
+		// Consumed logs and metrics go through Redis, so clients of every instance receive them
+		liveFanOut := redis_pubsub.NewLiveFanOut(redisClient, sse.LogSSE, sse.MetricSSE)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Println("REST server starting...")
+			if err := rest.StartRestServer(ctx, cfg, elasticSearch, ktm, dlq, sse, stats); err != nil {
+				errChan <- fmt.Errorf("REST server error: %w", err)
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Println("Kafka consumer starting...")
+
+			processor := log_consumer.NewDefaultLogProcessor(elasticSearch, liveFanOut, stats)
+			consumerGroupID := "log-consumer-group"
+			consumerService, err := log_consumer.NewKafkaConsumerService(&cfg, processor, consumerGroupID, dlq)
+			if err != nil {
+				errChan <- fmt.Errorf("failed to create consumer service: %w", err)
+				return
+			}
+
+			if err := consumerService.Start(ctx, "logs-", ktm, time.Minute*2); err != nil {
+				errChan <- fmt.Errorf("kafka logs consumer error: %w", err)
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			processor := metrics_consumer.NewDefaultMetricsProcessor(elasticSearch, liveFanOut, stats)
+			consumerGroupId := "metrics-consumer-group"
+			consumerService, err := metrics_consumer.NewKafkaConsumerService(&cfg, processor, consumerGroupId, dlq)
+			if err != nil {
+				errChan <- fmt.Errorf("failed to create consumer service: %w", err)
+				return
+			}
+
+			if err := consumerService.Start(ctx, "metrics-", ktm, time.Minute*2); err != nil {
+				errChan <- fmt.Errorf("kafka metrics consumer error: %w", err)
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			alertMonitor := redis_pubsub.NewAlertMonitor(redisClient, elasticSearch, sse.AlertSSE)
+			err := alertMonitor.StartMonitoring(ctx)
+			if err != nil {
+				errChan <- fmt.Errorf("failed monitor alert from redis: %w", err)
+				return
+			}
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := liveFanOut.Start(ctx); err != nil {
+				errChan <- fmt.Errorf("live fan-out error: %w", err)
+			}
+		}()
 	}
-	sse := serversentevents.NewSSEService()
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		log.Println("REST server starting...")
-		if err := rest.StartRestServer(ctx, cfg, elasticSearch, ktm, sse); err != nil {
-			errChan <- fmt.Errorf("REST server error: %w", err)
-		}
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		log.Println("Kafka consumer starting...")
-
-		processor := log_consumer.NewDefaultLogProcessor(elasticSearch, sse.LogSSE)
-		consumerGroupID := "log-consumer-group"
-		consumerService, err := log_consumer.NewKafkaConsumerService(&cfg, processor, consumerGroupID)
-		if err != nil {
-			errChan <- fmt.Errorf("failed to create consumer service: %w", err)
-			return
-		}
-
-		if err := consumerService.Start(ctx, "logs-", ktm, time.Minute*2); err != nil {
-			errChan <- fmt.Errorf("kafka logs consumer error: %w", err)
-		}
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-
-		processor := metrics_consumer.NewDefaultMetricsProcessor(elasticSearch, sse.MetricSSE)
-		consumerGroupId := "metrics-consumer-group"
-		consumerService, err := metrics_consumer.NewKafkaConsumerService(&cfg, processor, consumerGroupId)
-		if err != nil {
-			errChan <- fmt.Errorf("failed to create consumer service: %w", err)
-			return
-		}
-
-		if err := consumerService.Start(ctx, "metrics-", ktm, time.Minute*2); err != nil {
-			errChan <- fmt.Errorf("kafka metrics consumer error: %w", err)
-		}
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		alertMonitor := redis_pubsub.NewAlertMonitor(redisClient, elasticSearch, sse.AlertSSE)
-		err := alertMonitor.StartMonitoring(ctx)
-		if err != nil {
-			errChan <- fmt.Errorf("failed monitor alert from redis: %w", err)
-			return
-		}
-	}()
 
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, os.Interrupt, syscall.SIGTERM)
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Headers attached to every dead-lettered record
+const (
+	DLQHeaderError           = "dlq.error"
+	DLQHeaderSourceTopic     = "dlq.source.topic"
+	DLQHeaderSourcePartition = "dlq.source.partition"
+	DLQHeaderSourceOffset    = "dlq.source.offset"
+	DLQHeaderFailedAt        = "dlq.failed.at"
+)
+
+// DeadLetterQueue parks messages the consumers could not deserialize or process in
+// dlq-<source topic>, e.g. dlq-logs-<project>, and reads them back for inspection and replay.
+type DeadLetterQueue struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+}
+
+func NewDeadLetterQueue(brokers []string) (*DeadLetterQueue, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.MaxVersion
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+	config.Producer.Return.Successes = true
+
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dead letter client: %w", err)
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to create dead letter producer: %w", err)
+	}
+
+	return &DeadLetterQueue{
+		client:   client,
+		producer: producer,
+	}, nil
+}
+
+// DLQTopic returns the dead letter topic of a source topic
+func DLQTopic(sourceTopic string) string {
+	return "dlq-" + sourceTopic
+}
+
+// Send copies the original key, value and headers to the dead letter topic and records why
+// and where the message failed.
+func (d *DeadLetterQueue) Send(message *sarama.ConsumerMessage, cause error) error {
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers)+5)
+	for _, h := range message.Headers {
+		if h != nil {
+			headers = append(headers, *h)
+		}
+	}
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte(DLQHeaderError), Value: []byte(cause.Error())},
+		sarama.RecordHeader{Key: []byte(DLQHeaderSourceTopic), Value: []byte(message.Topic)},
+		sarama.RecordHeader{Key: []byte(DLQHeaderSourcePartition), Value: []byte(strconv.Itoa(int(message.Partition)))},
+		sarama.RecordHeader{Key: []byte(DLQHeaderSourceOffset), Value: []byte(strconv.FormatInt(message.Offset, 10))},
+		sarama.RecordHeader{Key: []byte(DLQHeaderFailedAt), Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+
+	msg := &sarama.ProducerMessage{
+		Topic:   DLQTopic(message.Topic),
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	}
+	if message.Key != nil {
+		msg.Key = sarama.ByteEncoder(message.Key)
+	}
+
+	partition, offset, err := d.producer.SendMessage(msg)
+	if err != nil {
+		return fmt.Errorf("failed to dead letter message %s[%d]@%d: %w", message.Topic, message.Partition, message.Offset, err)
+	}
+	log.Printf("Dead lettered message %s[%d]@%d to %s[%d]@%d: %v",
+		message.Topic, message.Partition, message.Offset, msg.Topic, partition, offset, cause)
+	return nil
+}
+
+// Produce writes a message, used to replay dead lettered records to their source topic
+func (d *DeadLetterQueue) Produce(msg *sarama.ProducerMessage) (int32, int64, error) {
+	return d.producer.SendMessage(msg)
+}
+
+// Client exposes the underlying client for reading dead letter topics
+func (d *DeadLetterQueue) Client() sarama.Client {
+	return d.client
+}
+
+func (d *DeadLetterQueue) Close() error {
+	if err := d.producer.Close(); err != nil {
+		return err
+	}
+	return d.client.Close()
+}
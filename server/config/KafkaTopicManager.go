@@ -29,40 +29,43 @@ func NewKafkaTopicManager(brokers []string) (*KafkaTopicManager, error) {
 	}, nil
 }
 
+// CreateProjectTopic creates the logs and metrics topics of a project and their dead letter topics
 func (ktm *KafkaTopicManager) CreateProjectTopic(projectName string) error {
-	topicName := fmt.Sprintf("logs-%s", projectName)
-
-	// Check if a topic already exists
-	exists, err := ktm.topicExists(topicName)
-	if err != nil {
-		return fmt.Errorf("failed to check if topic exists: %w", err)
-	}
-
-	if exists {
-		log.Printf("Topic %s already exists", topicName)
-		return nil
-	}
-
-	// Create a topic
-	topicDetail := &sarama.TopicDetail{
-		NumPartitions:     2,
-		ReplicationFactor: 1,
-		ConfigEntries: map[string]*string{
-			"cleanup.policy": stringPtr("delete"),
-			"retention.ms":   stringPtr("604800000"), // 7 days
-			"segment.ms":     stringPtr("86400000"),  // 1 day
-		},
+	for _, topicName := range []string{
+		fmt.Sprintf("logs-%s", projectName),
+		fmt.Sprintf("metrics-%s", projectName),
+	} {
+		if err := ktm.createTopic(topicName, "604800000"); err != nil { // 7 days
+			return err
+		}
+		// Dead letters are kept longer so they can be inspected and replayed
+		if err := ktm.createTopic(DLQTopic(topicName), "2592000000"); err != nil { // 30 days
+			return err
+		}
 	}
+	return nil
+}
 
-	err = ktm.admin.CreateTopic(topicName, topicDetail, false)
-	if err != nil {
-		return fmt.Errorf("failed to create topic %s: %w", topicName, err)
+// DeleteProjectTopics deletes the topics CreateProjectTopic creates, topics that do not exist
+// are skipped
+func (ktm *KafkaTopicManager) DeleteProjectTopics(projectName string) error {
+	for _, topicName := range []string{
+		fmt.Sprintf("logs-%s", projectName),
+		fmt.Sprintf("metrics-%s", projectName),
+	} {
+		for _, name := range []string{topicName, DLQTopic(topicName)} {
+			err := ktm.admin.DeleteTopic(name)
+			if err != nil && !errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+				return fmt.Errorf("failed to delete topic %s: %w", name, err)
+			}
+		}
 	}
-	// create topics for metrics
-	topicName = fmt.Sprintf("metrics-%s", projectName)
+	return nil
+}
 
+func (ktm *KafkaTopicManager) createTopic(topicName string, retentionMs string) error {
 	// Check if a topic already exists
-	exists, err = ktm.topicExists(topicName)
+	exists, err := ktm.topicExists(topicName)
 	if err != nil {
 		return fmt.Errorf("failed to check if topic exists: %w", err)
 	}
@@ -73,13 +76,13 @@ func (ktm *KafkaTopicManager) CreateProjectTopic(projectName string) error {
 	}
 
 	// Create a topic
-	topicDetail = &sarama.TopicDetail{
+	topicDetail := &sarama.TopicDetail{
 		NumPartitions:     2,
 		ReplicationFactor: 1,
 		ConfigEntries: map[string]*string{
 			"cleanup.policy": stringPtr("delete"),
-			"retention.ms":   stringPtr("604800000"), // 7 days
-			"segment.ms":     stringPtr("86400000"),  // 1 day
+			"retention.ms":   stringPtr(retentionMs),
+			"segment.ms":     stringPtr("86400000"), // 1 day
 		},
 	}
 
@@ -0,0 +1,294 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v9"
+)
+
+// LogsComponentTemplate holds the settings and mappings shared by every logs index,
+// see scripts/es_logs_component_template.json
+const LogsComponentTemplate = "logs_mappings"
+
+const (
+	logsRolloverMaxAge       = "1d"
+	logsRolloverMaxShardSize = "50gb"
+	logsWarmMaxAgeDays       = 7
+)
+
+// LogIndexManager keeps the logs of each project in rollover indices logs-<project>-000001,
+// logs-<project>-000002, ... behind the write alias logs-<project>, managed by an ILM policy
+// derived from the project's retention period.
+type LogIndexManager struct {
+	es *elasticsearch.Client
+}
+
+func NewLogIndexManager(es *elasticsearch.Client) *LogIndexManager {
+	return &LogIndexManager{es: es}
+}
+
+// LogsAlias returns the alias the logs of a project are written to and searched through
+func LogsAlias(projectName string) string {
+	return fmt.Sprintf("logs-%s", projectName)
+}
+
+func logsPolicy(projectName string) string {
+	return fmt.Sprintf("logs-%s-policy", projectName)
+}
+
+// ParseRetentionPeriod converts a retention period like "3 MONTHS", "14 days" or "1 year" into days.
+// A bare number is read as months, the unit the client offers.
+func ParseRetentionPeriod(retentionPeriod string) (int, error) {
+	parts := strings.Fields(strings.ToLower(retentionPeriod))
+	if len(parts) == 0 || len(parts) > 2 {
+		return 0, fmt.Errorf("invalid retention period %q", retentionPeriod)
+	}
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid retention period %q: must start with a positive number", retentionPeriod)
+	}
+	unit := "months"
+	if len(parts) == 2 {
+		unit = parts[1]
+	}
+	switch strings.TrimSuffix(unit, "s") {
+	case "day":
+		return n, nil
+	case "week":
+		return n * 7, nil
+	case "month":
+		return n * 30, nil
+	case "year":
+		return n * 365, nil
+	}
+	return 0, fmt.Errorf("invalid retention period %q: unit must be days, weeks, months or years", retentionPeriod)
+}
+
+// EnsureProjectIndex creates or updates the ILM policy and index template of a project and
+// bootstraps the first rollover index behind its alias. It is safe to call repeatedly, which is
+// how a changed retention period is applied.
+func (m *LogIndexManager) EnsureProjectIndex(projectName string, retentionPeriod string) error {
+	retentionDays, err := ParseRetentionPeriod(retentionPeriod)
+	if err != nil {
+		return err
+	}
+	if err := m.putPolicy(projectName, retentionDays); err != nil {
+		return err
+	}
+	if err := m.putIndexTemplate(projectName); err != nil {
+		return err
+	}
+	return m.bootstrapIndex(projectName)
+}
+
+// putPolicy rolls the write index over daily, merges it down once it is no longer written to
+// and deletes it when the retention period has passed.
+func (m *LogIndexManager) putPolicy(projectName string, retentionDays int) error {
+	warmAfterDays := min(max(retentionDays/2, 1), logsWarmMaxAgeDays)
+
+	phases := map[string]interface{}{
+		"hot": map[string]interface{}{
+			"min_age": "0ms",
+			"actions": map[string]interface{}{
+				"rollover": map[string]interface{}{
+					"max_age":                logsRolloverMaxAge,
+					"max_primary_shard_size": logsRolloverMaxShardSize,
+				},
+				"set_priority": map[string]interface{}{"priority": 100},
+			},
+		},
+		"delete": map[string]interface{}{
+			"min_age": fmt.Sprintf("%dd", retentionDays),
+			"actions": map[string]interface{}{
+				"delete": map[string]interface{}{},
+			},
+		},
+	}
+	if warmAfterDays < retentionDays {
+		phases["warm"] = map[string]interface{}{
+			"min_age": fmt.Sprintf("%dd", warmAfterDays),
+			"actions": map[string]interface{}{
+				"readonly":     map[string]interface{}{},
+				"forcemerge":   map[string]interface{}{"max_num_segments": 1},
+				"set_priority": map[string]interface{}{"priority": 50},
+			},
+		}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"policy": map[string]interface{}{"phases": phases},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal ILM policy: %w", err)
+	}
+
+	res, err := m.es.ILM.PutLifecycle(logsPolicy(projectName), m.es.ILM.PutLifecycle.WithBody(strings.NewReader(string(body))))
+	if err != nil {
+		return fmt.Errorf("failed to put ILM policy for %s: %w", projectName, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to put ILM policy for %s: %s", projectName, readBody(res.Body))
+	}
+
+	log.Printf("ILM policy %s set to warm after %dd and delete after %dd", logsPolicy(projectName), warmAfterDays, retentionDays)
+	return nil
+}
+
+// putIndexTemplate attaches the policy and rollover alias to every backing index of the project.
+// It takes precedence over the generic logs-* template and reuses its mappings.
+func (m *LogIndexManager) putIndexTemplate(projectName string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"index_patterns": []string{LogsAlias(projectName) + "-*"},
+		"priority":       502,
+		"composed_of":    []string{LogsComponentTemplate},
+		"template": map[string]interface{}{
+			"settings": map[string]interface{}{
+				"index.lifecycle.name":           logsPolicy(projectName),
+				"index.lifecycle.rollover_alias": LogsAlias(projectName),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal index template: %w", err)
+	}
+
+	res, err := m.es.Indices.PutIndexTemplate(LogsAlias(projectName), strings.NewReader(string(body)))
+	if err != nil {
+		return fmt.Errorf("failed to put index template for %s: %w", projectName, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to put index template for %s: %s", projectName, readBody(res.Body))
+	}
+	return nil
+}
+
+// bootstrapIndex creates logs-<project>-000001 as the write index of the alias. Projects that
+// still write to a plain logs-<project> index are migrated behind the alias.
+func (m *LogIndexManager) bootstrapIndex(projectName string) error {
+	alias := LogsAlias(projectName)
+
+	aliasRes, err := m.es.Indices.ExistsAlias([]string{alias})
+	if err != nil {
+		return fmt.Errorf("failed to check alias %s: %w", alias, err)
+	}
+	aliasRes.Body.Close()
+	if aliasRes.StatusCode == 200 {
+		return nil
+	}
+
+	indexRes, err := m.es.Indices.Exists([]string{alias})
+	if err != nil {
+		return fmt.Errorf("failed to check index %s: %w", alias, err)
+	}
+	indexRes.Body.Close()
+	if indexRes.StatusCode == 200 {
+		return m.migrateIndex(projectName)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"aliases": map[string]interface{}{
+			alias: map[string]interface{}{"is_write_index": true},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal bootstrap index: %w", err)
+	}
+
+	index := alias + "-000001"
+	res, err := m.es.Indices.Create(index, m.es.Indices.Create.WithBody(strings.NewReader(string(body))))
+	if err != nil {
+		return fmt.Errorf("failed to create index %s: %w", index, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		msg := readBody(res.Body)
+		if strings.Contains(msg, "resource_already_exists_exception") {
+			return nil
+		}
+		return fmt.Errorf("failed to create index %s: %s", index, msg)
+	}
+
+	log.Printf("Created index %s with write alias %s", index, alias)
+	return nil
+}
+
+// migrateIndex moves the logs of a plain logs-<project> index, created before rollover indices,
+// into logs-<project>-000001 and replaces the index with the alias in one step. The index is
+// cloned, which needs it to be read-only, so logs written meanwhile are rejected and dead
+// lettered, from where they can be replayed. A migration that was interrupted resumes from the
+// clone.
+func (m *LogIndexManager) migrateIndex(projectName string) error {
+	alias := LogsAlias(projectName)
+	index := alias + "-000001"
+	log.Printf("Migrating index %s behind the rollover alias %s", alias, alias)
+
+	res, err := m.es.Indices.PutSettings(
+		strings.NewReader(`{"index.blocks.write": true}`),
+		m.es.Indices.PutSettings.WithIndex(alias),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to block writes to %s: %w", alias, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to block writes to %s: %s", alias, readBody(res.Body))
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"settings": map[string]interface{}{
+			"index.blocks.write":             nil,
+			"index.lifecycle.name":           logsPolicy(projectName),
+			"index.lifecycle.rollover_alias": alias,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal clone settings: %w", err)
+	}
+	cloneRes, err := m.es.Indices.Clone(alias, index, m.es.Indices.Clone.WithBody(strings.NewReader(string(body))))
+	if err != nil {
+		return fmt.Errorf("failed to clone %s into %s: %w", alias, index, err)
+	}
+	defer cloneRes.Body.Close()
+	if cloneRes.IsError() {
+		msg := readBody(cloneRes.Body)
+		if !strings.Contains(msg, "resource_already_exists_exception") {
+			return fmt.Errorf("failed to clone %s into %s: %s", alias, index, msg)
+		}
+	}
+
+	body, err = json.Marshal(map[string]interface{}{
+		"actions": []map[string]interface{}{
+			{"remove_index": map[string]interface{}{"index": alias}},
+			{"add": map[string]interface{}{"index": index, "alias": alias, "is_write_index": true}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal alias actions: %w", err)
+	}
+	aliasRes, err := m.es.Indices.UpdateAliases(strings.NewReader(string(body)))
+	if err != nil {
+		return fmt.Errorf("failed to replace %s with its alias: %w", alias, err)
+	}
+	defer aliasRes.Body.Close()
+	if aliasRes.IsError() {
+		return fmt.Errorf("failed to replace %s with its alias: %s", alias, readBody(aliasRes.Body))
+	}
+
+	log.Printf("Migrated index %s into %s behind the write alias %s", alias, index, alias)
+	return nil
+}
+
+func readBody(body io.Reader) string {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// OffsetTracker marks the messages of one claimed partition in offset order. A message is
+// only marked once it and every earlier message have completed, so the committed offset never
+// skips a message that is still buffered or being retried.
+type OffsetTracker struct {
+	session sarama.ConsumerGroupSession
+	mutex   sync.Mutex
+	pending []*trackedMessage
+}
+
+type trackedMessage struct {
+	message *sarama.ConsumerMessage
+	done    bool
+}
+
+func NewOffsetTracker(session sarama.ConsumerGroupSession) *OffsetTracker {
+	return &OffsetTracker{session: session}
+}
+
+// Track registers a message in consumption order and returns the function that completes it.
+// Calling the function more than once has no effect.
+func (t *OffsetTracker) Track(message *sarama.ConsumerMessage) func() {
+	tracked := &trackedMessage{message: message}
+	t.mutex.Lock()
+	t.pending = append(t.pending, tracked)
+	t.mutex.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { t.complete(tracked) })
+	}
+}
+
+func (t *OffsetTracker) complete(tracked *trackedMessage) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tracked.done = true
+	for len(t.pending) > 0 && t.pending[0].done {
+		t.session.MarkMessage(t.pending[0].message, "")
+		t.pending[0] = nil
+		t.pending = t.pending[1:]
+	}
+}
@@ -6,96 +6,142 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/bufbuild/protocompile"
 	"github.com/riferrei/srclient"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 
 	protoLog "server/internal/services/proto/logs"
 	protoMetrics "server/internal/services/proto/metrics"
 )
 
+// schemaRetryDelay is how long a failed schema lookup is remembered, so a registry outage does
+// not cost a request per message
+const schemaRetryDelay = 30 * time.Second
+
 // ProtobufDeserializer handles deserialization of protobuf messages from Kafka
 type ProtobufDeserializer struct {
-	client *srclient.SchemaRegistryClient
+	client  *srclient.SchemaRegistryClient
+	mu      sync.RWMutex
+	schemas map[int]*registeredSchema
+}
+
+// registeredSchema is the message a registered schema encodes records with, or why it is unknown
+type registeredSchema struct {
+	message protoreflect.FullName
+	err     error
+	retryAt time.Time // when a failed lookup is tried again, zero once the schema was read
 }
 
 func NewProtobufDeserializer(client *srclient.SchemaRegistryClient) *ProtobufDeserializer {
-	return &ProtobufDeserializer{client: client}
+	return &ProtobufDeserializer{
+		client:  client,
+		schemas: make(map[int]*registeredSchema),
+	}
 }
 
 // DeserializeLogs decodes a protobuf message using the Confluent wire format
 func (d *ProtobufDeserializer) DeserializeLogs(data []byte) (*protoLog.Log, error) {
+	logMessage := &protoLog.Log{}
+	if err := d.deserialize(data, logMessage); err != nil {
+		return nil, err
+	}
+	return logMessage, nil
+}
+
+func (d *ProtobufDeserializer) DeserializeMetrics(data []byte) (*protoMetrics.Metrics, error) {
+	metrics := &protoMetrics.Metrics{}
+	if err := d.deserialize(data, metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+func (d *ProtobufDeserializer) deserialize(data []byte, message proto.Message) error {
 	if d == nil || d.client == nil {
-		return nil, fmt.Errorf("ProtobufDeserializer or its client is not initialized")
+		return fmt.Errorf("ProtobufDeserializer or its client is not initialized")
 	}
 
 	if len(data) < 5 {
-		return nil, fmt.Errorf("message too short: expected at least 5 bytes, got %d", len(data))
+		return fmt.Errorf("message too short: expected at least 5 bytes, got %d", len(data))
 	}
 
 	// Check magic byte
 	if data[0] != 0x0 {
-		return nil, fmt.Errorf("invalid magic byte: expected 0x0, got 0x%x", data[0])
+		return fmt.Errorf("invalid magic byte: expected 0x0, got 0x%x", data[0])
 	}
 
 	// Extract schema ID
-	schemaID := binary.BigEndian.Uint32(data[1:5])
-
-	// Verify schema exists
-	_, err := d.client.GetSchema(int(schemaID))
-	if err != nil {
-		log.Printf("Warning: Could not validate schema ID %d: %v", schemaID, err)
+	schemaID := int(binary.BigEndian.Uint32(data[1:5]))
+
+	// Verify the schema encodes the expected message. Schemas without a package, like the ones
+	// uploaded by older gateways, are matched by message name alone.
+	desc := message.ProtoReflect().Descriptor()
+	if schema := d.registeredSchema(schemaID); schema.err == nil {
+		name := schema.message
+		if name != desc.FullName() && (name.Parent() != "" || name.Name() != desc.Name()) {
+			return fmt.Errorf("schema ID %d encodes message %s, expected %s", schemaID, name, desc.FullName())
+		}
 	}
 
-	// Extract protobuf data
-	protoData := data[5:]
-
 	// Unmarshal protobuf message
-	logMessage := &protoLog.Log{}
-	err = proto.Unmarshal(protoData, logMessage)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal protobuf message: %w", err)
+	if err := proto.Unmarshal(data[5:], message); err != nil {
+		return fmt.Errorf("failed to unmarshal protobuf message: %w", err)
 	}
-
-	return logMessage, nil
+	return nil
 }
 
-func (d *ProtobufDeserializer) DeserializeMetrics(data []byte) (*protoMetrics.Metrics, error) {
-	if d == nil || d.client == nil {
-		return nil, fmt.Errorf("ProtobufDeserializer or its client is not initialized")
+// registeredSchema returns the message of a registered schema, the first one it declares as
+// records carry no message indexes. Schemas are immutable per ID, so a parsed ID is cached for
+// good, while a failed lookup is retried after schemaRetryDelay. Records are not validated
+// against schemas that cannot be read.
+func (d *ProtobufDeserializer) registeredSchema(schemaID int) *registeredSchema {
+	d.mu.RLock()
+	schema, ok := d.schemas[schemaID]
+	d.mu.RUnlock()
+	if ok && (schema.retryAt.IsZero() || time.Now().Before(schema.retryAt)) {
+		return schema
 	}
 
-	if len(data) < 5 {
-		return nil, fmt.Errorf("message too short: expected at least 5 bytes, got %d", len(data))
+	schema = &registeredSchema{}
+	registered, err := d.client.GetSchema(schemaID)
+	if err != nil {
+		schema.err = err
+		schema.retryAt = time.Now().Add(schemaRetryDelay)
+	} else {
+		schema.message, schema.err = firstMessage(registered.Schema())
 	}
-
-	// Check magic byte
-	if data[0] != 0x0 {
-		return nil, fmt.Errorf("invalid magic byte: expected 0x0, got 0x%x", data[0])
+	if schema.err != nil {
+		log.Printf("Warning: Could not validate schema ID %d: %v", schemaID, schema.err)
 	}
 
-	// Extract schema ID
-	schemaID := binary.BigEndian.Uint32(data[1:5])
+	d.mu.Lock()
+	d.schemas[schemaID] = schema
+	d.mu.Unlock()
+	return schema
+}
 
-	// Verify schema exists
-	_, err := d.client.GetSchema(int(schemaID))
-	if err != nil {
-		log.Printf("Warning: Could not validate schema ID %d: %v", schemaID, err)
+// firstMessage compiles a .proto schema and returns the full name of its first message
+func firstMessage(source string) (protoreflect.FullName, error) {
+	const path = "schema.proto"
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{path: source}),
+		}),
 	}
-
-	// Extract protobuf data
-	protoData := data[5:]
-
-	// Unmarshal protobuf message
-	metrics := &protoMetrics.Metrics{}
-	err = proto.Unmarshal(protoData, metrics)
+	files, err := compiler.Compile(context.Background(), path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal protobuf message: %w", err)
+		return "", fmt.Errorf("failed to parse schema: %w", err)
 	}
-
-	return metrics, nil
+	messages := files[0].Messages()
+	if messages.Len() == 0 {
+		return "", fmt.Errorf("schema declares no message")
+	}
+	return messages.Get(0).FullName(), nil
 }
 
 // SetupKafkaConsumer initializes the Sarama ConsumerGroup and the ProtobufDeserializer
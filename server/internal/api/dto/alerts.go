@@ -1,6 +1,11 @@
 package dto
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type CreateVerifyEmail struct {
 	Email   string `json:"email"`
@@ -27,6 +32,25 @@ type AlertMessage struct {
 	Version       string    `json:"version"`
 }
 
+// EventID identifies the alert in the live streams by the time it was raised, in milliseconds,
+// followed by its ID, so alerts raised in the same millisecond stay apart
+func (a *AlertMessage) EventID() string {
+	return fmt.Sprintf("%d:%s", a.Timestamp.UnixMilli(), a.ID)
+}
+
+// ParseAlertEventID returns the time of an alert event ID
+func ParseAlertEventID(id string) (time.Time, error) {
+	ms, alertID, ok := strings.Cut(id, ":")
+	if !ok || alertID == "" {
+		return time.Time{}, fmt.Errorf("invalid alert event ID %q, expected <milliseconds>:<alert ID>", id)
+	}
+	millis, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time in alert event ID %q", id)
+	}
+	return time.UnixMilli(millis), nil
+}
+
 type Method struct {
 	Method string `json:"method"`
 	Value  string `json:"value"`
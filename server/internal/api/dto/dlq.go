@@ -0,0 +1,10 @@
+package dto
+
+type DLQRecordRef struct {
+	Partition int32 `json:"partition"`
+	Offset    int64 `json:"offset"`
+}
+
+type DLQReplayRequest struct {
+	Records []DLQRecordRef `json:"records"`
+}
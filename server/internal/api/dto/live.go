@@ -0,0 +1,45 @@
+package dto
+
+import "time"
+
+// LiveMessage is a frame sent by a client of the WebSocket live stream. The first frame must be
+// an auth message with the token, after it the client subscribes to the logs, metrics or alerts
+// of projects and may change the filter of a log subscription at any time. A client subscribing
+// again after its connection dropped passes the eventId of the last event it received.
+type LiveMessage struct {
+	Type        string        `json:"type"` // auth, subscribe, unsubscribe or filter
+	Token       string        `json:"token,omitempty"`
+	ID          string        `json:"id,omitempty"`     // chosen by the client, defaults to <stream>:<project>
+	Stream      string        `json:"stream,omitempty"` // logs, metrics or alerts
+	Project     string        `json:"project,omitempty"`
+	Filter      *StreamFilter `json:"filter,omitempty"`
+	LastEventID string        `json:"lastEventId,omitempty"`
+}
+
+// LiveEvent is a frame sent to a client of the WebSocket live stream. Events of a subscription
+// carry its ID, so one connection can tell apart the streams of several projects.
+type LiveEvent struct {
+	Type    string      `json:"type"` // authenticated, subscribed, unsubscribed, filtered, log, metrics, alert or error
+	ID      string      `json:"id,omitempty"`
+	EventID string      `json:"eventId,omitempty"` // to resume from after log, metrics and alert events
+	Project string      `json:"project,omitempty"`
+	Message string      `json:"message,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// LiveClientStats describes a client of a live stream registry. A session holds one client per
+// stream and project it receives, Buffered counts the entries waiting to be written and Dropped
+// those discarded because the buffer was full.
+type LiveClientStats struct {
+	ClientID  string    `json:"clientId"`
+	SessionID string    `json:"sessionId"`
+	User      string    `json:"user"`
+	Transport string    `json:"transport"`
+	Stream    string    `json:"stream"` // logs, metrics or alerts
+	Project   string    `json:"project"`
+	Buffered  int       `json:"buffered"`
+	Capacity  int       `json:"capacity"`
+	Dropped   uint64    `json:"dropped"`
+	Started   time.Time `json:"started"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
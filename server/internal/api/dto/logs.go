@@ -1,6 +1,9 @@
 package dto
 
-import "time"
+import (
+	logquery "server/internal/services/log_query"
+	"time"
+)
 
 type Log struct {
 	ServiceName  string       `json:"serviceName"`
@@ -17,12 +20,65 @@ type BuildDetails struct {
 }
 
 type LogFilter struct {
-	Limit         int    `json:"limit"`
-	Offset        int    `json:"offset"`
-	Project       string `json:"project"`
-	Level         string `json:"level"`
-	From          string `json:"from"`
-	To            string `json:"to"`
-	SortByDate    string `json:"sortByDate"`
-	LastTimestamp string `json:"lastTimestamp"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	Project    string `json:"project"`
+	Level      string `json:"level"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	SortByDate string `json:"sortByDate"`
+	Cursor     string `json:"cursor"` // opaque token of the page to read, see repository.GetLogsPage
+
+	Search         string `json:"search"` // free text over message and stack
+	RequestId      string `json:"requestId"`
+	RequestMethod  string `json:"requestMethod"`
+	ResponseStatus string `json:"responseStatus"` // exact code, a class like 5xx or a range like 400-499
+	IpAddress      string `json:"ipAddress"`      // address or CIDR block
+	UserAgent      string `json:"userAgent"`
+	AppVersion     string `json:"appVersion"`
+
+	Attributes map[string]string `json:"attributes"` // exact attribute values by key
+
+	Query *logquery.Query `json:"-"` // parsed q parameter
+}
+
+// StreamFilter holds the filters of a live log stream client as sent by the client, the
+// WebSocket stream reads it from the subscribe and filter messages
+type StreamFilter struct {
+	Levels        []string `json:"levels"`
+	Search        string   `json:"search"`
+	UrlPrefix     string   `json:"urlPrefix"`
+	StatusClasses []string `json:"statusClass"` // classes like 5xx
+	Query         string   `json:"q"`
+}
+
+// LogAggregationRequest is the body of the logs aggregation endpoint. Logs are selected with the
+// same query parameters as the logs endpoint, the body only describes how to group them.
+type LogAggregationRequest struct {
+	Aggs map[string]*LogAggregation `json:"aggs"`
+}
+
+// LogAggregation is a single named aggregation. Exactly one of DateHistogram, Terms and
+// Percentiles is set. Bucket aggregations may nest further aggregations in Aggs.
+type LogAggregation struct {
+	DateHistogram *DateHistogramAggregation  `json:"dateHistogram,omitempty"`
+	Terms         *TermsAggregation          `json:"terms,omitempty"`
+	Percentiles   *PercentilesAggregation    `json:"percentiles,omitempty"`
+	Aggs          map[string]*LogAggregation `json:"aggs,omitempty"`
+}
+
+type DateHistogramAggregation struct {
+	Field    string `json:"field"`    // defaults to timestamp
+	Interval string `json:"interval"` // calendar unit like 1h or 1d, or a fixed interval like 30s or 5m
+	TimeZone string `json:"timeZone"` // IANA name or offset like +05:30, defaults to +05:30 like the metric queries
+}
+
+type TermsAggregation struct {
+	Field string `json:"field"` // a keyword, numeric or ip field, attributes.<key>, or statusClass to group by 2xx to 5xx
+	Size  int    `json:"size"`  // defaults to 10
+}
+
+type PercentilesAggregation struct {
+	Field    string    `json:"field"`    // defaults to response_time_ms
+	Percents []float64 `json:"percents"` // defaults to 50, 90, 95 and 99
 }
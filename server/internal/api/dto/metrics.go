@@ -21,3 +21,46 @@ type MinMaxDate struct {
 	MinDate string `json:"minDate"`
 	MaxDate string `json:"maxDate"`
 }
+
+// MetricName is a metric reported by a project, with the number of points indexed for it
+type MetricName struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+}
+
+// MetricQuery selects the points of a metric by name and labels and aggregates them per
+// interval. Aggregation is avg, sum, min, max, count or percentile, with the percentile in
+// Percent. Series are split by the label keys in GroupBy.
+type MetricQuery struct {
+	Project     string
+	Name        string
+	Type        string
+	Labels      map[string]string
+	GroupBy     []string
+	Aggregation string
+	Percent     float64
+	From        int64
+	To          int64
+	Interval    string
+	TimeZone    string
+}
+
+type MetricValuePoint struct {
+	TimeLabel string  `json:"timeLabel,omitempty"`
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+// MetricSeries holds the points of one label set, the labels are empty unless grouped by
+type MetricSeries struct {
+	Labels map[string]string   `json:"labels"`
+	Points []*MetricValuePoint `json:"points"`
+}
+
+// LabelMatcher selects points by the value of a label, or by a different value when Negate is set
+type LabelMatcher struct {
+	Name   string
+	Value  string
+	Negate bool
+}
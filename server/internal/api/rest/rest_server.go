@@ -2,9 +2,13 @@ package rest
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"server/config"
 	"server/internal/api/rest/resthandlers"
+	"server/internal/repository"
+	"server/internal/services"
+	indexstats "server/internal/services/index_stats"
 	serversentevents "server/internal/services/server_sent_events"
 
 	"github.com/elastic/go-elasticsearch/v9"
@@ -12,7 +16,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
-func StartRestServer(ctx context.Context, cfg config.AppConfig, elasticSearch *elasticsearch.Client, ktm *config.KafkaTopicManager, sse *serversentevents.SSEService) error {
+func StartRestServer(ctx context.Context, cfg config.AppConfig, elasticSearch *elasticsearch.Client, ktm *config.KafkaTopicManager, dlq *config.DeadLetterQueue, sse *serversentevents.SSEService, stats *indexstats.IndexStats) error {
 	app := fiber.New()
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:  "http://localhost:5173",
@@ -25,6 +29,19 @@ func StartRestServer(ctx context.Context, cfg config.AppConfig, elasticSearch *e
 		return err
 	}
 
+	// Projects created before dead letter topics and rollover indices existed get them at startup
+	projects := services.ProjectServices{
+		Repo: repository.NewProjectRepo(postgres),
+		Ktm:  ktm,
+		Lim:  config.NewLogIndexManager(elasticSearch),
+	}
+	if err := projects.EnsureProjectTopics(); err != nil {
+		return fmt.Errorf("failed to set up project topics: %w", err)
+	}
+	if err := projects.EnsureProjectIndices(); err != nil {
+		return fmt.Errorf("failed to set up project indices: %w", err)
+	}
+
 	synapse, err := config.NewSynapseSQL(cfg.SynapseDb, 10, 5, "1h")
 	if err != nil {
 		return err
@@ -37,8 +54,9 @@ func StartRestServer(ctx context.Context, cfg config.AppConfig, elasticSearch *e
 		SynapseDb:     synapse,
 		Config:        cfg,
 		Ktm:           ktm,
+		Dlq:           dlq,
 	}
-	SetupRoutes(restHandler, sse)
+	SetupRoutes(restHandler, sse, stats)
 	go func() {
 		<-ctx.Done()
 		log.Println("Shutting down gRPC server...")
@@ -55,9 +73,11 @@ func StartRestServer(ctx context.Context, cfg config.AppConfig, elasticSearch *e
 	return app.Listen(cfg.ServerPort)
 }
 
-func SetupRoutes(h *resthandlers.RestHandler, sse *serversentevents.SSEService) {
+func SetupRoutes(h *resthandlers.RestHandler, sse *serversentevents.SSEService, stats *indexstats.IndexStats) {
 	resthandlers.SetupProjectRoutes(h)
-	resthandlers.SetupLogsRoutes(h, sse.LogSSE)
-	resthandlers.SetupMetricsHandler(h, sse.MetricSSE)
-	resthandlers.SetupAlertRoutes(h, sse.AlertSSE)
+	resthandlers.SetupLogsRoutes(h, sse.LogSSE, sse.Sessions, stats)
+	resthandlers.SetupDLQRoutes(h)
+	resthandlers.SetupMetricsHandler(h, sse.MetricSSE, sse.Sessions)
+	resthandlers.SetupAlertRoutes(h, sse.AlertSSE, sse.Sessions)
+	resthandlers.SetupLiveRoutes(h, sse)
 }
@@ -15,4 +15,5 @@ type RestHandler struct {
 	ElasticSearch *elasticsearch.Client
 	SynapseDb     *gorm.DB
 	Ktm           *config.KafkaTopicManager
+	Dlq           *config.DeadLetterQueue
 }
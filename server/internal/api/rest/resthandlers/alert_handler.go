@@ -17,11 +17,12 @@ import (
 )
 
 type AlertHandler struct {
-	svc *services.AlertServices
-	sse *serversentevents.SSEAlertService
+	svc      *services.AlertServices
+	sse      *serversentevents.SSEAlertService
+	sessions *serversentevents.Sessions
 }
 
-func SetupAlertRoutes(r *RestHandler, a *serversentevents.SSEAlertService) {
+func SetupAlertRoutes(r *RestHandler, a *serversentevents.SSEAlertService, sessions *serversentevents.Sessions) {
 	app := r.App
 
 	api := app.Group("/api/v1/alerts")
@@ -29,8 +30,9 @@ func SetupAlertRoutes(r *RestHandler, a *serversentevents.SSEAlertService) {
 		Repo: repository.NewAlertRepo(r.ElasticSearch, r.PostgresDb),
 	}
 	h := AlertHandler{
-		svc: &svc,
-		sse: a,
+		svc:      &svc,
+		sse:      a,
+		sessions: sessions,
 	}
 
 	api.Post("/email", pkg.AuthMiddleware(), h.CreateAlertEmail)
@@ -204,8 +206,19 @@ func (a *AlertHandler) SendAlert(c *fiber.Ctx) error {
 	if !projectExists {
 		return ErrorMessage(c, fiber.StatusNotFound, "Project not found")
 	}
+	lastID := lastEventID(c)
+	if lastID != "" {
+		if err := validEventID("alerts", lastID); err != nil {
+			return BadRequestError(c, "invalid Last-Event-ID: "+err.Error())
+		}
+	}
+
 	user := c.Locals("user").(*pkg.UserClaims)
-	clientID := user.UniqueName
+	session, err := a.sessions.Open(user.UniqueName, "sse")
+	if err != nil {
+		return sessionError(c, err)
+	}
+	clientID := session.ID
 
 	c.Set("Content-Type", "text/event-stream")
 	c.Set("Cache-Control", "no-cache")
@@ -213,14 +226,15 @@ func (a *AlertHandler) SendAlert(c *fiber.Ctx) error {
 	c.Set("Access-Control-Allow-Origin", "*")
 	c.Set("Transfer-Encoding", "chunked")
 
-	// Register client
-	a.sse.RegisterAlertClient(clientID, project)
+	// Register client, the alerts it missed since its last event are replayed before the live ones
+	buffered, found := a.sse.RegisterAlertClient(clientID, session, project, lastID)
 
 	// Get a client channel
 	clientChan, ok := a.sse.GetAlertClientChannel(clientID)
 	if !ok {
 		log.Printf("No client channel found for ClientID: %s", clientID)
 		a.sse.UnregisterAlertClient(clientID)
+		a.sessions.Close(session)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get client channel"})
 	}
 
@@ -229,6 +243,7 @@ func (a *AlertHandler) SendAlert(c *fiber.Ctx) error {
 		defer func() {
 			log.Printf("Exiting stream writer for client: %s", clientID)
 			a.sse.UnregisterAlertClient(clientID)
+			a.sessions.Close(session)
 		}()
 
 		// Send initial connection confirmation
@@ -242,6 +257,26 @@ func (a *AlertHandler) SendAlert(c *fiber.Ctx) error {
 			return
 		}
 
+		var replay *liveReplay[serversentevents.AlertEvent]
+		if lastID != "" {
+			replay = replayAlerts(a.svc, project, lastID, buffered, found)
+			for _, alert := range replay.events {
+				data, err := json.Marshal(alert.Payload)
+				if err != nil {
+					log.Printf("Failed to marshal alert: %v", err)
+					continue
+				}
+				if _, err := w.WriteString(sseEvent(alert.ID, data)); err != nil {
+					log.Printf("Error writing to client %s: %v", clientID, err)
+					return
+				}
+			}
+			if err := w.Flush(); err != nil {
+				log.Printf("Error flushing buffer for client %s: %v", clientID, err)
+				return
+			}
+		}
+
 		heartbeatTicker := time.NewTicker(30 * time.Second)
 		defer heartbeatTicker.Stop()
 
@@ -275,19 +310,22 @@ func (a *AlertHandler) SendAlert(c *fiber.Ctx) error {
 		// Main message loop
 		for {
 			select {
-			case metrics, ok := <-clientChan:
+			case alert, ok := <-clientChan:
 				if !ok {
 					log.Printf("Client channel closed for %s", clientID)
 					return
 				}
+				if replay.skip(alert.ID) {
+					continue
+				}
 
-				data, err := json.Marshal(metrics)
+				data, err := json.Marshal(alert.Payload)
 				if err != nil {
 					log.Printf("Failed to marshal log entry: %v", err)
 					continue
 				}
 
-				_, err = w.WriteString("data: " + string(data) + "\n\n")
+				_, err = w.WriteString(sseEvent(alert.ID, data))
 				if err != nil {
 					log.Printf("Error writing to client %s: %v", clientID, err)
 					return
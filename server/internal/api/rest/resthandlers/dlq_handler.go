@@ -0,0 +1,124 @@
+package resthandlers
+
+import (
+	"errors"
+	"server/internal/api/dto"
+	"server/internal/repository"
+	"server/internal/services"
+	"server/pkg"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DLQHandler struct {
+	svc services.DLQServices
+}
+
+func SetupDLQRoutes(r *RestHandler) {
+	app := r.App
+	svc := services.DLQServices{
+		Repo: repository.NewDLQRepo(r.Dlq),
+	}
+	handler := DLQHandler{
+		svc: svc,
+	}
+	api := app.Group("/api/v1/logs/:project/dlq")
+	api.Get("/", pkg.AuthMiddleware(), handler.ListRecords)
+	api.Post("/replay", pkg.AuthMiddleware(), handler.ReplayRecords)
+	api.Get("/:partition/:offset", pkg.AuthMiddleware(), handler.GetRecord)
+	api.Post("/:partition/:offset/replay", pkg.AuthMiddleware(), handler.ReplayRecord)
+}
+
+// ListRecords lists the latest dead lettered records of a project, use ?type=metrics for the metrics topic.
+func (h *DLQHandler) ListRecords(c *fiber.Ctx) error {
+	project := c.Params("project")
+	if project == "" {
+		return ErrorMessage(c, fiber.StatusBadRequest, "project name is required")
+	}
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		return BadRequestError(c, "limit must be between 1 and 500")
+	}
+
+	records, err := h.svc.ListRecords(project, c.Query("type"), limit)
+	if errors.Is(err, services.ErrInvalidDLQType) {
+		return BadRequestError(c, err.Error())
+	}
+	if err != nil {
+		return InternalError(c, err)
+	}
+	return SuccessResponse(c, fiber.StatusOK, "Dead letter records retrieved successfully", fiber.Map{"records": records})
+}
+
+// GetRecord returns a single dead lettered record including its payload.
+func (h *DLQHandler) GetRecord(c *fiber.Ctx) error {
+	project := c.Params("project")
+	partition, offset, err := recordRef(c)
+	if err != nil {
+		return BadRequestError(c, err.Error())
+	}
+
+	record, err := h.svc.GetRecord(project, c.Query("type"), partition, offset)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDLQType) {
+			return BadRequestError(c, err.Error())
+		}
+		if errors.Is(err, repository.ErrDLQRecordNotFound) {
+			return ErrorMessage(c, fiber.StatusNotFound, "dead letter record not found")
+		}
+		return InternalError(c, err)
+	}
+	return SuccessResponse(c, fiber.StatusOK, "Dead letter record retrieved successfully", record)
+}
+
+// ReplayRecord produces a single dead lettered record back into the project's topic.
+func (h *DLQHandler) ReplayRecord(c *fiber.Ctx) error {
+	project := c.Params("project")
+	partition, offset, err := recordRef(c)
+	if err != nil {
+		return BadRequestError(c, err.Error())
+	}
+
+	results, err := h.svc.ReplayRecords(project, c.Query("type"), []dto.DLQRecordRef{{Partition: partition, Offset: offset}})
+	if err != nil {
+		return BadRequestError(c, err.Error())
+	}
+	if !results[0].Replayed {
+		if results[0].Error == "record not found" {
+			return ErrorMessage(c, fiber.StatusNotFound, "dead letter record not found")
+		}
+		return ErrorMessage(c, fiber.StatusInternalServerError, results[0].Error)
+	}
+	return SuccessResponse(c, fiber.StatusOK, "Dead letter record replayed successfully", results[0])
+}
+
+// ReplayRecords replays the records listed in the body and reports the outcome per record.
+func (h *DLQHandler) ReplayRecords(c *fiber.Ctx) error {
+	project := c.Params("project")
+	var req dto.DLQReplayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequestError(c, err.Error())
+	}
+	if len(req.Records) == 0 {
+		return BadRequestError(c, "records are required")
+	}
+
+	results, err := h.svc.ReplayRecords(project, c.Query("type"), req.Records)
+	if err != nil {
+		return BadRequestError(c, err.Error())
+	}
+	return SuccessResponse(c, fiber.StatusOK, "Dead letter records replayed", fiber.Map{"results": results})
+}
+
+func recordRef(c *fiber.Ctx) (int32, int64, error) {
+	partition, err := strconv.ParseInt(c.Params("partition"), 10, 32)
+	if err != nil || partition < 0 {
+		return 0, 0, errors.New("invalid partition")
+	}
+	offset, err := strconv.ParseInt(c.Params("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, errors.New("invalid offset")
+	}
+	return int32(partition), offset, nil
+}
@@ -0,0 +1,541 @@
+package resthandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"server/internal/api/dto"
+	"server/internal/models"
+	"server/internal/repository"
+	"server/internal/services"
+	serversentevents "server/internal/services/server_sent_events"
+	"server/pkg"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	liveAuthTimeout      = 10 * time.Second // time the client has to send the auth frame
+	livePingInterval     = 30 * time.Second
+	livePongWait         = 75 * time.Second // a connection without a pong for this long is closed
+	liveWriteWait        = 10 * time.Second
+	maxLiveMessageSize   = 64 * 1024
+	maxLiveSubscriptions = 50
+)
+
+// LiveHandler serves the WebSocket live stream, which multiplexes the log, metric and alert
+// streams of several projects over one connection. Subscriptions are registered with the same
+// fan-out registries as the SSE streams.
+type LiveHandler struct {
+	logs      services.LogServices
+	metrics   *services.MetricsServices
+	alerts    *services.AlertServices
+	sse       *serversentevents.SSEService
+	authorize func(token string) (*pkg.UserClaims, error)
+}
+
+func SetupLiveRoutes(r *RestHandler, sse *serversentevents.SSEService) {
+	handler := LiveHandler{
+		logs: services.LogServices{
+			Repo:     repository.NewLogRepo(r.ElasticSearch, r.SynapseDb),
+			Projects: repository.NewProjectRepo(r.PostgresDb),
+			Config:   r.Config,
+		},
+		metrics: &services.MetricsServices{
+			Repo:       repository.NewMetricsRepo(r.ElasticSearch),
+			ProjetRepo: repository.NewProjectRepo(r.PostgresDb),
+		},
+		alerts: &services.AlertServices{
+			Repo: repository.NewAlertRepo(r.ElasticSearch, r.PostgresDb),
+		},
+		sse:       sse,
+		authorize: pkg.TokenValidator(),
+	}
+
+	// The token is sent in the first frame rather than the query string, which ends up in
+	// proxy access logs
+	r.App.Get("/api/v1/live", handler.Upgrade, websocket.New(handler.Live))
+	r.App.Get("/api/v1/live/sessions", pkg.AuthMiddleware(), pkg.RequireScope(pkg.AdminScope), handler.ListSessions)
+}
+
+// Upgrade rejects requests that are not WebSocket upgrades
+func (h *LiveHandler) Upgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return ErrorMessage(c, fiber.StatusUpgradeRequired, "WebSocket upgrade required")
+	}
+	return c.Next()
+}
+
+var errLiveConnClosed = errors.New("live connection closed")
+
+// liveConn is one WebSocket connection and its subscriptions, keyed by subscription ID
+type liveConn struct {
+	session       *serversentevents.Session
+	conn          *websocket.Conn
+	writeMu       sync.Mutex // the connection supports one writer at a time
+	closed        bool       // set under writeMu once the handler returned and the connection is released
+	mu            sync.Mutex
+	subscriptions map[string]*liveSubscription
+}
+
+type liveSubscription struct {
+	id       string
+	stream   string
+	project  string
+	clientID string // ID of the client in the fan-out registry of the stream
+	stop     chan struct{}
+}
+
+func (lc *liveConn) send(event *dto.LiveEvent) error {
+	lc.writeMu.Lock()
+	defer lc.writeMu.Unlock()
+	if lc.closed {
+		return errLiveConnClosed
+	}
+	if err := lc.conn.SetWriteDeadline(time.Now().Add(liveWriteWait)); err != nil {
+		return err
+	}
+	return lc.conn.WriteJSON(event)
+}
+
+// close stops all writes, the websocket package reuses the connection once the handler returned
+func (lc *liveConn) close() {
+	lc.writeMu.Lock()
+	lc.closed = true
+	lc.writeMu.Unlock()
+}
+
+func (lc *liveConn) sendError(id string, err error) error {
+	return lc.send(&dto.LiveEvent{Type: "error", ID: id, Message: err.Error()})
+}
+
+// Live serves one WebSocket connection. The client authenticates with
+// {"type":"auth","token":"..."} and then sends subscribe, unsubscribe and filter messages, e.g.
+//
+//	{"type":"subscribe","id":"api-errors","stream":"logs","project":"api","filter":{"levels":["error"],"statusClass":["5xx"]}}
+//	{"type":"filter","id":"api-errors","filter":{"levels":["error","warn"]}}
+//	{"type":"unsubscribe","id":"api-errors"}
+//
+// Every message is acknowledged with a subscribed, filtered or unsubscribed event, or an error
+// event with the subscription ID. Logs, metrics and alerts arrive as log, metrics and alert
+// events carrying the ID of their subscription.
+func (h *LiveHandler) Live(c *websocket.Conn) {
+	lc := &liveConn{
+		conn:          c,
+		subscriptions: make(map[string]*liveSubscription),
+	}
+	defer lc.close()
+	c.SetReadLimit(maxLiveMessageSize)
+
+	// The connection is one session, however many projects it subscribes to
+	user, err := h.authenticate(lc)
+	if err == nil {
+		lc.session, err = h.sse.Sessions.Open(user.UniqueName, "websocket")
+	}
+	if err != nil {
+		_ = lc.sendError("", err)
+		_ = c.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()),
+			time.Now().Add(liveWriteWait))
+		return
+	}
+	defer h.sse.Sessions.Close(lc.session)
+	defer h.unsubscribeAll(lc)
+	log.Printf("Live session %s opened for %s", lc.session.ID, user.UniqueName)
+	defer log.Printf("Live session %s closed for %s", lc.session.ID, user.UniqueName)
+	if err := lc.send(&dto.LiveEvent{Type: "authenticated", Data: fiber.Map{"sessionId": lc.session.ID}}); err != nil {
+		return
+	}
+
+	_ = c.SetReadDeadline(time.Now().Add(livePongWait))
+	c.SetPongHandler(func(string) error {
+		h.updateActivity(lc)
+		return c.SetReadDeadline(time.Now().Add(livePongWait))
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go lc.ping(done)
+
+	for {
+		_, data, err := c.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("Error reading from live session %s: %v", lc.session.ID, err)
+			}
+			return
+		}
+
+		var msg dto.LiveMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			if err := lc.sendError("", errors.New("invalid message, expected a JSON object")); err != nil {
+				return
+			}
+			continue
+		}
+		if err := h.handleMessage(lc, &msg); err != nil {
+			if err := lc.sendError(msg.ID, err); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// authenticate reads the first frame, which must hold the token
+func (h *LiveHandler) authenticate(lc *liveConn) (*pkg.UserClaims, error) {
+	_ = lc.conn.SetReadDeadline(time.Now().Add(liveAuthTimeout))
+	_, data, err := lc.conn.ReadMessage()
+	if err != nil {
+		return nil, errors.New("expected an auth message")
+	}
+	var msg dto.LiveMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "auth" {
+		return nil, errors.New("expected an auth message")
+	}
+	return h.authorize(msg.Token)
+}
+
+// ping keeps the connection alive through proxies, the pongs refresh the read deadline
+func (lc *liveConn) ping(done chan struct{}) {
+	ticker := time.NewTicker(livePingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			lc.writeMu.Lock()
+			err := errLiveConnClosed
+			if !lc.closed {
+				err = lc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteWait))
+			}
+			lc.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (h *LiveHandler) handleMessage(lc *liveConn, msg *dto.LiveMessage) error {
+	switch msg.Type {
+	case "subscribe":
+		return h.subscribe(lc, msg)
+	case "unsubscribe":
+		lc.mu.Lock()
+		sub, ok := lc.subscriptions[msg.ID]
+		delete(lc.subscriptions, msg.ID)
+		lc.mu.Unlock()
+		if !ok {
+			return fmt.Errorf("subscription %q not found", msg.ID)
+		}
+		h.unsubscribe(sub)
+		return lc.send(&dto.LiveEvent{Type: "unsubscribed", ID: sub.id, Project: sub.project})
+	case "filter":
+		return h.setFilter(lc, msg)
+	case "auth":
+		return errors.New("already authenticated")
+	default:
+		return fmt.Errorf("unknown message type %q, expected subscribe, unsubscribe or filter", msg.Type)
+	}
+}
+
+func (h *LiveHandler) subscribe(lc *liveConn, msg *dto.LiveMessage) error {
+	if msg.Project == "" {
+		return errors.New("project is required")
+	}
+	if msg.ID == "" {
+		// set on the message so errors carry the ID as well
+		msg.ID = msg.Stream + ":" + msg.Project
+	}
+	id := msg.ID
+
+	var matcher serversentevents.LogMatcher
+	switch msg.Stream {
+	case "logs":
+		if msg.Filter != nil {
+			filter, err := newStreamFilter(msg.Filter)
+			if err != nil {
+				return err
+			}
+			if !filter.IsEmpty() {
+				matcher = filter
+			}
+		}
+	case "metrics", "alerts":
+		if msg.Filter != nil {
+			return errors.New("filters are only supported on log streams")
+		}
+	default:
+		return fmt.Errorf("unknown stream %q, expected logs, metrics or alerts", msg.Stream)
+	}
+	if msg.LastEventID != "" {
+		if err := validEventID(msg.Stream, msg.LastEventID); err != nil {
+			return fmt.Errorf("invalid lastEventId: %w", err)
+		}
+	}
+
+	exists, err := h.projectExists(msg.Stream, msg.Project)
+	if err != nil {
+		log.Printf("Failed to check project %s for live session %s: %v", msg.Project, lc.session.ID, err)
+		return errors.New("failed to check project")
+	}
+	if !exists {
+		return errors.New("project not found")
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if _, ok := lc.subscriptions[id]; ok {
+		return fmt.Errorf("subscription %q already exists", id)
+	}
+	if len(lc.subscriptions) >= maxLiveSubscriptions {
+		return fmt.Errorf("at most %d subscriptions are allowed per connection", maxLiveSubscriptions)
+	}
+
+	sub := &liveSubscription{
+		id:       id,
+		stream:   msg.Stream,
+		project:  msg.Project,
+		clientID: lc.session.ID + "/" + id,
+		stop:     make(chan struct{}),
+	}
+	// Forwarding starts once the client knows it is subscribed, replayed events come first
+	var forward func()
+	lastID := msg.LastEventID
+	switch sub.stream {
+	case "logs":
+		since := resumePosition(lastID)
+		buffered, found := h.sse.LogSSE.RegisterLogsClient(sub.clientID, lc.session, sub.project, matcher, since)
+		ch, ok := h.sse.LogSSE.GetLogClientChannel(sub.clientID)
+		if !ok {
+			h.sse.LogSSE.UnregisterLogsClient(sub.clientID)
+			return errors.New("failed to get client channel")
+		}
+		forward = func() {
+			var replay func() *liveReplay[models.Log]
+			if lastID != "" {
+				replay = func() *liveReplay[models.Log] {
+					return replayLogs(h.logs, sub.project, since, buffered, found, matcher)
+				}
+			}
+			position := newClientPosition(since)
+			resumeID := func(logEntry models.Log) string { return position.next(logEntry.EventID, logEntry.Position) }
+			forwardLive(lc, sub, ch, "log", replay, logEventID, resumeID, func(logEntry models.Log) interface{} { return logEntry })
+		}
+	case "metrics":
+		since := resumePosition(lastID)
+		buffered, found := h.sse.MetricSSE.RegisterMetricsClient(sub.clientID, lc.session, sub.project, since)
+		ch, ok := h.sse.MetricSSE.GetMetricsClientChannel(sub.clientID)
+		if !ok {
+			h.sse.MetricSSE.UnRegisterMetricsClient(sub.clientID)
+			return errors.New("failed to get client channel")
+		}
+		forward = func() {
+			var replay func() *liveReplay[models.Metrics]
+			if lastID != "" {
+				replay = func() *liveReplay[models.Metrics] {
+					return replayMetrics(h.metrics, sub.project, since, buffered, found)
+				}
+			}
+			position := newClientPosition(since)
+			resumeID := func(metrics models.Metrics) string { return position.next(metrics.EventID, metrics.Position) }
+			forwardLive(lc, sub, ch, "metrics", replay, metricsEventID, resumeID, func(metrics models.Metrics) interface{} { return metrics })
+		}
+	case "alerts":
+		buffered, found := h.sse.AlertSSE.RegisterAlertClient(sub.clientID, lc.session, sub.project, lastID)
+		ch, ok := h.sse.AlertSSE.GetAlertClientChannel(sub.clientID)
+		if !ok {
+			h.sse.AlertSSE.UnregisterAlertClient(sub.clientID)
+			return errors.New("failed to get client channel")
+		}
+		forward = func() {
+			var replay func() *liveReplay[serversentevents.AlertEvent]
+			if lastID != "" {
+				replay = func() *liveReplay[serversentevents.AlertEvent] {
+					return replayAlerts(h.alerts, sub.project, lastID, buffered, found)
+				}
+			}
+			forwardLive(lc, sub, ch, "alert", replay, alertEventID, alertEventID, alertData)
+		}
+	}
+	lc.subscriptions[id] = sub
+
+	if err := lc.send(&dto.LiveEvent{Type: "subscribed", ID: id, Project: sub.project}); err != nil {
+		return err
+	}
+	go forward()
+	return nil
+}
+
+// setFilter replaces the filter of a log subscription, an empty filter sends every log
+func (h *LiveHandler) setFilter(lc *liveConn, msg *dto.LiveMessage) error {
+	lc.mu.Lock()
+	sub, ok := lc.subscriptions[msg.ID]
+	lc.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("subscription %q not found", msg.ID)
+	}
+	if sub.stream != "logs" {
+		return errors.New("filters are only supported on log streams")
+	}
+
+	var matcher serversentevents.LogMatcher
+	if msg.Filter != nil {
+		filter, err := newStreamFilter(msg.Filter)
+		if err != nil {
+			return err
+		}
+		if !filter.IsEmpty() {
+			matcher = filter
+		}
+	}
+	if !h.sse.LogSSE.SetLogsClientMatcher(sub.clientID, matcher) {
+		return fmt.Errorf("subscription %q not found", msg.ID)
+	}
+	return lc.send(&dto.LiveEvent{Type: "filtered", ID: sub.id, Project: sub.project})
+}
+
+func (h *LiveHandler) projectExists(stream, project string) (bool, error) {
+	switch stream {
+	case "logs":
+		return h.logs.CheckIfIndexExists(project)
+	case "metrics":
+		exists, err := h.metrics.CheckIfProjectExists(project)
+		if errors.Is(err, services.ErrProjectNotFound) {
+			return false, nil
+		}
+		return exists, err
+	default:
+		return h.alerts.CheckProjectExists(project)
+	}
+}
+
+// unsubscribe stops forwarding and removes the client from the registry
+func (h *LiveHandler) unsubscribe(sub *liveSubscription) {
+	close(sub.stop)
+	switch sub.stream {
+	case "logs":
+		h.sse.LogSSE.UnregisterLogsClient(sub.clientID)
+	case "metrics":
+		h.sse.MetricSSE.UnRegisterMetricsClient(sub.clientID)
+	case "alerts":
+		h.sse.AlertSSE.UnregisterAlertClient(sub.clientID)
+	}
+}
+
+func (h *LiveHandler) unsubscribeAll(lc *liveConn) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	for id, sub := range lc.subscriptions {
+		h.unsubscribe(sub)
+		delete(lc.subscriptions, id)
+	}
+}
+
+// updateActivity marks the subscriptions of the connection as seen, so the stale client
+// cleanup of the registries leaves them alone
+func (h *LiveHandler) updateActivity(lc *liveConn) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	for _, sub := range lc.subscriptions {
+		switch sub.stream {
+		case "logs":
+			h.sse.LogSSE.UpdateLogClientActivity(sub.clientID)
+		case "metrics":
+			h.sse.MetricSSE.UpdateMetricsClientActivity(sub.clientID)
+		case "alerts":
+			h.sse.AlertSSE.UpdateAlertClientActivity(sub.clientID)
+		}
+	}
+}
+
+// forwardLive writes the events missed since the last event ID the client subscribed with, when
+// replay is set, then what the registry sends to the subscription until it is unsubscribed or
+// the registry closes its channel. eventID identifies an event, resumeID returns the ID the
+// client resumes from once it received it.
+func forwardLive[T any](lc *liveConn, sub *liveSubscription, ch chan T, eventType string, replay func() *liveReplay[T], eventID func(T) string, resumeID func(T) string, data func(T) interface{}) {
+	var replayed *liveReplay[T]
+	if replay != nil {
+		replayed = replay()
+		for _, value := range replayed.events {
+			select {
+			case <-sub.stop:
+				return
+			default:
+			}
+			event := &dto.LiveEvent{Type: eventType, ID: sub.id, EventID: resumeID(value), Project: sub.project, Data: data(value)}
+			if err := lc.send(event); err != nil {
+				log.Printf("Error writing to live session %s: %v", lc.session.ID, err)
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case value, ok := <-ch:
+			if !ok {
+				return
+			}
+			if replayed.skip(eventID(value)) {
+				continue
+			}
+			event := &dto.LiveEvent{Type: eventType, ID: sub.id, EventID: resumeID(value), Project: sub.project, Data: data(value)}
+			if err := lc.send(event); err != nil {
+				log.Printf("Error writing to live session %s: %v", lc.session.ID, err)
+				return
+			}
+		case <-sub.stop:
+			return
+		}
+	}
+}
+
+// alertData sends alerts published as JSON as objects rather than strings
+func alertData(alert serversentevents.AlertEvent) interface{} {
+	if json.Valid([]byte(alert.Payload)) {
+		return json.RawMessage(alert.Payload)
+	}
+	return alert.Payload
+}
+
+// sessionError responds to a live stream that could not open its session
+func sessionError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, serversentevents.ErrTooManySessions) {
+		return ErrorMessage(c, fiber.StatusTooManyRequests, err.Error())
+	}
+	return InternalError(c, err)
+}
+
+// ListSessions lists the live stream clients of this instance grouped by project, with how full
+// their buffers are and how many entries were dropped because a client could not keep up.
+// Sessions and their stats are kept in the memory of each instance, so behind a load balancer
+// the list only covers the connections of the instance that answered, named in the response.
+func (h *LiveHandler) ListSessions(c *fiber.Ctx) error {
+	instance, _ := os.Hostname()
+	projects := make(map[string][]*dto.LiveClientStats)
+	for _, stats := range [][]*dto.LiveClientStats{h.sse.LogSSE.Stats(), h.sse.MetricSSE.Stats(), h.sse.AlertSSE.Stats()} {
+		for _, client := range stats {
+			projects[client.Project] = append(projects[client.Project], client)
+		}
+	}
+	for _, clients := range projects {
+		sort.Slice(clients, func(i, j int) bool {
+			if !clients[i].Started.Equal(clients[j].Started) {
+				return clients[i].Started.Before(clients[j].Started)
+			}
+			return clients[i].ClientID < clients[j].ClientID
+		})
+	}
+	return SuccessResponse(c, fiber.StatusOK, "Live sessions retrieved successfully", fiber.Map{
+		"instance": instance,
+		"sessions": h.sse.Sessions.Count(),
+		"projects": projects,
+	})
+}
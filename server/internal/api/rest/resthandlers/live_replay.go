@@ -0,0 +1,174 @@
+package resthandlers
+
+import (
+	"encoding/json"
+	"log"
+	"server/internal/api/dto"
+	"server/internal/models"
+	"server/internal/services"
+	serversentevents "server/internal/services/server_sent_events"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxReplayEvents bounds the events read from Elasticsearch for a reconnecting client
+const maxReplayEvents = 1000
+
+// lastEventID returns the ID of the last event a reconnecting SSE client received. EventSource
+// sends it in the Last-Event-ID header, clients that cannot set headers pass lastEventId.
+func lastEventID(c *fiber.Ctx) string {
+	if id := c.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return c.Query("lastEventId")
+}
+
+// sseEvent formats an SSE message, with an id line when the event has one so the browser sends
+// it back when it reconnects
+func sseEvent(id string, data []byte) string {
+	if id == "" {
+		return "data: " + string(data) + "\n\n"
+	}
+	return "id: " + id + "\ndata: " + string(data) + "\n\n"
+}
+
+// liveReplay holds the events a reconnecting client missed: those read from Elasticsearch when
+// the replay buffer no longer held its last event, then the buffered ones. Elasticsearch lags
+// the live stream by the consumer batches, so stored events may be buffered as well or still
+// arrive live, skip drops them the second time.
+type liveReplay[T any] struct {
+	events  []T
+	pending map[string]bool // stored events not seen again yet
+}
+
+func newLiveReplay[T any](stored []T, buffered []T, id func(T) string) *liveReplay[T] {
+	r := &liveReplay[T]{
+		events:  make([]T, 0, len(stored)+len(buffered)),
+		pending: make(map[string]bool, len(stored)),
+	}
+	for _, event := range stored {
+		r.pending[id(event)] = true
+		r.events = append(r.events, event)
+	}
+	for _, event := range buffered {
+		if !r.skip(id(event)) {
+			r.events = append(r.events, event)
+		}
+	}
+	return r
+}
+
+// skip reports whether the event was already replayed from Elasticsearch
+func (r *liveReplay[T]) skip(eventID string) bool {
+	if r == nil || !r.pending[eventID] {
+		return false
+	}
+	delete(r.pending, eventID)
+	return true
+}
+
+// storedReplayError logs why the events missed by a client could not be read, it then only
+// receives the buffered ones
+func storedReplayError(stream, project, lastEventID string, err error) {
+	log.Printf("Failed to read %s of project %s after event %s: %v", stream, project, lastEventID, err)
+}
+
+// replayLogs returns the logs matching the filter that the client missed since the position,
+// buffered and found as returned by SSELogService.RegisterLogsClient
+func replayLogs(svc services.LogServices, project string, since models.StreamPosition, buffered []models.Log, found bool, matcher serversentevents.LogMatcher) *liveReplay[models.Log] {
+	var stored []models.Log
+	if !found {
+		logs, err := svc.GetLogsAfter(project, since, maxReplayEvents)
+		if err != nil {
+			storedReplayError("logs", project, since.String(), err)
+		}
+		for _, lg := range logs {
+			if matcher == nil || matcher.Match(lg) {
+				stored = append(stored, *lg)
+			}
+		}
+	}
+	return newLiveReplay(stored, buffered, logEventID)
+}
+
+// replayMetrics returns the samples the client missed since the position
+func replayMetrics(svc *services.MetricsServices, project string, since models.StreamPosition, buffered []models.Metrics, found bool) *liveReplay[models.Metrics] {
+	var stored []models.Metrics
+	if !found {
+		metrics, err := svc.GetMetricsAfter(project, since, maxReplayEvents)
+		if err != nil {
+			storedReplayError("metrics", project, since.String(), err)
+		}
+		for _, sample := range metrics {
+			stored = append(stored, *sample)
+		}
+	}
+	return newLiveReplay(stored, buffered, metricsEventID)
+}
+
+// replayAlerts returns the alerts the client missed since lastEventID
+func replayAlerts(svc *services.AlertServices, project string, lastEventID string, buffered []serversentevents.AlertEvent, found bool) *liveReplay[serversentevents.AlertEvent] {
+	var stored []serversentevents.AlertEvent
+	if !found {
+		alerts, err := svc.GetAlertsAfter(project, lastEventID, maxReplayEvents)
+		if err != nil {
+			storedReplayError("alerts", project, lastEventID, err)
+		}
+		for i := range alerts {
+			payload, err := json.Marshal(alerts[i])
+			if err != nil {
+				log.Printf("Failed to marshal stored alert %s: %v", alerts[i].ID, err)
+				continue
+			}
+			stored = append(stored, serversentevents.AlertEvent{ID: alerts[i].EventID(), Payload: string(payload)})
+		}
+	}
+	return newLiveReplay(stored, buffered, alertEventID)
+}
+
+// validEventID checks the ID a client resumes from, logs and metrics have stream positions and
+// alerts the time they were raised followed by their ID
+func validEventID(stream string, id string) error {
+	if stream == "alerts" {
+		_, err := dto.ParseAlertEventID(id)
+		return err
+	}
+	_, err := models.ParseStreamPosition(id)
+	return err
+}
+
+// resumePosition returns the position a client resumes a log or metrics stream from, nil when
+// it starts afresh. The ID was checked by validEventID.
+func resumePosition(lastEventID string) models.StreamPosition {
+	if lastEventID == "" {
+		return nil
+	}
+	position, _ := models.ParseStreamPosition(lastEventID)
+	return position
+}
+
+// clientPosition follows the position of a client in a log or metrics stream. The ID of every
+// event sent to the client is its position once it received the event, see models.StreamPosition.
+type clientPosition struct {
+	position models.StreamPosition
+}
+
+func newClientPosition(since models.StreamPosition) *clientPosition {
+	position := models.StreamPosition{}
+	position.Merge(since)
+	return &clientPosition{position: position}
+}
+
+// next moves the position past the event and to the position of the stream once it sent the
+// event, which also covers the events the filter of the client skipped
+func (p *clientPosition) next(eventID string, stream models.StreamPosition) string {
+	if partition, offset, err := models.ParseEventID(eventID); err == nil {
+		p.position.Advance(partition, offset)
+	}
+	p.position.Merge(stream)
+	return p.position.String()
+}
+
+func logEventID(logEntry models.Log) string                 { return logEntry.EventID }
+func metricsEventID(metrics models.Metrics) string          { return metrics.EventID }
+func alertEventID(alert serversentevents.AlertEvent) string { return alert.ID }
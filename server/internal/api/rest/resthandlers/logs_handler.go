@@ -2,11 +2,21 @@ package resthandlers
 
 import (
 	"bufio"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"server/internal/api/dto"
+	"server/internal/models"
 	"server/internal/repository"
 	"server/internal/services"
+	indexstats "server/internal/services/index_stats"
+	logaggregation "server/internal/services/log_aggregation"
+	logexport "server/internal/services/log_export"
+	logquery "server/internal/services/log_query"
 	"server/internal/services/server_sent_events"
 	"server/pkg"
 	"slices"
@@ -18,95 +28,404 @@ import (
 )
 
 type LogsHandler struct {
-	svc services.LogServices
-	sse *serversentevents.SSELogService
+	svc      services.LogServices
+	sse      *serversentevents.SSELogService
+	sessions *serversentevents.Sessions
+	stats    *indexstats.IndexStats
 }
 
-func SetupLogsRoutes(r *RestHandler, l *serversentevents.SSELogService) {
+func SetupLogsRoutes(r *RestHandler, l *serversentevents.SSELogService, sessions *serversentevents.Sessions, stats *indexstats.IndexStats) {
 	app := r.App
 	svc := services.LogServices{
-		Repo:   repository.NewLogRepo(r.ElasticSearch, r.SynapseDb),
-		Config: r.Config,
+		Repo:     repository.NewLogRepo(r.ElasticSearch, r.SynapseDb),
+		Projects: repository.NewProjectRepo(r.PostgresDb),
+		Config:   r.Config,
 	}
 	handler := LogsHandler{
-		svc: svc,
-		sse: l,
+		svc:      svc,
+		sse:      l,
+		sessions: sessions,
+		stats:    stats,
 	}
+	// The log streams of every transport start from the stored position of the project
+	l.SeedPositions(svc.GetLogsPosition)
 	api := app.Group("/api/v1/logs")
 	api.Get("/:project", pkg.AuthMiddleware(), handler.GetLogs)
 	api.Get("/:project/date", pkg.AuthMiddleware(), handler.GetLogsMinMaxDates)
+	api.Get("/:project/export", pkg.AuthMiddleware(), handler.ExportLogs)
+	api.Post("/:project/aggregations", pkg.AuthMiddleware(), handler.AggregateLogs)
+	api.Get("/:project/latency", pkg.AuthMiddleware(), handler.GetRouteLatencies)
 	api.Get("/:project/archives", pkg.AuthMiddleware(), handler.ListLogsFromArchive)
 	api.Get("/:project/archive", pkg.AuthMiddleware(), handler.GetLogsFromColdStorage)
 	api.Get("/:project/stream", pkg.SSEAuthMiddleware(), handler.StreamLogs)
+	api.Get("/:project/indexing-stats", pkg.AuthMiddleware(), handler.GetIndexingStats)
+
+	traces := app.Group("/api/v1/traces")
+	traces.Get("/:id", pkg.AuthMiddleware(), handler.GetTrace)
 }
 
+// maxLogsLimit bounds the logs of a page, Elasticsearch returns at most 10000 hits per search
+const maxLogsLimit = 1000
+
 func (h *LogsHandler) GetLogs(c *fiber.Ctx) error {
 	project := c.Params("project")
-	level := c.Query("level")
 	limit, err := strconv.Atoi(c.Query("limit", "100"))
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid limit or page"})
 	}
+	if limit > maxLogsLimit {
+		return BadRequestError(c, fmt.Sprintf("limit must be at most %d", maxLogsLimit))
+	}
 	offset, err := strconv.Atoi(c.Query("page", "1"))
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid limit or page"})
 	}
-	fromStr := c.Query("from", "")
-	toStr := c.Query("to", "")
-	sortByDate := c.Query("sortByDate", "asc")
-	sortByDate = strings.ToLower(sortByDate)
+
+	if project == "" {
+		return ErrorMessage(c, fiber.StatusBadRequest, "project name is required")
+	}
+	exists, err := h.svc.CheckIfIndexExists(project)
+	if err != nil {
+		return InternalError(c, err)
+	}
+
+	if !exists {
+		return ErrorMessage(c, fiber.StatusBadRequest, "project not found or No logs found")
+	}
+
+	filter, err := logFilterFromQuery(c, project)
+	if err != nil {
+		return filterError(c, err)
+	}
+	filter.Limit = limit
+	filter.Offset = offset
+
+	// Passing cursor, empty for the first page, switches to cursor pagination
+	if c.Context().QueryArgs().Has("cursor") {
+		filter.Cursor = c.Query("cursor")
+		page, err := h.svc.GetLogsPage(filter)
+		if errors.Is(err, repository.ErrInvalidCursor) || errors.Is(err, repository.ErrCursorExpired) {
+			return BadRequestError(c, err.Error())
+		}
+		if err != nil {
+			return InternalError(c, err)
+		}
+		return SuccessResponse(c, fiber.StatusOK, "Logs retrieved successfully", page)
+	}
+
+	logs, i, err := h.svc.GetLogs(filter)
+	log.Print(logs)
+	if err != nil {
+		return err
+	}
+	return SuccessResponse(c, fiber.StatusOK, "Logs retrieved successfully", fiber.Map{"logs": logs, "total": i})
+}
+
+// logFilterFromQuery reads the log filters shared by the query and export endpoints. Pagination
+// is left to the caller.
+func logFilterFromQuery(c *fiber.Ctx, project string) (*dto.LogFilter, error) {
+	level := c.Query("level")
+	var levelEnum = []string{"info", "debug", "warn", "error", "silly", "http", "verbose", ""}
+	if ok := slices.Contains(levelEnum, level); !ok {
+		return nil, errors.New("invalid level")
+	}
+
+	sortByDate := strings.ToLower(c.Query("sortByDate", "asc"))
 	var sortEnum = []string{"asc", "desc"}
 	if ok := slices.Contains(sortEnum, sortByDate); !ok {
 		sortByDate = "asc"
 	}
-	var levelEnum = []string{"info", "debug", "warn", "error", "silly", "http", "verbose", ""}
 
-	if ok := slices.Contains(levelEnum, level); !ok {
-		return ErrorMessage(c, fiber.StatusBadRequest, "Invalid level")
+	var fromFormatted, toFormatted string
+	var err error
+	if fromStr := c.Query("from", ""); fromStr != "" {
+		fromFormatted, err = pkg.ParseFormettedTimeString(fromStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if toStr := c.Query("to", ""); toStr != "" {
+		toFormatted, err = pkg.ParseFormettedTimeString(toStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	responseStatus := c.Query("responseStatus")
+	if responseStatus != "" {
+		if _, _, err := pkg.ParseStatusRange(responseStatus); err != nil {
+			return nil, err
+		}
+	}
+	ipAddress := c.Query("ipAddress")
+	if ipAddress != "" && net.ParseIP(ipAddress) == nil {
+		if _, _, err := net.ParseCIDR(ipAddress); err != nil {
+			return nil, errors.New("invalid ipAddress, expected an IP address or CIDR block")
+		}
+	}
+
+	// attributes.<key>=<value> filters on an attribute
+	var attributes map[string]string
+	var attributeErr error
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		name, ok := strings.CutPrefix(string(key), "attributes.")
+		if !ok {
+			return
+		}
+		if !logquery.IsAttributeKey(name) {
+			attributeErr = fmt.Errorf("invalid attribute key %q", name)
+			return
+		}
+		if attributes == nil {
+			attributes = map[string]string{}
+		}
+		attributes[name] = string(value)
+	})
+	if attributeErr != nil {
+		return nil, attributeErr
 	}
 
+	query, err := parseQueryParam(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.LogFilter{
+		Project:        project,
+		Level:          level,
+		From:           fromFormatted,
+		To:             toFormatted,
+		SortByDate:     sortByDate,
+		Search:         strings.TrimSpace(c.Query("search")),
+		RequestId:      c.Query("requestId"),
+		RequestMethod:  c.Query("requestMethod"),
+		ResponseStatus: responseStatus,
+		IpAddress:      ipAddress,
+		UserAgent:      c.Query("userAgent"),
+		AppVersion:     c.Query("appVersion"),
+		Attributes:     attributes,
+		Query:          query,
+	}, nil
+}
+
+// filterError responds to an invalid filter, with the position of the error for query parse errors
+func filterError(c *fiber.Ctx, err error) error {
+	var parseErr *logquery.ParseError
+	if errors.As(err, &parseErr) {
+		return ValidationError(c, "invalid query", parseErr)
+	}
+	return BadRequestError(c, err.Error())
+}
+
+// AggregateLogs runs the date histograms, terms and percentiles described in the body over the
+// logs matching the same filters as GetLogs, e.g. errors per minute split by level:
+//
+//	{"aggs": {"perMinute": {"dateHistogram": {"interval": "1m", "timeZone": "Asia/Kolkata"},
+//		"aggs": {"byLevel": {"terms": {"field": "level"}}}}}}
+func (h *LogsHandler) AggregateLogs(c *fiber.Ctx) error {
+	project := c.Params("project")
 	if project == "" {
 		return ErrorMessage(c, fiber.StatusBadRequest, "project name is required")
 	}
+	var req dto.LogAggregationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return BadRequestError(c, "invalid aggregation payload: "+err.Error())
+	}
+
+	filter, err := logFilterFromQuery(c, project)
+	if err != nil {
+		return filterError(c, err)
+	}
+
 	exists, err := h.svc.CheckIfIndexExists(project)
 	if err != nil {
 		return InternalError(c, err)
 	}
+	if !exists {
+		return ErrorMessage(c, fiber.StatusBadRequest, "project not found or No logs found")
+	}
 
+	result, err := h.svc.AggregateLogs(filter, &req)
+	var validationErr *logaggregation.ValidationError
+	if errors.As(err, &validationErr) {
+		return ValidationError(c, "invalid aggregation", validationErr)
+	}
+	if errors.Is(err, repository.ErrTooManyBuckets) {
+		return BadRequestError(c, err.Error())
+	}
+	if err != nil {
+		return InternalError(c, err)
+	}
+	return SuccessResponse(c, fiber.StatusOK, "Logs aggregated successfully", result)
+}
+
+// GetRouteLatencies returns response time percentiles per route for the logs matching the same
+// filters as GetLogs. routes sets how many of the busiest URLs are returned.
+func (h *LogsHandler) GetRouteLatencies(c *fiber.Ctx) error {
+	project := c.Params("project")
+	if project == "" {
+		return ErrorMessage(c, fiber.StatusBadRequest, "project name is required")
+	}
+	routes, err := strconv.Atoi(c.Query("routes", "20"))
+	if err != nil || routes < 1 || routes > 100 {
+		return BadRequestError(c, "routes must be between 1 and 100")
+	}
+
+	filter, err := logFilterFromQuery(c, project)
+	if err != nil {
+		return filterError(c, err)
+	}
+
+	exists, err := h.svc.CheckIfIndexExists(project)
+	if err != nil {
+		return InternalError(c, err)
+	}
 	if !exists {
 		return ErrorMessage(c, fiber.StatusBadRequest, "project not found or No logs found")
 	}
-	var fromFormatted, toFormatted string
 
-	if fromStr != "" {
-		fromFormatted, err = pkg.ParseFormettedTimeString(fromStr)
-		if err != nil {
-			return ErrorMessage(c, fiber.StatusBadRequest, err.Error())
-		}
+	latencies, err := h.svc.GetRouteLatencies(filter, routes)
+	var validationErr *logaggregation.ValidationError
+	if errors.As(err, &validationErr) {
+		// the project has no typed response times yet
+		return BadRequestError(c, validationErr.Message)
+	}
+	if err != nil {
+		return InternalError(c, err)
 	}
+	return SuccessResponse(c, fiber.StatusOK, "Route latencies retrieved successfully", latencies)
+}
 
-	if toStr != "" {
-		toFormatted, err = pkg.ParseFormettedTimeString(fromStr)
-		if err != nil {
-			return BadRequestError(c, err.Error())
+// GetTrace returns the logs of a trace or request ID across projects, grouped by service and
+// ordered by time. projects optionally restricts the search to a comma separated list.
+func (h *LogsHandler) GetTrace(c *fiber.Ctx) error {
+	id := strings.TrimSpace(c.Params("id"))
+	if id == "" || len(id) > 256 {
+		return BadRequestError(c, "a trace or request id of at most 256 characters is required")
+	}
+	limit, err := strconv.Atoi(c.Query("limit", "1000"))
+	if err != nil || limit < 1 || limit > 10000 {
+		return BadRequestError(c, "limit must be between 1 and 10000")
+	}
+	var projects []string
+	for _, project := range strings.Split(c.Query("projects"), ",") {
+		if project = strings.TrimSpace(project); project != "" {
+			projects = append(projects, project)
 		}
 	}
 
-	filter := &dto.LogFilter{
-		Project:    project,
-		Level:      level,
-		Limit:      limit,
-		Offset:     offset,
-		From:       fromFormatted,
-		To:         toFormatted,
-		SortByDate: sortByDate,
+	trace, err := h.svc.GetTrace(id, projects, limit)
+	if errors.Is(err, services.ErrUnknownProject) {
+		return BadRequestError(c, err.Error())
 	}
-	logs, i, err := h.svc.GetLogs(filter)
-	log.Print(logs)
 	if err != nil {
-		return err
+		return InternalError(c, err)
 	}
-	return SuccessResponse(c, fiber.StatusOK, "Logs retrieved successfully", fiber.Map{"logs": logs, "total": i})
+	return SuccessResponse(c, fiber.StatusOK, "Trace retrieved successfully", trace)
+}
+
+// exportFlushRows is the number of exported rows between two flushes of the response stream
+const exportFlushRows = 1000
+
+// ExportLogs streams every log matching the filters of GetLogs as gzip-compressed NDJSON, CSV
+// or Parquet. limit caps the number of exported logs and columns selects the CSV columns.
+func (h *LogsHandler) ExportLogs(c *fiber.Ctx) error {
+	project := c.Params("project")
+	if project == "" {
+		return ErrorMessage(c, fiber.StatusBadRequest, "project name is required")
+	}
+	format := strings.ToLower(c.Query("format", logexport.FormatNDJSON))
+	columns, err := logexport.ParseColumns(c.Query("columns"))
+	if err != nil {
+		return BadRequestError(c, err.Error())
+	}
+	limit, err := strconv.Atoi(c.Query("limit", "0"))
+	if err != nil || limit < 0 {
+		return BadRequestError(c, "invalid limit")
+	}
+
+	filter, err := logFilterFromQuery(c, project)
+	if err != nil {
+		return filterError(c, err)
+	}
+	filter.Limit = limit
+
+	exists, err := h.svc.CheckIfIndexExists(project)
+	if err != nil {
+		return InternalError(c, err)
+	}
+	if !exists {
+		return ErrorMessage(c, fiber.StatusBadRequest, "project not found or No logs found")
+	}
+	// Reject unknown formats before the response is committed
+	if _, err := logexport.NewWriter(format, columns, io.Discard); err != nil {
+		return BadRequestError(c, err.Error())
+	}
+
+	fileName := fmt.Sprintf("%s-logs-%s.%s", project, time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Set("Content-Type", logexport.ContentType(format))
+	c.Set("Content-Encoding", "gzip")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+
+	ctx := c.UserContext()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		gz := gzip.NewWriter(w)
+		writer, _ := logexport.NewWriter(format, columns, gz)
+
+		count := 0
+		err := h.svc.ExportLogs(ctx, filter, func(lg *models.Log) error {
+			if err := writer.Write(lg); err != nil {
+				return err
+			}
+			count++
+			// Flushing regularly sends the rows as they are read and notices a client that went away
+			if count%exportFlushRows == 0 {
+				if err := gz.Flush(); err != nil {
+					return err
+				}
+				return w.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			// Leave the file and the gzip stream unfinished, a truncated export must not look
+			// complete to the client. When the client is gone there is nobody to finish it for.
+			log.Printf("Export of %s logs aborted after %d rows: %v", project, count, err)
+			return
+		}
+		if err := writer.Close(); err != nil {
+			log.Printf("Failed to finish export of %s logs: %v", project, err)
+		}
+		if err := gz.Close(); err != nil {
+			log.Printf("Failed to finish export of %s logs: %v", project, err)
+		}
+		if err := w.Flush(); err != nil {
+			log.Printf("Failed to flush export of %s logs: %v", project, err)
+		}
+	})
+	return nil
+}
+
+// parseQueryParam parses the optional q parameter, e.g. level:error AND responseTime>500.
+// Parse errors are returned as *logquery.ParseError with the column they occurred at.
+func parseQueryParam(c *fiber.Ctx) (*logquery.Query, error) {
+	q := c.Query("q")
+	if strings.TrimSpace(q) == "" {
+		return nil, nil
+	}
+	return logquery.Parse(q)
+}
+
+// GetIndexingStats returns the indexed, retried and rejected document counts of the project since the server started.
+func (h *LogsHandler) GetIndexingStats(c *fiber.Ctx) error {
+	project := c.Params("project")
+	if project == "" {
+		return ErrorMessage(c, fiber.StatusBadRequest, "Project name is required")
+	}
+	return SuccessResponse(c, fiber.StatusOK, "Indexing stats retrieved successfully", fiber.Map{
+		"logs":    h.stats.Get("logs", project),
+		"metrics": h.stats.Get("metrics", project),
+	})
 }
 
 func (h *LogsHandler) GetLogsMinMaxDates(c *fiber.Ctx) error {
@@ -216,6 +535,63 @@ func (h *LogsHandler) GetLogsFromColdStorage(c *fiber.Ctx) error {
 	})
 }
 
+// streamFilterFromQuery reads the filters of a live stream client: levels=error,warn, search for
+// text in the message or stack, urlPrefix, statusClass=4xx,5xx and the q parameter
+func streamFilterFromQuery(c *fiber.Ctx) (*serversentevents.StreamFilter, error) {
+	return newStreamFilter(&dto.StreamFilter{
+		Levels:        strings.Split(c.Query("levels"), ","),
+		Search:        c.Query("search"),
+		UrlPrefix:     c.Query("urlPrefix"),
+		StatusClasses: strings.Split(c.Query("statusClass"), ","),
+		Query:         c.Query("q"),
+	})
+}
+
+// newStreamFilter validates the filters of a live stream client, shared by the SSE and
+// WebSocket streams
+func newStreamFilter(f *dto.StreamFilter) (*serversentevents.StreamFilter, error) {
+	filter := &serversentevents.StreamFilter{
+		Text:      strings.TrimSpace(f.Search),
+		UrlPrefix: strings.TrimSpace(f.UrlPrefix),
+	}
+
+	var levelEnum = []string{"info", "debug", "warn", "error", "silly", "http", "verbose"}
+	for _, level := range f.Levels {
+		level = strings.ToLower(strings.TrimSpace(level))
+		if level == "" {
+			continue
+		}
+		if !slices.Contains(levelEnum, level) {
+			return nil, fmt.Errorf("invalid level %q", level)
+		}
+		filter.Levels = append(filter.Levels, level)
+	}
+
+	for _, class := range f.StatusClasses {
+		class = strings.ToLower(strings.TrimSpace(class))
+		if class == "" {
+			continue
+		}
+		if len(class) != 3 || !strings.HasSuffix(class, "xx") || class[0] < '1' || class[0] > '5' {
+			return nil, fmt.Errorf("invalid status class %q, expected 1xx to 5xx", class)
+		}
+		filter.StatusClasses = append(filter.StatusClasses, int(class[0]-'0'))
+	}
+
+	if strings.TrimSpace(f.Query) != "" {
+		query, err := logquery.Parse(f.Query)
+		if err != nil {
+			return nil, err
+		}
+		filter.Query = query
+	}
+	return filter, nil
+}
+
+// StreamLogs streams the logs of the project as they are consumed. Each client may filter the
+// stream, logs that do not match are never written to it, see streamFilterFromQuery. Each log
+// carries the stream position as its event ID, a client reconnecting with Last-Event-ID first
+// receives what it missed.
 func (h *LogsHandler) StreamLogs(c *fiber.Ctx) error {
 	project := c.Params("project")
 	if project == "" {
@@ -226,8 +602,29 @@ func (h *LogsHandler) StreamLogs(c *fiber.Ctx) error {
 		return ErrorMessage(c, fiber.StatusNotFound, "project not found")
 	}
 
+	filter, err := streamFilterFromQuery(c)
+	if err != nil {
+		return filterError(c, err)
+	}
+	var matcher serversentevents.LogMatcher
+	if !filter.IsEmpty() {
+		matcher = filter
+	}
+	lastID := lastEventID(c)
+	if lastID != "" {
+		if err := validEventID("logs", lastID); err != nil {
+			return BadRequestError(c, "invalid Last-Event-ID: "+err.Error())
+		}
+	}
+
+	since := resumePosition(lastID)
+
 	user := c.Locals("user").(*pkg.UserClaims)
-	clientID := user.UniqueName
+	session, err := h.sessions.Open(user.UniqueName, "sse")
+	if err != nil {
+		return sessionError(c, err)
+	}
+	clientID := session.ID
 
 	c.Set("Content-Type", "text/event-stream")
 	c.Set("Cache-Control", "no-cache")
@@ -235,14 +632,15 @@ func (h *LogsHandler) StreamLogs(c *fiber.Ctx) error {
 	c.Set("Access-Control-Allow-Origin", "*")
 	c.Set("Transfer-Encoding", "chunked")
 
-	// Register client
-	h.sse.RegisterLogsClient(clientID, project)
+	// Register client, the logs it missed since its last event are replayed before the live ones
+	buffered, found := h.sse.RegisterLogsClient(clientID, session, project, matcher, since)
 
 	// Get a client channel
 	clientChan, ok := h.sse.GetLogClientChannel(clientID)
 	if !ok {
 		log.Printf("No client channel found for ClientID: %s", clientID)
 		h.sse.UnregisterLogsClient(clientID)
+		h.sessions.Close(session)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get client channel"})
 	}
 
@@ -251,6 +649,7 @@ func (h *LogsHandler) StreamLogs(c *fiber.Ctx) error {
 		defer func() {
 			log.Printf("Exiting stream writer for client: %s", clientID)
 			h.sse.UnregisterLogsClient(clientID)
+			h.sessions.Close(session)
 		}()
 
 		// Send initial connection confirmation
@@ -264,6 +663,28 @@ func (h *LogsHandler) StreamLogs(c *fiber.Ctx) error {
 			return
 		}
 
+		// The ID of each event is the position the client resumes from once it received it
+		position := newClientPosition(since)
+		var replay *liveReplay[models.Log]
+		if lastID != "" {
+			replay = replayLogs(h.svc, project, since, buffered, found, matcher)
+			for _, logEntry := range replay.events {
+				data, err := json.Marshal(logEntry)
+				if err != nil {
+					log.Printf("Failed to marshal log entry: %v", err)
+					continue
+				}
+				if _, err := w.WriteString(sseEvent(position.next(logEntry.EventID, logEntry.Position), data)); err != nil {
+					log.Printf("Error writing to client %s: %v", clientID, err)
+					return
+				}
+			}
+			if err := w.Flush(); err != nil {
+				log.Printf("Error flushing buffer for client %s: %v", clientID, err)
+				return
+			}
+		}
+
 		heartbeatTicker := time.NewTicker(30 * time.Second)
 		defer heartbeatTicker.Stop()
 
@@ -302,6 +723,9 @@ func (h *LogsHandler) StreamLogs(c *fiber.Ctx) error {
 					log.Printf("Client channel closed for %s", clientID)
 					return
 				}
+				if replay.skip(logEntry.EventID) {
+					continue
+				}
 
 				data, err := json.Marshal(logEntry)
 				if err != nil {
@@ -309,7 +733,7 @@ func (h *LogsHandler) StreamLogs(c *fiber.Ctx) error {
 					continue
 				}
 
-				_, err = w.WriteString("data: " + string(data) + "\n\n")
+				_, err = w.WriteString(sseEvent(position.next(logEntry.EventID, logEntry.Position), data))
 				if err != nil {
 					log.Printf("Error writing to client %s: %v", clientID, err)
 					return
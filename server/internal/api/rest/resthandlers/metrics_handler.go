@@ -3,38 +3,50 @@ package resthandlers
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"log"
+	"server/internal/api/dto"
+	"server/internal/models"
 	"server/internal/repository"
 	"server/internal/services"
+	"server/internal/services/promql"
 	serversentevents "server/internal/services/server_sent_events"
 	"server/pkg"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type MetricsHandler struct {
-	sse *serversentevents.SSEMetricsService
-	svc *services.MetricsServices
+	sse      *serversentevents.SSEMetricsService
+	sessions *serversentevents.Sessions
+	svc      *services.MetricsServices
 }
 
-func SetupMetricsHandler(r *RestHandler, l *serversentevents.SSEMetricsService) {
+func SetupMetricsHandler(r *RestHandler, l *serversentevents.SSEMetricsService, sessions *serversentevents.Sessions) {
 	app := r.App
 	svc := services.MetricsServices{
 		Repo:       repository.NewMetricsRepo(r.ElasticSearch),
 		ProjetRepo: repository.NewProjectRepo(r.PostgresDb),
 	}
 	handler := MetricsHandler{
-		sse: l,
-		svc: &svc,
+		sse:      l,
+		sessions: sessions,
+		svc:      &svc,
 	}
+	// The metrics streams of every transport start from the stored position of the project
+	l.SeedPositions(svc.GetMetricsPosition)
 	api := app.Group("/api/v1/metrics/")
 
 	api.Get("/:project/stream", pkg.SSEAuthMiddleware(), handler.StreamMetrics)
 	api.Get("/:project/cpu", pkg.AuthMiddleware(), handler.GetCpuUsage)
 	api.Get("/:project/memory", pkg.AuthMiddleware(), handler.Getmemoryusage)
 	api.Get("/:project/date", pkg.AuthMiddleware(), handler.GetMetricsMinMaxDates)
+	api.Get("/:project/names", pkg.AuthMiddleware(), handler.GetMetricNames)
+	api.Get("/:project/query", pkg.AuthMiddleware(), handler.QueryMetric)
+	api.Get("/:project/promql", pkg.AuthMiddleware(), handler.QueryPromQL)
 
 }
 
@@ -51,8 +63,21 @@ func (h *MetricsHandler) StreamMetrics(c *fiber.Ctx) error {
 	if !projectExists {
 		return ErrorMessage(c, fiber.StatusNotFound, "Project not found")
 	}
+	lastID := lastEventID(c)
+	if lastID != "" {
+		if err := validEventID("metrics", lastID); err != nil {
+			return BadRequestError(c, "invalid Last-Event-ID: "+err.Error())
+		}
+	}
+
+	since := resumePosition(lastID)
+
 	user := c.Locals("user").(*pkg.UserClaims)
-	clientID := user.UniqueName
+	session, err := h.sessions.Open(user.UniqueName, "sse")
+	if err != nil {
+		return sessionError(c, err)
+	}
+	clientID := session.ID
 
 	c.Set("Content-Type", "text/event-stream")
 	c.Set("Cache-Control", "no-cache")
@@ -60,14 +85,15 @@ func (h *MetricsHandler) StreamMetrics(c *fiber.Ctx) error {
 	c.Set("Access-Control-Allow-Origin", "*")
 	c.Set("Transfer-Encoding", "chunked")
 
-	// Register client
-	h.sse.RegisterMetricsClient(clientID, project)
+	// Register client, the samples it missed since its last event are replayed before the live ones
+	buffered, found := h.sse.RegisterMetricsClient(clientID, session, project, since)
 
 	// Get a client channel
 	clientChan, ok := h.sse.GetMetricsClientChannel(clientID)
 	if !ok {
 		log.Printf("No client channel found for ClientID: %s", clientID)
 		h.sse.UnRegisterMetricsClient(clientID)
+		h.sessions.Close(session)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get client channel"})
 	}
 
@@ -76,6 +102,7 @@ func (h *MetricsHandler) StreamMetrics(c *fiber.Ctx) error {
 		defer func() {
 			log.Printf("Exiting stream writer for client: %s", clientID)
 			h.sse.UnRegisterMetricsClient(clientID)
+			h.sessions.Close(session)
 		}()
 
 		// Send initial connection confirmation
@@ -89,6 +116,28 @@ func (h *MetricsHandler) StreamMetrics(c *fiber.Ctx) error {
 			return
 		}
 
+		// The ID of each event is the position the client resumes from once it received it
+		position := newClientPosition(since)
+		var replay *liveReplay[models.Metrics]
+		if lastID != "" {
+			replay = replayMetrics(h.svc, project, since, buffered, found)
+			for _, sample := range replay.events {
+				data, err := json.Marshal(sample)
+				if err != nil {
+					log.Printf("Failed to marshal log entry: %v", err)
+					continue
+				}
+				if _, err := w.WriteString(sseEvent(position.next(sample.EventID, sample.Position), data)); err != nil {
+					log.Printf("Error writing to client %s: %v", clientID, err)
+					return
+				}
+			}
+			if err := w.Flush(); err != nil {
+				log.Printf("Error flushing buffer for client %s: %v", clientID, err)
+				return
+			}
+		}
+
 		heartbeatTicker := time.NewTicker(30 * time.Second)
 		defer heartbeatTicker.Stop()
 
@@ -127,6 +176,9 @@ func (h *MetricsHandler) StreamMetrics(c *fiber.Ctx) error {
 					log.Printf("Client channel closed for %s", clientID)
 					return
 				}
+				if replay.skip(metrics.EventID) {
+					continue
+				}
 
 				data, err := json.Marshal(metrics)
 				if err != nil {
@@ -134,7 +186,7 @@ func (h *MetricsHandler) StreamMetrics(c *fiber.Ctx) error {
 					continue
 				}
 
-				_, err = w.WriteString("data: " + string(data) + "\n\n")
+				_, err = w.WriteString(sseEvent(position.next(metrics.EventID, metrics.Position), data))
 				if err != nil {
 					log.Printf("Error writing to client %s: %v", clientID, err)
 					return
@@ -255,3 +307,137 @@ func (h *MetricsHandler) GetMetricsMinMaxDates(c *fiber.Ctx) error {
 	}
 	return SuccessResponse(c, fiber.StatusOK, "Metrics dates retrieved successfully", dates)
 }
+
+func (h *MetricsHandler) GetMetricNames(c *fiber.Ctx) error {
+	project := c.Params("project")
+	if project == "" {
+		return ErrorMessage(c, fiber.StatusBadRequest, "Project name is required")
+	}
+	exists, err := h.svc.CheckIfProjectExists(project)
+	if err != nil {
+		return InternalError(c, err)
+	}
+	if !exists {
+		return ErrorMessage(c, fiber.StatusNotFound, "Project not found")
+	}
+	names, err := h.svc.GetMetricNames(project)
+	if err != nil {
+		return InternalError(c, err)
+	}
+	return SuccessResponse(c, fiber.StatusOK, "success", names)
+}
+
+// QueryMetric aggregates a generic metric over time. Points are selected with name and
+// labels.<key>=<value> query args, aggregated per interval with agg (avg, sum, min, max,
+// count or a percentile like p95) and split into series by the comma separated groupBy labels.
+func (h *MetricsHandler) QueryMetric(c *fiber.Ctx) error {
+	project := c.Params("project")
+	if project == "" {
+		return ErrorMessage(c, fiber.StatusBadRequest, "Project name is required")
+	}
+	exists, err := h.svc.CheckIfProjectExists(project)
+	if err != nil {
+		return InternalError(c, err)
+	}
+	if !exists {
+		return ErrorMessage(c, fiber.StatusNotFound, "Project not found")
+	}
+
+	query := &dto.MetricQuery{
+		Project:     project,
+		Name:        c.Query("name"),
+		Aggregation: c.Query("agg"),
+		Interval:    c.Query("interval", "1m"),
+		TimeZone:    c.Query("timeZone"),
+		Labels:      map[string]string{},
+	}
+	if from := c.Query("from"); from != "" {
+		query.From, err = strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			return BadRequestError(c, "invalid from date")
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		query.To, err = strconv.ParseInt(to, 10, 64)
+		if err != nil {
+			return BadRequestError(c, "invalid to date")
+		}
+	}
+	if groupBy := c.Query("groupBy"); groupBy != "" {
+		for _, key := range strings.Split(groupBy, ",") {
+			query.GroupBy = append(query.GroupBy, strings.TrimSpace(key))
+		}
+	}
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		if label, ok := strings.CutPrefix(string(key), "labels."); ok {
+			query.Labels[label] = string(value)
+		}
+	})
+
+	series, err := h.svc.QueryMetric(query)
+	if errors.Is(err, services.ErrInvalidMetricQuery) {
+		return BadRequestError(c, err.Error())
+	}
+	if errors.Is(err, services.ErrMetricNotFound) {
+		return ErrorMessage(c, fiber.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return InternalError(c, err)
+	}
+	return SuccessResponse(c, fiber.StatusOK, "success", series)
+}
+
+// QueryPromQL evaluates a PromQL query, see promql.Parse for the supported subset. start and
+// end are epoch milliseconds and step a duration like 1m. Without start the query is evaluated
+// once, at end or now.
+func (h *MetricsHandler) QueryPromQL(c *fiber.Ctx) error {
+	project := c.Params("project")
+	if project == "" {
+		return ErrorMessage(c, fiber.StatusBadRequest, "Project name is required")
+	}
+	exists, err := h.svc.CheckIfProjectExists(project)
+	if err != nil {
+		return InternalError(c, err)
+	}
+	if !exists {
+		return ErrorMessage(c, fiber.StatusNotFound, "Project not found")
+	}
+
+	query := c.Query("query")
+	if query == "" {
+		return BadRequestError(c, "query is required")
+	}
+	end := time.Now()
+	if to := c.Query("end"); to != "" {
+		millis, err := strconv.ParseInt(to, 10, 64)
+		if err != nil {
+			return BadRequestError(c, "invalid end date")
+		}
+		end = time.UnixMilli(millis)
+	}
+	start := end
+	if from := c.Query("start"); from != "" {
+		millis, err := strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			return BadRequestError(c, "invalid start date")
+		}
+		start = time.UnixMilli(millis)
+	}
+	step, err := promql.ParseDuration(c.Query("step", "1m"))
+	if err != nil {
+		return BadRequestError(c, "invalid step, expected a duration like 15s, 1m or 1h")
+	}
+
+	series, err := h.svc.QueryPromQL(project, query, start, end, step)
+	var parseErr *promql.ParseError
+	if errors.As(err, &parseErr) {
+		return ValidationError(c, "invalid query", parseErr)
+	}
+	if errors.Is(err, services.ErrInvalidMetricQuery) || errors.Is(err, repository.ErrTooManyMetricPoints) {
+		return BadRequestError(c, err.Error())
+	}
+	if err != nil {
+		return InternalError(c, err)
+	}
+	return SuccessResponse(c, fiber.StatusOK, "success", series)
+}
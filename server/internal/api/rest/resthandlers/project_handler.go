@@ -3,6 +3,7 @@ package resthandlers
 import (
 	"errors"
 	"log"
+	"server/config"
 	"server/internal/api/dto"
 	"server/internal/models"
 	"server/internal/repository"
@@ -25,6 +26,7 @@ func SetupProjectRoutes(r *RestHandler) {
 		Repo:   repository.NewProjectRepo(r.PostgresDb),
 		Config: r.Config,
 		Ktm:    r.Ktm,
+		Lim:    config.NewLogIndexManager(r.ElasticSearch),
 	}
 	handler := ProjectHandler{
 		svc: svc,
@@ -184,6 +186,11 @@ func (h *ProjectHandler) UpdateProject(c *fiber.Ctx) error {
 	if err := c.BodyParser(&project); err != nil {
 		return BadRequestError(c, err.Error())
 	}
+	if project.RetentionPeriod != "" {
+		if _, err := config.ParseRetentionPeriod(project.RetentionPeriod); err != nil {
+			return BadRequestError(c, err.Error())
+		}
+	}
 
 	updatedProject, err := h.svc.UpdateProject(&models.Project{
 		Name:            projectName,
@@ -23,3 +23,11 @@ func BadRequestError(ctx *fiber.Ctx, msg string) error {
 func SuccessResponse(ctx *fiber.Ctx, status int, message string, data interface{}) error {
 	return ctx.Status(status).JSON(&fiber.Map{"message": message, "data": data})
 }
+
+// ValidationError sends a 400 Bad Request response with the given message and structured details of what is invalid.
+func ValidationError(ctx *fiber.Ctx, msg string, details interface{}) error {
+	return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"message": msg,
+		"error":   details,
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"server/internal/services/server_sent_events"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,22 +19,29 @@ import (
 
 	"server/config"
 	"server/internal/models"
+	indexstats "server/internal/services/index_stats"
 	protogen "server/internal/services/proto/logs"
+	"server/pkg"
 )
 
 type LogProcessor interface {
-	ProcessLog(logMessage *protogen.Log, topic string, partition int32, offset int64) error
+	// ProcessLog buffers the log for indexing and calls done once it is indexed, or with the
+	// reason Elasticsearch permanently rejected it. An error is returned only when the log
+	// cannot be processed at all.
+	ProcessLog(ctx context.Context, logMessage *protogen.Log, topic string, partition int32, offset int64, done func(err error)) error
 }
 
 type ConsumerGroupHandler struct {
 	deserializer *config.ProtobufDeserializer
 	processor    LogProcessor
+	dlq          *config.DeadLetterQueue
 }
 
-func NewConsumerGroupHandler(deserializer *config.ProtobufDeserializer, processor LogProcessor) *ConsumerGroupHandler {
+func NewConsumerGroupHandler(deserializer *config.ProtobufDeserializer, processor LogProcessor, dlq *config.DeadLetterQueue) *ConsumerGroupHandler {
 	return &ConsumerGroupHandler{
 		deserializer: deserializer,
 		processor:    processor,
+		dlq:          dlq,
 	}
 }
 
@@ -50,6 +58,12 @@ func (h *ConsumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
 func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	log.Printf("Starting to consume from topic: %s, partition: %d", claim.Topic(), claim.Partition())
 
+	// Offsets are marked in order once each message is indexed or dead lettered
+	tracker := config.NewOffsetTracker(session)
+	// failed receives a message that could not be dead lettered after its batch was indexed.
+	// Returning ends the session without marking it, so it is redelivered.
+	failed := make(chan error, 1)
+
 	// Process messages
 	for {
 		select {
@@ -65,26 +79,45 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 
 			log.Printf("Received message from topic: %s, partition: %d, offset: %d",
 				message.Topic, message.Partition, message.Offset)
+			complete := tracker.Track(message)
+			// Documents rejected by Elasticsearch are dead lettered before their offset is marked
+			done := func(err error) {
+				if err != nil {
+					if err := h.deadLetter(message, err); err != nil {
+						select {
+						case failed <- err:
+						default:
+						}
+						return
+					}
+				}
+				complete()
+			}
 
 			// DeserializeLogs the message
 			logMessage, err := h.deserializer.DeserializeLogs(message.Value)
 			if err != nil {
 				log.Printf("Failed to deserialize message: %v", err)
-				session.MarkMessage(message, "")
+				if err := h.deadLetter(message, err); err != nil {
+					return err
+				}
+				complete()
 				continue
 			}
 
-			// Process the log message
-			err = h.processor.ProcessLog(logMessage, message.Topic, message.Partition, message.Offset)
+			// Process the log message, it is marked once its batch is indexed
+			err = h.processor.ProcessLog(session.Context(), logMessage, message.Topic, message.Partition, message.Offset, done)
 			if err != nil {
 				log.Printf("Failed to process log message: %v", err)
-				session.MarkMessage(message, "")
+				if err := h.deadLetter(message, err); err != nil {
+					return err
+				}
+				complete()
 				continue
 			}
 
-			session.MarkMessage(message, "")
-			log.Printf("Successfully processed message from topic: %s, offset: %d",
-				message.Topic, message.Offset)
+		case err := <-failed:
+			return err
 
 		case <-session.Context().Done():
 			log.Println("Consumer session context cancelled")
@@ -93,6 +126,19 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 	}
 }
 
+// deadLetter parks a failed message in its dead letter topic. If the dead letter topic is
+// unreachable the claim stops without marking, so the message is redelivered.
+func (h *ConsumerGroupHandler) deadLetter(message *sarama.ConsumerMessage, cause error) error {
+	if h.dlq == nil {
+		return nil
+	}
+	if err := h.dlq.Send(message, cause); err != nil {
+		log.Printf("Failed to dead letter message: %v", err)
+		return err
+	}
+	return nil
+}
+
 // DefaultLogProcessor implements basic log processing
 type DefaultLogProcessor struct {
 	es             *elasticsearch.Client
@@ -100,33 +146,45 @@ type DefaultLogProcessor struct {
 	batchSize      int
 	flushInterval  time.Duration
 	mutex          sync.RWMutex
-	logSSE         *serversentevents.SSELogService
+	live           serversentevents.LogBroadcaster
+	stats          *indexstats.IndexStats
+	ctx            context.Context // cancelled on Close to stop timer flush retries
+	cancel         context.CancelFunc
 }
 
 type ServiceBatch struct {
 	serviceName string
 	buffer      []LogDocument
+	acks        []func(err error)
 	flushTimer  *time.Timer
 	mutex       sync.Mutex
+	flushMutex  sync.Mutex
 }
 
 type LogDocument struct {
-	ServiceName    string        `json:"serviceName"`
-	BuildDetails   *BuildDetails `json:"buildDetails,omitempty"`
-	Level          string        `json:"level"`
-	Message        string        `json:"message"`
-	Stack          string        `json:"stack,omitempty"`
-	RequestId      string        `json:"requestId,omitempty"`
-	RequestUrl     string        `json:"requestUrl,omitempty"`
-	RequestMethod  string        `json:"requestMethod,omitempty"`
-	UserAgent      string        `json:"userAgent,omitempty"`
-	RemoteIp       string        `json:"ipAddress,omitempty"`
-	ResponseStatus string        `json:"responseStatus,omitempty"`
-	ResponseTime   string        `json:"responseTime,omitempty"`
-	Timestamp      time.Time     `json:"timestamp"`
-	Topic          string        `json:"topic"`
-	Partition      int32         `json:"partition"`
-	Offset         int64         `json:"offset"`
+	ServiceName    string                 `json:"serviceName"`
+	BuildDetails   *BuildDetails          `json:"buildDetails,omitempty"`
+	Level          string                 `json:"level"`
+	Message        string                 `json:"message"`
+	Stack          string                 `json:"stack,omitempty"`
+	RequestId      string                 `json:"requestId,omitempty"`
+	RequestUrl     string                 `json:"requestUrl,omitempty"`
+	RequestMethod  string                 `json:"requestMethod,omitempty"`
+	UserAgent      string                 `json:"userAgent,omitempty"`
+	RemoteIp       string                 `json:"ipAddress,omitempty"`
+	ResponseStatus string                 `json:"responseStatus,omitempty"`
+	ResponseTime   string                 `json:"responseTime,omitempty"`
+	ResponseTimeMs *float64               `json:"response_time_ms,omitempty"`
+	StatusCode     *int                   `json:"status_code,omitempty"`
+	Attributes     map[string]string      `json:"attributes,omitempty"`
+	TypedAttrs     map[string]interface{} `json:"typed_attributes,omitempty"`
+	TraceId        string                 `json:"traceId,omitempty"`
+	SpanId         string                 `json:"spanId,omitempty"`
+	ParentSpanId   string                 `json:"parentSpanId,omitempty"`
+	Timestamp      time.Time              `json:"timestamp"`
+	Topic          string                 `json:"topic"`
+	Partition      int32                  `json:"partition"`
+	Offset         int64                  `json:"offset"`
 }
 
 type BuildDetails struct {
@@ -134,17 +192,21 @@ type BuildDetails struct {
 	AppVersion  string `json:"appVersion"`
 }
 
-func NewDefaultLogProcessor(es *elasticsearch.Client, l *serversentevents.SSELogService) *DefaultLogProcessor {
+func NewDefaultLogProcessor(es *elasticsearch.Client, l serversentevents.LogBroadcaster, stats *indexstats.IndexStats) *DefaultLogProcessor {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &DefaultLogProcessor{
 		es:             es,
 		serviceBatches: make(map[string]*ServiceBatch),
 		batchSize:      1000,
 		flushInterval:  5 * time.Second,
-		logSSE:         l,
+		live:           l,
+		stats:          stats,
+		ctx:            ctx,
+		cancel:         cancel,
 	}
 }
 
-func (p *DefaultLogProcessor) ProcessLog(logMessage *protogen.Log, topic string, partition int32, offset int64) error {
+func (p *DefaultLogProcessor) ProcessLog(ctx context.Context, logMessage *protogen.Log, topic string, partition int32, offset int64, done func(err error)) error {
 	serviceName := logMessage.GetServiceName()
 	if serviceName == "" {
 		return fmt.Errorf("service name is required")
@@ -175,19 +237,61 @@ func (p *DefaultLogProcessor) ProcessLog(logMessage *protogen.Log, topic string,
 		Timestamp:      logMessage.GetTimestamp().AsTime(),
 		ResponseStatus: logMessage.GetResponseStatus(),
 		ResponseTime:   logMessage.GetResponseTime(),
+		ResponseTimeMs: responseTimeMs(logMessage),
+		StatusCode:     statusCode(logMessage),
+		Attributes:     logMessage.GetAttributes(),
+		TraceId:        strings.ToLower(logMessage.GetTraceId()),
+		SpanId:         strings.ToLower(logMessage.GetSpanId()),
+		ParentSpanId:   strings.ToLower(logMessage.GetParentSpanId()),
 		Topic:          topic,
 		Partition:      partition,
 		Offset:         offset,
 	}
-	if client, ok := p.logSSE.GetLogClientChannel(serviceName); ok {
-		log.Printf("Client channel found for service: %v", client)
+	if typed := logMessage.GetTypedAttributes(); typed != nil {
+		doc.TypedAttrs = typed.AsMap()
 	}
-	if len(p.logSSE.Clients) > 0 {
-		logForBroadcast := toLogModel(doc)
-		p.logSSE.BroadcastLogs(serviceName, logForBroadcast)
+	// Keep the strings filled for clients that only read them
+	if doc.ResponseStatus == "" && doc.StatusCode != nil {
+		doc.ResponseStatus = strconv.Itoa(*doc.StatusCode)
 	}
-	return batch.addDocument(doc, p.batchSize, p.flushInterval, p.es)
+	if doc.ResponseTime == "" && doc.ResponseTimeMs != nil {
+		doc.ResponseTime = strconv.FormatFloat(*doc.ResponseTimeMs, 'f', -1, 64) + " ms"
+	}
+	p.live.BroadcastLogs(serviceName, toLogModel(doc))
+	batch.addDocument(ctx, p.ctx, doc, done, p.batchSize, p.flushInterval, p.es, p.stats)
+	return nil
 }
+
+// responseTimeMs returns the typed response time, or parses the string sent by older clients.
+// Those send "0" for logs outside a request, so the string is only used for logs of a request.
+func responseTimeMs(logMessage *protogen.Log) *float64 {
+	if logMessage.ResponseTimeMs != nil {
+		ms := logMessage.GetResponseTimeMs()
+		return &ms
+	}
+	if logMessage.GetResponseTime() == "" || logMessage.GetRequestUrl() == "" {
+		return nil
+	}
+	ms, err := pkg.ParseResponseTime(logMessage.GetResponseTime())
+	if err != nil {
+		return nil
+	}
+	return &ms
+}
+
+// statusCode returns the typed status code, or parses the string sent by older clients
+func statusCode(logMessage *protogen.Log) *int {
+	if logMessage.StatusCode != nil {
+		code := int(logMessage.GetStatusCode())
+		return &code
+	}
+	code, err := pkg.ParseStatusCode(logMessage.GetResponseStatus())
+	if err != nil {
+		return nil
+	}
+	return &code
+}
+
 func (p *DefaultLogProcessor) getOrCreateServiceBatch(serviceName string) *ServiceBatch {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
@@ -204,43 +308,129 @@ func (p *DefaultLogProcessor) getOrCreateServiceBatch(serviceName string) *Servi
 	return batch
 }
 
-func (sb *ServiceBatch) addDocument(doc LogDocument, batchSize int, flushInterval time.Duration, client *elasticsearch.Client) error {
+// addDocument buffers the document and flushes once the batch is full, blocking the caller
+// until the batch is indexed. Partial batches are flushed by a timer after flushInterval.
+func (sb *ServiceBatch) addDocument(ctx context.Context, timerCtx context.Context, doc LogDocument, done func(err error), batchSize int, flushInterval time.Duration, client *elasticsearch.Client, stats *indexstats.IndexStats) {
 	sb.mutex.Lock()
-	defer sb.mutex.Unlock()
 
 	// Add to buffer
 	sb.buffer = append(sb.buffer, doc)
-	// Reset flush timer
+	sb.acks = append(sb.acks, done)
+
+	if len(sb.buffer) < batchSize {
+		if sb.flushTimer == nil {
+			sb.flushTimer = time.AfterFunc(flushInterval, func() {
+				_ = sb.flushBatch(timerCtx, client, stats)
+			})
+		}
+		sb.mutex.Unlock()
+		return
+	}
+
+	// Flush if the batch is full
+	docs, acks := sb.takeBuffer()
+	sb.mutex.Unlock()
+	_ = sb.flushDocuments(ctx, client, stats, docs, acks)
+}
+
+// takeBuffer hands the buffered documents over to a flush. The caller must hold sb.mutex.
+func (sb *ServiceBatch) takeBuffer() ([]LogDocument, []func(err error)) {
 	if sb.flushTimer != nil {
 		sb.flushTimer.Stop()
+		sb.flushTimer = nil
 	}
-	sb.flushTimer = time.AfterFunc(flushInterval, func() {
-		err := sb.flushBatch(client)
+	docs, acks := sb.buffer, sb.acks
+	sb.buffer = make([]LogDocument, 0, cap(docs))
+	sb.acks = make([]func(err error), 0, cap(acks))
+	return docs, acks
+}
+
+func (sb *ServiceBatch) flushBatch(ctx context.Context, client *elasticsearch.Client, stats *indexstats.IndexStats) error {
+	sb.mutex.Lock()
+	docs, acks := sb.takeBuffer()
+	sb.mutex.Unlock()
+	return sb.flushDocuments(ctx, client, stats, docs, acks)
+}
+
+// flushDocuments indexes the documents and resolves each one from its bulk item. Items failing
+// with 429 or 5xx, or the whole batch when the request fails, are retried with backoff until
+// ctx is done; other failures are permanent rejections handed back through the ack. Documents
+// given up on stay unacknowledged, so Kafka redelivers them.
+func (sb *ServiceBatch) flushDocuments(ctx context.Context, client *elasticsearch.Client, stats *indexstats.IndexStats, docs []LogDocument, acks []func(err error)) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	sb.flushMutex.Lock()
+	defer sb.flushMutex.Unlock()
+
+	err := pkg.RetryWithBackoff(ctx, time.Second, 30*time.Second, func() error {
+		items, err := sb.bulkIndex(client, docs)
 		if err != nil {
-			return
+			log.Printf("Failed to index %d logs for service %s, retrying: %v", len(docs), sb.serviceName, err)
+			stats.Add("logs", sb.serviceName, 0, int64(len(docs)), 0)
+			return err
 		}
-	})
 
-	// Flush if the batch is full
-	if len(sb.buffer) >= batchSize {
-		return sb.flushBatch(client)
-	}
+		var retryDocs []LogDocument
+		var retryAcks []func(err error)
+		var indexed, rejected int64
+		var lastErr string
+		for i, item := range items {
+			switch {
+			case item.Status >= 200 && item.Status < 300:
+				indexed++
+				acks[i](nil)
+			case item.Status == 429 || item.Status >= 500:
+				retryDocs = append(retryDocs, docs[i])
+				retryAcks = append(retryAcks, acks[i])
+				lastErr = item.reason()
+			default:
+				rejected++
+				acks[i](fmt.Errorf("rejected by elasticsearch with status %d: %s", item.Status, item.reason()))
+			}
+		}
+		stats.Add("logs", sb.serviceName, indexed, int64(len(retryDocs)), rejected)
+		if rejected > 0 {
+			log.Printf("Elasticsearch rejected %d logs for service %s", rejected, sb.serviceName)
+		}
 
+		docs, acks = retryDocs, retryAcks
+		if len(docs) > 0 {
+			log.Printf("Retrying %d logs for service %s: %s", len(docs), sb.serviceName, lastErr)
+			return fmt.Errorf("%d logs pending retry: %s", len(docs), lastErr)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Giving up indexing %d logs for service %s, offsets stay uncommitted: %v", len(docs), sb.serviceName, err)
+		return err
+	}
 	return nil
 }
 
-func (sb *ServiceBatch) flushBatch(client *elasticsearch.Client) error {
-	sb.mutex.Lock()
-	defer sb.mutex.Unlock()
+// bulkItem is the result of one action in a _bulk response
+type bulkItem struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error,omitempty"`
+}
 
-	if len(sb.buffer) == 0 {
-		return nil
+func (i bulkItem) reason() string {
+	if i.Error == nil {
+		return fmt.Sprintf("status %d", i.Status)
 	}
+	return i.Error.Type + ": " + i.Error.Reason
+}
 
+// bulkIndex sends the documents in one _bulk request and returns the item results in request order.
+func (sb *ServiceBatch) bulkIndex(client *elasticsearch.Client, docs []LogDocument) ([]bulkItem, error) {
 	var buf bytes.Buffer
-	indexName := fmt.Sprintf("logs-%s", sb.serviceName)
+	indexName := config.LogsAlias(sb.serviceName)
 
-	for _, doc := range sb.buffer {
+	for _, doc := range docs {
 		action := map[string]interface{}{
 			"index": map[string]interface{}{
 				"_index": indexName,
@@ -264,7 +454,7 @@ func (sb *ServiceBatch) flushBatch(client *elasticsearch.Client) error {
 		client.Bulk.WithRefresh("false"),
 	)
 	if err != nil {
-		return fmt.Errorf("bulk request failed for service %s: %w", sb.serviceName, err)
+		return nil, fmt.Errorf("bulk request failed for service %s: %w", sb.serviceName, err)
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -274,15 +464,28 @@ func (sb *ServiceBatch) flushBatch(client *elasticsearch.Client) error {
 	}(res.Body)
 
 	if res.IsError() {
-		return fmt.Errorf("bulk request returned error for service %s: %s", sb.serviceName, res.String())
+		return nil, fmt.Errorf("bulk request returned error for service %s: %s", sb.serviceName, res.String())
 	}
 
-	log.Printf("Successfully indexed %d logs documents for service: %s", len(sb.buffer), sb.serviceName)
-
-	// Clear buffer
-	sb.buffer = sb.buffer[:0]
+	var bulkRes struct {
+		Errors bool                  `json:"errors"`
+		Items  []map[string]bulkItem `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&bulkRes); err != nil {
+		return nil, fmt.Errorf("failed to decode bulk response for service %s: %w", sb.serviceName, err)
+	}
+	if len(bulkRes.Items) != len(docs) {
+		return nil, fmt.Errorf("bulk response for service %s has %d items, expected %d", sb.serviceName, len(bulkRes.Items), len(docs))
+	}
 
-	return nil
+	items := make([]bulkItem, len(docs))
+	for i, item := range bulkRes.Items {
+		// Each item is keyed by its action, "index" here
+		for _, result := range item {
+			items[i] = result
+		}
+	}
+	return items, nil
 }
 
 // FlushAll Force flush all service batches
@@ -292,7 +495,7 @@ func (p *DefaultLogProcessor) FlushAll() error {
 
 	var errors []string
 	for serviceName, batch := range p.serviceBatches {
-		if err := batch.flushBatch(p.es); err != nil {
+		if err := batch.flushBatch(p.ctx, p.es, p.stats); err != nil {
 			errors = append(errors, fmt.Sprintf("service %s: %v", serviceName, err))
 		}
 	}
@@ -304,20 +507,19 @@ func (p *DefaultLogProcessor) FlushAll() error {
 	return nil
 }
 
+// Close flushes what is buffered, giving failed flushes a short grace period, and stops
+// the timer flush retries.
 func (p *DefaultLogProcessor) Close() error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	defer p.cancel()
 
-	var errors []string
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
+	var errors []string
 	for serviceName, batch := range p.serviceBatches {
-		batch.mutex.Lock()
-		if batch.flushTimer != nil {
-			batch.flushTimer.Stop()
-		}
-		batch.mutex.Unlock()
-
-		if err := batch.flushBatch(p.es); err != nil {
+		if err := batch.flushBatch(ctx, p.es, p.stats); err != nil {
 			errors = append(errors, fmt.Sprintf("service %s: %v", serviceName, err))
 		}
 	}
@@ -335,7 +537,7 @@ type KafkaConsumerService struct {
 	consumerManager *config.KafkaConsumerManager
 }
 
-func NewKafkaConsumerService(cfg *config.AppConfig, processor LogProcessor, consumerGroupID string) (*KafkaConsumerService, error) {
+func NewKafkaConsumerService(cfg *config.AppConfig, processor LogProcessor, consumerGroupID string, dlq *config.DeadLetterQueue) (*KafkaConsumerService, error) {
 	consumerGroup, deserializer, err := config.SetupKafkaConsumer(cfg, consumerGroupID)
 	if err != nil {
 		return nil, err
@@ -343,7 +545,7 @@ func NewKafkaConsumerService(cfg *config.AppConfig, processor LogProcessor, cons
 
 	consumerManager := config.NewKafkaConsumerManager(consumerGroup)
 
-	handler := NewConsumerGroupHandler(deserializer, processor)
+	handler := NewConsumerGroupHandler(deserializer, processor, dlq)
 
 	return &KafkaConsumerService{
 		handler:         handler,
@@ -448,9 +650,13 @@ func filterValidTopics(input []string, validList []string) []string {
 
 // This function converts from the database model to the broadcast model.
 func toLogModel(doc LogDocument) *models.Log {
-	var builddetails = models.BuildDetails{
-		NodeVersion: doc.BuildDetails.NodeVersion,
-		AppVersion:  doc.BuildDetails.AppVersion,
+	// Build details are optional, every log is broadcast so a missing one must not panic
+	var builddetails models.BuildDetails
+	if doc.BuildDetails != nil {
+		builddetails = models.BuildDetails{
+			NodeVersion: doc.BuildDetails.NodeVersion,
+			AppVersion:  doc.BuildDetails.AppVersion,
+		}
 	}
 
 	logModel := models.Log{
@@ -466,7 +672,15 @@ func toLogModel(doc LogDocument) *models.Log {
 		IpAddress:      doc.RemoteIp,
 		ResponseStatus: doc.ResponseStatus,
 		ResponseTime:   doc.ResponseTime,
+		ResponseTimeMs: doc.ResponseTimeMs,
+		StatusCode:     doc.StatusCode,
+		Attributes:     doc.Attributes,
+		TypedAttrs:     doc.TypedAttrs,
+		TraceId:        doc.TraceId,
+		SpanId:         doc.SpanId,
+		ParentSpanId:   doc.ParentSpanId,
 		BuildDetails:   builddetails,
+		EventID:        models.EventID(doc.Partition, doc.Offset),
 	}
 
 	return &logModel
@@ -7,10 +7,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"regexp"
 	"server/config"
 	"server/internal/models"
+	indexstats "server/internal/services/index_stats"
 	metricProto "server/internal/services/proto/metrics"
 	serversentevents "server/internal/services/server_sent_events"
+	"server/pkg"
 	"sort"
 	"strings"
 	"sync"
@@ -20,19 +24,29 @@ import (
 	"github.com/elastic/go-elasticsearch/v9"
 )
 
+var (
+	metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:.]{0,199}$`)
+	labelName  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]{0,99}$`)
+)
+
 type MetricProcessor interface {
-	ProcessMetrics(metricsMessage *metricProto.Metrics, topic string, partition int32, offset int64) error
+	// ProcessMetrics buffers the sample for indexing and calls done once it is indexed, or with
+	// the reason Elasticsearch permanently rejected it. An error is returned only when the
+	// sample cannot be processed at all.
+	ProcessMetrics(ctx context.Context, metricsMessage *metricProto.Metrics, topic string, partition int32, offset int64, done func(err error)) error
 }
 
 type ConsumerGroupHandler struct {
 	deserializer *config.ProtobufDeserializer
 	processor    MetricProcessor
+	dlq          *config.DeadLetterQueue
 }
 
-func NewConsumerGroupHandler(deserializer *config.ProtobufDeserializer, processor MetricProcessor) *ConsumerGroupHandler {
+func NewConsumerGroupHandler(deserializer *config.ProtobufDeserializer, processor MetricProcessor, dlq *config.DeadLetterQueue) *ConsumerGroupHandler {
 	return &ConsumerGroupHandler{
 		deserializer: deserializer,
 		processor:    processor,
+		dlq:          dlq,
 	}
 }
 
@@ -50,6 +64,12 @@ func (h *ConsumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
 func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	log.Printf("Starting to consume from topic: %s, partition: %d", claim.Topic(), claim.Partition())
 
+	// Offsets are marked in order once each message is indexed or dead lettered
+	tracker := config.NewOffsetTracker(session)
+	// failed receives a message that could not be dead lettered after its batch was indexed.
+	// Returning ends the session without marking it, so it is redelivered.
+	failed := make(chan error, 1)
+
 	// Process messages
 	for {
 		select {
@@ -65,26 +85,45 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 
 			log.Printf("Received message from topic: %s, partition: %d, offset: %d",
 				message.Topic, message.Partition, message.Offset)
+			complete := tracker.Track(message)
+			// Documents rejected by Elasticsearch are dead lettered before their offset is marked
+			done := func(err error) {
+				if err != nil {
+					if err := h.deadLetter(message, err); err != nil {
+						select {
+						case failed <- err:
+						default:
+						}
+						return
+					}
+				}
+				complete()
+			}
 
 			// DeserializeLogs the message
 			metricMessage, err := h.deserializer.DeserializeMetrics(message.Value)
 			if err != nil {
 				log.Printf("Failed to deserialize message: %v", err)
-				session.MarkMessage(message, "")
+				if err := h.deadLetter(message, err); err != nil {
+					return err
+				}
+				complete()
 				continue
 			}
 
-			// Process the log message
-			err = h.processor.ProcessMetrics(metricMessage, message.Topic, message.Partition, message.Offset)
+			// Process the metrics message, it is marked once its batch is indexed
+			err = h.processor.ProcessMetrics(session.Context(), metricMessage, message.Topic, message.Partition, message.Offset, done)
 			if err != nil {
 				log.Printf("Failed to process log message: %v", err)
-				session.MarkMessage(message, "")
+				if err := h.deadLetter(message, err); err != nil {
+					return err
+				}
+				complete()
 				continue
 			}
 
-			session.MarkMessage(message, "")
-			log.Printf("Successfully processed message from topic: %s, offset: %d",
-				message.Topic, message.Offset)
+		case err := <-failed:
+			return err
 
 		case <-session.Context().Done():
 			log.Println("Consumer session context cancelled")
@@ -93,6 +132,19 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 	}
 }
 
+// deadLetter parks a failed message in its dead letter topic. If the dead letter topic is
+// unreachable the claim stops without marking, so the message is redelivered.
+func (h *ConsumerGroupHandler) deadLetter(message *sarama.ConsumerMessage, cause error) error {
+	if h.dlq == nil {
+		return nil
+	}
+	if err := h.dlq.Send(message, cause); err != nil {
+		log.Printf("Failed to dead letter message: %v", err)
+		return err
+	}
+	return nil
+}
+
 // DefaultMetricsProcessor implements basic log processing
 type DefaultMetricsProcessor struct {
 	es             *elasticsearch.Client
@@ -100,23 +152,32 @@ type DefaultMetricsProcessor struct {
 	batchSize      int
 	flushInterval  time.Duration
 	mutex          sync.RWMutex
-	metricsSSE     *serversentevents.SSEMetricsService
+	live           serversentevents.MetricsBroadcaster
+	stats          *indexstats.IndexStats
+	ctx            context.Context // cancelled on Close to stop timer flush retries
+	cancel         context.CancelFunc
 }
 
 type ServiceBatch struct {
 	serviceName string
-	buffer      []Metrics
+	buffer      []metricDocument
+	acks        []func(err error)
 	flushTimer  *time.Timer
 	mutex       sync.Mutex
+	flushMutex  sync.Mutex
 }
 
-func NewDefaultMetricsProcessor(es *elasticsearch.Client, m *serversentevents.SSEMetricsService) *DefaultMetricsProcessor {
+func NewDefaultMetricsProcessor(es *elasticsearch.Client, m serversentevents.MetricsBroadcaster, stats *indexstats.IndexStats) *DefaultMetricsProcessor {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &DefaultMetricsProcessor{
 		es:             es,
 		serviceBatches: make(map[string]*ServiceBatch),
 		batchSize:      200,
 		flushInterval:  10 * time.Second,
-		metricsSSE:     m,
+		live:           m,
+		stats:          stats,
+		ctx:            ctx,
+		cancel:         cancel,
 	}
 }
 
@@ -145,14 +206,85 @@ type Metrics struct {
 	Offset      int64        `json:"offset"`
 }
 
-func (p *DefaultMetricsProcessor) ProcessMetrics(metrics *metricProto.Metrics, topic string, partition int32, offset int64) error {
+// HistogramBucket is a cumulative bucket, the +Inf bucket has no upper bound
+type HistogramBucket struct {
+	UpperBound *float64 `json:"upperBound,omitempty"`
+	Count      uint64   `json:"count"`
+}
+
+// Distribution holds the buckets of a histogram in the shape of an Elasticsearch histogram
+// field, each bucket as its midpoint and the number of observations it holds
+type Distribution struct {
+	Values []float64 `json:"values"`
+	Counts []uint64  `json:"counts"`
+}
+
+// MetricPoint is the document of a generic counter, gauge or histogram sample
+type MetricPoint struct {
+	Name         string             `json:"name"`
+	Type         string             `json:"type"`
+	Labels       map[string]string  `json:"labels,omitempty"`
+	Timestamp    int64              `json:"timestamp"`
+	Value        *float64           `json:"value,omitempty"`
+	Buckets      []*HistogramBucket `json:"buckets,omitempty"`
+	Distribution *Distribution      `json:"distribution,omitempty"`
+	Sum          *float64           `json:"sum,omitempty"`
+	Count        *uint64            `json:"count,omitempty"`
+	ServiceName  string             `json:"serviceName"`
+	Topic        string             `json:"topic"`
+	Partition    int32              `json:"partition"`
+	Offset       int64              `json:"offset"`
+}
+
+// metricDocument is a document waiting in a batch, either a CPU and memory sample or a metric point
+type metricDocument struct {
+	id   string
+	body interface{}
+}
+
+func (p *DefaultMetricsProcessor) ProcessMetrics(ctx context.Context, metrics *metricProto.Metrics, topic string, partition int32, offset int64, done func(err error)) error {
 	serviceName := metrics.GetServiceName()
 	if serviceName == "" {
 		return fmt.Errorf("service name is required")
 	}
 
+	// Points are validated up front, so a bad point dead letters the message before any of it is indexed
+	points := make([]MetricPoint, 0, len(metrics.GetPoints()))
+	for i, point := range metrics.GetPoints() {
+		doc, err := toMetricPoint(point)
+		if err != nil {
+			return fmt.Errorf("invalid point %d: %w", i, err)
+		}
+		doc.ServiceName = serviceName
+		doc.Topic = topic
+		doc.Partition = partition
+		doc.Offset = offset
+		points = append(points, doc)
+	}
+
+	// Samples carrying only points have no CPU and memory document
+	hasUsage := metrics.GetCpuUsage() != nil || metrics.GetMemoryUsage() != nil || len(points) == 0
+	docs := make([]metricDocument, 0, len(points)+1)
+	id := fmt.Sprintf("%s-%d-%d", topic, partition, offset)
+	if hasUsage {
+		metricsData := toMetricsDocument(metrics, serviceName, topic, partition, offset)
+		metrics := toMetricsmodel(metricsData)
+		p.live.BroadcastMetrics(serviceName, &metrics)
+		docs = append(docs, metricDocument{id: id, body: metricsData})
+	}
+	for i := range points {
+		docs = append(docs, metricDocument{id: fmt.Sprintf("%s-%d", id, i), body: points[i]})
+	}
+
 	batch := p.getOrCreateServiceBatch(serviceName)
+	ack := ackAll(len(docs), done)
+	for _, doc := range docs {
+		batch.addDocument(ctx, p.ctx, doc, ack, p.batchSize, p.flushInterval, p.es, p.stats)
+	}
+	return nil
+}
 
+func toMetricsDocument(metrics *metricProto.Metrics, serviceName string, topic string, partition int32, offset int64) Metrics {
 	memoryUsage := MemoryUsage{
 		Timestamp:             metrics.MemoryUsage.GetTimestamp(),
 		TotalMemory:           metrics.MemoryUsage.GetTotalMemory(),
@@ -162,7 +294,7 @@ func (p *DefaultMetricsProcessor) ProcessMetrics(metrics *metricProto.Metrics, t
 	}
 	var coreUsage []*CoreUsage
 
-	for _, u := range metrics.GetCpuUsage().Cores {
+	for _, u := range metrics.GetCpuUsage().GetCores() {
 		c := CoreUsage{
 			Core:  u.GetCore(),
 			Usage: u.GetUsage(),
@@ -174,7 +306,7 @@ func (p *DefaultMetricsProcessor) ProcessMetrics(metrics *metricProto.Metrics, t
 		Average:   metrics.CpuUsage.GetAverage(),
 		Cores:     coreUsage,
 	}
-	metricsData := Metrics{
+	return Metrics{
 		MemoryUsage: &memoryUsage,
 		CpuUsage:    &cpuUsage,
 		ServiceName: serviceName,
@@ -182,16 +314,27 @@ func (p *DefaultMetricsProcessor) ProcessMetrics(metrics *metricProto.Metrics, t
 		Partition:   partition,
 		Offset:      offset,
 	}
+}
 
-	if client, ok := p.metricsSSE.GetMetricsClientChannel(serviceName); ok {
-		log.Printf("Client channel found for service: %v", client)
-	}
-	if len(p.metricsSSE.Clients) > 0 {
-		metrics := toMetricsmodel(metricsData)
-		p.metricsSSE.BroadcastMetrics(serviceName, &metrics)
+// ackAll calls done once all n documents of a message are resolved, with the first rejection
+func ackAll(n int, done func(err error)) func(err error) {
+	var mutex sync.Mutex
+	var firstErr error
+	pending := n
+	return func(err error) {
+		mutex.Lock()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		pending--
+		finished := pending == 0
+		mutex.Unlock()
+		if finished {
+			done(firstErr)
+		}
 	}
-	return batch.addDocument(metricsData, p.batchSize, p.flushInterval, p.es)
 }
+
 func (p *DefaultMetricsProcessor) getOrCreateServiceBatch(serviceName string) *ServiceBatch {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
@@ -200,7 +343,7 @@ func (p *DefaultMetricsProcessor) getOrCreateServiceBatch(serviceName string) *S
 	if !exists {
 		batch = &ServiceBatch{
 			serviceName: serviceName,
-			buffer:      make([]Metrics, 0, p.batchSize),
+			buffer:      make([]metricDocument, 0, p.batchSize),
 		}
 		p.serviceBatches[serviceName] = batch
 	}
@@ -208,60 +351,148 @@ func (p *DefaultMetricsProcessor) getOrCreateServiceBatch(serviceName string) *S
 	return batch
 }
 
-func (sb *ServiceBatch) addDocument(doc Metrics, batchSize int, flushInterval time.Duration, client *elasticsearch.Client) error {
+// addDocument buffers the document and flushes once the batch is full, blocking the caller
+// until the batch is indexed. Partial batches are flushed by a timer after flushInterval.
+func (sb *ServiceBatch) addDocument(ctx context.Context, timerCtx context.Context, doc metricDocument, done func(err error), batchSize int, flushInterval time.Duration, client *elasticsearch.Client, stats *indexstats.IndexStats) {
 	sb.mutex.Lock()
-	defer sb.mutex.Unlock()
 
 	// Add to buffer
 	sb.buffer = append(sb.buffer, doc)
+	sb.acks = append(sb.acks, done)
 
-	// Reset flush timer
+	if len(sb.buffer) < batchSize {
+		if sb.flushTimer == nil {
+			sb.flushTimer = time.AfterFunc(flushInterval, func() {
+				_ = sb.flushBatch(timerCtx, client, stats)
+			})
+		}
+		sb.mutex.Unlock()
+		return
+	}
+
+	// Flush if the batch is full
+	docs, acks := sb.takeBuffer()
+	sb.mutex.Unlock()
+	_ = sb.flushDocuments(ctx, client, stats, docs, acks)
+}
+
+// takeBuffer hands the buffered documents over to a flush. The caller must hold sb.mutex.
+func (sb *ServiceBatch) takeBuffer() ([]metricDocument, []func(err error)) {
 	if sb.flushTimer != nil {
 		sb.flushTimer.Stop()
+		sb.flushTimer = nil
+	}
+	docs, acks := sb.buffer, sb.acks
+	sb.buffer = make([]metricDocument, 0, cap(docs))
+	sb.acks = make([]func(err error), 0, cap(acks))
+	return docs, acks
+}
+
+func (sb *ServiceBatch) flushBatch(ctx context.Context, client *elasticsearch.Client, stats *indexstats.IndexStats) error {
+	sb.mutex.Lock()
+	docs, acks := sb.takeBuffer()
+	sb.mutex.Unlock()
+	return sb.flushDocuments(ctx, client, stats, docs, acks)
+}
+
+// flushDocuments indexes the documents and resolves each one from its bulk item. Items failing
+// with 429 or 5xx, or the whole batch when the request fails, are retried with backoff until
+// ctx is done; other failures are permanent rejections handed back through the ack. Documents
+// given up on stay unacknowledged, so Kafka redelivers them.
+func (sb *ServiceBatch) flushDocuments(ctx context.Context, client *elasticsearch.Client, stats *indexstats.IndexStats, docs []metricDocument, acks []func(err error)) error {
+	if len(docs) == 0 {
+		return nil
 	}
-	sb.flushTimer = time.AfterFunc(flushInterval, func() {
-		err := sb.flushBatch(client)
+
+	sb.flushMutex.Lock()
+	defer sb.flushMutex.Unlock()
+
+	err := pkg.RetryWithBackoff(ctx, time.Second, 30*time.Second, func() error {
+		items, err := sb.bulkIndex(client, docs)
 		if err != nil {
-			return
+			log.Printf("Failed to index %d metrics for service %s, retrying: %v", len(docs), sb.serviceName, err)
+			stats.Add("metrics", sb.serviceName, 0, int64(len(docs)), 0)
+			return err
 		}
-	})
 
-	// Flush if the batch is full
-	if len(sb.buffer) >= batchSize {
-		return sb.flushBatch(client)
-	}
+		var retryDocs []metricDocument
+		var retryAcks []func(err error)
+		var indexed, rejected int64
+		var lastErr string
+		for i, item := range items {
+			switch {
+			case item.Status >= 200 && item.Status < 300:
+				indexed++
+				acks[i](nil)
+			case item.Status == 429 || item.Status >= 500:
+				retryDocs = append(retryDocs, docs[i])
+				retryAcks = append(retryAcks, acks[i])
+				lastErr = item.reason()
+			default:
+				rejected++
+				acks[i](fmt.Errorf("rejected by elasticsearch with status %d: %s", item.Status, item.reason()))
+			}
+		}
+		stats.Add("metrics", sb.serviceName, indexed, int64(len(retryDocs)), rejected)
+		if rejected > 0 {
+			log.Printf("Elasticsearch rejected %d metrics for service %s", rejected, sb.serviceName)
+		}
 
+		docs, acks = retryDocs, retryAcks
+		if len(docs) > 0 {
+			log.Printf("Retrying %d metrics for service %s: %s", len(docs), sb.serviceName, lastErr)
+			return fmt.Errorf("%d metrics pending retry: %s", len(docs), lastErr)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Giving up indexing %d metrics for service %s, offsets stay uncommitted: %v", len(docs), sb.serviceName, err)
+		return err
+	}
 	return nil
 }
 
-func (sb *ServiceBatch) flushBatch(client *elasticsearch.Client) error {
-	sb.mutex.Lock()
-	defer sb.mutex.Unlock()
+// bulkItem is the result of one action in a _bulk response
+type bulkItem struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error,omitempty"`
+}
 
-	if len(sb.buffer) == 0 {
-		return nil
+func (i bulkItem) reason() string {
+	if i.Error == nil {
+		return fmt.Sprintf("status %d", i.Status)
 	}
+	return i.Error.Type + ": " + i.Error.Reason
+}
 
+// bulkIndex sends the documents in one _bulk request and returns the item results in request order.
+func (sb *ServiceBatch) bulkIndex(client *elasticsearch.Client, docs []metricDocument) ([]bulkItem, error) {
 	var buf bytes.Buffer
 	indexName := fmt.Sprintf("m-%s-%s", sb.serviceName, time.Now().Format("02.01.2006"))
 
-	for _, doc := range sb.buffer {
+	for _, doc := range docs {
 		action := map[string]interface{}{
 			"index": map[string]interface{}{
 				"_index": indexName,
-				"_id":    fmt.Sprintf("%s-%d-%d", doc.Topic, doc.Partition, doc.Offset),
+				"_id":    doc.id,
 			},
 		}
 		actionBytes, err := json.Marshal(action)
 
 		if err != nil {
-			return fmt.Errorf("failed to marshal action: %w", err)
+			return nil, fmt.Errorf("failed to marshal action: %w", err)
 		}
 		buf.Write(actionBytes)
 		buf.WriteByte('\n')
 
 		// Document line
-		docBytes, _ := json.Marshal(doc)
+		docBytes, err := json.Marshal(doc.body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal document: %w", err)
+		}
 		buf.Write(docBytes)
 		buf.WriteByte('\n')
 	}
@@ -273,7 +504,7 @@ func (sb *ServiceBatch) flushBatch(client *elasticsearch.Client) error {
 		client.Bulk.WithRefresh("wait_for"),
 	)
 	if err != nil {
-		return fmt.Errorf("bulk request failed for service %s: %w", sb.serviceName, err)
+		return nil, fmt.Errorf("bulk request failed for service %s: %w", sb.serviceName, err)
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -283,15 +514,28 @@ func (sb *ServiceBatch) flushBatch(client *elasticsearch.Client) error {
 	}(res.Body)
 
 	if res.IsError() {
-		return fmt.Errorf("bulk request returned error for service %s: %s", sb.serviceName, res.String())
+		return nil, fmt.Errorf("bulk request returned error for service %s: %s", sb.serviceName, res.String())
 	}
 
-	log.Printf("Successfully indexed %d documents for service: %s on %s", len(sb.buffer), sb.serviceName, indexName)
-
-	// Clear buffer
-	sb.buffer = sb.buffer[:0]
+	var bulkRes struct {
+		Errors bool                  `json:"errors"`
+		Items  []map[string]bulkItem `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&bulkRes); err != nil {
+		return nil, fmt.Errorf("failed to decode bulk response for service %s: %w", sb.serviceName, err)
+	}
+	if len(bulkRes.Items) != len(docs) {
+		return nil, fmt.Errorf("bulk response for service %s has %d items, expected %d", sb.serviceName, len(bulkRes.Items), len(docs))
+	}
 
-	return nil
+	items := make([]bulkItem, len(docs))
+	for i, item := range bulkRes.Items {
+		// Each item is keyed by its action, "index" here
+		for _, result := range item {
+			items[i] = result
+		}
+	}
+	return items, nil
 }
 
 // FlushAll Force flush all service batches
@@ -301,7 +545,7 @@ func (p *DefaultMetricsProcessor) FlushAll() error {
 
 	var errors []string
 	for serviceName, batch := range p.serviceBatches {
-		if err := batch.flushBatch(p.es); err != nil {
+		if err := batch.flushBatch(p.ctx, p.es, p.stats); err != nil {
 			errors = append(errors, fmt.Sprintf("service %s: %v", serviceName, err))
 		}
 	}
@@ -313,20 +557,19 @@ func (p *DefaultMetricsProcessor) FlushAll() error {
 	return nil
 }
 
+// Close flushes what is buffered, giving failed flushes a short grace period, and stops
+// the timer flush retries.
 func (p *DefaultMetricsProcessor) Close() error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	defer p.cancel()
 
-	var errors []string
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
+	var errors []string
 	for serviceName, batch := range p.serviceBatches {
-		batch.mutex.Lock()
-		if batch.flushTimer != nil {
-			batch.flushTimer.Stop()
-		}
-		batch.mutex.Unlock()
-
-		if err := batch.flushBatch(p.es); err != nil {
+		if err := batch.flushBatch(ctx, p.es, p.stats); err != nil {
 			errors = append(errors, fmt.Sprintf("service %s: %v", serviceName, err))
 		}
 	}
@@ -344,7 +587,7 @@ type KafkaConsumerService struct {
 	consumerManager *config.KafkaConsumerManager
 }
 
-func NewKafkaConsumerService(cfg *config.AppConfig, processor MetricProcessor, consumerGroupID string) (*KafkaConsumerService, error) {
+func NewKafkaConsumerService(cfg *config.AppConfig, processor MetricProcessor, consumerGroupID string, dlq *config.DeadLetterQueue) (*KafkaConsumerService, error) {
 	consumerGroup, deserializer, err := config.SetupKafkaConsumer(cfg, consumerGroupID)
 	if err != nil {
 		return nil, err
@@ -352,7 +595,7 @@ func NewKafkaConsumerService(cfg *config.AppConfig, processor MetricProcessor, c
 
 	consumerManager := config.NewKafkaConsumerManager(consumerGroup)
 
-	handler := NewConsumerGroupHandler(deserializer, processor)
+	handler := NewConsumerGroupHandler(deserializer, processor, dlq)
 
 	return &KafkaConsumerService{
 		handler:         handler,
@@ -488,5 +731,115 @@ func toMetricsmodel(m Metrics) models.Metrics {
 		MemoryUsage: &memoryUsage,
 		CpuUsage:    &cpuUsage,
 		ServiceName: m.ServiceName,
+		EventID:     models.EventID(m.Partition, m.Offset),
+	}
+}
+
+// toMetricPoint validates a point and converts it to its document. Histogram buckets are kept
+// as sent and also stored as a distribution, so percentiles can be computed over them.
+func toMetricPoint(point *metricProto.MetricPoint) (MetricPoint, error) {
+	if !metricName.MatchString(point.GetName()) {
+		return MetricPoint{}, fmt.Errorf("invalid metric name %q", point.GetName())
+	}
+	for key := range point.GetLabels() {
+		if !labelName.MatchString(key) {
+			return MetricPoint{}, fmt.Errorf("invalid label name %q on metric %s", key, point.GetName())
+		}
+	}
+
+	doc := MetricPoint{
+		Name:      point.GetName(),
+		Labels:    point.GetLabels(),
+		Timestamp: point.GetTimestamp(),
+	}
+	if doc.Timestamp == 0 {
+		doc.Timestamp = time.Now().UnixMilli()
+	}
+
+	switch point.GetType() {
+	case metricProto.MetricType_COUNTER, metricProto.MetricType_GAUGE:
+		value := point.GetValue()
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return MetricPoint{}, fmt.Errorf("metric %s has no finite value", point.GetName())
+		}
+		doc.Type = strings.ToLower(point.GetType().String())
+		doc.Value = &value
+	case metricProto.MetricType_HISTOGRAM:
+		buckets, distribution, err := toHistogram(point.GetBuckets())
+		if err != nil {
+			return MetricPoint{}, fmt.Errorf("metric %s: %w", point.GetName(), err)
+		}
+		sum, count := point.GetSum(), point.GetCount()
+		if math.IsNaN(sum) || math.IsInf(sum, 0) {
+			return MetricPoint{}, fmt.Errorf("metric %s has no finite sum", point.GetName())
+		}
+		doc.Type = "histogram"
+		doc.Buckets = buckets
+		doc.Distribution = distribution
+		doc.Sum = &sum
+		doc.Count = &count
+	default:
+		return MetricPoint{}, fmt.Errorf("metric %s has no type", point.GetName())
+	}
+	return doc, nil
+}
+
+// toHistogram checks that the buckets are ordered by upper bound with cumulative counts. Each
+// bucket of the distribution sits at the middle of its range, the first bucket starts at zero
+// and the +Inf bucket is placed at the highest finite bound.
+func toHistogram(buckets []*metricProto.HistogramBucket) ([]*HistogramBucket, *Distribution, error) {
+	if len(buckets) == 0 {
+		return nil, nil, fmt.Errorf("histogram has no buckets")
+	}
+	docs := make([]*HistogramBucket, 0, len(buckets))
+	distribution := &Distribution{}
+	var lower float64
+	var previous uint64
+	for i, bucket := range buckets {
+		upper := bucket.GetUpperBound()
+		if math.IsNaN(upper) || math.IsInf(upper, -1) || (i > 0 && upper <= *docs[i-1].UpperBound) {
+			return nil, nil, fmt.Errorf("histogram bucket bounds must be increasing")
+		}
+		if bucket.GetCount() < previous {
+			return nil, nil, fmt.Errorf("histogram bucket counts must be cumulative")
+		}
+		count := bucket.GetCount() - previous
+		previous = bucket.GetCount()
+
+		if math.IsInf(upper, 1) {
+			if i != len(buckets)-1 {
+				return nil, nil, fmt.Errorf("the +Inf bucket must be the last histogram bucket")
+			}
+			docs = append(docs, &HistogramBucket{Count: bucket.GetCount()})
+			if count > 0 && i > 0 {
+				addToDistribution(distribution, lower, count)
+			}
+			break
+		}
+		docs = append(docs, &HistogramBucket{UpperBound: &upper, Count: bucket.GetCount()})
+
+		start := lower
+		if i == 0 && upper <= 0 {
+			start = upper
+		}
+		if count > 0 {
+			addToDistribution(distribution, (start+upper)/2, count)
+		}
+		lower = upper
+	}
+	if len(distribution.Values) == 0 {
+		return docs, nil, nil
+	}
+	return docs, distribution, nil
+}
+
+// addToDistribution appends a value, merging it with the previous one when they are equal since
+// histogram field values must be strictly increasing
+func addToDistribution(d *Distribution, value float64, count uint64) {
+	if n := len(d.Values); n > 0 && d.Values[n-1] >= value {
+		d.Counts[n-1] += count
+		return
 	}
+	d.Values = append(d.Values, value)
+	d.Counts = append(d.Counts, count)
 }
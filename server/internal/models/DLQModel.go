@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// DLQRecord is a message parked in a dead letter topic together with the reason it failed
+type DLQRecord struct {
+	Topic           string            `json:"topic"`
+	Partition       int32             `json:"partition"`
+	Offset          int64             `json:"offset"`
+	Key             string            `json:"key,omitempty"`
+	Payload         []byte            `json:"payload,omitempty"`
+	PayloadSize     int               `json:"payloadSize"`
+	Headers         map[string]string `json:"headers"`
+	Error           string            `json:"error"`
+	SourceTopic     string            `json:"sourceTopic"`
+	SourcePartition int32             `json:"sourcePartition"`
+	SourceOffset    int64             `json:"sourceOffset"`
+	FailedAt        time.Time         `json:"failedAt"`
+	Timestamp       time.Time         `json:"timestamp"`
+}
+
+// DLQReplayResult reports where a dead lettered record was replayed to
+type DLQReplayResult struct {
+	Partition         int32  `json:"partition"`
+	Offset            int64  `json:"offset"`
+	Replayed          bool   `json:"replayed"`
+	ReplayedTopic     string `json:"replayedTopic,omitempty"`
+	ReplayedPartition int32  `json:"replayedPartition,omitempty"`
+	ReplayedOffset    int64  `json:"replayedOffset,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
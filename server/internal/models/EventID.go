@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// EventID identifies a log or metrics sample by the Kafka partition and offset it was consumed
+// from. Offsets only grow within a partition, see StreamPosition for how clients resume.
+func EventID(partition int32, offset int64) string {
+	return fmt.Sprintf("%d:%d", partition, offset)
+}
+
+// ParseEventID returns the partition and offset of an ID built by EventID
+func ParseEventID(id string) (int32, int64, error) {
+	p, o, ok := strings.Cut(id, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid event ID %q, expected <partition>:<offset>", id)
+	}
+	partition, err := strconv.ParseInt(p, 10, 32)
+	if err != nil || partition < 0 {
+		return 0, 0, fmt.Errorf("invalid partition in event ID %q", id)
+	}
+	offset, err := strconv.ParseInt(o, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("invalid offset in event ID %q", id)
+	}
+	return int32(partition), offset, nil
+}
+
+// StreamPosition is how far a live stream client got, the offset of the last event it received
+// or skipped per partition. Offsets are not ordered across partitions, so a single event ID does
+// not tell what a client missed: it missed every event past its offset of each partition, and
+// every event of the partitions it has no offset of. Clients resume from the position they got
+// with their last event, written like 0:1200,1:1187.
+type StreamPosition map[int32]int64
+
+// ParseStreamPosition reads a position written by StreamPosition.String. An ID built by EventID
+// is a position with a single partition.
+func ParseStreamPosition(id string) (StreamPosition, error) {
+	position := StreamPosition{}
+	for _, part := range strings.Split(id, ",") {
+		partition, offset, err := ParseEventID(part)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := position[partition]; ok {
+			return nil, fmt.Errorf("partition %d is repeated in %q", partition, id)
+		}
+		position[partition] = offset
+	}
+	return position, nil
+}
+
+func (p StreamPosition) String() string {
+	partitions := make([]int32, 0, len(p))
+	for partition := range p {
+		partitions = append(partitions, partition)
+	}
+	slices.Sort(partitions)
+
+	parts := make([]string, len(partitions))
+	for i, partition := range partitions {
+		parts[i] = EventID(partition, p[partition])
+	}
+	return strings.Join(parts, ",")
+}
+
+// Includes reports whether the event consumed from the partition at the offset is at or before
+// the position
+func (p StreamPosition) Includes(partition int32, offset int64) bool {
+	last, ok := p[partition]
+	return ok && offset <= last
+}
+
+// Advance moves the position past the event, it never goes back
+func (p StreamPosition) Advance(partition int32, offset int64) {
+	if last, ok := p[partition]; !ok || offset > last {
+		p[partition] = offset
+	}
+}
+
+// Merge advances the position to the other one, partition by partition
+func (p StreamPosition) Merge(other StreamPosition) {
+	for partition, offset := range other {
+		p.Advance(partition, offset)
+	}
+}
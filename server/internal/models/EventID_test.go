@@ -0,0 +1,49 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStreamPosition(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    StreamPosition
+		wantErr bool
+	}{
+		{id: "0:12", want: StreamPosition{0: 12}},
+		{id: "1:40,0:12", want: StreamPosition{0: 12, 1: 40}},
+		{id: "", wantErr: true},
+		{id: "0:12,", wantErr: true},
+		{id: "0:12,0:13", wantErr: true},
+		{id: "0:-1", wantErr: true},
+		{id: "a:1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStreamPosition(tt.id)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseStreamPosition(%q) = %v, want an error", tt.id, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseStreamPosition(%q) = %v, %v, want %v", tt.id, got, err, tt.want)
+		}
+	}
+}
+
+func TestStreamPosition(t *testing.T) {
+	position := StreamPosition{}
+	position.Advance(1, 40)
+	position.Advance(0, 12)
+	position.Advance(1, 39) // offsets of a partition never go back
+	position.Merge(StreamPosition{0: 10, 2: 5})
+
+	if got, want := position.String(), "0:12,1:40,2:5"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if !position.Includes(1, 40) || position.Includes(1, 41) || position.Includes(3, 0) {
+		t.Errorf("Includes does not match %v", position)
+	}
+}
@@ -10,17 +10,84 @@ type BuildDetails struct {
 }
 
 type Log struct {
-	ServiceName    string       `json:"serviceName" `
-	BuildDetails   BuildDetails `json:"buildDetails"`
-	Level          string       `json:"level"`
-	Message        string       `json:"message"`
-	Stack          string       `json:"stack"`
-	RequestId      string       `json:"requestId"`
-	RequestUrl     string       `json:"requestUrl"`
-	RequestMethod  string       `json:"requestMethod"`
-	IpAddress      string       `json:"ipAddress"`
-	UserAgent      string       `json:"userAgent"`
-	ResponseStatus string       `json:"responseStatus"`
-	ResponseTime   string       `json:"responseTime"`
-	Timestamp      time.Time    `json:"timestamp"`
+	ServiceName    string                 `json:"serviceName" `
+	BuildDetails   BuildDetails           `json:"buildDetails"`
+	Level          string                 `json:"level"`
+	Message        string                 `json:"message"`
+	Stack          string                 `json:"stack"`
+	RequestId      string                 `json:"requestId"`
+	RequestUrl     string                 `json:"requestUrl"`
+	RequestMethod  string                 `json:"requestMethod"`
+	IpAddress      string                 `json:"ipAddress"`
+	UserAgent      string                 `json:"userAgent"`
+	ResponseStatus string                 `json:"responseStatus"`
+	ResponseTime   string                 `json:"responseTime"`
+	ResponseTimeMs *float64               `json:"responseTimeMs,omitempty"`
+	StatusCode     *int                   `json:"statusCode,omitempty"`
+	Attributes     map[string]string      `json:"attributes,omitempty"`
+	TypedAttrs     map[string]interface{} `json:"typedAttributes,omitempty"`
+	TraceId        string                 `json:"traceId,omitempty"`
+	SpanId         string                 `json:"spanId,omitempty"`
+	ParentSpanId   string                 `json:"parentSpanId,omitempty"`
+	Timestamp      time.Time              `json:"timestamp"`
+	EventID        string                 `json:"eventId,omitempty"` // see EventID
+	Position       StreamPosition         `json:"-"`                 // of the live stream once it sent this log
+
+	// Highlight holds the matched fragments per field when the logs were searched
+	Highlight map[string][]string `json:"highlight,omitempty"`
+}
+
+// LogPage is a page of logs read with a cursor. The cursors are opaque tokens for the
+// neighbouring pages and are empty when there is no such page.
+type LogPage struct {
+	Logs       []*Log `json:"logs"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
+
+// LogAggregations holds the named aggregation results over the Total logs that matched
+type LogAggregations struct {
+	Total int64                            `json:"total"`
+	Aggs  map[string]*LogAggregationResult `json:"aggs"`
+}
+
+// LogAggregationResult is the result of a named aggregation, buckets for date histograms and
+// terms, values keyed by percent for percentiles.
+type LogAggregationResult struct {
+	Buckets    []*LogAggregationBucket `json:"buckets,omitempty"`
+	OtherCount int64                   `json:"otherCount,omitempty"` // logs outside the top terms
+	Values     map[string]*float64     `json:"values,omitempty"`
+}
+
+type LogAggregationBucket struct {
+	Key         interface{}                      `json:"key"`
+	KeyAsString string                           `json:"keyAsString,omitempty"`
+	Count       int64                            `json:"count"`
+	Aggs        map[string]*LogAggregationResult `json:"aggs,omitempty"`
+}
+
+// RouteLatency holds the response time percentiles, in milliseconds, of the requests to a route
+type RouteLatency struct {
+	Method      string              `json:"method"`
+	Route       string              `json:"route"`
+	Count       int64               `json:"count"`
+	Percentiles map[string]*float64 `json:"percentiles"`
+}
+
+// TraceView holds every log of a trace or request across projects, grouped by service
+type TraceView struct {
+	ID       string          `json:"id"`
+	Total    int64           `json:"total"` // matching logs, more than returned when the limit was hit
+	Start    time.Time       `json:"start"`
+	End      time.Time       `json:"end"`
+	Services []*TraceService `json:"services"`
+}
+
+// TraceService holds the logs one service wrote for a trace, ordered by time
+type TraceService struct {
+	ServiceName string    `json:"serviceName"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Logs        []*Log    `json:"logs"`
 }
@@ -17,7 +17,28 @@ type CpuUsage struct {
 	Cores     []*CoreUsage `json:"cores"`
 }
 type Metrics struct {
-	MemoryUsage *MemoryUsage `json:"memoryUsage"`
-	CpuUsage    *CpuUsage    `json:"cpuUsage"`
-	ServiceName string       `json:"serviceName"`
+	MemoryUsage *MemoryUsage   `json:"memoryUsage"`
+	CpuUsage    *CpuUsage      `json:"cpuUsage"`
+	ServiceName string         `json:"serviceName"`
+	EventID     string         `json:"eventId,omitempty"`
+	Position    StreamPosition `json:"-"` // of the live stream once it sent these samples
+}
+
+// MetricPoint is an indexed sample of a generic metric. Counters and gauges have a value,
+// histograms cumulative buckets with the sum and count of the observations.
+type MetricPoint struct {
+	Name      string             `json:"name"`
+	Type      string             `json:"type"`
+	Labels    map[string]string  `json:"labels"`
+	Timestamp int64              `json:"timestamp"`
+	Value     *float64           `json:"value,omitempty"`
+	Buckets   []*HistogramBucket `json:"buckets,omitempty"`
+	Sum       *float64           `json:"sum,omitempty"`
+	Count     *uint64            `json:"count,omitempty"`
+}
+
+// HistogramBucket is a cumulative bucket, the +Inf bucket has no upper bound
+type HistogramBucket struct {
+	UpperBound *float64 `json:"upperBound,omitempty"`
+	Count      uint64   `json:"count"`
 }
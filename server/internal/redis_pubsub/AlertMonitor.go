@@ -68,9 +68,8 @@ func (am *AlertMonitor) processAlert(ctx context.Context, payload string) error
 	if client, ok := am.SSE.GetAlertClientChannel(alert.ProjectName); ok {
 		log.Printf("Client channel found for service: %v", client)
 	}
-	if len(am.SSE.Clients) > 0 {
-		am.SSE.BroadcastAlerts(alert.ProjectName, &payload)
-	}
+	// Projects without clients are skipped by the registry
+	am.SSE.BroadcastAlerts(alert.ProjectName, alert.EventID(), &payload)
 	indexName := fmt.Sprintf("alerts-%s-%s", strings.ToLower(strings.ReplaceAll(alert.ProjectName, " ", "_")), alert.Timestamp.Format("2006-01-02"))
 
 	for _, method := range alert.Methods {
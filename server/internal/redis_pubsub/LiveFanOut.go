@@ -0,0 +1,246 @@
+package redis_pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"server/internal/models"
+	serversentevents "server/internal/services/server_sent_events"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	liveLogsPrefix    = "live:logs:"    // followed by the project
+	liveMetricsPrefix = "live:metrics:" // followed by the project
+
+	liveQueueSize = 1024
+	// liveListenersTTL is how long the subscriber count of a channel is cached before
+	// publishing to it again checks for listeners
+	liveListenersTTL = time.Second
+	// liveResyncInterval bounds how long the subscriptions can miss a change of the registries
+	liveResyncInterval = 30 * time.Second
+)
+
+// LiveFanOut relays consumed logs and metrics through Redis pub/sub, so live stream clients
+// receive them on every instance and not only on the one consuming the partition. Each
+// instance subscribes to the channels of the projects its clients watch and hands what it
+// receives to its registries. Alerts already reach every instance through the alerts channel.
+type LiveFanOut struct {
+	Redis   *redis.Client
+	Logs    *serversentevents.SSELogService
+	Metrics *serversentevents.SSEMetricsService
+
+	queue   chan liveMessage
+	changed chan struct{}
+	// listeners caches the subscriber count per channel, used by the publish goroutine only
+	listeners map[string]listenerCount
+}
+
+type liveMessage struct {
+	channel string
+	project string
+	payload interface{} // *models.Log or *models.Metrics
+}
+
+type listenerCount struct {
+	count   int64
+	checked time.Time
+}
+
+func NewLiveFanOut(redis *redis.Client, logs *serversentevents.SSELogService, metrics *serversentevents.SSEMetricsService) *LiveFanOut {
+	f := &LiveFanOut{
+		Redis:     redis,
+		Logs:      logs,
+		Metrics:   metrics,
+		queue:     make(chan liveMessage, liveQueueSize),
+		changed:   make(chan struct{}, 1),
+		listeners: make(map[string]listenerCount),
+	}
+	logs.OnProjectsChange(f.notify)
+	metrics.OnProjectsChange(f.notify)
+	return f
+}
+
+// BroadcastLogs publishes the log to the instances whose clients watch the project. It never
+// blocks the consumer, logs are dropped when the queue is full.
+func (f *LiveFanOut) BroadcastLogs(project string, logEntry *models.Log) {
+	f.enqueue(liveMessage{channel: liveLogsPrefix + project, project: project, payload: logEntry})
+}
+
+// BroadcastMetrics publishes the metrics to the instances whose clients watch the project
+func (f *LiveFanOut) BroadcastMetrics(project string, metrics *models.Metrics) {
+	f.enqueue(liveMessage{channel: liveMetricsPrefix + project, project: project, payload: metrics})
+}
+
+func (f *LiveFanOut) enqueue(msg liveMessage) {
+	select {
+	case f.queue <- msg:
+	default:
+		log.Printf("Live fan-out queue full, dropping message for %s", msg.channel)
+	}
+}
+
+// notify is called by the registries with their lock held, a pending signal is enough
+func (f *LiveFanOut) notify() {
+	select {
+	case f.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Start publishes and receives live messages until the context is cancelled. Publishing starts
+// first, so while Redis is unreachable the clients of this instance still receive its messages,
+// and the subscriptions are retried every liveResyncInterval.
+func (f *LiveFanOut) Start(ctx context.Context) error {
+	pubsub := f.Redis.Subscribe(ctx)
+	defer func(pubsub *redis.PubSub) {
+		if err := pubsub.Close(); err != nil {
+			log.Printf("Failed to close live fan-out subscription: %v", err)
+		}
+	}(pubsub)
+
+	go f.publish(ctx)
+	subscribed := make(map[string]bool)
+	if err := f.sync(ctx, pubsub, subscribed); err != nil {
+		log.Printf("Failed to subscribe to live channels, retrying in %s: %v", liveResyncInterval, err)
+	}
+	log.Println("Live fan-out started")
+
+	ticker := time.NewTicker(liveResyncInterval)
+	defer ticker.Stop()
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-f.changed:
+			if err := f.sync(ctx, pubsub, subscribed); err != nil {
+				log.Printf("Failed to update live channel subscriptions: %v", err)
+			}
+		case <-ticker.C:
+			if err := f.sync(ctx, pubsub, subscribed); err != nil {
+				log.Printf("Failed to update live channel subscriptions: %v", err)
+			}
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			f.receive(msg)
+		}
+	}
+}
+
+// sync subscribes to the channels of the projects with clients in the registries and
+// unsubscribes from the others
+func (f *LiveFanOut) sync(ctx context.Context, pubsub *redis.PubSub, subscribed map[string]bool) error {
+	wanted := make(map[string]bool)
+	for _, project := range f.Logs.Projects() {
+		wanted[liveLogsPrefix+project] = true
+	}
+	for _, project := range f.Metrics.Projects() {
+		wanted[liveMetricsPrefix+project] = true
+	}
+
+	var add, remove []string
+	for channel := range wanted {
+		if !subscribed[channel] {
+			add = append(add, channel)
+		}
+	}
+	for channel := range subscribed {
+		if !wanted[channel] {
+			remove = append(remove, channel)
+		}
+	}
+
+	if len(add) > 0 {
+		if err := pubsub.Subscribe(ctx, add...); err != nil {
+			return err
+		}
+		for _, channel := range add {
+			subscribed[channel] = true
+		}
+	}
+	if len(remove) > 0 {
+		if err := pubsub.Unsubscribe(ctx, remove...); err != nil {
+			return err
+		}
+		for _, channel := range remove {
+			delete(subscribed, channel)
+		}
+	}
+	return nil
+}
+
+// receive hands a message published by any instance to the clients of this one
+func (f *LiveFanOut) receive(msg *redis.Message) {
+	if project, ok := strings.CutPrefix(msg.Channel, liveLogsPrefix); ok {
+		var logEntry models.Log
+		if err := json.Unmarshal([]byte(msg.Payload), &logEntry); err != nil {
+			log.Printf("Failed to parse live log from %s: %v", msg.Channel, err)
+			return
+		}
+		f.Logs.BroadcastLogs(project, &logEntry)
+		return
+	}
+	if project, ok := strings.CutPrefix(msg.Channel, liveMetricsPrefix); ok {
+		var metrics models.Metrics
+		if err := json.Unmarshal([]byte(msg.Payload), &metrics); err != nil {
+			log.Printf("Failed to parse live metrics from %s: %v", msg.Channel, err)
+			return
+		}
+		f.Metrics.BroadcastMetrics(project, &metrics)
+	}
+}
+
+// publish sends the queued messages to the channels that have subscribers. When Redis cannot
+// be reached the message still goes to the clients of this instance.
+func (f *LiveFanOut) publish(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-f.queue:
+			if !f.hasListeners(ctx, msg.channel) {
+				continue
+			}
+			data, err := json.Marshal(msg.payload)
+			if err != nil {
+				log.Printf("Failed to marshal live message for %s: %v", msg.channel, err)
+				continue
+			}
+			if err := f.Redis.Publish(ctx, msg.channel, data).Err(); err != nil {
+				log.Printf("Failed to publish live message to %s: %v", msg.channel, err)
+				f.deliverLocally(msg)
+			}
+		}
+	}
+}
+
+// hasListeners tells whether any instance subscribes to the channel, so logs of projects nobody
+// watches are not published. A new subscriber may miss up to liveListenersTTL of messages.
+func (f *LiveFanOut) hasListeners(ctx context.Context, channel string) bool {
+	cached, ok := f.listeners[channel]
+	if ok && time.Since(cached.checked) < liveListenersTTL {
+		return cached.count > 0
+	}
+	counts, err := f.Redis.PubSubNumSub(ctx, channel).Result()
+	if err != nil {
+		// Publishing reports the error and falls back to the local clients
+		return true
+	}
+	f.listeners[channel] = listenerCount{count: counts[channel], checked: time.Now()}
+	return counts[channel] > 0
+}
+
+func (f *LiveFanOut) deliverLocally(msg liveMessage) {
+	switch payload := msg.payload.(type) {
+	case *models.Log:
+		f.Logs.BroadcastLogs(msg.project, payload)
+	case *models.Metrics:
+		f.Metrics.BroadcastMetrics(msg.project, payload)
+	}
+}
@@ -0,0 +1,150 @@
+package redis_pubsub
+
+import (
+	"context"
+	"server/internal/models"
+	serversentevents "server/internal/services/server_sent_events"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestFanOut(t *testing.T, addr string) (*LiveFanOut, *serversentevents.SSELogService) {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	t.Cleanup(func() { _ = client.Close() })
+	logs := serversentevents.NewSSELogsService()
+	return NewLiveFanOut(client, logs, serversentevents.NewSSEMetricsService()), logs
+}
+
+func startFanOut(t *testing.T, f *LiveFanOut) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := f.Start(ctx); err != nil {
+			t.Errorf("Start: %v", err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// registerClient adds a logs client of the project and returns its channel
+func registerClient(t *testing.T, logs *serversentevents.SSELogService, project string) chan models.Log {
+	t.Helper()
+	session := &serversentevents.Session{ID: "session-" + project, User: "user"}
+	logs.RegisterLogsClient(session.ID, session, project, nil, nil)
+	ch, ok := logs.GetLogClientChannel(session.ID)
+	if !ok {
+		t.Fatal("client channel not found")
+	}
+	t.Cleanup(func() { logs.UnregisterLogsClient(session.ID) })
+	return ch
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func receiveLog(t *testing.T, ch chan models.Log) models.Log {
+	t.Helper()
+	select {
+	case logEntry := <-ch:
+		return logEntry
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the log")
+		return models.Log{}
+	}
+}
+
+func TestLiveFanOutAcrossInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	publisher, _ := newTestFanOut(t, mr.Addr())
+	subscriber, subscriberLogs := newTestFanOut(t, mr.Addr())
+	ch := registerClient(t, subscriberLogs, "shop")
+	startFanOut(t, publisher)
+	startFanOut(t, subscriber)
+
+	waitFor(t, "the subscription", func() bool {
+		return mr.PubSubNumSub(liveLogsPrefix + "shop")[liveLogsPrefix+"shop"] > 0
+	})
+
+	publisher.BroadcastLogs("shop", &models.Log{ServiceName: "shop", Message: "order placed", EventID: "0:42"})
+	got := receiveLog(t, ch)
+	if got.Message != "order placed" || got.EventID != "0:42" {
+		t.Errorf("received %+v, want the published log", got)
+	}
+
+	// Projects nobody watches are not published
+	publisher.BroadcastLogs("billing", &models.Log{ServiceName: "billing", Message: "invoice"})
+	select {
+	case logEntry := <-ch:
+		t.Errorf("received %+v from another project", logEntry)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLiveFanOutSync(t *testing.T) {
+	mr := miniredis.RunT(t)
+	f, logs := newTestFanOut(t, mr.Addr())
+	registerClient(t, logs, "shop")
+
+	ctx := context.Background()
+	pubsub := f.Redis.Subscribe(ctx)
+	defer pubsub.Close()
+
+	// A channel of a project without clients is dropped, the channels of watched ones are added
+	stale := liveLogsPrefix + "gone"
+	if err := pubsub.Subscribe(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+	subscribed := map[string]bool{stale: true}
+	if err := f.sync(ctx, pubsub, subscribed); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{liveLogsPrefix + "shop": true}
+	if len(subscribed) != len(want) || !subscribed[liveLogsPrefix+"shop"] {
+		t.Errorf("subscribed = %v, want %v", subscribed, want)
+	}
+	waitFor(t, "the subscriptions to update", func() bool {
+		counts := mr.PubSubNumSub(liveLogsPrefix+"shop", stale)
+		return counts[liveLogsPrefix+"shop"] == 1 && counts[stale] == 0
+	})
+
+	// Syncing again without changes leaves the subscriptions alone
+	if err := f.sync(ctx, pubsub, subscribed); err != nil {
+		t.Fatal(err)
+	}
+	if len(subscribed) != 1 {
+		t.Errorf("subscribed = %v after a sync without changes", subscribed)
+	}
+}
+
+func TestLiveFanOutDeliversLocallyWhenRedisIsDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+
+	f, logs := newTestFanOut(t, addr)
+	ch := registerClient(t, logs, "shop")
+	startFanOut(t, f)
+
+	f.BroadcastLogs("shop", &models.Log{ServiceName: "shop", Message: "still delivered"})
+	if got := receiveLog(t, ch); got.Message != "still delivered" {
+		t.Errorf("received %+v, want the local log", got)
+	}
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"server/internal/api/dto"
 	"server/internal/models"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v9"
 	"gorm.io/gorm"
@@ -11,6 +12,7 @@ import (
 type AlertsRepo interface {
 	GetAlertRules(project string) ([]*models.Alert, error)
 	GetAlerts(project string) (*[]dto.AlertMessage, error)
+	GetAlertsAfter(project string, since time.Time, limit int) ([]dto.AlertMessage, error)
 	CreateAlert(alert *models.Alert) (*models.Alert, error)
 	UpdateAlert(alert *models.Alert) error
 	DeleteAlert(id string) error
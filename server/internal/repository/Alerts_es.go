@@ -7,6 +7,7 @@ import (
 	"io"
 	"server/internal/api/dto"
 	"strings"
+	"time"
 )
 
 func (a *AlertRepo) GetAlerts(project string) (*[]dto.AlertMessage, error) {
@@ -85,3 +86,63 @@ func (a *AlertRepo) GetAlerts(project string) (*[]dto.AlertMessage, error) {
 
 	return &alerts, nil
 }
+
+// GetAlertsAfter returns up to limit alerts of the project raised at or after since, oldest first
+func (a *AlertRepo) GetAlertsAfter(project string, since time.Time, limit int) ([]dto.AlertMessage, error) {
+
+	index := fmt.Sprintf("alerts-%s-*", project)
+
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"range": map[string]interface{}{
+				"timestamp": map[string]interface{}{
+					"gte": since.Format(time.RFC3339Nano),
+				},
+			},
+		},
+		"sort": []map[string]interface{}{
+			{"timestamp": map[string]interface{}{"order": "asc"}},
+		},
+		"size": limit,
+	}
+
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	res, err := a.es.Search(a.es.Search.WithContext(context.Background()),
+		a.es.Search.WithIndex(index),
+		a.es.Search.WithBody(strings.NewReader(string(queryBytes))),
+		a.es.Search.WithIgnoreUnavailable(true),
+		a.es.Search.WithAllowNoIndices(true))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	bodyBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("elasticsearch returned error: %s", string(bodyBytes))
+	}
+
+	var searchResult struct {
+		Hits struct {
+			Hits []struct {
+				Source dto.AlertMessage `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(bodyBytes, &searchResult); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal search result: %w", err)
+	}
+
+	alerts := make([]dto.AlertMessage, 0, len(searchResult.Hits.Hits))
+	for _, hit := range searchResult.Hits.Hits {
+		alerts = append(alerts, hit.Source)
+	}
+	return alerts, nil
+}
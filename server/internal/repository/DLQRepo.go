@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"errors"
+	"server/internal/models"
+)
+
+var ErrDLQRecordNotFound = errors.New("dead letter record not found")
+
+type DLQRepo interface {
+	ListRecords(topic string, limit int) ([]*models.DLQRecord, error)
+	GetRecord(topic string, partition int32, offset int64) (*models.DLQRecord, error)
+	ReplayRecord(topic string, partition int32, offset int64) (*models.DLQReplayResult, error)
+}
@@ -13,6 +13,8 @@ type ProjectRepo interface {
 	GetProjectByName(name string) (*models.Project, error)
 	GetProjectsCount() (int64, error)
 	GetLogs(projectName string) ([]*models.Log, error)
+	GetActiveProjectNames() ([]string, error)
+	GetActiveProjects() ([]*models.Project, error)
 	GetRecentProjects(projectNames string) ([]*models.Project, error)
 	UpsertKeyStore(keyStore *models.KeyStore) error
 }
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"server/config"
+	"server/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// dlqReadTimeout bounds how long a read waits for records that are expected in a partition
+const dlqReadTimeout = 5 * time.Second
+
+type dlqKafka struct {
+	dlq *config.DeadLetterQueue
+}
+
+func NewDLQRepo(dlq *config.DeadLetterQueue) DLQRepo {
+	return &dlqKafka{dlq: dlq}
+}
+
+// ListRecords returns the latest records of the dead letter topic across all partitions,
+// newest first. Payloads are left out, use GetRecord to inspect one.
+func (d *dlqKafka) ListRecords(topic string, limit int) ([]*models.DLQRecord, error) {
+	client := d.dlq.Client()
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		if errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+			return []*models.DLQRecord{}, nil
+		}
+		return nil, fmt.Errorf("failed to get partitions of %s: %w", topic, err)
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	records := make([]*models.DLQRecord, 0)
+	for _, partition := range partitions {
+		oldest, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get oldest offset of %s[%d]: %w", topic, partition, err)
+		}
+		newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get newest offset of %s[%d]: %w", topic, partition, err)
+		}
+
+		messages, err := readRange(consumer, topic, partition, max(oldest, newest-int64(limit)), newest)
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range messages {
+			record := toDLQRecord(message)
+			record.Payload = nil
+			records = append(records, record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Timestamp.After(records[j].Timestamp)
+	})
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+func (d *dlqKafka) GetRecord(topic string, partition int32, offset int64) (*models.DLQRecord, error) {
+	message, err := d.getMessage(topic, partition, offset)
+	if err != nil {
+		return nil, err
+	}
+	return toDLQRecord(message), nil
+}
+
+// ReplayRecord produces the original key, value and headers back to the topic the dead letter
+// topic belongs to, so the consumers pick the record up again.
+func (d *dlqKafka) ReplayRecord(topic string, partition int32, offset int64) (*models.DLQReplayResult, error) {
+	message, err := d.getMessage(topic, partition, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers)+1)
+	for _, h := range message.Headers {
+		if h != nil && !strings.HasPrefix(string(h.Key), "dlq.") {
+			headers = append(headers, *h)
+		}
+	}
+	headers = append(headers, sarama.RecordHeader{
+		Key:   []byte("dlq.replayed.from"),
+		Value: []byte(fmt.Sprintf("%s[%d]@%d", topic, partition, offset)),
+	})
+
+	msg := &sarama.ProducerMessage{
+		Topic:   strings.TrimPrefix(topic, "dlq-"),
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	}
+	if message.Key != nil {
+		msg.Key = sarama.ByteEncoder(message.Key)
+	}
+
+	replayedPartition, replayedOffset, err := d.dlq.Produce(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay %s[%d]@%d: %w", topic, partition, offset, err)
+	}
+	log.Printf("Replayed %s[%d]@%d to %s[%d]@%d", topic, partition, offset, msg.Topic, replayedPartition, replayedOffset)
+
+	return &models.DLQReplayResult{
+		Partition:         partition,
+		Offset:            offset,
+		Replayed:          true,
+		ReplayedTopic:     msg.Topic,
+		ReplayedPartition: replayedPartition,
+		ReplayedOffset:    replayedOffset,
+	}, nil
+}
+
+func (d *dlqKafka) getMessage(topic string, partition int32, offset int64) (*sarama.ConsumerMessage, error) {
+	consumer, err := sarama.NewConsumerFromClient(d.dlq.Client())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	messages, err := readRange(consumer, topic, partition, offset, offset+1)
+	if err != nil {
+		if errors.Is(err, sarama.ErrUnknownTopicOrPartition) || errors.Is(err, sarama.ErrOffsetOutOfRange) {
+			return nil, ErrDLQRecordNotFound
+		}
+		return nil, err
+	}
+	if len(messages) == 0 || messages[0].Offset != offset {
+		return nil, ErrDLQRecordNotFound
+	}
+	return messages[0], nil
+}
+
+// readRange reads the messages of a partition in [from, to). It stops early when no message
+// arrives within dlqReadTimeout, since compaction and transaction markers leave offset gaps.
+func readRange(consumer sarama.Consumer, topic string, partition int32, from, to int64) ([]*sarama.ConsumerMessage, error) {
+	if from >= to {
+		return nil, nil
+	}
+	pc, err := consumer.ConsumePartition(topic, partition, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s[%d] from offset %d: %w", topic, partition, from, err)
+	}
+	defer pc.Close()
+
+	var messages []*sarama.ConsumerMessage
+	timeout := time.NewTimer(dlqReadTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case message, ok := <-pc.Messages():
+			if !ok || message.Offset >= to {
+				return messages, nil
+			}
+			messages = append(messages, message)
+			if message.Offset >= to-1 {
+				return messages, nil
+			}
+			timeout.Reset(dlqReadTimeout)
+		case <-timeout.C:
+			return messages, nil
+		}
+	}
+}
+
+func toDLQRecord(message *sarama.ConsumerMessage) *models.DLQRecord {
+	record := &models.DLQRecord{
+		Topic:       message.Topic,
+		Partition:   message.Partition,
+		Offset:      message.Offset,
+		Key:         string(message.Key),
+		Payload:     message.Value,
+		PayloadSize: len(message.Value),
+		Headers:     make(map[string]string, len(message.Headers)),
+		Timestamp:   message.Timestamp,
+	}
+	for _, h := range message.Headers {
+		if h == nil {
+			continue
+		}
+		value := string(h.Value)
+		record.Headers[string(h.Key)] = value
+		switch string(h.Key) {
+		case config.DLQHeaderError:
+			record.Error = value
+		case config.DLQHeaderSourceTopic:
+			record.SourceTopic = value
+		case config.DLQHeaderSourcePartition:
+			if p, err := strconv.ParseInt(value, 10, 32); err == nil {
+				record.SourcePartition = int32(p)
+			}
+		case config.DLQHeaderSourceOffset:
+			if o, err := strconv.ParseInt(value, 10, 64); err == nil {
+				record.SourceOffset = o
+			}
+		case config.DLQHeaderFailedAt:
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				record.FailedAt = t
+			}
+		}
+	}
+	return record
+}
@@ -1,12 +1,29 @@
 package repository
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"server/internal/api/dto"
 	"server/internal/models"
 )
 
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrCursorExpired = errors.New("cursor expired, start again from the first page")
+
+	ErrTooManyBuckets = errors.New("the aggregation returns too many buckets, use a larger interval or a shorter time range")
+)
+
 type LogRepo interface {
 	GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error)
+	GetLogsPage(filters *dto.LogFilter) (*models.LogPage, error)
+	ExportLogs(ctx context.Context, filters *dto.LogFilter, fn func(lg *models.Log) error) error
+	GetLogsAfter(index string, position models.StreamPosition, limit int) ([]*models.Log, error)
+	GetLogsPosition(index string) (models.StreamPosition, error)
+	GetTraceLogs(index string, services []string, id string, limit int) ([]*models.Log, int64, error)
+	GetLogsFieldTypes(index string) (map[string]string, error)
+	AggregateLogs(filters *dto.LogFilter, aggs map[string]interface{}, runtimeMappings map[string]interface{}) (int64, map[string]json.RawMessage, error)
 	GetLogsAvailabilities(projectName string) ([]string, error)
 	GetLogsFromArchiveStorage(ProjectName string, fileName string, filters *dto.LogFilter) ([]*models.Log, int64, error)
 	GetArchiveLogMinMaxDate(projectName string, fileName string) ([]string, error)
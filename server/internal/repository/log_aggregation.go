@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"server/internal/api/dto"
+	"strings"
+)
+
+// GetLogsFieldTypes returns the type of every field mapped in the logs indices behind the alias,
+// subfields included as name.subfield. Rollover indices share a template, so the first type
+// seen for a field wins.
+func (l *LogES) GetLogsFieldTypes(index string) (map[string]string, error) {
+	res, err := l.es.Indices.GetMapping(l.es.Indices.GetMapping.WithIndex(index))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mapping of %s: %w", index, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("failed to get mapping of %s: %s", index, string(body))
+	}
+
+	var indices map[string]struct {
+		Mappings fieldMapping `json:"mappings"`
+	}
+	if err := json.Unmarshal(body, &indices); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mapping: %w", err)
+	}
+
+	types := map[string]string{}
+	for _, index := range indices {
+		flattenMapping("", index.Mappings.Properties, types)
+	}
+	return types, nil
+}
+
+type fieldMapping struct {
+	Type       string                  `json:"type"`
+	Properties map[string]fieldMapping `json:"properties"`
+	Fields     map[string]fieldMapping `json:"fields"`
+}
+
+func flattenMapping(prefix string, properties map[string]fieldMapping, types map[string]string) {
+	for name, mapping := range properties {
+		path := prefix + name
+		if len(mapping.Properties) > 0 {
+			flattenMapping(path+".", mapping.Properties, types)
+			continue
+		}
+		if _, ok := types[path]; !ok && mapping.Type != "" {
+			types[path] = mapping.Type
+		}
+		for sub, subMapping := range mapping.Fields {
+			if _, ok := types[path+"."+sub]; !ok {
+				types[path+"."+sub] = subMapping.Type
+			}
+		}
+	}
+}
+
+// AggregateLogs runs aggregations over the logs matching the filters and returns the number of
+// matching logs with the raw aggregations of the response
+func (l *LogES) AggregateLogs(filters *dto.LogFilter, aggs map[string]interface{}, runtimeMappings map[string]interface{}) (int64, map[string]json.RawMessage, error) {
+	if filters.Project == "" {
+		return 0, nil, fmt.Errorf("project name is required")
+	}
+
+	query, err := buildLogsQuery(filters)
+	if err != nil {
+		return 0, nil, err
+	}
+	delete(query, "highlight")
+	delete(query, "sort")
+	delete(query, "_source")
+	query["size"] = 0
+	query["aggs"] = aggs
+	if len(runtimeMappings) > 0 {
+		query["runtime_mappings"] = runtimeMappings
+	}
+
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+	res, err := l.es.Search(
+		l.es.Search.WithIndex(filters.Project),
+		l.es.Search.WithBody(strings.NewReader(string(queryBytes))),
+		l.es.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return 0, nil, fmt.Errorf("elasticsearch search failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if res.IsError() {
+		if strings.Contains(string(body), "too_many_buckets_exception") {
+			return 0, nil, ErrTooManyBuckets
+		}
+		return 0, nil, fmt.Errorf("elasticsearch returned error: %s", string(body))
+	}
+
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations map[string]json.RawMessage `json:"aggregations"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return 0, nil, fmt.Errorf("failed to unmarshal aggregations: %w", err)
+	}
+	return result.Hits.Total.Value, result.Aggregations, nil
+}
@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"server/internal/api/dto"
+	"server/internal/models"
+	"slices"
+	"strings"
+)
+
+// logsPitKeepAlive is how long a cursor stays valid after its page was read
+const logsPitKeepAlive = "5m"
+
+// logCursor is the state behind the opaque cursor token: the point in time the pages are read
+// from, the sort values to continue after and a hash of the project and filters it belongs to.
+type logCursor struct {
+	PitID    string        `json:"pit"`
+	After    []interface{} `json:"after"`
+	Order    string        `json:"order"`
+	Backward bool          `json:"backward,omitempty"`
+	Filters  string        `json:"filters"`
+}
+
+func (c *logCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeLogCursor(token string) (*logCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	cursor := &logCursor{}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(cursor); err != nil || cursor.PitID == "" || len(cursor.After) == 0 || cursor.Filters == "" {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Order != "asc" && cursor.Order != "desc" {
+		return nil, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// logFiltersHash identifies the project and query a cursor was issued for. A cursor only
+// continues the pages of the same search, the sort values mean nothing for other filters.
+func logFiltersHash(project string, query map[string]interface{}) string {
+	data, _ := json.Marshal([]interface{}{project, query["query"]})
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// logsSort orders logs by time. Ties are broken by partition and offset, the parts of the
+// document _id (topic-partition-offset) that differ within a project, since _id itself
+// cannot be sorted on. Elasticsearch appends _shard_doc to the sort values of PIT searches.
+func logsSort(order string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{"timestamp": map[string]interface{}{"order": order}},
+		{"partition": map[string]interface{}{"order": order, "unmapped_type": "integer"}},
+		{"offset": map[string]interface{}{"order": order, "unmapped_type": "long"}},
+	}
+}
+
+func reverseOrder(order string) string {
+	if order == "desc" {
+		return "asc"
+	}
+	return "desc"
+}
+
+// GetLogsPage reads a page of logs with a point in time and search_after, so pages stay stable
+// while new logs arrive and are not limited by the result window. Without filters.Cursor the
+// first page is read and a new point in time is opened.
+func (l *LogES) GetLogsPage(filters *dto.LogFilter) (*models.LogPage, error) {
+	if filters.Project == "" {
+		return nil, fmt.Errorf("project name is required")
+	}
+
+	query, err := buildLogsQuery(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	filtersHash := logFiltersHash(filters.Project, query)
+	cursor := &logCursor{Order: filters.SortByDate}
+	if cursor.Order != "desc" {
+		cursor.Order = "asc"
+	}
+	if filters.Cursor != "" {
+		cursor, err = decodeLogCursor(filters.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Filters != filtersHash {
+			return nil, fmt.Errorf("%w: it was issued for other filters or another project", ErrInvalidCursor)
+		}
+	} else {
+		cursor.PitID, err = l.openPointInTime(filters.Project)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	order := cursor.Order
+	if cursor.Backward {
+		order = reverseOrder(order)
+	}
+
+	// one extra hit tells whether there is another page in the direction we read
+	query["size"] = limit + 1
+	query["sort"] = logsSort(order)
+	query["pit"] = map[string]interface{}{"id": cursor.PitID, "keep_alive": logsPitKeepAlive}
+	if len(cursor.After) > 0 {
+		query["search_after"] = cursor.After
+	}
+
+	searchResult, err := l.searchLogs(query)
+	if err != nil {
+		if filters.Cursor == "" {
+			l.closePointInTime(cursor.PitID)
+		}
+		return nil, err
+	}
+
+	hits := searchResult.Hits.Hits
+	hasMore := len(hits) > limit
+	if hasMore {
+		hits = hits[:limit]
+	}
+	if cursor.Backward {
+		slices.Reverse(hits)
+	}
+
+	pitID := searchResult.PitID
+	if pitID == "" {
+		pitID = cursor.PitID
+	}
+	// A first page with all the logs has no cursor to read the point in time with
+	if filters.Cursor == "" && !hasMore {
+		l.closePointInTime(pitID)
+	}
+
+	page := &models.LogPage{
+		Logs:  toLogModels(hits),
+		Total: searchResult.Hits.Total.Value,
+	}
+	if len(hits) == 0 {
+		return page, nil
+	}
+	first, last := hits[0].Sort, hits[len(hits)-1].Sort
+
+	// Reading forward there is a previous page unless this is the first one, reading backward
+	// there is always a next page
+	if hasMore || cursor.Backward {
+		page.NextCursor = (&logCursor{PitID: pitID, After: last, Order: cursor.Order, Filters: filtersHash}).encode()
+	}
+	if (cursor.Backward && hasMore) || (!cursor.Backward && len(cursor.After) > 0) {
+		page.PrevCursor = (&logCursor{PitID: pitID, After: first, Order: cursor.Order, Backward: true, Filters: filtersHash}).encode()
+	}
+	return page, nil
+}
+
+func (l *LogES) openPointInTime(index string) (string, error) {
+	res, err := l.es.OpenPointInTime([]string{index}, logsPitKeepAlive)
+	if err != nil {
+		return "", fmt.Errorf("failed to open point in time on %s: %w", index, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	if res.IsError() {
+		return "", fmt.Errorf("failed to open point in time on %s: %s", index, string(body))
+	}
+
+	var pit struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &pit); err != nil {
+		return "", fmt.Errorf("failed to unmarshal point in time: %w", err)
+	}
+	return pit.ID, nil
+}
+
+// logsExportBatchSize is the number of hits read per request while exporting
+const logsExportBatchSize = 2000
+
+// ExportLogs walks every log matching the filters in sort order with a point in time, passing
+// them to fn one at a time. filters.Limit caps the number of logs, zero exports all of them.
+// It stops at the first error returned by fn or when ctx is done.
+func (l *LogES) ExportLogs(ctx context.Context, filters *dto.LogFilter, fn func(lg *models.Log) error) error {
+	if filters.Project == "" {
+		return fmt.Errorf("project name is required")
+	}
+
+	query, err := buildLogsQuery(filters)
+	if err != nil {
+		return err
+	}
+	delete(query, "highlight")
+
+	order := "asc"
+	if filters.SortByDate == "desc" {
+		order = "desc"
+	}
+
+	pitID, err := l.openPointInTime(filters.Project)
+	if err != nil {
+		return err
+	}
+	defer func() { l.closePointInTime(pitID) }()
+
+	query["sort"] = logsSort(order)
+	query["track_total_hits"] = false
+	exported := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		size := logsExportBatchSize
+		if filters.Limit > 0 {
+			size = min(size, filters.Limit-exported)
+		}
+		query["size"] = size
+		query["pit"] = map[string]interface{}{"id": pitID, "keep_alive": logsPitKeepAlive}
+
+		searchResult, err := l.searchLogs(query, l.es.Search.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		if searchResult.PitID != "" {
+			pitID = searchResult.PitID
+		}
+
+		hits := searchResult.Hits.Hits
+		for _, lg := range toLogModels(hits) {
+			if err := fn(lg); err != nil {
+				return err
+			}
+		}
+		exported += len(hits)
+		if len(hits) < size || (filters.Limit > 0 && exported >= filters.Limit) {
+			return nil
+		}
+		query["search_after"] = hits[len(hits)-1].Sort
+	}
+}
+
+func (l *LogES) closePointInTime(pitID string) {
+	body, _ := json.Marshal(map[string]string{"id": pitID})
+	res, err := l.es.ClosePointInTime(l.es.ClosePointInTime.WithBody(strings.NewReader(string(body))))
+	if err != nil {
+		log.Printf("Failed to close point in time: %v", err)
+		return
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		log.Printf("Failed to close point in time: %s", res.String())
+	}
+}
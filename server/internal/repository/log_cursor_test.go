@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"server/internal/api/dto"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v9"
+)
+
+func TestLogCursorRoundTrip(t *testing.T) {
+	cursors := []*logCursor{
+		{PitID: "pit-1", After: []interface{}{json.Number("1718000000000"), json.Number("3"), json.Number("9007199254740993")}, Order: "asc", Filters: "abc"},
+		{PitID: "pit-2", After: []interface{}{json.Number("1"), "x"}, Order: "desc", Backward: true, Filters: "def"},
+	}
+	for _, cursor := range cursors {
+		token := cursor.encode()
+		if strings.ContainsAny(token, "+/=") {
+			t.Errorf("encode() = %q, want a URL-safe token", token)
+		}
+		got, err := decodeLogCursor(token)
+		if err != nil {
+			t.Fatalf("decodeLogCursor(%q) failed: %v", token, err)
+		}
+		if !reflect.DeepEqual(got, cursor) {
+			t.Errorf("decodeLogCursor(encode(%+v)) = %+v", cursor, got)
+		}
+	}
+}
+
+func TestDecodeLogCursorInvalid(t *testing.T) {
+	encode := func(cursor map[string]interface{}) string {
+		data, _ := json.Marshal(cursor)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "not base64", token: "!!!"},
+		{name: "not json", token: base64.RawURLEncoding.EncodeToString([]byte("pit"))},
+		{name: "no pit", token: encode(map[string]interface{}{"after": []int{1}, "order": "asc", "filters": "abc"})},
+		{name: "no sort values", token: encode(map[string]interface{}{"pit": "p", "order": "asc", "filters": "abc"})},
+		{name: "unknown order", token: encode(map[string]interface{}{"pit": "p", "after": []int{1}, "order": "up", "filters": "abc"})},
+		{name: "no filters", token: encode(map[string]interface{}{"pit": "p", "after": []int{1}, "order": "asc"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeLogCursor(tt.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeLogCursor(%q) error = %v, want ErrInvalidCursor", tt.token, err)
+			}
+		})
+	}
+}
+
+func TestLogFiltersHash(t *testing.T) {
+	query := func(level string) map[string]interface{} {
+		q, err := buildLogsQuery(&dto.LogFilter{Project: "shop", Level: level})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q
+	}
+	base := logFiltersHash("shop", query("error"))
+	if got := logFiltersHash("shop", query("error")); got != base {
+		t.Errorf("logFiltersHash is not stable: %q != %q", got, base)
+	}
+	if got := logFiltersHash("billing", query("error")); got == base {
+		t.Errorf("logFiltersHash does not depend on the project")
+	}
+	if got := logFiltersHash("shop", query("info")); got == base {
+		t.Errorf("logFiltersHash does not depend on the filters")
+	}
+}
+
+// esResponder answers every Elasticsearch request with the given status and body
+type esResponder struct {
+	status   int
+	body     string
+	requests []string
+}
+
+func (r *esResponder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Elastic-Product", "Elasticsearch")
+	return &http.Response{
+		StatusCode: r.status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(r.body)),
+		Request:    req,
+	}, nil
+}
+
+func newTestLogES(t *testing.T, responder *esResponder) *LogES {
+	t.Helper()
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Transport: responder})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &LogES{es: client}
+}
+
+func TestGetLogsPageCursorFromOtherFilters(t *testing.T) {
+	responder := &esResponder{status: http.StatusOK, body: `{}`}
+	repo := newTestLogES(t, responder)
+
+	filters := &dto.LogFilter{Project: "shop", Level: "error"}
+	query, err := buildLogsQuery(filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor := &logCursor{PitID: "pit", After: []interface{}{1}, Order: "asc", Filters: logFiltersHash("shop", query)}
+
+	tests := []struct {
+		name    string
+		filters *dto.LogFilter
+	}{
+		{name: "other filters", filters: &dto.LogFilter{Project: "shop", Level: "info"}},
+		{name: "other project", filters: &dto.LogFilter{Project: "billing", Level: "error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filters.Cursor = cursor.encode()
+			if _, err := repo.GetLogsPage(tt.filters); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("GetLogsPage error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+	if len(responder.requests) != 0 {
+		t.Errorf("GetLogsPage searched with a foreign cursor: %v", responder.requests)
+	}
+}
+
+func TestGetLogsPageCursorExpired(t *testing.T) {
+	responder := &esResponder{
+		status: http.StatusNotFound,
+		body:   `{"error":{"root_cause":[{"type":"search_context_missing_exception","reason":"No search context found for id [1]"}],"type":"search_phase_execution_exception"},"status":404}`,
+	}
+	repo := newTestLogES(t, responder)
+
+	filters := &dto.LogFilter{Project: "shop"}
+	query, err := buildLogsQuery(filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filters.Cursor = (&logCursor{PitID: "pit", After: []interface{}{1}, Order: "asc", Filters: logFiltersHash("shop", query)}).encode()
+
+	if _, err := repo.GetLogsPage(filters); !errors.Is(err, ErrCursorExpired) {
+		t.Errorf("GetLogsPage error = %v, want ErrCursorExpired", err)
+	}
+	if len(responder.requests) != 1 || !strings.HasSuffix(responder.requests[0], "/_search") {
+		t.Errorf("GetLogsPage requests = %v, want one search", responder.requests)
+	}
+}
+
+func TestGetLogsPageClosesUnusedPointInTime(t *testing.T) {
+	hit := `{"_source":{"message":"hello"},"sort":[1]}`
+	tests := []struct {
+		name      string
+		limit     int
+		hits      string
+		wantClose bool
+	}{
+		{name: "no logs", limit: 10, hits: ``, wantClose: true},
+		{name: "one page", limit: 10, hits: hit, wantClose: true},
+		{name: "more pages", limit: 1, hits: hit + "," + hit, wantClose: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The same body answers the point in time and the search
+			responder := &esResponder{
+				status: http.StatusOK,
+				body:   `{"id":"pit","pit_id":"pit","hits":{"total":{"value":2},"hits":[` + tt.hits + `]}}`,
+			}
+			repo := newTestLogES(t, responder)
+
+			page, err := repo.GetLogsPage(&dto.LogFilter{Project: "shop", Limit: tt.limit})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (page.NextCursor == "") != tt.wantClose {
+				t.Errorf("NextCursor = %q", page.NextCursor)
+			}
+			closed := slices.Contains(responder.requests, "DELETE /_pit")
+			if closed != tt.wantClose {
+				t.Errorf("point in time closed = %v, want %v, requests %v", closed, tt.wantClose, responder.requests)
+			}
+		})
+	}
+}
@@ -8,10 +8,14 @@ import (
 	"log"
 	"server/internal/api/dto"
 	"server/internal/models"
+	logquery "server/internal/services/log_query"
+	"server/pkg"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v9"
+	"github.com/elastic/go-elasticsearch/v9/esapi"
 	"gorm.io/gorm"
 )
 
@@ -38,12 +42,51 @@ type LogEntry struct {
 }
 
 func (l *LogES) GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error) {
-	var logs []*models.Log
-
 	if filters.Project == "" {
 		return nil, 0, fmt.Errorf("project name is required")
 	}
 
+	query, err := buildLogsQuery(filters)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Add sorting
+	if filters.SortByDate == "desc" {
+		query["sort"] = []map[string]interface{}{
+			{
+				"timestamp": map[string]interface{}{
+					"order": "desc",
+				},
+			},
+		}
+	} else {
+		query["sort"] = []map[string]interface{}{
+			{
+				"timestamp": map[string]interface{}{
+					"order": "asc",
+				},
+			},
+		}
+	}
+
+	// Add pagination
+	if filters.Offset > 0 && filters.Limit > 0 {
+		query["from"] = (filters.Offset - 1) * filters.Limit
+		query["size"] = filters.Limit
+	} else if filters.Limit > 0 {
+		query["size"] = filters.Limit
+	}
+
+	searchResult, err := l.searchLogs(query, l.es.Search.WithIndex(filters.Project))
+	if err != nil {
+		return nil, 0, err
+	}
+	return toLogModels(searchResult.Hits.Hits), searchResult.Hits.Total.Value, nil
+}
+
+// buildLogsQuery builds the search body for the filters, without sorting and pagination
+func buildLogsQuery(filters *dto.LogFilter) (map[string]interface{}, error) {
 	// Build the Elasticsearch query
 	query := map[string]interface{}{
 		"query": map[string]interface{}{
@@ -56,6 +99,8 @@ func (l *LogES) GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error) {
 			"serviceName", "level", "message", "stack", "requestMethod",
 			"requestUrl", "requestId", "ipAddress", "userAgent",
 			"timestamp", "buildDetails", "responseStatus", "responseTime",
+			"response_time_ms", "status_code", "attributes", "typed_attributes",
+			"traceId", "spanId", "parentSpanId",
 		},
 	}
 
@@ -70,6 +115,74 @@ func (l *LogES) GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error) {
 		})
 	}
 
+	// Free text search over message and stack
+	if filters.Search != "" {
+		mustQueries = append(mustQueries, map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":    filters.Search,
+				"fields":   []string{"message", "stack"},
+				"operator": "and",
+			},
+		})
+	}
+
+	// Exact field filters
+	for _, f := range [][2]string{
+		{"requestId", filters.RequestId},
+		{"requestMethod", strings.ToUpper(filters.RequestMethod)},
+		{"ipAddress", filters.IpAddress},
+		{"userAgent.keyword", filters.UserAgent},
+		{"buildDetails.appVersion", filters.AppVersion},
+	} {
+		if f[1] != "" {
+			mustQueries = append(mustQueries, map[string]interface{}{
+				"term": map[string]interface{}{
+					f[0]: f[1],
+				},
+			})
+		}
+	}
+
+	// Attribute filters, in key order so the query is stable
+	attributeKeys := make([]string, 0, len(filters.Attributes))
+	for key := range filters.Attributes {
+		attributeKeys = append(attributeKeys, key)
+	}
+	sort.Strings(attributeKeys)
+	for _, key := range attributeKeys {
+		mustQueries = append(mustQueries, map[string]interface{}{
+			"term": map[string]interface{}{
+				"attributes." + key: filters.Attributes[key],
+			},
+		})
+	}
+
+	// Filter by response status, an exact code or a range like 5xx
+	if filters.ResponseStatus != "" {
+		from, to, err := pkg.ParseStatusRange(filters.ResponseStatus)
+		if err != nil {
+			return nil, err
+		}
+		mustQueries = append(mustQueries, logquery.StatusRangeQuery(from, to))
+	}
+
+	// Query language, e.g. level:error AND responseTime>500
+	if filters.Query != nil {
+		mustQueries = append(mustQueries, filters.Query.Compile())
+	}
+
+	// Highlight what the search or query matched in message and stack
+	if filters.Search != "" || filters.Query != nil {
+		query["highlight"] = map[string]interface{}{
+			"pre_tags":  []string{"<mark>"},
+			"post_tags": []string{"</mark>"},
+			"fields": map[string]interface{}{
+				"message": map[string]interface{}{"number_of_fragments": 0},
+				"stack":   map[string]interface{}{"fragment_size": 200, "number_of_fragments": 3},
+			},
+		}
+	}
+
 	// Filter by date range
 	if filters.From != "" || filters.To != "" {
 		rangeQuery := map[string]interface{}{
@@ -81,7 +194,7 @@ func (l *LogES) GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error) {
 		if filters.From != "" {
 			fromTime, err := time.Parse(time.RFC3339, filters.From)
 			if err != nil {
-				return nil, 0, fmt.Errorf("invalid 'from' date format: %w", err)
+				return nil, fmt.Errorf("invalid 'from' date format: %w", err)
 			}
 			rangeQuery["range"].(map[string]interface{})["timestamp"].(map[string]interface{})["gte"] = fromTime.Format(time.RFC3339)
 		}
@@ -89,7 +202,7 @@ func (l *LogES) GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error) {
 		if filters.To != "" {
 			toTime, err := time.Parse(time.RFC3339, filters.To)
 			if err != nil {
-				return nil, 0, fmt.Errorf("invalid 'to' date format: %w", err)
+				return nil, fmt.Errorf("invalid 'to' date format: %w", err)
 			}
 			adjustedToTime := toTime.Add(24 * time.Hour)
 			rangeQuery["range"].(map[string]interface{})["timestamp"].(map[string]interface{})["lt"] = adjustedToTime.Format(time.RFC3339)
@@ -107,50 +220,45 @@ func (l *LogES) GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error) {
 		query["query"].(map[string]interface{})["bool"].(map[string]interface{})["must"] = mustQueries
 	}
 
-	// Add sorting
-	if filters.SortByDate == "desc" {
-		query["sort"] = []map[string]interface{}{
-			{
-				"timestamp": map[string]interface{}{
-					"order": "desc",
-				},
-			},
-		}
-	} else {
-		query["sort"] = []map[string]interface{}{
-			{
-				"timestamp": map[string]interface{}{
-					"order": "asc",
-				},
-			},
-		}
-	}
+	return query, nil
+}
 
-	// Add pagination
-	if filters.Offset > 0 && filters.Limit > 0 {
-		query["from"] = (filters.Offset - 1) * filters.Limit
-		query["size"] = filters.Limit
-	} else if filters.Limit > 0 {
-		query["size"] = filters.Limit
-	}
+type logHit struct {
+	ID        string                 `json:"_id"`
+	Source    map[string]interface{} `json:"_source"`
+	Highlight map[string][]string    `json:"highlight"`
+	Sort      []interface{}          `json:"sort"`
+}
 
+type logSearchResult struct {
+	PitID string `json:"pit_id"`
+	Hits  struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []logHit `json:"hits"`
+	} `json:"hits"`
+}
+
+// searchLogs runs a logs search and decodes its hits
+func (l *LogES) searchLogs(query map[string]interface{}, o ...func(*esapi.SearchRequest)) (*logSearchResult, error) {
 	// Convert query to JSON
 	queryBytes, err := json.Marshal(query)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to marshal query: %w", err)
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
 	}
 
 	// Execute search
-	res, err := l.es.Search(
+	opts := append([]func(*esapi.SearchRequest){
 		l.es.Search.WithContext(context.Background()),
-		l.es.Search.WithIndex(filters.Project),
 		l.es.Search.WithBody(strings.NewReader(string(queryBytes))),
 		l.es.Search.WithTrackTotalHits(true),
 		l.es.Search.WithPretty(),
-	)
+	}, o...)
+	res, err := l.es.Search(opts...)
 
 	if err != nil {
-		return nil, 0, fmt.Errorf("elasticsearch search failed: %w", err)
+		return nil, fmt.Errorf("elasticsearch search failed: %w", err)
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -162,32 +270,31 @@ func (l *LogES) GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error) {
 	// Read response
 	bodyBytes, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	if res.IsError() {
-		return nil, 0, fmt.Errorf("elasticsearch returned error: %s", string(bodyBytes))
+		if strings.Contains(string(bodyBytes), "search_context_missing_exception") {
+			return nil, ErrCursorExpired
+		}
+		return nil, fmt.Errorf("elasticsearch returned error: %s", string(bodyBytes))
 	}
 
 	// Parse response
-	var searchResult struct {
-		Hits struct {
-			Total struct {
-				Value int64 `json:"value"`
-			} `json:"total"`
-			Hits []struct {
-				Source map[string]interface{} `json:"_source"`
-			} `json:"hits"`
-		} `json:"hits"`
-	}
-
-	if err := json.Unmarshal(bodyBytes, &searchResult); err != nil {
-		return nil, 0, fmt.Errorf("failed to unmarshal search result: %w", err)
+	searchResult := &logSearchResult{}
+	decoder := json.NewDecoder(strings.NewReader(string(bodyBytes)))
+	decoder.UseNumber() // keep sort values such as offsets exact
+	if err := decoder.Decode(searchResult); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal search result: %w", err)
 	}
+	return searchResult, nil
+}
 
-	// Convert hits to log models
-	for _, hit := range searchResult.Hits.Hits {
-		lg := &models.Log{}
+// toLogModels converts search hits to log models
+func toLogModels(hits []logHit) []*models.Log {
+	logs := make([]*models.Log, 0, len(hits))
+	for _, hit := range hits {
+		lg := &models.Log{Highlight: hit.Highlight}
 
 		// Map fields from the source
 		if serviceName, ok := hit.Source["serviceName"].(string); ok {
@@ -211,6 +318,15 @@ func (l *LogES) GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error) {
 		if requestId, ok := hit.Source["requestId"].(string); ok {
 			lg.RequestId = requestId
 		}
+		if traceId, ok := hit.Source["traceId"].(string); ok {
+			lg.TraceId = traceId
+		}
+		if spanId, ok := hit.Source["spanId"].(string); ok {
+			lg.SpanId = spanId
+		}
+		if parentSpanId, ok := hit.Source["parentSpanId"].(string); ok {
+			lg.ParentSpanId = parentSpanId
+		}
 		if ipAddress, ok := hit.Source["ipAddress"].(string); ok {
 			lg.IpAddress = ipAddress
 		}
@@ -226,6 +342,37 @@ func (l *LogES) GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error) {
 			lg.ResponseTime = responseTime
 		}
 
+		if responseTimeMs, ok := hit.Source["response_time_ms"].(json.Number); ok {
+			if ms, err := responseTimeMs.Float64(); err == nil {
+				lg.ResponseTimeMs = &ms
+			}
+		}
+		if statusCode, ok := hit.Source["status_code"].(json.Number); ok {
+			if code, err := statusCode.Int64(); err == nil {
+				c := int(code)
+				lg.StatusCode = &c
+			}
+		}
+		partition, okPartition := hit.Source["partition"].(json.Number)
+		offset, okOffset := hit.Source["offset"].(json.Number)
+		if okPartition && okOffset {
+			p, errPartition := partition.Int64()
+			o, errOffset := offset.Int64()
+			if errPartition == nil && errOffset == nil {
+				lg.EventID = models.EventID(int32(p), o)
+			}
+		}
+
+		if attributes, ok := hit.Source["attributes"].(map[string]interface{}); ok {
+			lg.Attributes = make(map[string]string, len(attributes))
+			for key, value := range attributes {
+				lg.Attributes[key] = fmt.Sprint(value)
+			}
+		}
+		if typedAttrs, ok := hit.Source["typed_attributes"].(map[string]interface{}); ok {
+			lg.TypedAttrs = typedAttrs
+		}
+
 		if timestampStr, ok := hit.Source["timestamp"].(string); ok {
 			if timestamp, err := time.Parse(time.RFC3339, timestampStr); err == nil {
 				lg.Timestamp = timestamp
@@ -250,8 +397,7 @@ func (l *LogES) GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error) {
 		logs = append(logs, lg)
 	}
 
-	return logs, searchResult.Hits.Total.Value, nil
-
+	return logs
 }
 
 func (l *LogES) GetLogsAvailabilities(projectName string) ([]string, error) {
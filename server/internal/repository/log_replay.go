@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"server/internal/models"
+	"strings"
+)
+
+// maxStreamPartitions bounds the partitions read for a stream position, topics have a few
+const maxStreamPartitions = 100
+
+// pastPositionQuery matches the events consumed after the position: those past its offset of
+// each partition, and every event of the partitions it has no offset of
+func pastPositionQuery(position models.StreamPosition) map[string]interface{} {
+	should := make([]interface{}, 0, len(position)+1)
+	known := make([]int32, 0, len(position))
+	for partition, offset := range position {
+		should = append(should, map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"partition": partition}},
+					map[string]interface{}{"range": map[string]interface{}{"offset": map[string]interface{}{"gt": offset}}},
+				},
+			},
+		})
+		known = append(known, partition)
+	}
+	should = append(should, map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must_not": []interface{}{
+				map[string]interface{}{"terms": map[string]interface{}{"partition": known}},
+			},
+		},
+	})
+	return map[string]interface{}{
+		"bool": map[string]interface{}{"should": should, "minimum_should_match": 1},
+	}
+}
+
+// pastPositionSort orders events by offset, so those of a partition come in the order they were
+// consumed and partitions interleave about as they were produced
+var pastPositionSort = []map[string]interface{}{
+	{"offset": map[string]interface{}{"order": "asc", "unmapped_type": "long"}},
+	{"partition": map[string]interface{}{"order": "asc", "unmapped_type": "integer"}},
+}
+
+// positionAggs reads the last offset stored per partition
+var positionAggs = map[string]interface{}{
+	"partitions": map[string]interface{}{
+		"terms": map[string]interface{}{"field": "partition", "size": maxStreamPartitions},
+		"aggs": map[string]interface{}{
+			"offset": map[string]interface{}{"max": map[string]interface{}{"field": "offset"}},
+		},
+	},
+}
+
+type positionAggsResult struct {
+	Aggregations struct {
+		Partitions struct {
+			Buckets []struct {
+				Key    int32 `json:"key"`
+				Offset struct {
+					Value float64 `json:"value"`
+				} `json:"offset"`
+			} `json:"buckets"`
+		} `json:"partitions"`
+	} `json:"aggregations"`
+}
+
+func (r *positionAggsResult) position() models.StreamPosition {
+	position := models.StreamPosition{}
+	for _, bucket := range r.Aggregations.Partitions.Buckets {
+		position[bucket.Key] = int64(bucket.Offset.Value)
+	}
+	return position
+}
+
+// GetLogsAfter returns up to limit logs consumed after the position, so a live stream client can
+// resume from the last log it received, see models.StreamPosition
+func (l *LogES) GetLogsAfter(index string, position models.StreamPosition, limit int) ([]*models.Log, error) {
+	searchResult, err := l.searchLogs(map[string]interface{}{
+		"query": pastPositionQuery(position),
+		"sort":  pastPositionSort,
+		"size":  limit,
+	}, l.es.Search.WithIndex(index))
+	if err != nil {
+		return nil, err
+	}
+	return toLogModels(searchResult.Hits.Hits), nil
+}
+
+// GetLogsPosition returns the last offset of the stored logs per partition
+func (l *LogES) GetLogsPosition(index string) (models.StreamPosition, error) {
+	query, err := json.Marshal(map[string]interface{}{"size": 0, "aggs": positionAggs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+	res, err := l.es.Search(
+		l.es.Search.WithIndex(index),
+		l.es.Search.WithBody(strings.NewReader(string(query))),
+		l.es.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("elasticsearch search failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("elasticsearch returned error: %s", string(body))
+	}
+	var result positionAggsResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal aggregations: %w", err)
+	}
+	return result.position(), nil
+}
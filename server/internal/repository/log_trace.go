@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"fmt"
+	"server/internal/models"
+	"strings"
+)
+
+// GetTraceLogs returns the logs the given services wrote for a trace, matching the ID as a trace
+// ID or a request ID, ordered by time. index may be a pattern covering several projects. Trace
+// IDs are indexed in lower case, request IDs as they were sent.
+func (l *LogES) GetTraceLogs(index string, services []string, id string, limit int) ([]*models.Log, int64, error) {
+	if len(services) == 0 {
+		return nil, 0, fmt.Errorf("at least one service is required")
+	}
+
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					{"term": map[string]interface{}{"traceId": strings.ToLower(id)}},
+					{"term": map[string]interface{}{"requestId": id}},
+				},
+				"minimum_should_match": 1,
+				"filter": []map[string]interface{}{
+					{"terms": map[string]interface{}{"serviceName": services}},
+				},
+			},
+		},
+		"sort": logsSort("asc"),
+		"size": limit,
+	}
+
+	searchResult, err := l.searchLogs(query,
+		l.es.Search.WithIndex(index),
+		l.es.Search.WithIgnoreUnavailable(true),
+		l.es.Search.WithAllowNoIndices(true),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return toLogModels(searchResult.Hits.Hits), searchResult.Hits.Total.Value, nil
+}
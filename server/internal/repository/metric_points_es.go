@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"server/internal/api/dto"
+	"server/internal/models"
+	"sort"
+)
+
+const (
+	// maxMetricNames bounds the number of metric names listed for a project
+	maxMetricNames = 1000
+	// maxMetricSeries bounds the number of label sets returned when grouping
+	maxMetricSeries = 50
+)
+
+// GetMetricNames lists the generic metrics of the project with their type
+func (m *metricsES) GetMetricNames(project string) ([]*dto.MetricName, error) {
+	query := map[string]interface{}{
+		"size":  0,
+		"query": map[string]interface{}{"term": map[string]interface{}{"serviceName": project}},
+		"aggs": map[string]interface{}{
+			"names": map[string]interface{}{
+				"terms": map[string]interface{}{"field": "name", "size": maxMetricNames, "order": map[string]interface{}{"_key": "asc"}},
+				"aggs": map[string]interface{}{
+					"types": map[string]interface{}{"terms": map[string]interface{}{"field": "type", "size": 1}},
+				},
+			},
+		},
+	}
+
+	var esResp struct {
+		Aggregations struct {
+			Names struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					DocCount int64  `json:"doc_count"`
+					Types    struct {
+						Buckets []struct {
+							Key string `json:"key"`
+						} `json:"buckets"`
+					} `json:"types"`
+				} `json:"buckets"`
+			} `json:"names"`
+		} `json:"aggregations"`
+	}
+	if err := m.searchMetrics(project, query, &esResp); err != nil {
+		return nil, err
+	}
+
+	names := make([]*dto.MetricName, 0, len(esResp.Aggregations.Names.Buckets))
+	for _, bucket := range esResp.Aggregations.Names.Buckets {
+		name := &dto.MetricName{Name: bucket.Key, Count: bucket.DocCount}
+		if len(bucket.Types.Buckets) > 0 {
+			name.Type = bucket.Types.Buckets[0].Key
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (m *metricsES) GetMetricType(project string, name string) (string, error) {
+	query := map[string]interface{}{
+		"size": 0,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"serviceName": project}},
+					map[string]interface{}{"term": map[string]interface{}{"name": name}},
+				},
+			},
+		},
+		"aggs": map[string]interface{}{
+			"types": map[string]interface{}{"terms": map[string]interface{}{"field": "type", "size": 1}},
+		},
+	}
+
+	var esResp struct {
+		Aggregations struct {
+			Types struct {
+				Buckets []struct {
+					Key string `json:"key"`
+				} `json:"buckets"`
+			} `json:"types"`
+		} `json:"aggregations"`
+	}
+	if err := m.searchMetrics(project, query, &esResp); err != nil {
+		return "", err
+	}
+	if len(esResp.Aggregations.Types.Buckets) == 0 {
+		return "", nil
+	}
+	return esResp.Aggregations.Types.Buckets[0].Key, nil
+}
+
+// QueryMetric aggregates the points of a metric per interval, one series per label set when
+// grouped. The query is expected to be validated, Type included.
+func (m *metricsES) QueryMetric(q *dto.MetricQuery) ([]*dto.MetricSeries, error) {
+	lteValue := interface{}(q.To)
+	if q.To == 0 {
+		lteValue = "now"
+	}
+	filters := []interface{}{
+		map[string]interface{}{"term": map[string]interface{}{"serviceName": q.Project}},
+		map[string]interface{}{"term": map[string]interface{}{"name": q.Name}},
+		map[string]interface{}{
+			"range": map[string]interface{}{
+				"timestamp": map[string]interface{}{"gte": q.From, "lte": lteValue},
+			},
+		},
+	}
+	// Label filters are added in key order, so the same query is always sent the same way
+	keys := make([]string, 0, len(q.Labels))
+	for key := range q.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		filters = append(filters, map[string]interface{}{
+			"term": map[string]interface{}{"labels." + key: q.Labels[key]},
+		})
+	}
+
+	overTime := map[string]interface{}{
+		"date_histogram": map[string]interface{}{
+			"field":          "timestamp",
+			"fixed_interval": q.Interval,
+			"format":         "yyyy-MM-dd HH:mm",
+			"time_zone":      q.TimeZone,
+		},
+		"aggs": metricValueAggs(q),
+	}
+
+	aggs := map[string]interface{}{"by_time": overTime}
+	switch len(q.GroupBy) {
+	case 0:
+	case 1:
+		aggs = map[string]interface{}{
+			"series": map[string]interface{}{
+				"terms": map[string]interface{}{"field": "labels." + q.GroupBy[0], "size": maxMetricSeries},
+				"aggs":  aggs,
+			},
+		}
+	default:
+		terms := make([]interface{}, len(q.GroupBy))
+		for i, key := range q.GroupBy {
+			terms[i] = map[string]interface{}{"field": "labels." + key}
+		}
+		aggs = map[string]interface{}{
+			"series": map[string]interface{}{
+				"multi_terms": map[string]interface{}{"terms": terms, "size": maxMetricSeries},
+				"aggs":        aggs,
+			},
+		}
+	}
+
+	query := map[string]interface{}{
+		"size":  0,
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filters}},
+		"aggs":  aggs,
+	}
+
+	type timeBuckets struct {
+		Buckets []struct {
+			KeyAsString string `json:"key_as_string"`
+			Key         int64  `json:"key"`
+			Value       struct {
+				Value  *float64            `json:"value"`
+				Values map[string]*float64 `json:"values"`
+			} `json:"value"`
+		} `json:"buckets"`
+	}
+	var esResp struct {
+		Aggregations struct {
+			ByTime timeBuckets `json:"by_time"`
+			Series struct {
+				Buckets []struct {
+					Key    interface{} `json:"key"`
+					ByTime timeBuckets `json:"by_time"`
+				} `json:"buckets"`
+			} `json:"series"`
+		} `json:"aggregations"`
+	}
+	if err := m.searchMetrics(q.Project, query, &esResp); err != nil {
+		return nil, err
+	}
+
+	toPoints := func(byTime timeBuckets) []*dto.MetricValuePoint {
+		points := make([]*dto.MetricValuePoint, 0, len(byTime.Buckets))
+		for _, bucket := range byTime.Buckets {
+			value := bucket.Value.Value
+			for _, percentile := range bucket.Value.Values {
+				value = percentile
+			}
+			// Intervals without points have no value
+			if value == nil {
+				continue
+			}
+			points = append(points, &dto.MetricValuePoint{
+				TimeLabel: bucket.KeyAsString,
+				Timestamp: bucket.Key,
+				Value:     *value,
+			})
+		}
+		return points
+	}
+
+	if len(q.GroupBy) == 0 {
+		return []*dto.MetricSeries{{
+			Labels: map[string]string{},
+			Points: toPoints(esResp.Aggregations.ByTime),
+		}}, nil
+	}
+
+	series := make([]*dto.MetricSeries, 0, len(esResp.Aggregations.Series.Buckets))
+	for _, bucket := range esResp.Aggregations.Series.Buckets {
+		// terms keys are a single value, multi_terms keys one value per label
+		values, ok := bucket.Key.([]interface{})
+		if !ok {
+			values = []interface{}{bucket.Key}
+		}
+		labels := make(map[string]string, len(q.GroupBy))
+		for i, key := range q.GroupBy {
+			if i < len(values) {
+				labels[key] = fmt.Sprint(values[i])
+			}
+		}
+		series = append(series, &dto.MetricSeries{Labels: labels, Points: toPoints(bucket.ByTime)})
+	}
+	return series, nil
+}
+
+// metricValueAggs computes the value of each interval under the "value" aggregation. Counters
+// and gauges aggregate their values, histograms their sums, counts and distributions.
+func metricValueAggs(q *dto.MetricQuery) map[string]interface{} {
+	if q.Aggregation == "percentile" {
+		field := "value"
+		if q.Type == "histogram" {
+			field = "distribution"
+		}
+		return map[string]interface{}{
+			"value": map[string]interface{}{
+				"percentiles": map[string]interface{}{"field": field, "percents": []float64{q.Percent}},
+			},
+		}
+	}
+
+	if q.Type != "histogram" {
+		fn := q.Aggregation
+		if fn == "count" {
+			fn = "value_count"
+		}
+		return map[string]interface{}{
+			"value": map[string]interface{}{fn: map[string]interface{}{"field": "value"}},
+		}
+	}
+
+	switch q.Aggregation {
+	case "sum":
+		return map[string]interface{}{"value": map[string]interface{}{"sum": map[string]interface{}{"field": "sum"}}}
+	case "count":
+		return map[string]interface{}{"value": map[string]interface{}{"sum": map[string]interface{}{"field": "count"}}}
+	}
+	// The average observation is the total of the sums over the total of the counts
+	return map[string]interface{}{
+		"sum":   map[string]interface{}{"sum": map[string]interface{}{"field": "sum"}},
+		"count": map[string]interface{}{"sum": map[string]interface{}{"field": "count"}},
+		"value": map[string]interface{}{
+			"bucket_script": map[string]interface{}{
+				"buckets_path": map[string]interface{}{"sum": "sum", "count": "count"},
+				"script":       "params.count > 0 ? params.sum / params.count : 0",
+			},
+		},
+	}
+}
+
+// searchMetrics runs the query on the daily metrics indices of the project and decodes the response into out
+func (m *metricsES) searchMetrics(project string, query map[string]interface{}, out interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return fmt.Errorf("encoding query: %w", err)
+	}
+
+	res, err := m.es.Search(
+		m.es.Search.WithContext(context.Background()),
+		m.es.Search.WithIndex("m-"+project+"-*"),
+		m.es.Search.WithBody(&buf),
+		m.es.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return fmt.Errorf("es search failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("es search failed with status %d: %s", res.StatusCode, string(body))
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+func (m *metricsES) GetMetricPoints(project string, names []string, matchers []*dto.LabelMatcher, from int64, to int64, limit int) ([]*models.MetricPoint, error) {
+	filters := []interface{}{
+		map[string]interface{}{"term": map[string]interface{}{"serviceName": project}},
+		map[string]interface{}{"terms": map[string]interface{}{"name": names}},
+		map[string]interface{}{
+			"range": map[string]interface{}{
+				"timestamp": map[string]interface{}{"gte": from, "lte": to},
+			},
+		},
+	}
+	var mustNot []interface{}
+	for _, matcher := range matchers {
+		term := map[string]interface{}{"term": map[string]interface{}{"labels." + matcher.Name: matcher.Value}}
+		negate := matcher.Negate
+		// An empty value matches points without the label, as in Prometheus
+		if matcher.Value == "" {
+			term = map[string]interface{}{"exists": map[string]interface{}{"field": "labels." + matcher.Name}}
+			negate = !negate
+		}
+		if negate {
+			mustNot = append(mustNot, term)
+		} else {
+			filters = append(filters, term)
+		}
+	}
+
+	boolQuery := map[string]interface{}{"filter": filters}
+	if len(mustNot) > 0 {
+		boolQuery["must_not"] = mustNot
+	}
+	query := map[string]interface{}{
+		"size":             limit,
+		"track_total_hits": limit + 1,
+		"query":            map[string]interface{}{"bool": boolQuery},
+		"sort":             []interface{}{map[string]interface{}{"timestamp": "asc"}},
+		"_source":          []string{"name", "type", "labels", "timestamp", "value", "buckets", "sum", "count"},
+	}
+
+	var esResp struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source models.MetricPoint `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := m.searchMetrics(project, query, &esResp); err != nil {
+		return nil, err
+	}
+	if esResp.Hits.Total.Value > int64(limit) {
+		return nil, ErrTooManyMetricPoints
+	}
+
+	points := make([]*models.MetricPoint, 0, len(esResp.Hits.Hits))
+	for i := range esResp.Hits.Hits {
+		points = append(points, &esResp.Hits.Hits[i].Source)
+	}
+	return points, nil
+}
@@ -1,11 +1,27 @@
 package repository
 
 import (
+	"errors"
 	"server/internal/api/dto"
+	"server/internal/models"
 )
 
+// ErrTooManyMetricPoints is returned when more points match than can be read at once
+var ErrTooManyMetricPoints = errors.New("too many points match, use a shorter time range or more specific labels")
+
 type MetricsRepo interface {
 	GetCpuUsages(project string, from int64, to int64, groupBy string) ([]*dto.CpuUsagePoint, error)
 	GetMemoryUsages(project string, from int64, to int64, groupBy string) ([]*dto.MemoryUsagepoint, error)
 	GetMetricsMinMaxDate(project string) ([]*dto.MinMaxDate, error)
+	// GetMetricsAfter returns the CPU and memory samples consumed after the position
+	GetMetricsAfter(project string, position models.StreamPosition, limit int) ([]*models.Metrics, error)
+	// GetMetricsPosition returns the last offset of the stored samples per partition
+	GetMetricsPosition(project string) (models.StreamPosition, error)
+	GetMetricNames(project string) ([]*dto.MetricName, error)
+	// GetMetricType returns the type of the metric, empty when the project has no points for it
+	GetMetricType(project string, name string) (string, error)
+	QueryMetric(query *dto.MetricQuery) ([]*dto.MetricSeries, error)
+	// GetMetricPoints returns the points of the named metrics between from and to in time order,
+	// failing with ErrTooManyMetricPoints when more than limit match
+	GetMetricPoints(project string, names []string, matchers []*dto.LabelMatcher, from int64, to int64, limit int) ([]*models.MetricPoint, error)
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"server/internal/api/dto"
+	"server/internal/models"
 	"server/pkg"
 
 	"github.com/elastic/go-elasticsearch/v9"
@@ -307,3 +308,59 @@ func (m *metricsES) GetMetricsMinMaxDate(project string) ([]*dto.MinMaxDate, err
 		},
 	}, nil
 }
+
+// GetMetricsAfter returns up to limit CPU and memory samples consumed after the position, so a
+// live stream client can resume from the last sample it received, see models.StreamPosition
+func (m *metricsES) GetMetricsAfter(project string, position models.StreamPosition, limit int) ([]*models.Metrics, error) {
+	var next struct {
+		Hits struct {
+			Hits []struct {
+				Source struct {
+					models.Metrics
+					Partition int32 `json:"partition"`
+					Offset    int64 `json:"offset"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	err := m.searchMetrics(project, map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"serviceName": project}},
+					map[string]interface{}{"exists": map[string]interface{}{"field": "cpuUsage.timestamp"}},
+					pastPositionQuery(position),
+				},
+			},
+		},
+		"sort": pastPositionSort,
+		"size": limit,
+	}, &next)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]*models.Metrics, 0, len(next.Hits.Hits))
+	for _, hit := range next.Hits.Hits {
+		sample := hit.Source.Metrics
+		sample.EventID = models.EventID(hit.Source.Partition, hit.Source.Offset)
+		metrics = append(metrics, &sample)
+	}
+	return metrics, nil
+}
+
+// GetMetricsPosition returns the last offset of the stored samples of the project per partition
+func (m *metricsES) GetMetricsPosition(project string) (models.StreamPosition, error) {
+	var result positionAggsResult
+	err := m.searchMetrics(project, map[string]interface{}{
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{"serviceName": project},
+		},
+		"size": 0,
+		"aggs": positionAggs,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.position(), nil
+}
@@ -38,6 +38,26 @@ func (l *projectPSQL) GetAllProjects(page int, limit int) ([]*models.Project, er
 	return projects, nil
 }
 
+// GetActiveProjectNames returns the names of every active project
+func (l *projectPSQL) GetActiveProjectNames() ([]string, error) {
+	var names []string
+	err := l.db.Model(&models.Project{}).Where("active = ?", true).Order("name").Pluck("name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// GetActiveProjects returns every active project
+func (l *projectPSQL) GetActiveProjects() ([]*models.Project, error) {
+	var projects []*models.Project
+	err := l.db.Where("active = ?", true).Order("name").Find(&projects).Error
+	if err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
 // GetProjectByID retrieves a project by its ID from the database and returns the project object or an error if not found.
 func (l *projectPSQL) GetProjectByID(id string) (*models.Project, error) {
 	var project models.Project
@@ -142,3 +142,24 @@ func (as *AlertServices) GetAlerts(projectName string) (*[]dto.AlertMessage, err
 	}
 	return alerts, nil
 }
+
+// GetAlertsAfter returns up to limit alerts of the project raised after the event a live stream
+// client last received, see dto.AlertMessage.EventID
+func (as *AlertServices) GetAlertsAfter(projectName string, eventID string, limit int) ([]dto.AlertMessage, error) {
+	since, err := dto.ParseAlertEventID(eventID)
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := as.Repo.GetAlertsAfter(projectName, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	// The range starts at the last alert itself, which the client already received
+	after := make([]dto.AlertMessage, 0, len(alerts))
+	for _, alert := range alerts {
+		if alert.EventID() != eventID {
+			after = append(after, alert)
+		}
+	}
+	return after, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"server/internal/api/dto"
+	"server/internal/models"
+	"server/internal/repository"
+)
+
+type DLQServices struct {
+	Repo repository.DLQRepo
+}
+
+// ErrInvalidDLQType is returned for a source type other than logs or metrics
+var ErrInvalidDLQType = errors.New("invalid type")
+
+// dlqTopic maps a project and source type ("logs" or "metrics") to its dead letter topic.
+func dlqTopic(project string, source string) (string, error) {
+	switch source {
+	case "", "logs":
+		return "dlq-logs-" + project, nil
+	case "metrics":
+		return "dlq-metrics-" + project, nil
+	default:
+		return "", fmt.Errorf("%w %q, expected logs or metrics", ErrInvalidDLQType, source)
+	}
+}
+
+func (s *DLQServices) ListRecords(project string, source string, limit int) ([]*models.DLQRecord, error) {
+	topic, err := dlqTopic(project, source)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.ListRecords(topic, limit)
+}
+
+func (s *DLQServices) GetRecord(project string, source string, partition int32, offset int64) (*models.DLQRecord, error) {
+	topic, err := dlqTopic(project, source)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.GetRecord(topic, partition, offset)
+}
+
+// ReplayRecords replays each record to the project's topic and reports the outcome per record.
+func (s *DLQServices) ReplayRecords(project string, source string, records []dto.DLQRecordRef) ([]*models.DLQReplayResult, error) {
+	topic, err := dlqTopic(project, source)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*models.DLQReplayResult, 0, len(records))
+	for _, ref := range records {
+		result, err := s.Repo.ReplayRecord(topic, ref.Partition, ref.Offset)
+		if err != nil {
+			result = &models.DLQReplayResult{Partition: ref.Partition, Offset: ref.Offset, Error: err.Error()}
+			if errors.Is(err, repository.ErrDLQRecordNotFound) {
+				result.Error = "record not found"
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -2,35 +2,200 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"server/config"
 	"server/internal/api/dto"
 	"server/internal/models"
 	"server/internal/repository"
+	logaggregation "server/internal/services/log_aggregation"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azdatalake"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azdatalake/filesystem"
 )
 
 type LogServices struct {
-	Repo   repository.LogRepo
-	Config config.AppConfig
+	Repo     repository.LogRepo
+	Projects repository.ProjectRepo
+	Config   config.AppConfig
 }
 
+// ErrUnknownProject is returned when a requested project does not exist or is inactive
+var ErrUnknownProject = errors.New("unknown project")
+
 func (s *LogServices) GetLogs(filters *dto.LogFilter) ([]*models.Log, int64, error) {
-	filters.Project = "logs-" + filters.Project
+	filters.Project = config.LogsAlias(filters.Project)
 	return s.Repo.GetLogs(filters)
 }
 
+// GetLogsPage reads logs page by page with the cursor tokens returned alongside each page
+func (s *LogServices) GetLogsPage(filters *dto.LogFilter) (*models.LogPage, error) {
+	filters.Project = config.LogsAlias(filters.Project)
+	return s.Repo.GetLogsPage(filters)
+}
+
+// GetLogsAfter returns up to limit logs of the project stored after the position a live stream
+// client resumes from, see models.StreamPosition
+func (s *LogServices) GetLogsAfter(project string, position models.StreamPosition, limit int) ([]*models.Log, error) {
+	return s.Repo.GetLogsAfter(config.LogsAlias(project), position, limit)
+}
+
+// GetLogsPosition returns the position of the stored logs of the project, the live log streams
+// start from it, see serversentevents.PositionSeed
+func (s *LogServices) GetLogsPosition(project string) (models.StreamPosition, error) {
+	return s.Repo.GetLogsPosition(config.LogsAlias(project))
+}
+
+// ExportLogs passes every log matching the filters to fn, see repository.LogRepo.ExportLogs
+func (s *LogServices) ExportLogs(ctx context.Context, filters *dto.LogFilter, fn func(lg *models.Log) error) error {
+	filters.Project = config.LogsAlias(filters.Project)
+	return s.Repo.ExportLogs(ctx, filters, fn)
+}
+
+// AggregateLogs validates the aggregations against the mapping of the project logs and runs them
+// over the logs matching the filters
+func (s *LogServices) AggregateLogs(filters *dto.LogFilter, req *dto.LogAggregationRequest) (*models.LogAggregations, error) {
+	filters.Project = config.LogsAlias(filters.Project)
+	mapping, err := s.Repo.GetLogsFieldTypes(filters.Project)
+	if err != nil {
+		return nil, err
+	}
+	timeRange, err := aggregationTimeRange(filters)
+	if err != nil {
+		return nil, err
+	}
+	search, err := logaggregation.Compile(req.Aggs, mapping, timeRange)
+	if err != nil {
+		return nil, err
+	}
+	total, raw, err := s.Repo.AggregateLogs(filters, search.Aggs, search.RuntimeMappings)
+	if err != nil {
+		return nil, err
+	}
+	aggs, err := logaggregation.Decode(req.Aggs, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LogAggregations{Total: total, Aggs: aggs}, nil
+}
+
+// aggregationTimeRange returns the range of the logs matched by the filters, to is inclusive of
+// its day like in the logs query and defaults to now
+func aggregationTimeRange(filters *dto.LogFilter) (logaggregation.TimeRange, error) {
+	timeRange := logaggregation.TimeRange{To: time.Now()}
+	if filters.From != "" {
+		from, err := time.Parse(time.RFC3339, filters.From)
+		if err != nil {
+			return timeRange, fmt.Errorf("invalid 'from' date format: %w", err)
+		}
+		timeRange.From = from
+	}
+	if filters.To != "" {
+		to, err := time.Parse(time.RFC3339, filters.To)
+		if err != nil {
+			return timeRange, fmt.Errorf("invalid 'to' date format: %w", err)
+		}
+		timeRange.To = to.Add(24 * time.Hour)
+	}
+	return timeRange, nil
+}
+
+// GetRouteLatencies returns the response time percentiles of the busiest routes, a route being a
+// request method and URL
+func (s *LogServices) GetRouteLatencies(filters *dto.LogFilter, routes int) ([]*models.RouteLatency, error) {
+	req := &dto.LogAggregationRequest{Aggs: map[string]*dto.LogAggregation{
+		"routes": {
+			Terms: &dto.TermsAggregation{Field: "requestUrl", Size: routes},
+			Aggs: map[string]*dto.LogAggregation{
+				"methods": {
+					Terms: &dto.TermsAggregation{Field: "requestMethod"},
+					Aggs: map[string]*dto.LogAggregation{
+						"latency": {Percentiles: &dto.PercentilesAggregation{Field: "response_time_ms"}},
+					},
+				},
+			},
+		},
+	}}
+	result, err := s.AggregateLogs(filters, req)
+	if err != nil {
+		return nil, err
+	}
+
+	latencies := []*models.RouteLatency{}
+	for _, route := range result.Aggs["routes"].Buckets {
+		url, _ := route.Key.(string)
+		if url == "" {
+			continue
+		}
+		for _, method := range route.Aggs["methods"].Buckets {
+			name, _ := method.Key.(string)
+			latencies = append(latencies, &models.RouteLatency{
+				Method:      name,
+				Route:       url,
+				Count:       method.Count,
+				Percentiles: method.Aggs["latency"].Values,
+			})
+		}
+	}
+	return latencies, nil
+}
+
+// GetTrace collects the logs of a trace or request ID across the active projects, or only the
+// given ones, grouped by service in the order the services first logged
+func (s *LogServices) GetTrace(id string, projects []string, limit int) (*models.TraceView, error) {
+	active, err := s.Projects.GetActiveProjectNames()
+	if err != nil {
+		return nil, err
+	}
+	services := active
+	if len(projects) > 0 {
+		for _, project := range projects {
+			if !slices.Contains(active, project) {
+				return nil, fmt.Errorf("%w %q", ErrUnknownProject, project)
+			}
+		}
+		services = projects
+	}
+
+	view := &models.TraceView{ID: id, Services: []*models.TraceService{}}
+	if len(services) == 0 {
+		return view, nil
+	}
+	logs, total, err := s.Repo.GetTraceLogs(config.LogsAlias("*"), services, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	view.Total = total
+
+	byService := make(map[string]*models.TraceService)
+	for _, lg := range logs {
+		service, ok := byService[lg.ServiceName]
+		if !ok {
+			service = &models.TraceService{ServiceName: lg.ServiceName, Start: lg.Timestamp}
+			byService[lg.ServiceName] = service
+			view.Services = append(view.Services, service)
+		}
+		service.End = lg.Timestamp
+		service.Logs = append(service.Logs, lg)
+	}
+	if len(logs) > 0 {
+		view.Start = logs[0].Timestamp
+		view.End = logs[len(logs)-1].Timestamp
+	}
+	return view, nil
+}
+
 func (s *LogServices) GetLogsMinMaxDate(projectName string) ([]string, error) {
-	projectName = "logs-" + projectName
+	projectName = config.LogsAlias(projectName)
 	return s.Repo.GetLogsAvailabilities(projectName)
 }
 
 func (s *LogServices) CheckIfIndexExists(indexName string) (bool, error) {
-	indexName = "logs-" + indexName
+	indexName = config.LogsAlias(indexName)
 	return s.Repo.CheckIfIndexExists(indexName)
 }
 
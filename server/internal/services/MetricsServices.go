@@ -2,12 +2,41 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"regexp"
 	"server/internal/api/dto"
+	"server/internal/models"
 	"server/internal/repository"
+	"server/internal/services/promql"
+	"strconv"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrInvalidMetricQuery is wrapped by the errors of metric queries that cannot be run
+	ErrInvalidMetricQuery = errors.New("invalid metric query")
+	// ErrMetricNotFound is returned when the project has no points for the metric
+	ErrMetricNotFound = errors.New("metric not found")
+	// ErrProjectNotFound is returned by CheckIfProjectExists for projects that were never created
+	ErrProjectNotFound = errors.New("project not found")
+
+	metricInterval   = regexp.MustCompile(`^([1-9][0-9]*)(s|m|h|d)$`)
+	metricZoneOffset = regexp.MustCompile(`^[+-]([01][0-9]|2[0-3]):[0-5][0-9]$`)
+	metricLabelName  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]{0,99}$`)
+)
+
+const (
+	// maxMetricPoints bounds the number of intervals of a metric query
+	maxMetricPoints  = 2000
+	maxGroupByLabels = 3
+	// maxPromQLPoints bounds the points read for one selector of a PromQL query
+	maxPromQLPoints = 10000
+)
+
 type MetricsServices struct {
 	Repo       repository.MetricsRepo
 	ProjetRepo repository.ProjectRepo
@@ -24,7 +53,7 @@ func (ms *MetricsServices) GetMemoryUsage(project string, from int64, to int64,
 func (ms *MetricsServices) CheckIfProjectExists(project string) (bool, error) {
 	exists, err := ms.ProjetRepo.GetProjectByName(project)
 	if err == gorm.ErrRecordNotFound {
-		return false, errors.New("project not found")
+		return false, ErrProjectNotFound
 	}
 	if err != nil || exists == nil {
 		return false, err
@@ -34,8 +63,19 @@ func (ms *MetricsServices) CheckIfProjectExists(project string) (bool, error) {
 
 }
 
+// GetMetricsAfter returns up to limit CPU and memory samples of the project stored after the
+// position a live stream client resumes from, see models.StreamPosition
+func (ms *MetricsServices) GetMetricsAfter(project string, position models.StreamPosition, limit int) ([]*models.Metrics, error) {
+	return ms.Repo.GetMetricsAfter(project, position, limit)
+}
 
-func(ms *MetricsServices) GetMetricsMinMaxDate(project string) ([]*dto.MinMaxDate, error) {
+// GetMetricsPosition returns the position of the stored samples of the project, the live metrics
+// streams start from it, see serversentevents.PositionSeed
+func (ms *MetricsServices) GetMetricsPosition(project string) (models.StreamPosition, error) {
+	return ms.Repo.GetMetricsPosition(project)
+}
+
+func (ms *MetricsServices) GetMetricsMinMaxDate(project string) ([]*dto.MinMaxDate, error) {
 	dates, err := ms.Repo.GetMetricsMinMaxDate(project)
 	if err != nil {
 		return nil, err
@@ -45,4 +85,133 @@ func(ms *MetricsServices) GetMetricsMinMaxDate(project string) ([]*dto.MinMaxDat
 	}
 	return dates, nil
 
-}
\ No newline at end of file
+}
+
+func (ms *MetricsServices) GetMetricNames(project string) ([]*dto.MetricName, error) {
+	return ms.Repo.GetMetricNames(project)
+}
+
+// QueryMetric validates the query, resolves the type of the metric and aggregates its points.
+// Percentiles are requested as p followed by the percentile, like p95 or p99.9.
+func (ms *MetricsServices) QueryMetric(q *dto.MetricQuery) ([]*dto.MetricSeries, error) {
+	if q.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidMetricQuery)
+	}
+	if q.To != 0 && q.To <= q.From {
+		return nil, fmt.Errorf("%w: to must be after from", ErrInvalidMetricQuery)
+	}
+
+	match := metricInterval.FindStringSubmatch(q.Interval)
+	if match == nil {
+		return nil, fmt.Errorf("%w: invalid interval %q, expected a duration like 30s, 5m, 1h or 1d", ErrInvalidMetricQuery, q.Interval)
+	}
+	n, _ := strconv.ParseInt(match[1], 10, 64)
+	unit := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}[match[2]]
+	to := q.To
+	if to == 0 {
+		to = time.Now().UnixMilli()
+	}
+	if (to-q.From)/(n*unit.Milliseconds()) > maxMetricPoints {
+		return nil, fmt.Errorf("%w: the interval gives more than %d points, use a larger interval or a shorter time range", ErrInvalidMetricQuery, maxMetricPoints)
+	}
+
+	if q.TimeZone == "" {
+		q.TimeZone = "+05:30"
+	}
+	if !metricZoneOffset.MatchString(q.TimeZone) {
+		if _, err := time.LoadLocation(q.TimeZone); err != nil {
+			return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidMetricQuery, q.TimeZone)
+		}
+	}
+
+	if len(q.GroupBy) > maxGroupByLabels {
+		return nil, fmt.Errorf("%w: series can be grouped by at most %d labels", ErrInvalidMetricQuery, maxGroupByLabels)
+	}
+	for _, key := range q.GroupBy {
+		if !metricLabelName.MatchString(key) {
+			return nil, fmt.Errorf("%w: invalid label name %q", ErrInvalidMetricQuery, key)
+		}
+	}
+	for key := range q.Labels {
+		if !metricLabelName.MatchString(key) {
+			return nil, fmt.Errorf("%w: invalid label name %q", ErrInvalidMetricQuery, key)
+		}
+	}
+
+	if q.Aggregation == "" {
+		q.Aggregation = "avg"
+	}
+	if percent, ok := strings.CutPrefix(q.Aggregation, "p"); ok {
+		value, err := strconv.ParseFloat(percent, 64)
+		if err != nil || value <= 0 || value > 100 {
+			return nil, fmt.Errorf("%w: invalid percentile %q, expected one like p50 or p99", ErrInvalidMetricQuery, q.Aggregation)
+		}
+		q.Aggregation = "percentile"
+		q.Percent = value
+	}
+
+	metricType, err := ms.Repo.GetMetricType(q.Project, q.Name)
+	if err != nil {
+		return nil, err
+	}
+	if metricType == "" {
+		return nil, ErrMetricNotFound
+	}
+	q.Type = metricType
+
+	switch q.Aggregation {
+	case "avg", "sum", "count", "percentile":
+	case "min", "max":
+		if q.Type == "histogram" {
+			return nil, fmt.Errorf("%w: %s is not supported on histograms, use a percentile", ErrInvalidMetricQuery, q.Aggregation)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown aggregation %q, expected avg, sum, min, max, count or a percentile like p95", ErrInvalidMetricQuery, q.Aggregation)
+	}
+
+	return ms.Repo.QueryMetric(q)
+}
+
+// QueryPromQL evaluates a PromQL query on the project's metrics at every step from start to
+// end. Steps without a value are left out of the series, and series without any are dropped.
+func (ms *MetricsServices) QueryPromQL(project string, query string, start time.Time, end time.Time, step time.Duration) ([]*dto.MetricSeries, error) {
+	if end.Before(start) {
+		return nil, fmt.Errorf("%w: end must not be before start", ErrInvalidMetricQuery)
+	}
+	if step <= 0 {
+		return nil, fmt.Errorf("%w: step must be positive", ErrInvalidMetricQuery)
+	}
+	if end.Sub(start)/step >= maxMetricPoints {
+		return nil, fmt.Errorf("%w: the step gives more than %d points, use a larger step or a shorter time range", ErrInvalidMetricQuery, maxMetricPoints)
+	}
+
+	expr, err := promql.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	source := func(names []string, matchers []*dto.LabelMatcher, from int64, to int64) ([]*models.MetricPoint, error) {
+		return ms.Repo.GetMetricPoints(project, names, matchers, from, to, maxPromQLPoints)
+	}
+	result, err := promql.Evaluate(expr, source, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]*dto.MetricSeries, 0, len(result))
+	for _, s := range result {
+		points := make([]*dto.MetricValuePoint, 0, len(s.Values))
+		for i, value := range s.Values {
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			points = append(points, &dto.MetricValuePoint{
+				Timestamp: start.Add(time.Duration(i) * step).UnixMilli(),
+				Value:     value,
+			})
+		}
+		if len(points) > 0 {
+			series = append(series, &dto.MetricSeries{Labels: s.Labels, Points: points})
+		}
+	}
+	return series, nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"server/internal/api/dto"
+	"server/internal/models"
+	"server/internal/repository"
+	"testing"
+	"time"
+)
+
+// pointsRepo serves metric points like the index does, failing when more than limit match
+type pointsRepo struct {
+	repository.MetricsRepo
+	points []*models.MetricPoint
+	limits []int
+}
+
+func (r *pointsRepo) GetMetricPoints(project string, names []string, matchers []*dto.LabelMatcher, from int64, to int64, limit int) ([]*models.MetricPoint, error) {
+	r.limits = append(r.limits, limit)
+	var points []*models.MetricPoint
+	for _, p := range r.points {
+		if p.Timestamp >= from && p.Timestamp <= to {
+			points = append(points, p)
+		}
+	}
+	if len(points) > limit {
+		return nil, repository.ErrTooManyMetricPoints
+	}
+	return points, nil
+}
+
+func gaugePoints(n int) []*models.MetricPoint {
+	points := make([]*models.MetricPoint, n)
+	for i := range points {
+		value := float64(i)
+		points[i] = &models.MetricPoint{Name: "up", Type: "gauge", Timestamp: int64(i) * 1000, Value: &value}
+	}
+	return points
+}
+
+func TestQueryPromQLLimits(t *testing.T) {
+	start := time.UnixMilli(0)
+	tests := []struct {
+		name    string
+		points  int
+		end     time.Time
+		step    time.Duration
+		wantErr error
+	}{
+		{name: "within the limit", points: 100, end: start.Add(time.Minute), step: time.Second},
+		{name: "too many points", points: maxPromQLPoints + 1, end: start.Add(3 * time.Hour), step: time.Minute, wantErr: repository.ErrTooManyMetricPoints},
+		{name: "too many steps", points: 1, end: start.Add(maxMetricPoints * time.Second), step: time.Second, wantErr: ErrInvalidMetricQuery},
+		{name: "end before start", points: 1, end: start.Add(-time.Second), step: time.Second, wantErr: ErrInvalidMetricQuery},
+		{name: "zero step", points: 1, end: start, step: 0, wantErr: ErrInvalidMetricQuery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &pointsRepo{points: gaugePoints(tt.points)}
+			ms := &MetricsServices{Repo: repo}
+			_, err := ms.QueryPromQL("shop", "max(up)", start, tt.end, tt.step)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("QueryPromQL failed: %v", err)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("QueryPromQL error = %v, want %v", err, tt.wantErr)
+			}
+			for _, limit := range repo.limits {
+				if limit != maxPromQLPoints {
+					t.Errorf("points were read with limit %d, want %d", limit, maxPromQLPoints)
+				}
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ type ProjectServices struct {
 	Repo   repository.ProjectRepo
 	Config config.AppConfig
 	Ktm    *config.KafkaTopicManager
+	Lim    *config.LogIndexManager
 }
 
 // CreateProject creates a new project in the repository and returns the created project or an error if creation fails.
@@ -29,13 +30,60 @@ func (p *ProjectServices) CreateProject(project *models.Project) (*models.Projec
 	if err != nil {
 		return nil, err
 	}
+	// A project that could not be set up is removed again, so creating it can be retried
 	err = p.Ktm.CreateProjectTopic(project.Name)
+	if err == nil {
+		err = p.Lim.EnsureProjectIndex(project.Name, project.RetentionPeriod)
+	}
 	if err != nil {
+		p.rollbackProject(project.Name)
 		return nil, err
 	}
 	return project, nil
 }
 
+// rollbackProject removes the row and topics of a project whose creation failed. The ILM
+// policy and index template are left, EnsureProjectIndex overwrites them on the next attempt.
+func (p *ProjectServices) rollbackProject(name string) {
+	if err := p.Ktm.DeleteProjectTopics(name); err != nil {
+		log.Printf("Failed to delete the topics of project %s after its creation failed: %v", name, err)
+	}
+	if err := p.Repo.DeleteProject(name); err != nil {
+		log.Printf("Failed to delete project %s after its creation failed: %v", name, err)
+	}
+}
+
+// EnsureProjectTopics creates the missing topics of every active project, such as the dead
+// letter topics of projects created before they existed
+func (p *ProjectServices) EnsureProjectTopics() error {
+	names, err := p.Repo.GetActiveProjectNames()
+	if err != nil {
+		return fmt.Errorf("failed to list active projects: %w", err)
+	}
+	for _, name := range names {
+		if err := p.Ktm.CreateProjectTopic(name); err != nil {
+			return fmt.Errorf("failed to create the topics of project %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// EnsureProjectIndices applies the rollover alias and ILM policy to the logs of every active
+// project, migrating projects created before them. A project that fails is logged and skipped
+// so the others are still set up.
+func (p *ProjectServices) EnsureProjectIndices() error {
+	projects, err := p.Repo.GetActiveProjects()
+	if err != nil {
+		return fmt.Errorf("failed to list active projects: %w", err)
+	}
+	for _, project := range projects {
+		if err := p.Lim.EnsureProjectIndex(project.Name, project.RetentionPeriod); err != nil {
+			log.Printf("Failed to set up the logs index of project %s: %v", project.Name, err)
+		}
+	}
+	return nil
+}
+
 // GetAllProjects retrieves a paginated list of projects based on the provided page number and limit. It returns the projects or an error.
 func (p *ProjectServices) GetAllProjects(page int, limit int) ([]*models.Project, error) {
 	projects, err := p.Repo.GetAllProjects(page, limit)
@@ -58,6 +106,9 @@ func ValidateProject(project *models.Project) error {
 	case project.RetentionPeriod == "":
 		return errors.New("project retention period is required")
 	}
+	if _, err := config.ParseRetentionPeriod(project.RetentionPeriod); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -123,11 +174,18 @@ func (p *ProjectServices) GetLogs(projectName string) ([]*models.Log, error) {
 }
 
 // UpdateProject updates an existing project in the repository and returns the updated project or an error if the update fails.
+// A changed retention period is applied to the ILM policy of the project's logs.
 func (p *ProjectServices) UpdateProject(project *models.Project) (*models.Project, error) {
 	updatedProject, err := p.Repo.UpdateProject(project)
 	if err != nil {
 		return nil, err
 	}
+	if project.RetentionPeriod != "" {
+		err = p.Lim.EnsureProjectIndex(updatedProject.Name, updatedProject.RetentionPeriod)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return updatedProject, nil
 }
 
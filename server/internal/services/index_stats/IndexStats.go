@@ -0,0 +1,54 @@
+package indexstats
+
+import "sync"
+
+// Counters of documents handled by the bulk indexer since the server started
+type Counters struct {
+	Indexed  int64 `json:"indexed"`
+	Retried  int64 `json:"retried"`
+	Rejected int64 `json:"rejected"`
+}
+
+// IndexStats keeps per-project indexing counters for each document kind ("logs", "metrics")
+type IndexStats struct {
+	mutex    sync.RWMutex
+	counters map[string]map[string]*Counters
+}
+
+func NewIndexStats() *IndexStats {
+	return &IndexStats{
+		counters: make(map[string]map[string]*Counters),
+	}
+}
+
+func (s *IndexStats) Add(kind string, project string, indexed int64, retried int64, rejected int64) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	projects, ok := s.counters[kind]
+	if !ok {
+		projects = make(map[string]*Counters)
+		s.counters[kind] = projects
+	}
+	c, ok := projects[project]
+	if !ok {
+		c = &Counters{}
+		projects[project] = c
+	}
+	c.Indexed += indexed
+	c.Retried += retried
+	c.Rejected += rejected
+}
+
+func (s *IndexStats) Get(kind string, project string) Counters {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if c, ok := s.counters[kind][project]; ok {
+		return *c
+	}
+	return Counters{}
+}
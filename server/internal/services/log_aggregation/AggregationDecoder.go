@@ -0,0 +1,82 @@
+package logaggregation
+
+import (
+	"encoding/json"
+	"fmt"
+	"server/internal/api/dto"
+	"server/internal/models"
+)
+
+type esBucketAggregation struct {
+	Buckets          []map[string]json.RawMessage `json:"buckets"`
+	SumOtherDocCount int64                        `json:"sum_other_doc_count"`
+}
+
+type esPercentiles struct {
+	Values map[string]*float64 `json:"values"`
+}
+
+// Decode reads the aggregations of a search response, following the shape of the request
+func Decode(aggs map[string]*dto.LogAggregation, raw map[string]json.RawMessage) (map[string]*models.LogAggregationResult, error) {
+	results := make(map[string]*models.LogAggregationResult, len(aggs))
+	for name, agg := range aggs {
+		data, ok := raw[name]
+		if !ok {
+			continue
+		}
+		result, err := decodeAgg(agg, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode aggregation %s: %w", name, err)
+		}
+		results[name] = result
+	}
+	return results, nil
+}
+
+func decodeAgg(agg *dto.LogAggregation, data json.RawMessage) (*models.LogAggregationResult, error) {
+	if agg.Percentiles != nil {
+		var percentiles esPercentiles
+		if err := json.Unmarshal(data, &percentiles); err != nil {
+			return nil, err
+		}
+		return &models.LogAggregationResult{Values: percentiles.Values}, nil
+	}
+
+	var buckets esBucketAggregation
+	if err := json.Unmarshal(data, &buckets); err != nil {
+		return nil, err
+	}
+	result := &models.LogAggregationResult{
+		Buckets:    make([]*models.LogAggregationBucket, 0, len(buckets.Buckets)),
+		OtherCount: buckets.SumOtherDocCount,
+	}
+	for _, rawBucket := range buckets.Buckets {
+		// Buckets hold their key and count next to the nested aggregations
+		decoded := &models.LogAggregationBucket{}
+		if err := unmarshalIfSet(rawBucket["key"], &decoded.Key); err != nil {
+			return nil, err
+		}
+		if err := unmarshalIfSet(rawBucket["key_as_string"], &decoded.KeyAsString); err != nil {
+			return nil, err
+		}
+		if err := unmarshalIfSet(rawBucket["doc_count"], &decoded.Count); err != nil {
+			return nil, err
+		}
+		if len(agg.Aggs) > 0 {
+			subAggs, err := Decode(agg.Aggs, rawBucket)
+			if err != nil {
+				return nil, err
+			}
+			decoded.Aggs = subAggs
+		}
+		result.Buckets = append(result.Buckets, decoded)
+	}
+	return result, nil
+}
+
+func unmarshalIfSet(data json.RawMessage, v interface{}) error {
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
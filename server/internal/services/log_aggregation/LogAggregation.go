@@ -0,0 +1,373 @@
+package logaggregation
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"server/internal/api/dto"
+	logquery "server/internal/services/log_query"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones are validated without relying on the host zoneinfo
+)
+
+const (
+	maxDepth        = 3
+	maxAggregations = 10
+	defaultTermSize = 10
+	maxTermSize     = 100
+	// maxBuckets is search.max_buckets of Elasticsearch, the buckets a search may return
+	maxBuckets = 65536
+)
+
+var (
+	aggregationName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	fixedInterval   = regexp.MustCompile(`^([1-9][0-9]*)(ms|s|m|h|d)$`)
+	zoneOffset      = regexp.MustCompile(`^[+-]([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+	// calendarIntervals are the intervals that follow the calendar, so days keep daylight saving
+	// changes and months their length
+	calendarIntervals = map[string]string{
+		"1m": "minute", "minute": "minute",
+		"1h": "hour", "hour": "hour",
+		"1d": "day", "day": "day",
+		"1w": "week", "week": "week",
+		"1M": "month", "month": "month",
+		"1q": "quarter", "quarter": "quarter",
+		"1y": "year", "year": "year",
+	}
+
+	// calendarDurations are the shortest lengths of the calendar units, so bucket counts are not
+	// underestimated
+	calendarDurations = map[string]time.Duration{
+		"minute":  time.Minute,
+		"hour":    time.Hour,
+		"day":     23 * time.Hour,
+		"week":    7*24*time.Hour - time.Hour,
+		"month":   28*24*time.Hour - time.Hour,
+		"quarter": 89*24*time.Hour - time.Hour,
+		"year":    365*24*time.Hour - time.Hour,
+	}
+	fixedUnits = map[string]time.Duration{
+		"ms": time.Millisecond, "s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour,
+	}
+
+	defaultPercents = []float64{50, 90, 95, 99}
+
+	numericTypes = []string{"long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float", "unsigned_long"}
+	termTypes    = append([]string{"keyword", "ip", "boolean", "date"}, numericTypes...)
+)
+
+// numericStringScript emits the numbers held by string fields, such as "250" or "250ms", so
+// percentiles can be computed on them. Values that are not numbers are skipped.
+const numericStringScript = `
+if (doc[params.field].size() == 0) { return; }
+String v = doc[params.field].value.trim();
+if (v.endsWith('ms')) { v = v.substring(0, v.length() - 2).trim(); }
+try { emit(Double.parseDouble(v)); } catch (NumberFormatException e) {}`
+
+// statusClassField groups logs by status class, 2xx to 5xx, computed from status_code
+const statusClassField = "statusClass"
+
+const statusClassScript = `
+if (doc['status_code'].size() > 0) { emit((doc['status_code'].value / 100) + 'xx'); }`
+
+// Mapping maps the field paths of the logs index, subfields included, to their types
+type Mapping map[string]string
+
+// ValidationError tells which part of the request is invalid, by its path in the body
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+func invalid(path string, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+}
+
+// TimeRange is the range of timestamps of the aggregated logs. From is zero when the logs are
+// not bounded below.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// Search is the compiled form of an aggregation request
+type Search struct {
+	Aggs            map[string]interface{}
+	RuntimeMappings map[string]interface{}
+}
+
+// Compile validates the aggregations against the mapping and returns the Elasticsearch
+// aggregations. Defaults are filled in on the request, so results can be decoded with it.
+// Date histograms over timestamp are bounded by the time range, and rejected when the buckets
+// of all aggregations would be more than Elasticsearch returns.
+func Compile(aggs map[string]*dto.LogAggregation, mapping Mapping, timeRange TimeRange) (*Search, error) {
+	if len(aggs) == 0 {
+		return nil, invalid("aggs", "at least one aggregation is required")
+	}
+	c := &compiler{mapping: mapping, timeRange: timeRange, search: &Search{RuntimeMappings: map[string]interface{}{}}}
+	compiled, err := c.compileAggs("aggs", aggs, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	c.search.Aggs = compiled
+	return c.search, nil
+}
+
+type compiler struct {
+	mapping   Mapping
+	timeRange TimeRange
+	search    *Search
+	buckets   int64 // buckets counted so far
+}
+
+// compileAggs compiles the aggregations of one level, each run once per bucket of the parent
+// levels, which there are parentBuckets of
+func (c *compiler) compileAggs(path string, aggs map[string]*dto.LogAggregation, depth int, parentBuckets int64) (map[string]interface{}, error) {
+	if depth > maxDepth {
+		return nil, invalid(path, "aggregations can be nested at most %d levels deep", maxDepth)
+	}
+	if len(aggs) > maxAggregations {
+		return nil, invalid(path, "at most %d aggregations are allowed per level", maxAggregations)
+	}
+
+	// Compile in name order, so the first error reported is stable
+	names := make([]string, 0, len(aggs))
+	for name := range aggs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	compiled := make(map[string]interface{}, len(aggs))
+	for _, name := range names {
+		aggPath := path + "." + name
+		if !aggregationName.MatchString(name) {
+			return nil, invalid(aggPath, "names may only contain letters, digits, '_' and '-'")
+		}
+		agg, err := c.compileAgg(aggPath, aggs[name], depth, parentBuckets)
+		if err != nil {
+			return nil, err
+		}
+		compiled[name] = agg
+	}
+	return compiled, nil
+}
+
+func (c *compiler) compileAgg(path string, agg *dto.LogAggregation, depth int, parentBuckets int64) (map[string]interface{}, error) {
+	if agg == nil {
+		return nil, invalid(path, "aggregation is empty")
+	}
+	kinds := 0
+	for _, set := range []bool{agg.DateHistogram != nil, agg.Terms != nil, agg.Percentiles != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return nil, invalid(path, "exactly one of dateHistogram, terms or percentiles is required")
+	}
+
+	var compiled map[string]interface{}
+	var buckets int64
+	var err error
+	switch {
+	case agg.DateHistogram != nil:
+		compiled, buckets, err = c.dateHistogram(path+".dateHistogram", agg.DateHistogram, parentBuckets)
+	case agg.Terms != nil:
+		compiled, err = c.terms(path+".terms", agg.Terms)
+		buckets = int64(agg.Terms.Size)
+	case agg.Percentiles != nil:
+		if len(agg.Aggs) > 0 {
+			return nil, invalid(path+".aggs", "percentiles cannot have nested aggregations")
+		}
+		compiled, err = c.percentiles(path+".percentiles", agg.Percentiles)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.buckets += parentBuckets * buckets
+
+	if len(agg.Aggs) > 0 {
+		subAggs, err := c.compileAggs(path+".aggs", agg.Aggs, depth+1, parentBuckets*buckets)
+		if err != nil {
+			return nil, err
+		}
+		compiled["aggs"] = subAggs
+	}
+	return compiled, nil
+}
+
+// dateHistogram compiles the histogram and counts its buckets, which is 1 when they depend on
+// the logs rather than on the time range
+func (c *compiler) dateHistogram(path string, h *dto.DateHistogramAggregation, parentBuckets int64) (map[string]interface{}, int64, error) {
+	if h.Field == "" {
+		h.Field = "timestamp"
+	}
+	if err := c.requireType(path+".field", h.Field, []string{"date", "date_nanos"}); err != nil {
+		return nil, 0, err
+	}
+	if h.TimeZone == "" {
+		h.TimeZone = "+05:30"
+	}
+	if !zoneOffset.MatchString(h.TimeZone) {
+		if _, err := time.LoadLocation(h.TimeZone); err != nil {
+			return nil, 0, invalid(path+".timeZone", "unknown time zone %q, expected an IANA name like Asia/Kolkata or an offset like +05:30", h.TimeZone)
+		}
+	}
+
+	histogram := map[string]interface{}{
+		"field":         h.Field,
+		"time_zone":     h.TimeZone,
+		"min_doc_count": 0,
+	}
+	var interval time.Duration
+	if unit, ok := calendarIntervals[h.Interval]; ok {
+		histogram["calendar_interval"] = unit
+		interval = calendarDurations[unit]
+	} else if match := fixedInterval.FindStringSubmatch(h.Interval); match != nil {
+		histogram["fixed_interval"] = h.Interval
+		n, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || n > math.MaxInt64/int64(fixedUnits[match[2]]) {
+			return nil, 0, invalid(path+".interval", "interval %q is too large", h.Interval)
+		}
+		interval = time.Duration(n) * fixedUnits[match[2]]
+	} else {
+		return nil, 0, invalid(path+".interval", "invalid interval %q, expected a calendar unit like 1h or 1d or a fixed interval like 30s or 5m", h.Interval)
+	}
+
+	// Without a lower bound the empty buckets only span the logs, their number is left to
+	// Elasticsearch, see repository.ErrTooManyBuckets
+	if h.Field != "timestamp" || c.timeRange.From.IsZero() {
+		return map[string]interface{}{"date_histogram": histogram}, 1, nil
+	}
+	from, to := c.timeRange.From, c.timeRange.To
+	if !to.After(from) {
+		return map[string]interface{}{"date_histogram": histogram}, 1, nil
+	}
+	// A bucket more for the one cut by the time zone or the calendar at each end
+	buckets := int64(to.Sub(from)/interval) + 2
+	if buckets > maxBuckets || c.buckets+parentBuckets*buckets > maxBuckets {
+		return nil, 0, invalid(path+".interval", "interval %s gives about %d buckets from %s to %s, at most %d are allowed, use a larger interval or a shorter time range",
+			h.Interval, parentBuckets*buckets, from.Format(time.RFC3339), to.Format(time.RFC3339), maxBuckets)
+	}
+	histogram["extended_bounds"] = map[string]interface{}{"min": from.UnixMilli(), "max": to.UnixMilli() - 1}
+	return map[string]interface{}{"date_histogram": histogram}, buckets, nil
+}
+
+func (c *compiler) terms(path string, t *dto.TermsAggregation) (map[string]interface{}, error) {
+	if t.Field == "" {
+		return nil, invalid(path+".field", "field is required")
+	}
+	if t.Size == 0 {
+		t.Size = defaultTermSize
+	}
+	if t.Size < 0 || t.Size > maxTermSize {
+		return nil, invalid(path+".size", "size must be between 1 and %d", maxTermSize)
+	}
+
+	if t.Field == statusClassField {
+		if !slices.Contains(numericTypes, c.mapping["status_code"]) {
+			return nil, invalid(path+".field", "%s needs logs with a numeric status_code", statusClassField)
+		}
+		c.search.RuntimeMappings[statusClassField] = map[string]interface{}{
+			"type":   "keyword",
+			"script": map[string]interface{}{"source": statusClassScript},
+		}
+		return map[string]interface{}{
+			"terms": map[string]interface{}{"field": statusClassField, "size": t.Size},
+		}, nil
+	}
+
+	// Attribute keys are not part of the mapping, they are all indexed in one flattened field
+	if key, ok := strings.CutPrefix(t.Field, "attributes."); ok && c.mapping["attributes"] == "flattened" {
+		if !logquery.IsAttributeKey(key) {
+			return nil, invalid(path+".field", "invalid attribute key %q", key)
+		}
+		return map[string]interface{}{
+			"terms": map[string]interface{}{"field": t.Field, "size": t.Size},
+		}, nil
+	}
+
+	// Text fields are grouped on their keyword subfield
+	field := t.Field
+	if c.mapping[field] == "text" && c.mapping[field+".keyword"] == "keyword" {
+		field += ".keyword"
+	}
+	if err := c.requireType(path+".field", field, termTypes); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"terms": map[string]interface{}{"field": field, "size": t.Size},
+	}, nil
+}
+
+func (c *compiler) percentiles(path string, p *dto.PercentilesAggregation) (map[string]interface{}, error) {
+	if p.Field == "" {
+		p.Field = "response_time_ms"
+	}
+	if len(p.Percents) == 0 {
+		p.Percents = defaultPercents
+	}
+	for i, percent := range p.Percents {
+		if percent <= 0 || percent > 100 {
+			return nil, invalid(fmt.Sprintf("%s.percents[%d]", path, i), "percents must be above 0 and at most 100")
+		}
+	}
+
+	field := p.Field
+	if !slices.Contains(numericTypes, c.mapping[field]) {
+		// Numbers indexed as strings are parsed by a runtime field
+		keyword := field
+		if c.mapping[field] == "text" {
+			keyword += ".keyword"
+		}
+		if c.mapping[keyword] != "keyword" {
+			return nil, invalid(path+".field", "%s is not a numeric field or a string field holding numbers", p.Field)
+		}
+		field = p.Field + "_numeric"
+		c.search.RuntimeMappings[field] = map[string]interface{}{
+			"type": "double",
+			"script": map[string]interface{}{
+				"source": numericStringScript,
+				"params": map[string]interface{}{"field": keyword},
+			},
+		}
+	}
+	return map[string]interface{}{
+		"percentiles": map[string]interface{}{"field": field, "percents": p.Percents},
+	}, nil
+}
+
+func (c *compiler) requireType(path, field string, types []string) error {
+	fieldType, ok := c.mapping[field]
+	if !ok {
+		return invalid(path, "unknown field %q, expected one of %s", field, c.fieldsOf(types))
+	}
+	if !slices.Contains(types, fieldType) {
+		return invalid(path, "field %q of type %s cannot be used here, expected one of %s", field, fieldType, c.fieldsOf(types))
+	}
+	return nil
+}
+
+// fieldsOf lists the fields of the given types
+func (c *compiler) fieldsOf(types []string) string {
+	var fields []string
+	for field, fieldType := range c.mapping {
+		if slices.Contains(types, fieldType) {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return "(none)"
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ", ")
+}
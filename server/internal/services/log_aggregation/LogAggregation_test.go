@@ -0,0 +1,60 @@
+package logaggregation
+
+import (
+	"errors"
+	"server/internal/api/dto"
+	"testing"
+	"time"
+)
+
+func TestCompileDateHistogramBuckets(t *testing.T) {
+	mapping := Mapping{"timestamp": "date", "level": "keyword"}
+	to := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	month := TimeRange{From: to.AddDate(0, 0, -30), To: to}
+	histogram := func(interval string) *dto.LogAggregation {
+		return &dto.LogAggregation{DateHistogram: &dto.DateHistogramAggregation{Interval: interval}}
+	}
+
+	tests := []struct {
+		name      string
+		aggs      map[string]*dto.LogAggregation
+		timeRange TimeRange
+		wantErr   bool
+	}{
+		{name: "hours over a month", aggs: map[string]*dto.LogAggregation{"h": histogram("1h")}, timeRange: month},
+		{name: "seconds over a month", aggs: map[string]*dto.LogAggregation{"h": histogram("1s")}, timeRange: month, wantErr: true},
+		{name: "seconds without a lower bound", aggs: map[string]*dto.LogAggregation{"h": histogram("1s")}, timeRange: TimeRange{To: to}},
+		{name: "months over a month", aggs: map[string]*dto.LogAggregation{"h": histogram("month")}, timeRange: month},
+		{
+			name: "minutes per level over a month",
+			aggs: map[string]*dto.LogAggregation{
+				"levels": {Terms: &dto.TermsAggregation{Field: "level"}, Aggs: map[string]*dto.LogAggregation{"h": histogram("1m")}},
+			},
+			timeRange: month,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.aggs, mapping, tt.timeRange)
+			var validationErr *ValidationError
+			if got := errors.As(err, &validationErr); got != tt.wantErr {
+				t.Errorf("Compile error = %v, want a validation error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileDateHistogramBounds(t *testing.T) {
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	aggs := map[string]*dto.LogAggregation{"h": {DateHistogram: &dto.DateHistogramAggregation{Interval: "1d"}}}
+	search, err := Compile(aggs, Mapping{"timestamp": "date"}, TimeRange{From: from, To: from.AddDate(0, 0, 7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	histogram := search.Aggs["h"].(map[string]interface{})["date_histogram"].(map[string]interface{})
+	bounds, ok := histogram["extended_bounds"].(map[string]interface{})
+	if !ok || bounds["min"] != from.UnixMilli() || bounds["max"] != from.AddDate(0, 0, 7).UnixMilli()-1 {
+		t.Errorf("extended_bounds = %v, want the time range", histogram["extended_bounds"])
+	}
+}
@@ -0,0 +1,229 @@
+package logexport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"server/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Supported export formats
+const (
+	FormatNDJSON  = "ndjson"
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// parquetRowGroupSize bounds how many rows the Parquet writer buffers before writing a row group
+const parquetRowGroupSize = 50000
+
+// Columns lists the exportable columns in their default order
+var Columns = []string{
+	"timestamp", "serviceName", "level", "message", "stack", "requestId", "traceId", "spanId",
+	"parentSpanId", "requestUrl",
+	"requestMethod", "ipAddress", "userAgent", "responseStatus", "responseTime",
+	"statusCode", "responseTimeMs", "appVersion", "nodeVersion",
+}
+
+var columnValues = map[string]func(l *models.Log) string{
+	"timestamp":      func(l *models.Log) string { return l.Timestamp.UTC().Format(time.RFC3339Nano) },
+	"serviceName":    func(l *models.Log) string { return l.ServiceName },
+	"level":          func(l *models.Log) string { return l.Level },
+	"message":        func(l *models.Log) string { return l.Message },
+	"stack":          func(l *models.Log) string { return l.Stack },
+	"requestId":      func(l *models.Log) string { return l.RequestId },
+	"traceId":        func(l *models.Log) string { return l.TraceId },
+	"spanId":         func(l *models.Log) string { return l.SpanId },
+	"parentSpanId":   func(l *models.Log) string { return l.ParentSpanId },
+	"requestUrl":     func(l *models.Log) string { return l.RequestUrl },
+	"requestMethod":  func(l *models.Log) string { return l.RequestMethod },
+	"ipAddress":      func(l *models.Log) string { return l.IpAddress },
+	"userAgent":      func(l *models.Log) string { return l.UserAgent },
+	"responseStatus": func(l *models.Log) string { return l.ResponseStatus },
+	"responseTime":   func(l *models.Log) string { return l.ResponseTime },
+	"statusCode": func(l *models.Log) string {
+		if l.StatusCode == nil {
+			return ""
+		}
+		return strconv.Itoa(*l.StatusCode)
+	},
+	"responseTimeMs": func(l *models.Log) string {
+		if l.ResponseTimeMs == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*l.ResponseTimeMs, 'f', -1, 64)
+	},
+	"appVersion":  func(l *models.Log) string { return l.BuildDetails.AppVersion },
+	"nodeVersion": func(l *models.Log) string { return l.BuildDetails.NodeVersion },
+}
+
+// columnValue returns the getter of a column, attributes.<key> columns read an attribute
+func columnValue(column string) (func(l *models.Log) string, bool) {
+	if key, ok := strings.CutPrefix(column, "attributes."); ok && key != "" {
+		return func(l *models.Log) string { return l.Attributes[key] }, true
+	}
+	get, ok := columnValues[column]
+	return get, ok
+}
+
+// Writer encodes logs one by one to the export output. Close flushes what is buffered but
+// does not close the underlying writer.
+type Writer interface {
+	Write(l *models.Log) error
+	Close() error
+}
+
+// ContentType returns the media type of an export format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "application/x-ndjson"
+}
+
+// ParseColumns validates a comma separated column list, an empty list selects every column
+func ParseColumns(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return Columns, nil
+	}
+	var columns []string
+	for _, column := range strings.Split(list, ",") {
+		column = strings.TrimSpace(column)
+		if _, ok := columnValue(column); !ok {
+			return nil, fmt.Errorf("unknown column %q, expected attributes.<key> or one of %s", column, strings.Join(Columns, ", "))
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// NewWriter returns a writer for the format. Columns only apply to CSV.
+func NewWriter(format string, columns []string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		return newCSVWriter(w, columns)
+	case FormatParquet:
+		return &parquetWriter{
+			writer: parquet.NewGenericWriter[parquetRow](w, parquet.MaxRowsPerRowGroup(parquetRowGroupSize)),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format %q, expected ndjson, csv or parquet", format)
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(l *models.Log) error {
+	return n.encoder.Encode(l)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	values []func(l *models.Log) string
+	record []string
+}
+
+func newCSVWriter(w io.Writer, columns []string) (*csvWriter, error) {
+	c := &csvWriter{
+		writer: csv.NewWriter(w),
+		values: make([]func(l *models.Log) string, len(columns)),
+		record: make([]string, len(columns)),
+	}
+	for i, column := range columns {
+		c.values[i], _ = columnValue(column)
+	}
+	if err := c.writer.Write(columns); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvWriter) Write(l *models.Log) error {
+	for i, value := range c.values {
+		c.record[i] = value(l)
+	}
+	return c.writer.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type parquetRow struct {
+	Timestamp      time.Time         `parquet:"timestamp,timestamp(millisecond)"`
+	ServiceName    string            `parquet:"serviceName,dict"`
+	Level          string            `parquet:"level,dict"`
+	Message        string            `parquet:"message,zstd"`
+	Stack          string            `parquet:"stack,optional,zstd"`
+	RequestId      string            `parquet:"requestId,optional"`
+	TraceId        string            `parquet:"traceId,optional"`
+	SpanId         string            `parquet:"spanId,optional"`
+	ParentSpanId   string            `parquet:"parentSpanId,optional"`
+	RequestUrl     string            `parquet:"requestUrl,optional"`
+	RequestMethod  string            `parquet:"requestMethod,optional,dict"`
+	IpAddress      string            `parquet:"ipAddress,optional"`
+	UserAgent      string            `parquet:"userAgent,optional,dict"`
+	ResponseStatus string            `parquet:"responseStatus,optional,dict"`
+	ResponseTime   string            `parquet:"responseTime,optional"`
+	StatusCode     *int32            `parquet:"statusCode,optional"`
+	ResponseTimeMs *float64          `parquet:"responseTimeMs,optional"`
+	AppVersion     string            `parquet:"appVersion,optional,dict"`
+	NodeVersion    string            `parquet:"nodeVersion,optional,dict"`
+	Attributes     map[string]string `parquet:"attributes,optional"`
+}
+
+type parquetWriter struct {
+	writer *parquet.GenericWriter[parquetRow]
+	row    [1]parquetRow
+}
+
+func (p *parquetWriter) Write(l *models.Log) error {
+	p.row[0] = parquetRow{
+		Timestamp:      l.Timestamp,
+		ServiceName:    l.ServiceName,
+		Level:          l.Level,
+		Message:        l.Message,
+		Stack:          l.Stack,
+		RequestId:      l.RequestId,
+		TraceId:        l.TraceId,
+		SpanId:         l.SpanId,
+		ParentSpanId:   l.ParentSpanId,
+		RequestUrl:     l.RequestUrl,
+		RequestMethod:  l.RequestMethod,
+		IpAddress:      l.IpAddress,
+		UserAgent:      l.UserAgent,
+		ResponseStatus: l.ResponseStatus,
+		ResponseTime:   l.ResponseTime,
+		ResponseTimeMs: l.ResponseTimeMs,
+		AppVersion:     l.BuildDetails.AppVersion,
+		NodeVersion:    l.BuildDetails.NodeVersion,
+		Attributes:     l.Attributes,
+	}
+	if l.StatusCode != nil {
+		code := int32(*l.StatusCode)
+		p.row[0].StatusCode = &code
+	}
+	_, err := p.writer.Write(p.row[:])
+	return err
+}
+
+func (p *parquetWriter) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,231 @@
+package logquery
+
+import (
+	"server/pkg"
+	"strconv"
+	"strings"
+)
+
+// numericStringScript compares legacy fields that hold numbers as strings, such as "250" or
+// "250ms", since a keyword range would compare them alphabetically.
+const numericStringScript = `
+if (doc[params.field].size() == 0) { return false; }
+String v = doc[params.field].value.trim();
+if (v.endsWith('ms')) { v = v.substring(0, v.length() - 2).trim(); }
+double d;
+try { d = Double.parseDouble(v); } catch (NumberFormatException e) { return false; }
+if (params.op == '>') { return d > params.value; }
+if (params.op == '>=') { return d >= params.value; }
+if (params.op == '<') { return d < params.value; }
+if (params.op == '<=') { return d <= params.value; }
+return d == params.value;`
+
+var rangeOperators = map[string]string{">": "gt", ">=": "gte", "<": "lt", "<=": "lte"}
+
+// Compile returns the Elasticsearch query for the parsed query
+func (q *Query) Compile() map[string]interface{} {
+	return compileNode(q.Root)
+}
+
+func compileNode(node Node) map[string]interface{} {
+	switch n := node.(type) {
+	case *AndNode:
+		return boolQuery("must", compileNodes(n.Children))
+	case *OrNode:
+		query := boolQuery("should", compileNodes(n.Children))
+		query["bool"].(map[string]interface{})["minimum_should_match"] = 1
+		return query
+	case *NotNode:
+		return boolQuery("must_not", []map[string]interface{}{compileNode(n.Child)})
+	case *TextNode:
+		return compileText(n)
+	case *FieldNode:
+		if n.Operator == "!=" {
+			return boolQuery("must_not", []map[string]interface{}{compileField(n, ":")})
+		}
+		return compileField(n, n.Operator)
+	}
+	return map[string]interface{}{"match_none": map[string]interface{}{}}
+}
+
+func compileNodes(nodes []Node) []map[string]interface{} {
+	queries := make([]map[string]interface{}, len(nodes))
+	for i, n := range nodes {
+		queries[i] = compileNode(n)
+	}
+	return queries
+}
+
+func boolQuery(occur string, queries []map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			occur: queries,
+		},
+	}
+}
+
+func compileText(n *TextNode) map[string]interface{} {
+	if strings.Contains(n.Value, "*") {
+		return map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					wildcardQuery("message", n.Value, true),
+					wildcardQuery("stack", n.Value, true),
+				},
+				"minimum_should_match": 1,
+			},
+		}
+	}
+	match := map[string]interface{}{
+		"query":  n.Value,
+		"fields": []string{"message", "stack"},
+	}
+	if n.Quoted {
+		match["type"] = "phrase"
+	} else {
+		match["operator"] = "and"
+	}
+	return map[string]interface{}{"multi_match": match}
+}
+
+func compileField(n *FieldNode, op string) map[string]interface{} {
+	f := n.field
+	if rangeOp, ok := rangeOperators[op]; ok {
+		query := map[string]interface{}{
+			"range": map[string]interface{}{
+				f.esField: map[string]interface{}{rangeOp: n.Value},
+			},
+		}
+		if f.legacyField != "" {
+			return withLegacy(f.esField, query, numericStringQuery(f.legacyField, op, n.Value))
+		}
+		return query
+	}
+
+	switch f.kind {
+	case kindText:
+		if strings.Contains(n.Value, "*") {
+			if f.exactField != "" {
+				return wildcardQuery(f.exactField, n.Value, false)
+			}
+			return wildcardQuery(f.esField, n.Value, true)
+		}
+		return map[string]interface{}{
+			"match_phrase": map[string]interface{}{f.esField: n.Value},
+		}
+	case kindNumber:
+		number, _ := strconv.ParseFloat(n.Value, 64)
+		query := map[string]interface{}{
+			"term": map[string]interface{}{f.esField: number},
+		}
+		if f.legacyField != "" {
+			return withLegacy(f.esField, query, numericStringQuery(f.legacyField, "==", n.Value))
+		}
+		return query
+	case kindStatus:
+		from, to, _ := pkg.ParseStatusRange(n.Value)
+		return StatusRangeQuery(from, to)
+	case kindKeyword, kindTyped:
+		if f.prefixOnly && strings.HasSuffix(n.Value, "*") {
+			return map[string]interface{}{
+				"prefix": map[string]interface{}{f.esField: strings.TrimSuffix(n.Value, "*")},
+			}
+		}
+		if strings.Contains(n.Value, "*") {
+			return wildcardQuery(f.esField, n.Value, false)
+		}
+	}
+	return map[string]interface{}{
+		"term": map[string]interface{}{f.esField: n.Value},
+	}
+}
+
+func wildcardQuery(field, value string, caseInsensitive bool) map[string]interface{} {
+	return map[string]interface{}{
+		"wildcard": map[string]interface{}{
+			field: map[string]interface{}{
+				"value":            value,
+				"case_insensitive": caseInsensitive,
+			},
+		},
+	}
+}
+
+// withLegacy matches the typed field, or the legacy string field of logs that were indexed
+// before the typed field existed
+func withLegacy(field string, typed, legacy map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should": []map[string]interface{}{
+				typed,
+				{
+					"bool": map[string]interface{}{
+						"must_not": []map[string]interface{}{
+							{"exists": map[string]interface{}{"field": field}},
+						},
+						"filter": []map[string]interface{}{legacy},
+					},
+				},
+			},
+			"minimum_should_match": 1,
+		},
+	}
+}
+
+func numericStringQuery(field, op, value string) map[string]interface{} {
+	number, _ := strconv.ParseFloat(value, 64)
+	return map[string]interface{}{
+		"script": map[string]interface{}{
+			"script": map[string]interface{}{
+				"source": numericStringScript,
+				"params": map[string]interface{}{
+					"field": field,
+					"op":    op,
+					"value": number,
+				},
+			},
+		},
+	}
+}
+
+// StatusRangeQuery matches a single status code or an inclusive range of codes. Logs indexed
+// before status_code existed only hold the status as a keyword, so for those ranges compare
+// three digit codes as strings.
+func StatusRangeQuery(from, to int) map[string]interface{} {
+	if from == to {
+		return withLegacy("status_code",
+			map[string]interface{}{
+				"term": map[string]interface{}{"status_code": from},
+			},
+			map[string]interface{}{
+				"term": map[string]interface{}{"responseStatus.keyword": strconv.Itoa(from)},
+			},
+		)
+	}
+	return withLegacy("status_code",
+		map[string]interface{}{
+			"range": map[string]interface{}{
+				"status_code": map[string]interface{}{"gte": from, "lte": to},
+			},
+		},
+		map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []map[string]interface{}{
+					{
+						"range": map[string]interface{}{
+							"responseStatus.keyword": map[string]interface{}{
+								"gte": strconv.Itoa(from),
+								"lte": strconv.Itoa(to),
+							},
+						},
+					},
+					{
+						"regexp": map[string]interface{}{
+							"responseStatus.keyword": "[1-5][0-9]{2}",
+						},
+					},
+				},
+			},
+		},
+	)
+}
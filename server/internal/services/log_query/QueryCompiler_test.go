@@ -0,0 +1,165 @@
+package logquery
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type obj = map[string]interface{}
+type list = []interface{}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input string
+		want  obj
+	}{
+		{
+			input: "level:error",
+			want:  obj{"term": obj{"level": "error"}},
+		},
+		{
+			input: "level:error OR level:warn",
+			want: obj{"bool": obj{
+				"should": list{
+					obj{"term": obj{"level": "error"}},
+					obj{"term": obj{"level": "warn"}},
+				},
+				"minimum_should_match": 1,
+			}},
+		},
+		{
+			input: "serviceName:shop NOT level:debug",
+			want: obj{"bool": obj{"must": list{
+				obj{"term": obj{"serviceName": "shop"}},
+				obj{"bool": obj{"must_not": list{obj{"term": obj{"level": "debug"}}}}},
+			}}},
+		},
+		{
+			input: "level!=debug",
+			want:  obj{"bool": obj{"must_not": list{obj{"term": obj{"level": "debug"}}}}},
+		},
+		{
+			input: "timed out",
+			want: obj{"bool": obj{"must": list{
+				obj{"multi_match": obj{"query": "timed", "fields": list{"message", "stack"}, "operator": "and"}},
+				obj{"multi_match": obj{"query": "out", "fields": list{"message", "stack"}, "operator": "and"}},
+			}}},
+		},
+		{
+			input: `"timed out"`,
+			want:  obj{"multi_match": obj{"query": "timed out", "fields": list{"message", "stack"}, "type": "phrase"}},
+		},
+		{
+			input: "conn*",
+			want: obj{"bool": obj{
+				"should": list{
+					obj{"wildcard": obj{"message": obj{"value": "conn*", "case_insensitive": true}}},
+					obj{"wildcard": obj{"stack": obj{"value": "conn*", "case_insensitive": true}}},
+				},
+				"minimum_should_match": 1,
+			}},
+		},
+		{
+			input: "message:placed",
+			want:  obj{"match_phrase": obj{"message": "placed"}},
+		},
+		{
+			input: "requestUrl:/checkout*",
+			want:  obj{"wildcard": obj{"requestUrl.keyword": obj{"value": "/checkout*", "case_insensitive": false}}},
+		},
+		{
+			input: "stack:*socket*",
+			want:  obj{"wildcard": obj{"stack": obj{"value": "*socket*", "case_insensitive": true}}},
+		},
+		{
+			input: "requestId:abc*",
+			want:  obj{"wildcard": obj{"requestId": obj{"value": "abc*", "case_insensitive": false}}},
+		},
+		{
+			input: "attributes.tenant:acme*",
+			want:  obj{"prefix": obj{"attributes.tenant": "acme"}},
+		},
+		{
+			input: "typedAttributes.items>=2",
+			want:  obj{"range": obj{"typed_attributes.items": obj{"gte": "2"}}},
+		},
+		{
+			input: "responseTime>500",
+			want: withLegacy("response_time_ms",
+				obj{"range": obj{"response_time_ms": obj{"gt": "500"}}},
+				obj{"script": obj{"script": obj{
+					"source": numericStringScript,
+					"params": obj{"field": "responseTime.keyword", "op": ">", "value": 500},
+				}}},
+			),
+		},
+		{
+			input: "responseTime:250",
+			want: withLegacy("response_time_ms",
+				obj{"term": obj{"response_time_ms": 250}},
+				obj{"script": obj{"script": obj{
+					"source": numericStringScript,
+					"params": obj{"field": "responseTime.keyword", "op": "==", "value": 250},
+				}}},
+			),
+		},
+		{
+			input: "status:404",
+			want: withLegacy("status_code",
+				obj{"term": obj{"status_code": 404}},
+				obj{"term": obj{"responseStatus.keyword": "404"}},
+			),
+		},
+		{
+			input: "status:5xx",
+			want: withLegacy("status_code",
+				obj{"range": obj{"status_code": obj{"gte": 500, "lte": 599}}},
+				obj{"bool": obj{"filter": list{
+					obj{"range": obj{"responseStatus.keyword": obj{"gte": "500", "lte": "599"}}},
+					obj{"regexp": obj{"responseStatus.keyword": "[1-5][0-9]{2}"}},
+				}}},
+			),
+		},
+		{
+			input: "status>=500",
+			want: withLegacy("status_code",
+				obj{"range": obj{"status_code": obj{"gte": "500"}}},
+				obj{"script": obj{"script": obj{
+					"source": numericStringScript,
+					"params": obj{"field": "responseStatus.keyword", "op": ">=", "value": 500},
+				}}},
+			),
+		},
+		{
+			input: "timestamp>=now-1h",
+			want:  obj{"range": obj{"timestamp": obj{"gte": "now-1h"}}},
+		},
+		{
+			input: "ipAddress:10.0.0.0/8",
+			want:  obj{"term": obj{"ipAddress": "10.0.0.0/8"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			q, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+			}
+			got, want := toJSON(t, q.Compile()), toJSON(t, tt.want)
+			if got != want {
+				t.Errorf("Compile(%q) =\n%s\nwant\n%s", tt.input, got, want)
+			}
+		})
+	}
+}
+
+// toJSON renders a query with sorted keys, so queries built from different map and slice
+// types compare equal
+func toJSON(t *testing.T, query map[string]interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,156 @@
+package logquery
+
+import (
+	"fmt"
+	"regexp"
+	"server/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type fieldKind int
+
+const (
+	kindKeyword fieldKind = iota // exact values
+	kindText                     // analyzed text, matched as a phrase
+	kindNumber                   // numeric comparisons
+	kindStatus                   // response status, also accepts classes like 5xx
+	kindIP                       // address or CIDR block
+	kindDate                     // RFC3339 or date math like now-1h
+	kindTyped                    // typed attribute, compared as numbers when both sides are numbers
+)
+
+const (
+	attributesPrefix      = "attributes."
+	typedAttributesPrefix = "typedAttributes."
+)
+
+var attributeKey = regexp.MustCompile(`^[A-Za-z0-9_@-]+(\.[A-Za-z0-9_@-]+)*$`)
+
+type field struct {
+	name string
+	kind fieldKind
+	// esField is the field queried in Elasticsearch, exactField the keyword field used for
+	// wildcards and ranges on text fields
+	esField    string
+	exactField string
+	// legacyField holds the value as a string in logs indexed before the typed esField existed,
+	// it is compared with a script for those logs
+	legacyField string
+	// prefixOnly marks flattened fields, which only support trailing * wildcards as prefixes
+	prefixOnly bool
+	value      func(l *models.Log) string
+}
+
+// fields lists what can be queried, keyed by the lower-cased name used in queries
+var fields = map[string]*field{}
+
+func init() {
+	for _, f := range []*field{
+		{name: "serviceName", kind: kindKeyword, esField: "serviceName", value: func(l *models.Log) string { return l.ServiceName }},
+		{name: "level", kind: kindKeyword, esField: "level", value: func(l *models.Log) string { return l.Level }},
+		{name: "message", kind: kindText, esField: "message", exactField: "message.keyword", value: func(l *models.Log) string { return l.Message }},
+		{name: "stack", kind: kindText, esField: "stack", value: func(l *models.Log) string { return l.Stack }},
+		{name: "requestId", kind: kindKeyword, esField: "requestId", value: func(l *models.Log) string { return l.RequestId }},
+		{name: "requestUrl", kind: kindText, esField: "requestUrl", exactField: "requestUrl.keyword", value: func(l *models.Log) string { return l.RequestUrl }},
+		{name: "traceId", kind: kindKeyword, esField: "traceId", value: func(l *models.Log) string { return l.TraceId }},
+		{name: "spanId", kind: kindKeyword, esField: "spanId", value: func(l *models.Log) string { return l.SpanId }},
+		{name: "parentSpanId", kind: kindKeyword, esField: "parentSpanId", value: func(l *models.Log) string { return l.ParentSpanId }},
+		{name: "requestMethod", kind: kindKeyword, esField: "requestMethod", value: func(l *models.Log) string { return l.RequestMethod }},
+		{name: "ipAddress", kind: kindIP, esField: "ipAddress", value: func(l *models.Log) string { return l.IpAddress }},
+		{name: "userAgent", kind: kindText, esField: "userAgent", exactField: "userAgent.keyword", value: func(l *models.Log) string { return l.UserAgent }},
+		{name: "responseStatus", kind: kindStatus, esField: "status_code", legacyField: "responseStatus.keyword", value: statusValue},
+		{name: "responseTime", kind: kindNumber, esField: "response_time_ms", legacyField: "responseTime.keyword", value: responseTimeValue},
+		{name: "appVersion", kind: kindKeyword, esField: "buildDetails.appVersion", value: func(l *models.Log) string { return l.BuildDetails.AppVersion }},
+		{name: "nodeVersion", kind: kindKeyword, esField: "buildDetails.nodeVersion", value: func(l *models.Log) string { return l.BuildDetails.NodeVersion }},
+		{name: "timestamp", kind: kindDate, esField: "timestamp", value: func(l *models.Log) string { return l.Timestamp.Format(timeLayout) }},
+	} {
+		fields[strings.ToLower(f.name)] = f
+	}
+	fields["builddetails.appversion"] = fields["appversion"]
+	fields["builddetails.nodeversion"] = fields["nodeversion"]
+	fields["status"] = fields["responsestatus"]
+}
+
+func statusValue(l *models.Log) string {
+	if l.StatusCode != nil {
+		return strconv.Itoa(*l.StatusCode)
+	}
+	return l.ResponseStatus
+}
+
+func responseTimeValue(l *models.Log) string {
+	if l.ResponseTimeMs != nil {
+		return strconv.FormatFloat(*l.ResponseTimeMs, 'f', -1, 64)
+	}
+	return l.ResponseTime
+}
+
+// IsAttributeKey reports whether key can be used to filter on an attribute
+func IsAttributeKey(key string) bool {
+	return len(key) <= 128 && attributeKey.MatchString(key)
+}
+
+func lookupField(name string) (*field, bool) {
+	if key, ok := cutPrefixFold(name, attributesPrefix); ok && IsAttributeKey(key) {
+		return &field{
+			name:       attributesPrefix + key,
+			kind:       kindKeyword,
+			esField:    "attributes." + key,
+			prefixOnly: true,
+			value:      func(l *models.Log) string { return l.Attributes[key] },
+		}, true
+	}
+	if key, ok := cutPrefixFold(name, typedAttributesPrefix); ok && IsAttributeKey(key) {
+		return &field{
+			name:    typedAttributesPrefix + key,
+			kind:    kindTyped,
+			esField: "typed_attributes." + key,
+			value:   func(l *models.Log) string { return typedAttributeValue(l.TypedAttrs, key) },
+		}, true
+	}
+	f, ok := fields[strings.ToLower(name)]
+	return f, ok
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return "", false
+	}
+	return s[len(prefix):], true
+}
+
+// typedAttributeValue looks a dotted key up in the typed attributes, following nested objects
+func typedAttributeValue(attrs map[string]interface{}, key string) string {
+	if v, ok := attrs[key]; ok {
+		return fmt.Sprint(v)
+	}
+	// A dotted key may also point into nested objects
+	for i := 0; i < len(key); i++ {
+		if key[i] != '.' {
+			continue
+		}
+		if nested, ok := attrs[key[:i]].(map[string]interface{}); ok {
+			if value := typedAttributeValue(nested, key[i+1:]); value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// FieldNames returns the names that can be used in queries
+func FieldNames() []string {
+	names := make([]string, 0, len(fields))
+	seen := make(map[*field]bool)
+	for _, f := range fields {
+		if !seen[f] {
+			seen[f] = true
+			names = append(names, f.name)
+		}
+	}
+	names = append(names, attributesPrefix+"<key>", typedAttributesPrefix+"<key>")
+	sort.Strings(names)
+	return names
+}
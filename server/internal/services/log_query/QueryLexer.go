@@ -0,0 +1,147 @@
+package logquery
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+	tokenOperator
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of query"
+	case tokenWord:
+		return "word"
+	case tokenString:
+		return "quoted string"
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenLParen:
+		return "'('"
+	case tokenRParen:
+		return "')'"
+	case tokenOperator:
+		return "operator"
+	}
+	return "token"
+}
+
+type token struct {
+	kind   tokenKind
+	value  string
+	column int // 1-based column of the first character
+}
+
+// operators are matched longest first
+var operators = []string{">=", "<=", "!=", ":", "=", ">", "<"}
+
+// isWordRune reports whether r can be part of an unquoted word. Operators, parentheses, quotes
+// and whitespace end a word, values containing them have to be quoted.
+func isWordRune(r rune) bool {
+	if unicode.IsSpace(r) {
+		return false
+	}
+	switch r {
+	case '(', ')', '"', ':', '=', '!', '<', '>':
+		return false
+	}
+	return true
+}
+
+// lex splits the query into tokens. AND, OR and NOT are only keywords in upper case, so lower
+// case words are searched for as text.
+func lex(input string) ([]token, error) {
+	runes := []rune(input)
+	var tokens []token
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		column := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "(", column: column})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")", column: column})
+			i++
+		case r == '"':
+			value, next, err := lexString(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value, column: column})
+			i = next
+		case !isWordRune(r):
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, newParseError(column, string(r), "unexpected character %q", r)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, value: op, column: column})
+			i += len([]rune(op))
+		default:
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			kind := tokenWord
+			switch word {
+			case "AND":
+				kind = tokenAnd
+			case "OR":
+				kind = tokenOr
+			case "NOT":
+				kind = tokenNot
+			}
+			tokens = append(tokens, token{kind: kind, value: word, column: column})
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, column: len(runes) + 1})
+	return tokens, nil
+}
+
+// lexString reads a double-quoted string starting at runes[start]. A backslash escapes the
+// next character.
+func lexString(runes []rune, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 == len(runes) {
+				return "", 0, newParseError(i+1, "\\", "unfinished escape sequence")
+			}
+			i++
+			b.WriteRune(runes[i])
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteRune(runes[i])
+		}
+	}
+	return "", 0, newParseError(start+1, "\"", "unterminated quoted string")
+}
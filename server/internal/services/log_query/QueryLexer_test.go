@@ -0,0 +1,112 @@
+package logquery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []token
+	}{
+		{
+			input: "level:error",
+			want: []token{
+				{kind: tokenWord, value: "level", column: 1},
+				{kind: tokenOperator, value: ":", column: 6},
+				{kind: tokenWord, value: "error", column: 7},
+				{kind: tokenEOF, column: 12},
+			},
+		},
+		{
+			input: "responseTime>=500 AND NOT (a OR b)",
+			want: []token{
+				{kind: tokenWord, value: "responseTime", column: 1},
+				{kind: tokenOperator, value: ">=", column: 13},
+				{kind: tokenWord, value: "500", column: 15},
+				{kind: tokenAnd, value: "AND", column: 19},
+				{kind: tokenNot, value: "NOT", column: 23},
+				{kind: tokenLParen, value: "(", column: 27},
+				{kind: tokenWord, value: "a", column: 28},
+				{kind: tokenOr, value: "OR", column: 30},
+				{kind: tokenWord, value: "b", column: 33},
+				{kind: tokenRParen, value: ")", column: 34},
+				{kind: tokenEOF, column: 35},
+			},
+		},
+		{
+			// keywords are upper case only, lower case ones are words
+			input: "timeout and or not",
+			want: []token{
+				{kind: tokenWord, value: "timeout", column: 1},
+				{kind: tokenWord, value: "and", column: 9},
+				{kind: tokenWord, value: "or", column: 13},
+				{kind: tokenWord, value: "not", column: 16},
+				{kind: tokenEOF, column: 19},
+			},
+		},
+		{
+			input: `message:"connection \"reset\" by peer" x!=y`,
+			want: []token{
+				{kind: tokenWord, value: "message", column: 1},
+				{kind: tokenOperator, value: ":", column: 8},
+				{kind: tokenString, value: `connection "reset" by peer`, column: 9},
+				{kind: tokenWord, value: "x", column: 40},
+				{kind: tokenOperator, value: "!=", column: 41},
+				{kind: tokenWord, value: "y", column: 43},
+				{kind: tokenEOF, column: 44},
+			},
+		},
+		{
+			// columns count runes, not bytes
+			input: "é<=1",
+			want: []token{
+				{kind: tokenWord, value: "é", column: 1},
+				{kind: tokenOperator, value: "<=", column: 2},
+				{kind: tokenWord, value: "1", column: 4},
+				{kind: tokenEOF, column: 5},
+			},
+		},
+		{
+			input: "  ",
+			want:  []token{{kind: tokenEOF, column: 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := lex(tt.input)
+			if err != nil {
+				t.Fatalf("lex(%q) failed: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lex(%q) =\n%+v\nwant\n%+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		column int
+		token  string
+	}{
+		{input: `message:"unterminated`, column: 9, token: `"`},
+		{input: `"trailing escape\`, column: 17, token: `\`},
+		{input: "a ! b", column: 3, token: "!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := lex(tt.input)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("lex(%q) error = %v, want a ParseError", tt.input, err)
+			}
+			if parseErr.Column != tt.column || parseErr.Token != tt.token {
+				t.Errorf("lex(%q) error at column %d token %q, want column %d token %q", tt.input, parseErr.Column, parseErr.Token, tt.column, tt.token)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package logquery
+
+import (
+	"bytes"
+	"net"
+	"server/internal/models"
+	"server/pkg"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Match evaluates the query against a single log, used to filter live streams. Text is matched
+// case-insensitively as a substring, which approximates the analyzed search in Elasticsearch.
+func (q *Query) Match(l *models.Log) bool {
+	return matchNode(q.Root, l)
+}
+
+func matchNode(node Node, l *models.Log) bool {
+	switch n := node.(type) {
+	case *AndNode:
+		for _, child := range n.Children {
+			if !matchNode(child, l) {
+				return false
+			}
+		}
+		return true
+	case *OrNode:
+		for _, child := range n.Children {
+			if matchNode(child, l) {
+				return true
+			}
+		}
+		return false
+	case *NotNode:
+		return !matchNode(n.Child, l)
+	case *TextNode:
+		return matchText(l.Message, n.Value) || matchText(l.Stack, n.Value)
+	case *FieldNode:
+		if n.Operator == "!=" {
+			return !matchField(n, ":", l)
+		}
+		return matchField(n, n.Operator, l)
+	}
+	return false
+}
+
+func matchText(text, value string) bool {
+	text = strings.ToLower(text)
+	value = strings.ToLower(value)
+	if strings.Contains(value, "*") {
+		for _, word := range strings.Fields(text) {
+			if globMatch(value, word) {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(text, value)
+}
+
+func matchField(n *FieldNode, op string, l *models.Log) bool {
+	f := n.field
+	switch f.kind {
+	case kindDate:
+		want, err := parseDate(n.Value, time.Now())
+		if err != nil {
+			return false
+		}
+		return compare(l.Timestamp.Compare(want), op)
+	case kindNumber, kindStatus:
+		actual, ok := parseNumericString(f.value(l))
+		if !ok {
+			return false
+		}
+		if f.kind == kindStatus && isEquality(op) {
+			from, to, err := pkg.ParseStatusRange(n.Value)
+			return err == nil && actual >= float64(from) && actual <= float64(to)
+		}
+		want, err := strconv.ParseFloat(n.Value, 64)
+		if err != nil {
+			return false
+		}
+		return compare(cmpFloat(actual, want), op)
+	case kindIP:
+		actual := net.ParseIP(f.value(l))
+		if actual == nil {
+			return false
+		}
+		if isEquality(op) {
+			if _, block, err := net.ParseCIDR(n.Value); err == nil {
+				return block.Contains(actual)
+			}
+		}
+		want := net.ParseIP(n.Value)
+		if want == nil {
+			return false
+		}
+		return compare(bytes.Compare(actual.To16(), want.To16()), op)
+	case kindText:
+		value := f.value(l)
+		if strings.Contains(n.Value, "*") {
+			if f.exactField != "" {
+				return globMatch(n.Value, value)
+			}
+			return matchText(value, n.Value)
+		}
+		return strings.Contains(strings.ToLower(value), strings.ToLower(n.Value))
+	case kindTyped:
+		value := f.value(l)
+		if isEquality(op) && strings.Contains(n.Value, "*") {
+			return globMatch(n.Value, value)
+		}
+		actual, err1 := strconv.ParseFloat(value, 64)
+		want, err2 := strconv.ParseFloat(n.Value, 64)
+		if err1 == nil && err2 == nil {
+			return compare(cmpFloat(actual, want), op)
+		}
+		return compare(strings.Compare(value, n.Value), op)
+	default:
+		value := f.value(l)
+		if isEquality(op) && strings.Contains(n.Value, "*") {
+			return globMatch(n.Value, value)
+		}
+		return compare(strings.Compare(value, n.Value), op)
+	}
+}
+
+func isEquality(op string) bool {
+	return op == ":" || op == "="
+}
+
+func compare(c int, op string) bool {
+	switch op {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return c == 0
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// parseNumericString reads numbers sent as strings, allowing a trailing "ms"
+func parseNumericString(s string) (float64, bool) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "ms"))
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
+
+// globMatch matches * and ? wildcards like the Elasticsearch wildcard query, * also spans '/'
+func globMatch(pattern, value string) bool {
+	p, v := []rune(pattern), []rune(value)
+	star, next := -1, 0
+	i, j := 0, 0
+	for j < len(v) {
+		switch {
+		case i < len(p) && p[i] == '*':
+			star, next = i, j
+			i++
+		case i < len(p) && (p[i] == '?' || p[i] == v[j]):
+			i++
+			j++
+		case star >= 0:
+			next++
+			i, j = star+1, next
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
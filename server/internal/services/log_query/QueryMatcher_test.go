@@ -0,0 +1,374 @@
+package logquery
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"server/internal/models"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func intPtr(v int) *int           { return &v }
+func floatPtr(v float64) *float64 { return &v }
+
+// matchLogs are indexed by the want lists of TestMatchParity
+var matchLogs = []*models.Log{
+	{
+		ServiceName: "shop", Level: "error", Message: "payment timed out",
+		RequestUrl: "/checkout/pay", RequestMethod: "POST", UserAgent: "Mozilla/5.0",
+		StatusCode: intPtr(504), ResponseTimeMs: floatPtr(1200),
+		Attributes: map[string]string{"tenant": "acme-eu"},
+		TypedAttrs: map[string]interface{}{"items": 3.0},
+	},
+	{
+		ServiceName: "shop", Level: "info", Message: "order placed",
+		RequestUrl: "/checkout/confirm", RequestMethod: "GET", UserAgent: "curl/8.0",
+		StatusCode: intPtr(200), ResponseTimeMs: floatPtr(85),
+		Attributes: map[string]string{"tenant": "globex"},
+		TypedAttrs: map[string]interface{}{"items": 1.0},
+	},
+	// Logs indexed before status_code and response_time_ms existed
+	{
+		ServiceName: "shop", Level: "warn", Message: "slow response from inventory",
+		RequestUrl: "/inventory", RequestMethod: "GET", UserAgent: "bot/1.0",
+		ResponseStatus: "503", ResponseTime: "750ms",
+	},
+	{
+		ServiceName: "shop", Level: "error", Message: "connection reset by peer",
+		Stack: "Error: connection reset\n    at socket", ResponseStatus: "n/a",
+	},
+	{
+		ServiceName: "billing", Level: "debug", Message: "cache warmed",
+		RequestUrl: "/health", RequestMethod: "GET",
+		StatusCode: intPtr(204), ResponseTimeMs: floatPtr(3),
+	},
+}
+
+// TestMatchParity checks that Match, which filters live streams, selects the same logs as the
+// compiled query does in Elasticsearch
+func TestMatchParity(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []int
+	}{
+		{input: "level:error", want: []int{0, 3}},
+		{input: "level:error OR level:warn", want: []int{0, 2, 3}},
+		{input: "NOT level:error", want: []int{1, 2, 4}},
+		{input: "level!=error", want: []int{1, 2, 4}},
+		{input: `"timed out"`, want: []int{0}},
+		{input: "connection reset", want: []int{3}},
+		{input: "conn*", want: []int{3}},
+		{input: "message:placed", want: []int{1}},
+		{input: "stack:socket", want: []int{3}},
+		{input: "status:5xx", want: []int{0, 2}},
+		{input: "status:200", want: []int{1}},
+		{input: "status>=500", want: []int{0, 2}},
+		{input: "responseTime>500", want: []int{0, 2}},
+		{input: "responseTime<100", want: []int{1, 4}},
+		{input: "responseTime:85", want: []int{1}},
+		{input: "requestUrl:/checkout*", want: []int{0, 1}},
+		{input: "requestMethod:POST", want: []int{0}},
+		{input: "userAgent:curl*", want: []int{1}},
+		{input: "attributes.tenant:acme*", want: []int{0}},
+		{input: "typedAttributes.items>=2", want: []int{0}},
+		{input: "serviceName:shop NOT status:2xx", want: []int{0, 2, 3}},
+		{input: "(level:error OR level:warn) responseTime>500", want: []int{0, 2}},
+		{input: "level:debug OR requestMethod:POST NOT status:504", want: []int{4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			q, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+			}
+			compiled := normalizeQuery(t, q.Compile())
+
+			matched, searched := []int{}, []int{}
+			for i, l := range matchLogs {
+				if q.Match(l) {
+					matched = append(matched, i)
+				}
+				if evalQuery(t, compiled, indexedLog(l)) {
+					searched = append(searched, i)
+				}
+			}
+			if !reflect.DeepEqual(matched, tt.want) {
+				t.Errorf("Match(%q) selected logs %v, want %v", tt.input, matched, tt.want)
+			}
+			if !reflect.DeepEqual(searched, tt.want) {
+				t.Errorf("Compile(%q) selected logs %v, want %v", tt.input, searched, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"/checkout*", "/checkout/pay", true},
+		{"*/pay", "/checkout/pay", true},
+		{"/c?eckout*", "/checkout", true},
+		{"*", "", true},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{"/checkout", "/checkout/pay", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+// textFields are analyzed in the index mapping, the other fields are keywords or numbers
+var textFields = map[string]bool{"message": true, "stack": true, "requestUrl": true, "userAgent": true}
+
+// indexedLog returns the fields of the log as the index mapping stores them
+func indexedLog(l *models.Log) map[string]interface{} {
+	doc := map[string]interface{}{}
+	set := func(field, value string) {
+		if value != "" {
+			doc[field] = value
+		}
+	}
+	set("serviceName", l.ServiceName)
+	set("level", l.Level)
+	set("requestId", l.RequestId)
+	set("requestMethod", l.RequestMethod)
+	set("ipAddress", l.IpAddress)
+	for field, value := range map[string]string{"message": l.Message, "requestUrl": l.RequestUrl, "userAgent": l.UserAgent} {
+		set(field, value)
+		set(field+".keyword", value)
+	}
+	set("stack", l.Stack)
+	set("responseStatus.keyword", l.ResponseStatus)
+	set("responseTime.keyword", l.ResponseTime)
+	if l.StatusCode != nil {
+		doc["status_code"] = float64(*l.StatusCode)
+	}
+	if l.ResponseTimeMs != nil {
+		doc["response_time_ms"] = *l.ResponseTimeMs
+	}
+	for k, v := range l.Attributes {
+		set("attributes."+k, v)
+	}
+	for k, v := range l.TypedAttrs {
+		doc["typed_attributes."+k] = v
+	}
+	return doc
+}
+
+// normalizeQuery round trips the query through JSON, as it is sent to Elasticsearch
+func normalizeQuery(t *testing.T, query map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	var normalized map[string]interface{}
+	if err := json.Unmarshal([]byte(toJSON(t, query)), &normalized); err != nil {
+		t.Fatal(err)
+	}
+	return normalized
+}
+
+// evalQuery evaluates the subset of the Elasticsearch query DSL used by Compile against a
+// document, analyzing text fields into lower case words like the standard analyzer
+func evalQuery(t *testing.T, query map[string]interface{}, doc map[string]interface{}) bool {
+	t.Helper()
+	if len(query) != 1 {
+		t.Fatalf("query %v should have a single clause", query)
+	}
+	for kind, body := range query {
+		clause := body.(map[string]interface{})
+		switch kind {
+		case "bool":
+			return evalBool(t, clause, doc)
+		case "multi_match":
+			for _, field := range clause["fields"].([]interface{}) {
+				words := analyze(docString(doc, field.(string)))
+				if clause["type"] == "phrase" && containsPhrase(words, analyze(clause["query"].(string))) {
+					return true
+				}
+				if clause["operator"] == "and" && containsAll(words, analyze(clause["query"].(string))) {
+					return true
+				}
+			}
+			return false
+		case "exists":
+			_, ok := doc[clause["field"].(string)]
+			return ok
+		case "script":
+			params := clause["script"].(map[string]interface{})["params"].(map[string]interface{})
+			value := strings.TrimSpace(docString(doc, params["field"].(string)))
+			actual, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, "ms")), 64)
+			if err != nil {
+				return false
+			}
+			want := params["value"].(float64)
+			switch params["op"] {
+			case ">":
+				return actual > want
+			case ">=":
+				return actual >= want
+			case "<":
+				return actual < want
+			case "<=":
+				return actual <= want
+			}
+			return actual == want
+		}
+
+		field, arg := singleField(t, clause)
+		actual, ok := doc[field]
+		if !ok {
+			return false
+		}
+		switch kind {
+		case "term":
+			if number, isNumber := actual.(float64); isNumber {
+				want, err := strconv.ParseFloat(docValueString(arg), 64)
+				return err == nil && number == want
+			}
+			return actual == arg
+		case "match_phrase":
+			return containsPhrase(analyze(actual.(string)), analyze(arg.(string)))
+		case "prefix":
+			return strings.HasPrefix(actual.(string), arg.(string))
+		case "regexp":
+			return regexp.MustCompile("^(?:" + arg.(string) + ")$").MatchString(actual.(string))
+		case "wildcard":
+			spec := arg.(map[string]interface{})
+			pattern := spec["value"].(string)
+			if textFields[field] {
+				// wildcards run against the analyzed words, which are lower case
+				for _, word := range analyze(actual.(string)) {
+					if globMatch(strings.ToLower(pattern), word) {
+						return true
+					}
+				}
+				return false
+			}
+			value := actual.(string)
+			if spec["case_insensitive"] == true {
+				pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+			}
+			return globMatch(pattern, value)
+		case "range":
+			for op, bound := range arg.(map[string]interface{}) {
+				var c int
+				if number, isNumber := actual.(float64); isNumber {
+					want, err := strconv.ParseFloat(docValueString(bound), 64)
+					if err != nil {
+						t.Fatalf("range bound %v of %s is not a number", bound, field)
+					}
+					c = cmpFloat(number, want)
+				} else {
+					c = strings.Compare(actual.(string), bound.(string))
+				}
+				if !map[string]bool{"gt": c > 0, "gte": c >= 0, "lt": c < 0, "lte": c <= 0}[op] {
+					return false
+				}
+			}
+			return true
+		}
+		t.Fatalf("unsupported query %s", kind)
+	}
+	return false
+}
+
+func evalBool(t *testing.T, clause map[string]interface{}, doc map[string]interface{}) bool {
+	t.Helper()
+	clauses := func(occur string) []map[string]interface{} {
+		var queries []map[string]interface{}
+		for _, q := range asList(clause[occur]) {
+			queries = append(queries, q.(map[string]interface{}))
+		}
+		return queries
+	}
+	for _, occur := range []string{"must", "filter"} {
+		for _, q := range clauses(occur) {
+			if !evalQuery(t, q, doc) {
+				return false
+			}
+		}
+	}
+	for _, q := range clauses("must_not") {
+		if evalQuery(t, q, doc) {
+			return false
+		}
+	}
+	should := clauses("should")
+	minimum := 0
+	if m, ok := clause["minimum_should_match"].(float64); ok {
+		minimum = int(m)
+	} else if len(should) > 0 && clause["must"] == nil && clause["filter"] == nil {
+		minimum = 1
+	}
+	matched := 0
+	for _, q := range should {
+		if evalQuery(t, q, doc) {
+			matched++
+		}
+	}
+	return matched >= minimum
+}
+
+func asList(v interface{}) []interface{} {
+	if v == nil {
+		return nil
+	}
+	return v.([]interface{})
+}
+
+func singleField(t *testing.T, clause map[string]interface{}) (string, interface{}) {
+	t.Helper()
+	if len(clause) != 1 {
+		t.Fatalf("clause %v should name a single field", clause)
+	}
+	for field, arg := range clause {
+		return field, arg
+	}
+	return "", nil
+}
+
+func docString(doc map[string]interface{}, field string) string {
+	s, _ := doc[field].(string)
+	return s
+}
+
+func docValueString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return strconv.FormatFloat(v.(float64), 'f', -1, 64)
+}
+
+func analyze(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func containsAll(words, terms []string) bool {
+	for _, term := range terms {
+		found := false
+		for _, word := range words {
+			found = found || word == term
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func containsPhrase(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		if reflect.DeepEqual(words[i:i+len(phrase)], phrase) {
+			return true
+		}
+	}
+	return false
+}
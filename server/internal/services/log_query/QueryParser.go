@@ -0,0 +1,353 @@
+package logquery
+
+import (
+	"fmt"
+	"net"
+	"server/pkg"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseError describes why a query could not be parsed and where, so clients can point at it.
+type ParseError struct {
+	Column  int    `json:"column"`
+	Token   string `json:"token,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Message)
+}
+
+func newParseError(column int, token string, format string, args ...interface{}) *ParseError {
+	return &ParseError{Column: column, Token: token, Message: fmt.Sprintf(format, args...)}
+}
+
+// Node is an element of the parsed query. String renders it back in canonical form.
+type Node interface {
+	String() string
+}
+
+// AndNode matches when all children match
+type AndNode struct {
+	Children []Node
+}
+
+// OrNode matches when any child matches
+type OrNode struct {
+	Children []Node
+}
+
+// NotNode matches when its child does not
+type NotNode struct {
+	Child Node
+}
+
+// FieldNode compares a field with a value, e.g. level:error or responseTime>500
+type FieldNode struct {
+	Field    string
+	Operator string
+	Value    string
+	Quoted   bool
+	Column   int
+	field    *field
+}
+
+// TextNode searches message and stack for a word or a quoted phrase
+type TextNode struct {
+	Value  string
+	Quoted bool
+	Column int
+}
+
+func (n *AndNode) String() string { return joinNodes(n.Children, " AND ") }
+func (n *OrNode) String() string  { return joinNodes(n.Children, " OR ") }
+func (n *NotNode) String() string { return "NOT " + joinNodes([]Node{n.Child}, "") }
+
+func (n *FieldNode) String() string {
+	return n.field.name + n.Operator + quoteValue(n.Value, n.Quoted)
+}
+
+func (n *TextNode) String() string {
+	return quoteValue(n.Value, n.Quoted)
+}
+
+func joinNodes(nodes []Node, sep string) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = n.String()
+		switch n.(type) {
+		case *AndNode, *OrNode:
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+func quoteValue(value string, quoted bool) string {
+	if !quoted {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// Query is a parsed LogBoy query. It compiles to an Elasticsearch query for searches and is
+// matched in memory against live logs.
+type Query struct {
+	Source string
+	Root   Node
+}
+
+func (q *Query) String() string {
+	return q.Root.String()
+}
+
+// Parse parses a query such as
+//
+//	level:error AND requestUrl:"/checkout*" AND responseTime>500 NOT userAgent:bot
+//
+// Terms next to each other are joined with AND, NOT binds tighter than AND, and AND binds
+// tighter than OR. Words without a field are searched for in message and stack.
+func Parse(input string) (*Query, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, newParseError(1, "", "query is empty")
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, newParseError(tok.column, tok.value, "unexpected %s", describe(tok))
+	}
+	return &Query{Source: input, Root: root}, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (Node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []Node{first}
+	for p.peek().kind == tokenOr {
+		p.next()
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &OrNode{Children: children}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	first, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	children := []Node{first}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenWord, tokenString, tokenNot, tokenLParen:
+			// implicit AND
+		default:
+			if len(children) == 1 {
+				return first, nil
+			}
+			return &AndNode{Children: children}, nil
+		}
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+}
+
+func (p *parser) parseNot() (Node, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &NotNode{Child: child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.peek(); closing.kind != tokenRParen {
+			return nil, newParseError(tok.column, tok.value, "missing ')' for '(' at column %d, found %s", tok.column, describe(closing))
+		}
+		p.next()
+		return node, nil
+	case tokenWord:
+		if p.peek().kind == tokenOperator {
+			return p.parseField(tok)
+		}
+		return &TextNode{Value: tok.value, Column: tok.column}, nil
+	case tokenString:
+		return &TextNode{Value: tok.value, Quoted: true, Column: tok.column}, nil
+	case tokenOperator:
+		return nil, newParseError(tok.column, tok.value, "expected a field name before '%s'", tok.value)
+	case tokenEOF:
+		return nil, newParseError(tok.column, "", "unexpected end of query, expected a term")
+	default:
+		return nil, newParseError(tok.column, tok.value, "expected a term, found %s", describe(tok))
+	}
+}
+
+func (p *parser) parseField(name token) (Node, error) {
+	f, ok := lookupField(name.value)
+	if !ok {
+		return nil, newParseError(name.column, name.value, "unknown field %q, expected one of %s", name.value, strings.Join(FieldNames(), ", "))
+	}
+	op := p.next()
+	value := p.next()
+	if value.kind != tokenWord && value.kind != tokenString {
+		return nil, newParseError(value.column, value.value, "expected a value after '%s', found %s", op.value, describe(value))
+	}
+
+	node := &FieldNode{
+		Field:    f.name,
+		Operator: op.value,
+		Value:    value.value,
+		Quoted:   value.kind == tokenString,
+		Column:   name.column,
+		field:    f,
+	}
+	if f.kind == kindText && !isEquality(op.value) && op.value != "!=" {
+		return nil, newParseError(op.column, op.value, "operator '%s' is not supported on text field %s", op.value, f.name)
+	}
+	if err := validateField(node); err != nil {
+		return nil, newParseError(value.column, value.value, "%s", err.Error())
+	}
+	return node, nil
+}
+
+// validateField checks that the value fits the type of the field
+func validateField(n *FieldNode) error {
+	equality := isEquality(n.Operator) || n.Operator == "!="
+	if !equality && strings.Contains(n.Value, "*") {
+		return fmt.Errorf("wildcards are not supported with '%s'", n.Operator)
+	}
+
+	if n.field.prefixOnly && strings.Contains(strings.TrimSuffix(n.Value, "*"), "*") {
+		return fmt.Errorf("%s only supports a trailing * wildcard", n.field.name)
+	}
+
+	switch n.field.kind {
+	case kindNumber:
+		if _, err := strconv.ParseFloat(n.Value, 64); err != nil {
+			return fmt.Errorf("%s expects a number", n.field.name)
+		}
+	case kindStatus:
+		if equality {
+			if _, _, err := pkg.ParseStatusRange(n.Value); err != nil {
+				return fmt.Errorf("%s expects a status code, a class like 5xx or a range like 400-499", n.field.name)
+			}
+		} else if _, err := strconv.Atoi(n.Value); err != nil {
+			return fmt.Errorf("%s expects a status code", n.field.name)
+		}
+	case kindIP:
+		if net.ParseIP(n.Value) == nil {
+			if _, _, err := net.ParseCIDR(n.Value); err != nil || !equality {
+				return fmt.Errorf("%s expects an IP address or, with ':', a CIDR block", n.field.name)
+			}
+		}
+	case kindDate:
+		if _, err := parseDate(n.Value, time.Now()); err != nil {
+			return fmt.Errorf("%s expects an RFC3339 time or date math like now-1h", n.field.name)
+		}
+	}
+	return nil
+}
+
+func describe(tok token) string {
+	switch tok.kind {
+	case tokenWord, tokenOperator:
+		return fmt.Sprintf("'%s'", tok.value)
+	case tokenString:
+		return fmt.Sprintf("%q", tok.value)
+	}
+	return tok.kind.String()
+}
+
+const timeLayout = time.RFC3339Nano
+
+// parseDate parses an RFC3339 time or date math relative to now: now, now-15m, now-1d, now+2h
+func parseDate(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if !strings.HasPrefix(value, "now") {
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+	rest := strings.TrimPrefix(value, "now")
+	if rest == "" {
+		return now, nil
+	}
+	if len(rest) < 3 || (rest[0] != '-' && rest[0] != '+') {
+		return time.Time{}, fmt.Errorf("invalid date math %q", value)
+	}
+	n, err := strconv.Atoi(rest[1 : len(rest)-1])
+	if err != nil || n < 0 {
+		return time.Time{}, fmt.Errorf("invalid date math %q", value)
+	}
+	var unit time.Duration
+	switch rest[len(rest)-1] {
+	case 's':
+		unit = time.Second
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	default:
+		return time.Time{}, fmt.Errorf("invalid date math unit in %q", value)
+	}
+	offset := time.Duration(n) * unit
+	if rest[0] == '-' {
+		offset = -offset
+	}
+	return now.Add(offset), nil
+}
@@ -0,0 +1,119 @@
+package logquery
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePrecedence(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "level:error", want: "level:error"},
+		{input: "LEVEL:error", want: "level:error"},
+		{input: "a b c", want: "a AND b AND c"},
+		{input: "a OR b c", want: "a OR (b AND c)"},
+		{input: "a b OR c", want: "(a AND b) OR c"},
+		{input: "NOT a b", want: "NOT a AND b"},
+		{input: "NOT (a OR b)", want: "NOT (a OR b)"},
+		{input: "NOT NOT a", want: "NOT NOT a"},
+		{input: "(a OR b) AND c", want: "(a OR b) AND c"},
+		{input: "a AND (b AND c)", want: "a AND (b AND c)"},
+		{input: `level:error AND requestUrl:"/checkout*" AND responseTime>500 NOT userAgent:bot`,
+			want: `level:error AND requestUrl:"/checkout*" AND responseTime>500 AND NOT userAgent:bot`},
+		{input: `"timed out" OR status:5xx`, want: `"timed out" OR responseStatus:5xx`},
+		{input: "attributes.tenant:acme*", want: "attributes.tenant:acme*"},
+		{input: "typedAttributes.cart.items>=3", want: "typedAttributes.cart.items>=3"},
+		{input: "ipAddress:10.0.0.0/8", want: "ipAddress:10.0.0.0/8"},
+		{input: "timestamp>now-15m", want: "timestamp>now-15m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			q, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+			}
+			if got := q.String(); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		column  int
+		token   string
+		message string
+	}{
+		{input: "", column: 1, message: "query is empty"},
+		{input: "(level:error", column: 1, token: "(", message: "missing ')'"},
+		{input: "level:error)", column: 12, token: ")", message: "unexpected ')'"},
+		{input: "level:", column: 7, message: "expected a value after ':'"},
+		{input: ":error", column: 1, token: ":", message: "expected a field name"},
+		{input: "a AND", column: 6, message: "unexpected end of query"},
+		{input: "a OR OR b", column: 6, token: "OR", message: "expected a term"},
+		{input: "colour:red", column: 1, token: "colour", message: `unknown field "colour"`},
+		{input: "message>5", column: 8, token: ">", message: "not supported on text field message"},
+		{input: "responseTime>slow", column: 14, token: "slow", message: "expects a number"},
+		{input: "responseTime>5*", column: 14, token: "5*", message: "wildcards are not supported"},
+		{input: "status:6xx", column: 8, token: "6xx", message: "expects a status code"},
+		{input: "status>5xx", column: 8, token: "5xx", message: "expects a status code"},
+		{input: "ipAddress>10.0.0.0/8", column: 11, token: "10.0.0.0/8", message: "expects an IP address"},
+		{input: "timestamp>yesterday", column: 11, token: "yesterday", message: "expects an RFC3339 time"},
+		{input: "attributes.tenant:a*c", column: 19, token: "a*c", message: "only supports a trailing * wildcard"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Parse(%q) error = %v, want a ParseError", tt.input, err)
+			}
+			if parseErr.Column != tt.column || parseErr.Token != tt.token {
+				t.Errorf("Parse(%q) error at column %d token %q, want column %d token %q", tt.input, parseErr.Column, parseErr.Token, tt.column, tt.token)
+			}
+			if !strings.Contains(parseErr.Message, tt.message) {
+				t.Errorf("Parse(%q) error %q, want it to contain %q", tt.input, parseErr.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "now", want: now},
+		{value: "now-15m", want: now.Add(-15 * time.Minute)},
+		{value: "now+2h", want: now.Add(2 * time.Hour)},
+		{value: "now-1d", want: now.Add(-24 * time.Hour)},
+		{value: "now-1w", want: now.Add(-7 * 24 * time.Hour)},
+		{value: "2024-04-30T08:00:00Z", want: time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC)},
+		{value: "now-1y", wantErr: true},
+		{value: "now-m", wantErr: true},
+		{value: "now*2h", wantErr: true},
+		{value: "2024-04-30", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseDate(tt.value, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseDate(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("parseDate(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
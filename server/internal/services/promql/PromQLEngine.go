@@ -0,0 +1,471 @@
+package promql
+
+import (
+	"math"
+	"server/internal/api/dto"
+	"server/internal/models"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LookbackDelta is how far back an instant selector looks for the latest sample of a series
+const LookbackDelta = 5 * time.Minute
+
+// Source reads the points of the named metrics between from and to, in epoch milliseconds and
+// in time order. The label matchers are a subset of the selector's that the source can apply.
+type Source func(names []string, matchers []*dto.LabelMatcher, from int64, to int64) ([]*models.MetricPoint, error)
+
+// Series is the result for one label set, with a value per step and NaN where there is none
+type Series struct {
+	Labels map[string]string
+	Values []float64
+}
+
+// Evaluate evaluates the expression at every step from start to end, as a Prometheus range
+// query does. Counters, gauges and histograms are read as the series Prometheus would have:
+// a histogram named x is seen as x_bucket with an le label, x_sum and x_count.
+func Evaluate(expr Expr, source Source, start time.Time, end time.Time, step time.Duration) ([]*Series, error) {
+	e := &evaluator{source: source}
+	for t := start; !t.After(end); t = t.Add(step) {
+		e.steps = append(e.steps, t.UnixMilli())
+	}
+	return e.eval(expr)
+}
+
+type sample struct {
+	t int64
+	v float64
+}
+
+type rawSeries struct {
+	labels  map[string]string
+	samples []sample
+}
+
+type evaluator struct {
+	source Source
+	steps  []int64
+}
+
+func (e *evaluator) eval(expr Expr) ([]*Series, error) {
+	switch n := expr.(type) {
+	case *VectorSelector:
+		return e.evalSelector(n)
+	case *Call:
+		if n.Func == "histogram_quantile" {
+			vector, err := e.eval(n.Args[1])
+			if err != nil {
+				return nil, err
+			}
+			return e.histogramQuantile(n.Args[0].(*NumberLiteral).Value, vector), nil
+		}
+		return e.evalRangeFunction(n.Func, n.Args[0].(*VectorSelector))
+	case *Aggregate:
+		vector, err := e.eval(n.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return e.aggregate(n, vector), nil
+	}
+	return nil, newParseError(1, "", "cannot evaluate %s", expr.String())
+}
+
+// evalSelector takes the latest sample of each series within the lookback delta of each step
+func (e *evaluator) evalSelector(selector *VectorSelector) ([]*Series, error) {
+	series, err := e.selectSeries(selector, LookbackDelta)
+	if err != nil {
+		return nil, err
+	}
+	lookback := LookbackDelta.Milliseconds()
+	result := make([]*Series, 0, len(series))
+	for _, s := range series {
+		values := make([]float64, len(e.steps))
+		for i, t := range e.steps {
+			values[i] = math.NaN()
+			// index of the first sample after t
+			j := sort.Search(len(s.samples), func(k int) bool { return s.samples[k].t > t })
+			if j > 0 && s.samples[j-1].t > t-lookback {
+				values[i] = s.samples[j-1].v
+			}
+		}
+		result = append(result, &Series{Labels: s.labels, Values: values})
+	}
+	return result, nil
+}
+
+// evalRangeFunction applies the function to the samples of each series in the window
+// before each step. The metric name is dropped, as the result is not that metric anymore.
+func (e *evaluator) evalRangeFunction(fn string, selector *VectorSelector) ([]*Series, error) {
+	series, err := e.selectSeries(selector, selector.Range)
+	if err != nil {
+		return nil, err
+	}
+	window := selector.Range.Milliseconds()
+	result := make([]*Series, 0, len(series))
+	for _, s := range series {
+		values := make([]float64, len(e.steps))
+		for i, t := range e.steps {
+			from := sort.Search(len(s.samples), func(k int) bool { return s.samples[k].t > t-window })
+			to := sort.Search(len(s.samples), func(k int) bool { return s.samples[k].t > t })
+			values[i] = rangeFunction(fn, s.samples[from:to], t-window, t, selector.Range)
+		}
+		result = append(result, &Series{Labels: withoutLabels(s.labels, "__name__"), Values: values})
+	}
+	return result, nil
+}
+
+func rangeFunction(fn string, samples []sample, rangeStart int64, rangeEnd int64, window time.Duration) float64 {
+	if len(samples) == 0 {
+		return math.NaN()
+	}
+	switch fn {
+	case "rate":
+		return extrapolatedRate(samples, rangeStart, rangeEnd, window, true)
+	case "increase":
+		return extrapolatedRate(samples, rangeStart, rangeEnd, window, false)
+	case "count_over_time":
+		return float64(len(samples))
+	}
+
+	result := samples[0].v
+	var sum float64
+	for _, s := range samples {
+		sum += s.v
+		switch fn {
+		case "min_over_time":
+			result = math.Min(result, s.v)
+		case "max_over_time":
+			result = math.Max(result, s.v)
+		}
+	}
+	switch fn {
+	case "sum_over_time":
+		return sum
+	case "avg_over_time":
+		return sum / float64(len(samples))
+	}
+	return result
+}
+
+// extrapolatedRate computes the increase of a counter over the window, accounting for resets
+// and extrapolating to the edges of the window the way Prometheus does
+func extrapolatedRate(samples []sample, rangeStart int64, rangeEnd int64, window time.Duration, isRate bool) float64 {
+	if len(samples) < 2 {
+		return math.NaN()
+	}
+	first, last := samples[0], samples[len(samples)-1]
+	sampledInterval := float64(last.t-first.t) / 1000
+	if sampledInterval <= 0 {
+		return math.NaN()
+	}
+
+	result := last.v - first.v
+	previous := first.v
+	for _, s := range samples[1:] {
+		// A counter that went down was reset, the value before the reset was counted too
+		if s.v < previous {
+			result += previous
+		}
+		previous = s.v
+	}
+
+	durationToStart := float64(first.t-rangeStart) / 1000
+	durationToEnd := float64(rangeEnd-last.t) / 1000
+	averageInterval := sampledInterval / float64(len(samples)-1)
+
+	// Counters do not go below zero, so the extrapolation stops where the counter would be zero
+	if result > 0 && first.v >= 0 {
+		if durationToZero := sampledInterval * (first.v / result); durationToZero < durationToStart {
+			durationToStart = durationToZero
+		}
+	}
+
+	threshold := averageInterval * 1.1
+	extrapolatedInterval := sampledInterval
+	if durationToStart < threshold {
+		extrapolatedInterval += durationToStart
+	} else {
+		extrapolatedInterval += averageInterval / 2
+	}
+	if durationToEnd < threshold {
+		extrapolatedInterval += durationToEnd
+	} else {
+		extrapolatedInterval += averageInterval / 2
+	}
+
+	result *= extrapolatedInterval / sampledInterval
+	if isRate {
+		result /= window.Seconds()
+	}
+	return result
+}
+
+// aggregate combines the series per group and step, ignoring steps without a value
+func (e *evaluator) aggregate(agg *Aggregate, vector []*Series) []*Series {
+	groups := make(map[string]*Series)
+	counts := make(map[string][]int)
+	var order []string
+	for _, s := range vector {
+		var labels map[string]string
+		if agg.Without {
+			labels = withoutLabels(s.Labels, append([]string{"__name__"}, agg.Grouping...)...)
+		} else {
+			labels = make(map[string]string, len(agg.Grouping))
+			for _, key := range agg.Grouping {
+				if value, ok := s.Labels[key]; ok {
+					labels[key] = value
+				}
+			}
+		}
+		key := labelsKey(labels)
+		group, ok := groups[key]
+		if !ok {
+			group = &Series{Labels: labels, Values: make([]float64, len(e.steps))}
+			for i := range group.Values {
+				group.Values[i] = math.NaN()
+			}
+			groups[key] = group
+			counts[key] = make([]int, len(e.steps))
+			order = append(order, key)
+		}
+
+		for i, value := range s.Values {
+			if math.IsNaN(value) {
+				continue
+			}
+			current := group.Values[i]
+			counts[key][i]++
+			switch {
+			case math.IsNaN(current):
+				group.Values[i] = value
+			case agg.Op == "sum" || agg.Op == "avg":
+				group.Values[i] = current + value
+			case agg.Op == "min":
+				group.Values[i] = math.Min(current, value)
+			case agg.Op == "max":
+				group.Values[i] = math.Max(current, value)
+			}
+		}
+	}
+
+	result := make([]*Series, 0, len(order))
+	for _, key := range order {
+		group := groups[key]
+		for i, n := range counts[key] {
+			switch {
+			case n == 0:
+			case agg.Op == "avg":
+				group.Values[i] /= float64(n)
+			case agg.Op == "count":
+				group.Values[i] = float64(n)
+			}
+		}
+		result = append(result, group)
+	}
+	return result
+}
+
+// histogramQuantile estimates the quantile from the le buckets of each label set, interpolating
+// linearly within the bucket the quantile falls in, as Prometheus does
+func (e *evaluator) histogramQuantile(quantile float64, vector []*Series) []*Series {
+	type bucket struct {
+		upperBound float64
+		series     *Series
+	}
+	groups := make(map[string][]bucket)
+	labelsOf := make(map[string]map[string]string)
+	var order []string
+	for _, s := range vector {
+		upperBound, err := strconv.ParseFloat(s.Labels["le"], 64)
+		if err != nil {
+			continue
+		}
+		labels := withoutLabels(s.Labels, "le", "__name__")
+		key := labelsKey(labels)
+		if _, ok := groups[key]; !ok {
+			labelsOf[key] = labels
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], bucket{upperBound: upperBound, series: s})
+	}
+
+	result := make([]*Series, 0, len(order))
+	for _, key := range order {
+		buckets := groups[key]
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].upperBound < buckets[j].upperBound })
+		values := make([]float64, len(e.steps))
+		for i := range e.steps {
+			bounds := make([]float64, 0, len(buckets))
+			counts := make([]float64, 0, len(buckets))
+			for _, b := range buckets {
+				if v := b.series.Values[i]; !math.IsNaN(v) {
+					bounds = append(bounds, b.upperBound)
+					counts = append(counts, v)
+				}
+			}
+			values[i] = bucketQuantile(quantile, bounds, counts)
+		}
+		result = append(result, &Series{Labels: labelsOf[key], Values: values})
+	}
+	return result
+}
+
+func bucketQuantile(quantile float64, bounds []float64, counts []float64) float64 {
+	switch {
+	case math.IsNaN(quantile):
+		return math.NaN()
+	case quantile < 0:
+		return math.Inf(-1)
+	case quantile > 1:
+		return math.Inf(1)
+	}
+	if len(bounds) < 2 || !math.IsInf(bounds[len(bounds)-1], 1) {
+		return math.NaN()
+	}
+	// Counts estimated from rates can dip slightly, keep them monotonic
+	for i := 1; i < len(counts); i++ {
+		counts[i] = math.Max(counts[i], counts[i-1])
+	}
+	total := counts[len(counts)-1]
+	if total == 0 {
+		return math.NaN()
+	}
+
+	rank := quantile * total
+	b := sort.SearchFloat64s(counts, rank)
+	if b == len(bounds)-1 {
+		return bounds[len(bounds)-2]
+	}
+	if b == 0 && bounds[0] <= 0 {
+		return bounds[0]
+	}
+
+	start, end := 0.0, bounds[b]
+	count := counts[b]
+	if b > 0 {
+		start = bounds[b-1]
+		count -= counts[b-1]
+		rank -= counts[b-1]
+	}
+	if count == 0 {
+		return end
+	}
+	return start + (end-start)*(rank/count)
+}
+
+// selectSeries reads the series of the selector with the samples needed to evaluate every
+// step, going back window before the first one
+func (e *evaluator) selectSeries(selector *VectorSelector, window time.Duration) ([]*rawSeries, error) {
+	if len(e.steps) == 0 {
+		return nil, nil
+	}
+	names := []string{selector.Name}
+	base, part := "", ""
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if name, ok := strings.CutSuffix(selector.Name, suffix); ok {
+			base, part = name, suffix
+			names = append(names, base)
+		}
+	}
+
+	// Exact matchers are applied by the source, all of them are checked again below
+	var pushed []*dto.LabelMatcher
+	for _, m := range selector.Matchers {
+		if (m.Op == "=" || m.Op == "!=") && m.Name != "le" {
+			pushed = append(pushed, &dto.LabelMatcher{Name: m.Name, Value: m.Value, Negate: m.Op == "!="})
+		}
+	}
+
+	from := e.steps[0] - window.Milliseconds()
+	points, err := e.source(names, pushed, from, e.steps[len(e.steps)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	series := make(map[string]*rawSeries)
+	var order []string
+	add := func(labels map[string]string, t int64, v float64) {
+		for _, m := range selector.Matchers {
+			if !m.matches(labels[m.Name]) {
+				return
+			}
+		}
+		key := labelsKey(labels)
+		s, ok := series[key]
+		if !ok {
+			s = &rawSeries{labels: labels}
+			series[key] = s
+			order = append(order, key)
+		}
+		s.samples = append(s.samples, sample{t: t, v: v})
+	}
+
+	for _, p := range points {
+		switch {
+		case p.Type != "histogram" && p.Name == selector.Name && p.Value != nil:
+			add(withLabel(p.Labels, "__name__", selector.Name), p.Timestamp, *p.Value)
+		case p.Type == "histogram" && p.Name == base:
+			switch part {
+			case "_bucket":
+				for _, b := range p.Buckets {
+					le := "+Inf"
+					if b.UpperBound != nil {
+						le = strconv.FormatFloat(*b.UpperBound, 'f', -1, 64)
+					}
+					labels := withLabel(withLabel(p.Labels, "__name__", selector.Name), "le", le)
+					add(labels, p.Timestamp, float64(b.Count))
+				}
+			case "_sum":
+				if p.Sum != nil {
+					add(withLabel(p.Labels, "__name__", selector.Name), p.Timestamp, *p.Sum)
+				}
+			case "_count":
+				if p.Count != nil {
+					add(withLabel(p.Labels, "__name__", selector.Name), p.Timestamp, float64(*p.Count))
+				}
+			}
+		}
+	}
+
+	result := make([]*rawSeries, 0, len(order))
+	for _, key := range order {
+		result = append(result, series[key])
+	}
+	return result, nil
+}
+
+func withLabel(labels map[string]string, name string, value string) map[string]string {
+	copied := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		copied[k] = v
+	}
+	copied[name] = value
+	return copied
+}
+
+func withoutLabels(labels map[string]string, names ...string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if !slices.Contains(names, k) {
+			copied[k] = v
+		}
+	}
+	return copied
+}
+
+// labelsKey identifies a label set
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key + "\xff" + labels[key] + "\xff")
+	}
+	return b.String()
+}
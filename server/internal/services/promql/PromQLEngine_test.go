@@ -0,0 +1,202 @@
+package promql
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"server/internal/api/dto"
+	"server/internal/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+// counterPoints returns a counter growing by perSecond, sampled every 15s for 10 minutes
+func counterPoints(name string, labels map[string]string, perSecond float64) []*models.MetricPoint {
+	var points []*models.MetricPoint
+	for t := int64(0); t <= 600; t += 15 {
+		points = append(points, &models.MetricPoint{
+			Name:      name,
+			Type:      "counter",
+			Labels:    labels,
+			Timestamp: t * 1000,
+			Value:     floatPtr(float64(t) * perSecond),
+		})
+	}
+	return points
+}
+
+// pointsSource serves the points of the requested metrics in the time range, as the index does
+func pointsSource(points []*models.MetricPoint) Source {
+	return func(names []string, matchers []*dto.LabelMatcher, from int64, to int64) ([]*models.MetricPoint, error) {
+		var selected []*models.MetricPoint
+		for _, p := range points {
+			if slices.Contains(names, p.Name) && p.Timestamp >= from && p.Timestamp <= to {
+				selected = append(selected, p)
+			}
+		}
+		slices.SortStableFunc(selected, func(a, b *models.MetricPoint) int { return int(a.Timestamp - b.Timestamp) })
+		return selected, nil
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	var points []*models.MetricPoint
+	points = append(points, counterPoints("http_requests_total", map[string]string{"job": "api", "instance": "a"}, 1)...)
+	points = append(points, counterPoints("http_requests_total", map[string]string{"job": "api", "instance": "b"}, 2)...)
+	points = append(points, counterPoints("http_requests_total", map[string]string{"job": "web", "instance": "c"}, 5)...)
+	points = append(points,
+		&models.MetricPoint{Name: "queue_depth", Type: "gauge", Labels: map[string]string{"queue": "mail"}, Timestamp: 250_000, Value: floatPtr(7)},
+		&models.MetricPoint{Name: "queue_depth", Type: "gauge", Labels: map[string]string{"queue": "mail"}, Timestamp: 290_000, Value: floatPtr(3)},
+		&models.MetricPoint{
+			Name: "latency_seconds", Type: "histogram", Labels: map[string]string{"job": "api"}, Timestamp: 300_000,
+			Buckets: []*models.HistogramBucket{
+				{UpperBound: floatPtr(0.1), Count: 50},
+				{UpperBound: floatPtr(0.5), Count: 90},
+				{Count: 100},
+			},
+		},
+	)
+
+	// Two steps, at 5 and 6 minutes
+	start, end, step := time.UnixMilli(300_000), time.UnixMilli(360_000), time.Minute
+	tests := []struct {
+		query string
+		want  []*Series
+	}{
+		{
+			query: `rate(http_requests_total{instance="a"}[1m])`,
+			want:  []*Series{{Labels: map[string]string{"job": "api", "instance": "a"}, Values: []float64{1, 1}}},
+		},
+		{
+			query: `increase(http_requests_total{job="web"}[2m])`,
+			want:  []*Series{{Labels: map[string]string{"job": "web", "instance": "c"}, Values: []float64{600, 600}}},
+		},
+		{
+			query: "sum by (job) (rate(http_requests_total[1m]))",
+			want: []*Series{
+				{Labels: map[string]string{"job": "api"}, Values: []float64{3, 3}},
+				{Labels: map[string]string{"job": "web"}, Values: []float64{5, 5}},
+			},
+		},
+		{
+			query: "sum(rate(http_requests_total[1m]))",
+			want:  []*Series{{Labels: map[string]string{}, Values: []float64{8, 8}}},
+		},
+		{
+			query: `avg without (instance) (rate(http_requests_total{job=~"a.*"}[1m]))`,
+			want:  []*Series{{Labels: map[string]string{"job": "api"}, Values: []float64{1.5, 1.5}}},
+		},
+		{
+			query: `count(http_requests_total{instance!="c"})`,
+			want:  []*Series{{Labels: map[string]string{}, Values: []float64{2, 2}}},
+		},
+		{
+			// The latest sample within the lookback delta of 5 minutes
+			query: "queue_depth",
+			want: []*Series{{
+				Labels: map[string]string{"__name__": "queue_depth", "queue": "mail"},
+				Values: []float64{3, 3},
+			}},
+		},
+		{
+			// Only the window before each step counts, the minute before 6 minutes has no samples
+			query: "max_over_time(queue_depth[1m])",
+			want:  []*Series{{Labels: map[string]string{"queue": "mail"}, Values: []float64{7, math.NaN()}}},
+		},
+		{
+			query: "histogram_quantile(0.7, latency_seconds_bucket)",
+			want:  []*Series{{Labels: map[string]string{"job": "api"}, Values: []float64{0.3, 0.3}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			expr, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.query, err)
+			}
+			got, err := Evaluate(expr, pointsSource(points), start, end, step)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) failed: %v", tt.query, err)
+			}
+			if !equalSeries(got, tt.want) {
+				t.Errorf("Evaluate(%q) =\n%s\nwant\n%s", tt.query, formatSeries(got), formatSeries(tt.want))
+			}
+		})
+	}
+}
+
+func TestEvaluateRateWithCounterReset(t *testing.T) {
+	// The counter restarts from zero at 30s, the increase before the reset still counts
+	var points []*models.MetricPoint
+	for i, v := range []float64{10, 20, 5, 15, 25} {
+		points = append(points, &models.MetricPoint{Name: "jobs_total", Type: "counter", Timestamp: int64(i) * 15_000, Value: floatPtr(v)})
+	}
+	expr, err := Parse("increase(jobs_total[1m])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Evaluate(expr, pointsSource(points), time.UnixMilli(60_000), time.UnixMilli(60_000), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The samples within (0s, 60s] are 20, 5, 15 and 25, an increase of 5 + 10 + 10 after the
+	// reset plus the 20 before it, over 45s extrapolated to 60s
+	want := []*Series{{Labels: map[string]string{}, Values: []float64{25 * 60.0 / 45}}}
+	if !equalSeries(got, want) {
+		t.Errorf("increase with a reset =\n%s\nwant\n%s", formatSeries(got), formatSeries(want))
+	}
+}
+
+func TestEvaluateSourceError(t *testing.T) {
+	errTooMany := errors.New("too many points")
+	source := func([]string, []*dto.LabelMatcher, int64, int64) ([]*models.MetricPoint, error) {
+		return nil, errTooMany
+	}
+	expr, err := Parse("sum(rate(x[5m]))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Evaluate(expr, source, time.UnixMilli(0), time.UnixMilli(60_000), time.Minute); !errors.Is(err, errTooMany) {
+		t.Errorf("Evaluate error = %v, want the source error", err)
+	}
+}
+
+func equalSeries(got, want []*Series) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if !reflect.DeepEqual(got[i].Labels, want[i].Labels) || len(got[i].Values) != len(want[i].Values) {
+			return false
+		}
+		for j, v := range got[i].Values {
+			w := want[i].Values[j]
+			if math.IsNaN(v) != math.IsNaN(w) || (!math.IsNaN(w) && math.Abs(v-w) > 1e-9) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func formatSeries(series []*Series) string {
+	var s string
+	for _, serie := range series {
+		s += labelsKey(serie.Labels) + " " + formatValues(serie.Values) + "\n"
+	}
+	return s
+}
+
+func formatValues(values []float64) string {
+	s := "["
+	for i, v := range values {
+		if i > 0 {
+			s += " "
+		}
+		s += (&NumberLiteral{Value: v}).String()
+	}
+	return s + "]"
+}
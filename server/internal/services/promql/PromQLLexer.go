@@ -0,0 +1,170 @@
+package promql
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenNumber
+	tokenDuration
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenLBrace
+	tokenRBrace
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+	tokenMatchOp
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of query"
+	case tokenIdentifier:
+		return "identifier"
+	case tokenNumber:
+		return "number"
+	case tokenDuration:
+		return "duration"
+	case tokenString:
+		return "string"
+	case tokenLParen:
+		return "'('"
+	case tokenRParen:
+		return "')'"
+	case tokenLBrace:
+		return "'{'"
+	case tokenRBrace:
+		return "'}'"
+	case tokenLBracket:
+		return "'['"
+	case tokenRBracket:
+		return "']'"
+	case tokenComma:
+		return "','"
+	case tokenMatchOp:
+		return "label matcher"
+	}
+	return "token"
+}
+
+type token struct {
+	kind   tokenKind
+	value  string
+	column int // 1-based column of the first character
+}
+
+// matchOps are matched longest first
+var matchOps = []string{"=~", "!~", "!=", "="}
+
+var punctuation = map[rune]tokenKind{
+	'(': tokenLParen, ')': tokenRParen,
+	'{': tokenLBrace, '}': tokenRBrace,
+	'[': tokenLBracket, ']': tokenRBracket,
+	',': tokenComma,
+}
+
+func isIdentifierStart(r rune) bool {
+	return r == '_' || r == ':' || (r < unicode.MaxASCII && unicode.IsLetter(r))
+}
+
+func isIdentifierRune(r rune) bool {
+	return isIdentifierStart(r) || (r >= '0' && r <= '9')
+}
+
+// lex splits the query into tokens. Inside brackets numbers followed by a unit are durations.
+func lex(input string) ([]token, error) {
+	runes := []rune(input)
+	var tokens []token
+	inBrackets := false
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		column := i + 1
+		if kind, ok := punctuation[r]; ok {
+			switch kind {
+			case tokenLBracket:
+				inBrackets = true
+			case tokenRBracket:
+				inBrackets = false
+			}
+			tokens = append(tokens, token{kind: kind, value: string(r), column: column})
+			i++
+			continue
+		}
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			value, next, err := lexString(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value, column: column})
+			i = next
+		case r == '=' || r == '!':
+			op := ""
+			for _, candidate := range matchOps {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, newParseError(column, string(r), "unexpected character %q", r)
+			}
+			tokens = append(tokens, token{kind: tokenMatchOp, value: op, column: column})
+			i += len(op)
+		case r >= '0' && r <= '9' || r == '.':
+			start := i
+			for i < len(runes) && (isIdentifierRune(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			kind := tokenNumber
+			if inBrackets {
+				kind = tokenDuration
+			}
+			tokens = append(tokens, token{kind: kind, value: string(runes[start:i]), column: column})
+		case isIdentifierStart(r):
+			start := i
+			for i < len(runes) && isIdentifierRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdentifier, value: string(runes[start:i]), column: column})
+		default:
+			return nil, newParseError(column, string(r), "unexpected character %q", r)
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, column: len(runes) + 1})
+	return tokens, nil
+}
+
+// lexString reads a string quoted with the character at runes[start]. A backslash escapes the
+// next character.
+func lexString(runes []rune, start int) (string, int, error) {
+	quote := runes[start]
+	var b strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 == len(runes) {
+				return "", 0, newParseError(i+1, "\\", "unfinished escape sequence")
+			}
+			i++
+			b.WriteRune(runes[i])
+		case quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteRune(runes[i])
+		}
+	}
+	return "", 0, newParseError(start+1, string(quote), "unterminated string")
+}
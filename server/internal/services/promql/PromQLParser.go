@@ -0,0 +1,477 @@
+package promql
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseError describes why a query could not be parsed and where, so clients can point at it.
+type ParseError struct {
+	Column  int    `json:"column"`
+	Token   string `json:"token,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Message)
+}
+
+func newParseError(column int, token string, format string, args ...interface{}) *ParseError {
+	return &ParseError{Column: column, Token: token, Message: fmt.Sprintf(format, args...)}
+}
+
+// valueType is the type an expression evaluates to
+type valueType string
+
+const (
+	typeScalar valueType = "scalar"
+	typeVector valueType = "instant vector"
+	typeMatrix valueType = "range vector"
+)
+
+func (t valueType) withArticle() string {
+	if t == typeVector {
+		return "an " + string(t)
+	}
+	return "a " + string(t)
+}
+
+// Expr is an element of the parsed query. String renders it back in canonical form.
+type Expr interface {
+	String() string
+	valueType() valueType
+}
+
+// NumberLiteral is a scalar such as the quantile of histogram_quantile
+type NumberLiteral struct {
+	Value float64
+}
+
+// LabelMatcher compares a label with a value using =, !=, =~ or !~
+type LabelMatcher struct {
+	Name  string
+	Op    string
+	Value string
+	re    *regexp.Regexp
+}
+
+// VectorSelector selects series by name and labels, over a time range when Range is set
+type VectorSelector struct {
+	Name     string
+	Matchers []*LabelMatcher
+	Range    time.Duration
+}
+
+// Call is a function call such as rate(x[5m])
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+// Aggregate combines series, keeping the labels in Grouping or, with Without, all the others
+type Aggregate struct {
+	Op       string
+	Grouping []string
+	Without  bool
+	Expr     Expr
+}
+
+func (n *NumberLiteral) String() string { return strconv.FormatFloat(n.Value, 'f', -1, 64) }
+
+func (m *LabelMatcher) String() string { return m.Name + m.Op + strconv.Quote(m.Value) }
+
+func (s *VectorSelector) String() string {
+	var b strings.Builder
+	b.WriteString(s.Name)
+	if len(s.Matchers) > 0 {
+		matchers := make([]string, len(s.Matchers))
+		for i, m := range s.Matchers {
+			matchers[i] = m.String()
+		}
+		b.WriteString("{" + strings.Join(matchers, ", ") + "}")
+	}
+	if s.Range > 0 {
+		b.WriteString("[" + formatDuration(s.Range) + "]")
+	}
+	return b.String()
+}
+
+func (c *Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = arg.String()
+	}
+	return c.Func + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (a *Aggregate) String() string {
+	grouping := ""
+	if len(a.Grouping) > 0 || a.Without {
+		keyword := "by"
+		if a.Without {
+			keyword = "without"
+		}
+		grouping = " " + keyword + " (" + strings.Join(a.Grouping, ", ") + ") "
+	}
+	return a.Op + grouping + "(" + a.Expr.String() + ")"
+}
+
+func (n *NumberLiteral) valueType() valueType { return typeScalar }
+func (a *Aggregate) valueType() valueType     { return typeVector }
+func (c *Call) valueType() valueType          { return typeVector }
+
+func (s *VectorSelector) valueType() valueType {
+	if s.Range > 0 {
+		return typeMatrix
+	}
+	return typeVector
+}
+
+// aggregations are the supported aggregation operators
+var aggregations = []string{"sum", "avg", "min", "max", "count"}
+
+// functions lists the supported functions with the types of their arguments
+var functions = map[string][]valueType{
+	"rate":               {typeMatrix},
+	"increase":           {typeMatrix},
+	"avg_over_time":      {typeMatrix},
+	"min_over_time":      {typeMatrix},
+	"max_over_time":      {typeMatrix},
+	"sum_over_time":      {typeMatrix},
+	"count_over_time":    {typeMatrix},
+	"histogram_quantile": {typeScalar, typeVector},
+}
+
+// Parse parses the supported subset of PromQL: selectors with label matchers and ranges, the
+// sum, avg, min, max and count aggregations with by or without, rate, increase, the
+// *_over_time functions and histogram_quantile, such as
+//
+//	histogram_quantile(0.95, sum by (le) (rate(http_request_duration_seconds_bucket[5m])))
+//
+// The query must evaluate to an instant vector.
+func Parse(input string) (Expr, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, newParseError(1, "", "query is empty")
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, newParseError(tok.column, tok.value, "unexpected %s", describe(tok))
+	}
+	if t := expr.valueType(); t != typeVector {
+		return nil, newParseError(1, "", "the query must evaluate to an instant vector, not %s", t.withArticle())
+	}
+	return expr, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, newParseError(tok.column, tok.value, "expected %s, found %s", kind, describe(tok))
+	}
+	return tok, nil
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokenNumber:
+		p.next()
+		value, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, newParseError(tok.column, tok.value, "invalid number %q", tok.value)
+		}
+		return &NumberLiteral{Value: value}, nil
+	case tokenLParen:
+		p.next()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case tokenLBrace:
+		return p.parseSelector("", tok.column)
+	case tokenIdentifier:
+		p.next()
+		following := p.peek()
+		if slices.Contains(aggregations, tok.value) && (following.kind == tokenLParen || following.value == "by" || following.value == "without") {
+			return p.parseAggregate(tok)
+		}
+		if following.kind == tokenLParen {
+			return p.parseCall(tok)
+		}
+		return p.parseSelector(tok.value, tok.column)
+	case tokenEOF:
+		return nil, newParseError(tok.column, "", "unexpected end of query, expected an expression")
+	}
+	return nil, newParseError(tok.column, tok.value, "expected an expression, found %s", describe(tok))
+}
+
+func (p *parser) parseAggregate(op token) (Expr, error) {
+	agg := &Aggregate{Op: op.value}
+	grouped := false
+	if tok := p.peek(); tok.value == "by" || tok.value == "without" {
+		if err := p.parseGrouping(agg); err != nil {
+			return nil, err
+		}
+		grouped = true
+	}
+	if _, err := p.expect(tokenLParen); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenRParen); err != nil {
+		return nil, err
+	}
+	if t := expr.valueType(); t != typeVector {
+		return nil, newParseError(op.column, op.value, "%s expects an instant vector, found %s", op.value, t.withArticle())
+	}
+	agg.Expr = expr
+
+	// The grouping can also follow the expression, as in sum(x) by (job)
+	if tok := p.peek(); !grouped && (tok.value == "by" || tok.value == "without") {
+		if err := p.parseGrouping(agg); err != nil {
+			return nil, err
+		}
+	}
+	return agg, nil
+}
+
+func (p *parser) parseGrouping(agg *Aggregate) error {
+	agg.Without = p.next().value == "without"
+	if _, err := p.expect(tokenLParen); err != nil {
+		return err
+	}
+	for p.peek().kind != tokenRParen {
+		label, err := p.expect(tokenIdentifier)
+		if err != nil {
+			return err
+		}
+		agg.Grouping = append(agg.Grouping, label.value)
+		if p.peek().kind != tokenComma {
+			break
+		}
+		p.next()
+	}
+	_, err := p.expect(tokenRParen)
+	return err
+}
+
+func (p *parser) parseCall(name token) (Expr, error) {
+	argTypes, ok := functions[name.value]
+	if !ok {
+		names := make([]string, 0, len(functions))
+		for fn := range functions {
+			names = append(names, fn)
+		}
+		slices.Sort(names)
+		return nil, newParseError(name.column, name.value, "unsupported function %q, expected one of %s", name.value, strings.Join(names, ", "))
+	}
+	p.next()
+
+	call := &Call{Func: name.value}
+	for p.peek().kind != tokenRParen {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+		if p.peek().kind != tokenComma {
+			break
+		}
+		p.next()
+	}
+	if _, err := p.expect(tokenRParen); err != nil {
+		return nil, err
+	}
+
+	if len(call.Args) != len(argTypes) {
+		return nil, newParseError(name.column, name.value, "%s expects %d arguments, found %d", name.value, len(argTypes), len(call.Args))
+	}
+	for i, arg := range call.Args {
+		if t := arg.valueType(); t != argTypes[i] {
+			return nil, newParseError(name.column, name.value, "argument %d of %s must be %s, found %s", i+1, name.value, argTypes[i].withArticle(), t.withArticle())
+		}
+	}
+	return call, nil
+}
+
+func (p *parser) parseSelector(name string, column int) (Expr, error) {
+	selector := &VectorSelector{Name: name}
+	if p.peek().kind == tokenLBrace {
+		p.next()
+		for p.peek().kind != tokenRBrace {
+			matcher, err := p.parseMatcher()
+			if err != nil {
+				return nil, err
+			}
+			if matcher.Name == "__name__" {
+				if matcher.Op != "=" || (selector.Name != "" && selector.Name != matcher.Value) {
+					return nil, newParseError(column, matcher.Name, "the metric name can only be matched exactly, once")
+				}
+				selector.Name = matcher.Value
+			} else {
+				selector.Matchers = append(selector.Matchers, matcher)
+			}
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+		if _, err := p.expect(tokenRBrace); err != nil {
+			return nil, err
+		}
+	}
+	if selector.Name == "" {
+		return nil, newParseError(column, "", "a selector needs a metric name")
+	}
+
+	if p.peek().kind == tokenLBracket {
+		p.next()
+		tok, err := p.expect(tokenDuration)
+		if err != nil {
+			return nil, err
+		}
+		selector.Range, err = ParseDuration(tok.value)
+		if err != nil || selector.Range <= 0 {
+			return nil, newParseError(tok.column, tok.value, "invalid duration %q, expected one like 30s, 5m or 1h", tok.value)
+		}
+		if _, err := p.expect(tokenRBracket); err != nil {
+			return nil, err
+		}
+	}
+	return selector, nil
+}
+
+func (p *parser) parseMatcher() (*LabelMatcher, error) {
+	name, err := p.expect(tokenIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	op, err := p.expect(tokenMatchOp)
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.expect(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	matcher := &LabelMatcher{Name: name.value, Op: op.value, Value: value.value}
+	if op.value == "=~" || op.value == "!~" {
+		// Regular expressions are anchored at both ends, as in Prometheus
+		matcher.re, err = regexp.Compile("^(?:" + value.value + ")$")
+		if err != nil {
+			return nil, newParseError(value.column, value.value, "invalid regular expression: %s", err.Error())
+		}
+	}
+	return matcher, nil
+}
+
+// matches reports whether the label value, empty when the label is not set, is matched
+func (m *LabelMatcher) matches(value string) bool {
+	switch m.Op {
+	case "=":
+		return value == m.Value
+	case "!=":
+		return value != m.Value
+	case "=~":
+		return m.re.MatchString(value)
+	}
+	return !m.re.MatchString(value)
+}
+
+func describe(tok token) string {
+	switch tok.kind {
+	case tokenIdentifier, tokenNumber, tokenDuration, tokenMatchOp:
+		return fmt.Sprintf("'%s'", tok.value)
+	case tokenString:
+		return fmt.Sprintf("%q", tok.value)
+	}
+	return tok.kind.String()
+}
+
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"ms", time.Millisecond},
+	{"s", time.Second},
+	{"m", time.Minute},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+	{"w", 7 * 24 * time.Hour},
+	{"y", 365 * 24 * time.Hour},
+}
+
+var durationPart = regexp.MustCompile(`^([0-9]+)(ms|s|m|h|d|w|y)`)
+
+// ParseDuration parses Prometheus durations such as 30s, 5m or 1h30m
+func ParseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+	var total time.Duration
+	for rest := value; rest != ""; {
+		match := durationPart.FindStringSubmatch(rest)
+		if match == nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		n, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		for _, u := range durationUnits {
+			if u.suffix == match[2] {
+				total += time.Duration(n) * u.unit
+			}
+		}
+		rest = rest[len(match[0]):]
+	}
+	return total, nil
+}
+
+func formatDuration(d time.Duration) string {
+	var b strings.Builder
+	for i := len(durationUnits) - 1; i >= 0; i-- {
+		u := durationUnits[i]
+		if n := d / u.unit; n > 0 {
+			b.WriteString(strconv.FormatInt(int64(n), 10) + u.suffix)
+			d -= n * u.unit
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package promql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "up", want: "up"},
+		{input: "(up)", want: "up"},
+		{input: `{__name__="up"}`, want: "up"},
+		{input: `http_requests_total{job="api",code=~'5..'}`, want: `http_requests_total{job="api", code=~"5.."}`},
+		{input: "rate(http_requests_total[5m])", want: "rate(http_requests_total[5m])"},
+		{input: "avg_over_time(queue_depth[1h30m])", want: "avg_over_time(queue_depth[1h30m])"},
+		{input: "sum by (job) (rate(x[5m]))", want: "sum by (job) (rate(x[5m]))"},
+		{input: "sum(rate(x[5m])) by (job, code)", want: "sum by (job, code) (rate(x[5m]))"},
+		{input: "count without (instance) (up)", want: "count without (instance) (up)"},
+		{input: "max(up)", want: "max(up)"},
+		{
+			input: "histogram_quantile(0.95, sum by (le) (rate(http_request_duration_seconds_bucket[5m])))",
+			want:  "histogram_quantile(0.95, sum by (le) (rate(http_request_duration_seconds_bucket[5m])))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+			}
+			if got := expr.String(); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		column  int
+		message string
+	}{
+		{input: " ", column: 1, message: "query is empty"},
+		{input: "up up", column: 4, message: "unexpected 'up'"},
+		{input: "x[5m]", column: 1, message: "must evaluate to an instant vector, not a range vector"},
+		{input: "rate(x)", column: 1, message: "argument 1 of rate must be a range vector, found an instant vector"},
+		{input: "histogram_quantile(0.9)", column: 1, message: "histogram_quantile expects 2 arguments, found 1"},
+		{input: "foo(x)", column: 1, message: `unsupported function "foo"`},
+		{input: "sum(x[5m])", column: 1, message: "sum expects an instant vector, found a range vector"},
+		{input: "sum(x", column: 6, message: "expected ')', found end of query"},
+		{input: `x{job="a"`, column: 10, message: "expected '}', found end of query"},
+		{input: `x{job~"a"}`, column: 6, message: `unexpected character '~'`},
+		{input: `x{job=~"("}`, column: 8, message: "invalid regular expression"},
+		{input: `x{job="a`, column: 7, message: "unterminated string"},
+		{input: `{job="a"}`, column: 1, message: "a selector needs a metric name"},
+		{input: `up{__name__="down"}`, column: 1, message: "the metric name can only be matched exactly"},
+		{input: "rate(x[5])", column: 8, message: "invalid duration"},
+		{input: "sum by job (x)", column: 8, message: "expected '(', found 'job'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Parse(%q) error = %v, want a ParseError", tt.input, err)
+			}
+			if parseErr.Column != tt.column {
+				t.Errorf("Parse(%q) error at column %d, want %d: %v", tt.input, parseErr.Column, tt.column, err)
+			}
+			if !strings.Contains(parseErr.Message, tt.message) {
+				t.Errorf("Parse(%q) error %q, want it to contain %q", tt.input, parseErr.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "30s", want: 30 * time.Second},
+		{value: "5m", want: 5 * time.Minute},
+		{value: "1h30m", want: 90 * time.Minute},
+		{value: "250ms", want: 250 * time.Millisecond},
+		{value: "1w", want: 7 * 24 * time.Hour},
+		{value: "", wantErr: true},
+		{value: "5", wantErr: true},
+		{value: "5x", wantErr: true},
+		{value: "1.5h", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDuration(%q) = %v, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+		if formatted := formatDuration(got); formatted != tt.value {
+			t.Errorf("formatDuration(%v) = %q, want %q", got, formatted, tt.value)
+		}
+	}
+}
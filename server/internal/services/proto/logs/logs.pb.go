@@ -9,6 +9,7 @@ package protogen
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
@@ -89,8 +90,18 @@ type Log struct {
 	ResponseStatus string                 `protobuf:"bytes,11,opt,name=responseStatus,proto3" json:"responseStatus,omitempty"`
 	ResponseTime   string                 `protobuf:"bytes,12,opt,name=responseTime,proto3" json:"responseTime,omitempty"`
 	Timestamp      *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	// Typed response fields, preferred over the responseTime and responseStatus strings when set
+	ResponseTimeMs *float64 `protobuf:"fixed64,14,opt,name=response_time_ms,json=responseTimeMs,proto3,oneof" json:"response_time_ms,omitempty"`
+	StatusCode     *int32   `protobuf:"varint,15,opt,name=status_code,json=statusCode,proto3,oneof" json:"status_code,omitempty"`
+	// Extra context such as user or tenant IDs, as strings or with their JSON types
+	Attributes      map[string]string `protobuf:"bytes,16,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	TypedAttributes *structpb.Struct  `protobuf:"bytes,17,opt,name=typed_attributes,json=typedAttributes,proto3" json:"typed_attributes,omitempty"`
+	// W3C trace context, hex encoded
+	TraceId       string `protobuf:"bytes,18,opt,name=traceId,proto3" json:"traceId,omitempty"`
+	SpanId        string `protobuf:"bytes,19,opt,name=spanId,proto3" json:"spanId,omitempty"`
+	ParentSpanId  string `protobuf:"bytes,20,opt,name=parentSpanId,proto3" json:"parentSpanId,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Log) Reset() {
@@ -214,17 +225,66 @@ func (x *Log) GetTimestamp() *timestamppb.Timestamp {
 	return nil
 }
 
+func (x *Log) GetResponseTimeMs() float64 {
+	if x != nil && x.ResponseTimeMs != nil {
+		return *x.ResponseTimeMs
+	}
+	return 0
+}
+
+func (x *Log) GetStatusCode() int32 {
+	if x != nil && x.StatusCode != nil {
+		return *x.StatusCode
+	}
+	return 0
+}
+
+func (x *Log) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *Log) GetTypedAttributes() *structpb.Struct {
+	if x != nil {
+		return x.TypedAttributes
+	}
+	return nil
+}
+
+func (x *Log) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *Log) GetSpanId() string {
+	if x != nil {
+		return x.SpanId
+	}
+	return ""
+}
+
+func (x *Log) GetParentSpanId() string {
+	if x != nil {
+		return x.ParentSpanId
+	}
+	return ""
+}
+
 var File_logs_proto protoreflect.FileDescriptor
 
 const file_logs_proto_rawDesc = "" +
 	"\n" +
 	"\n" +
-	"logs.proto\x12\x06logboy\x1a\x1fgoogle/protobuf/timestamp.proto\"P\n" +
+	"logs.proto\x12\x06logboy\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1cgoogle/protobuf/struct.proto\"P\n" +
 	"\fBuildDetails\x12 \n" +
 	"\vnodeVersion\x18\x01 \x01(\tR\vnodeVersion\x12\x1e\n" +
 	"\n" +
 	"appVersion\x18\x02 \x01(\tR\n" +
-	"appVersion\"\xcb\x03\n" +
+	"appVersion\"\xdb\x06\n" +
 	"\x03Log\x12 \n" +
 	"\vserviceName\x18\x01 \x01(\tR\vserviceName\x128\n" +
 	"\fbuildDetails\x18\x02 \x01(\v2\x14.logboy.BuildDetailsR\fbuildDetails\x12\x14\n" +
@@ -241,7 +301,22 @@ const file_logs_proto_rawDesc = "" +
 	" \x01(\tR\bremoteIp\x12&\n" +
 	"\x0eresponseStatus\x18\v \x01(\tR\x0eresponseStatus\x12\"\n" +
 	"\fresponseTime\x18\f \x01(\tR\fresponseTime\x128\n" +
-	"\ttimestamp\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\ttimestampB\x11Z\x0fserver/protogenb\x06proto3"
+	"\ttimestamp\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12-\n" +
+	"\x10response_time_ms\x18\x0e \x01(\x01H\x00R\x0eresponseTimeMs\x88\x01\x01\x12$\n" +
+	"\vstatus_code\x18\x0f \x01(\x05H\x01R\n" +
+	"statusCode\x88\x01\x01\x12;\n" +
+	"\n" +
+	"attributes\x18\x10 \x03(\v2\x1b.logboy.Log.AttributesEntryR\n" +
+	"attributes\x12B\n" +
+	"\x10typed_attributes\x18\x11 \x01(\v2\x17.google.protobuf.StructR\x0ftypedAttributes\x12\x18\n" +
+	"\atraceId\x18\x12 \x01(\tR\atraceId\x12\x16\n" +
+	"\x06spanId\x18\x13 \x01(\tR\x06spanId\x12\"\n" +
+	"\fparentSpanId\x18\x14 \x01(\tR\fparentSpanId\x1a=\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x13\n" +
+	"\x11_response_time_msB\x0e\n" +
+	"\f_status_codeB\x11Z\x0fserver/protogenb\x06proto3"
 
 var (
 	file_logs_proto_rawDescOnce sync.Once
@@ -255,20 +330,24 @@ func file_logs_proto_rawDescGZIP() []byte {
 	return file_logs_proto_rawDescData
 }
 
-var file_logs_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_logs_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
 var file_logs_proto_goTypes = []any{
 	(*BuildDetails)(nil),          // 0: logboy.BuildDetails
 	(*Log)(nil),                   // 1: logboy.Log
-	(*timestamppb.Timestamp)(nil), // 2: google.protobuf.Timestamp
+	nil,                           // 2: logboy.Log.AttributesEntry
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),       // 4: google.protobuf.Struct
 }
 var file_logs_proto_depIdxs = []int32{
 	0, // 0: logboy.Log.buildDetails:type_name -> logboy.BuildDetails
-	2, // 1: logboy.Log.timestamp:type_name -> google.protobuf.Timestamp
-	2, // [2:2] is the sub-list for method output_type
-	2, // [2:2] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	3, // 1: logboy.Log.timestamp:type_name -> google.protobuf.Timestamp
+	2, // 2: logboy.Log.attributes:type_name -> logboy.Log.AttributesEntry
+	4, // 3: logboy.Log.typed_attributes:type_name -> google.protobuf.Struct
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_logs_proto_init() }
@@ -276,13 +355,14 @@ func file_logs_proto_init() {
 	if File_logs_proto != nil {
 		return
 	}
+	file_logs_proto_msgTypes[1].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_logs_proto_rawDesc), len(file_logs_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   3,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
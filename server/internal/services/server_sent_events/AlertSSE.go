@@ -2,73 +2,71 @@ package serversentevents
 
 import (
 	"log"
+	"server/internal/api/dto"
 	"sync"
 	"time"
 )
 
+// AlertEvent is an alert as published by the alert manager, with its ID in the live streams
+type AlertEvent struct {
+	ID      string // see dto.AlertMessage.EventID
+	Payload string // the alert in JSON
+}
+
 type AlertClient struct {
-	ID        string // Unique client ID
-	Project   string // Project associated with the client
-	Channel   chan string
-	closed    bool       // Track if a client is closed
-	mu        sync.Mutex // Protect a closed state
-	LastSeen  time.Time  // Track last activity
-	Connected bool       // Track connection state
+	ID       string   // Unique client ID, derived from the session
+	Session  *Session // Connection the client belongs to
+	Project  string   // Project associated with the client
+	Channel  chan AlertEvent
+	closed   bool       // Track if a client is closed
+	mu       sync.Mutex // Protect a closed state
+	LastSeen time.Time  // Track last activity
+	Dropped  uint64     // Alerts dropped because the channel was full
 }
 
 type SSEAlertService struct {
 	mu              sync.RWMutex
 	Clients         map[string]*AlertClient
 	ProjectClients  map[string]map[string]*AlertClient // Store client pointers instead of channels
-	ProjectChannels map[string]chan string
+	ProjectChannels map[string]chan AlertEvent
 	shutdownChans   map[string]chan struct{} // For graceful goroutine shutdown
+	replays         map[string]*replayBuffer[AlertEvent]
+	lingers         map[string]*time.Timer // Projects without clients waiting for a reconnect
 }
 
 func NewSSEAlertService() *SSEAlertService {
 	return &SSEAlertService{
 		Clients:         make(map[string]*AlertClient),
 		ProjectClients:  make(map[string]map[string]*AlertClient),
-		ProjectChannels: make(map[string]chan string),
+		ProjectChannels: make(map[string]chan AlertEvent),
 		shutdownChans:   make(map[string]chan struct{}),
+		replays:         make(map[string]*replayBuffer[AlertEvent]),
+		lingers:         make(map[string]*time.Timer),
 	}
 }
 
-func (s *SSEAlertService) RegisterAlertClient(clientID, project string) {
+// RegisterAlertClient registers a client of the session for the project and returns the
+// buffered alerts it missed since lastEventID, like SSELogService.RegisterLogsClient
+func (s *SSEAlertService) RegisterAlertClient(clientID string, session *Session, project string, lastEventID string) (replay []AlertEvent, found bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// If a client already exists, unregister it first to avoid conflicts
 	if existingClient, exists := s.Clients[clientID]; exists {
-		existingClient.mu.Lock()
-		timeSinceLastSeen := time.Since(existingClient.LastSeen)
-		existingClient.mu.Unlock()
-
-		// If a client was seen recently, just reactivate instead of recreating
-		if timeSinceLastSeen < 5*time.Second && existingClient.Project == project {
-			log.Printf("Reactivating recent client: %s for project: %s", clientID, project)
-			existingClient.mu.Lock()
-			existingClient.Connected = true
-			existingClient.LastSeen = time.Now()
-			existingClient.mu.Unlock()
-			return
-		}
-
-		// Clean up an old client if it's stale or different project
-		log.Printf("Client %s exists but is stale or different project, cleaning up", clientID)
 		s.unregisterAlertClientUnsafe(clientID, existingClient)
 	}
 
 	// Create a new client
 	client := &AlertClient{
-		ID:        clientID,
-		Project:   project,
-		Channel:   make(chan string, 100),
-		closed:    false,
-		LastSeen:  time.Now(),
-		Connected: true,
+		ID:       clientID,
+		Session:  session,
+		Project:  project,
+		Channel:  make(chan AlertEvent, 100),
+		closed:   false,
+		LastSeen: time.Now(),
 	}
 	s.Clients[clientID] = client
-	log.Printf("Registered new client: %s for project: %s", clientID, project)
+	log.Printf("Registered new client: %s of %s for project: %s", clientID, session.User, project)
 
 	// Add client to project's client list
 	if _, exists := s.ProjectClients[project]; !exists {
@@ -76,46 +74,36 @@ func (s *SSEAlertService) RegisterAlertClient(clientID, project string) {
 	}
 	s.ProjectClients[project][clientID] = client
 
+	// A client came back in time, the project keeps running
+	if timer, lingering := s.lingers[project]; lingering {
+		timer.Stop()
+		delete(s.lingers, project)
+	}
+
 	// If this is the first client for this project, start a fan-out goroutine
 	if _, exists := s.ProjectChannels[project]; !exists {
-		projectChan := make(chan string, 100)
+		projectChan := make(chan AlertEvent, 100)
 		shutdownChan := make(chan struct{})
 		s.ProjectChannels[project] = projectChan
 		s.shutdownChans[project] = shutdownChan
+		s.replays[project] = newReplayBuffer[AlertEvent](ReplayBufferSize)
 		go s.fanOutAlerts(project, projectChan, shutdownChan)
 		log.Printf("Started fan-out goroutine for project: %s", project)
 	}
+
+	if lastEventID == "" {
+		return nil, true
+	}
+	return s.replays[project].since(lastEventID)
 }
 
+// UnregisterAlertClient removes the client once its connection ends
 func (s *SSEAlertService) UnregisterAlertClient(clientID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if client, exists := s.Clients[clientID]; exists {
-		client.mu.Lock()
-		client.Connected = false
-		client.mu.Unlock()
-
-		// Schedule cleanup after a delay to handle reconnections
-		go func() {
-			time.Sleep(10 * time.Second) // Wait 10 seconds before cleanup
-			s.mu.Lock()
-			defer s.mu.Unlock()
-
-			// Check if a client is still disconnected
-			if client, exists := s.Clients[clientID]; exists {
-				client.mu.Lock()
-				stillDisconnected := !client.Connected
-				client.mu.Unlock()
-
-				if stillDisconnected {
-					log.Printf("Cleaning up disconnected client after delay: %s", clientID)
-					s.unregisterAlertClientUnsafe(clientID, client)
-				}
-			}
-		}()
-
-		log.Printf("Marked client as disconnected: %s", clientID)
+		s.unregisterAlertClientUnsafe(clientID, client)
 	} else {
 		log.Printf("Client not found for unregistration: %s", clientID)
 	}
@@ -142,31 +130,55 @@ func (s *SSEAlertService) unregisterAlertClientUnsafe(clientID string, client *A
 		delete(projectClients, clientID)
 		log.Printf("Removed client %s from project %s", clientID, client.Project)
 
-		// If no more clients for this project, cleanup project resources
+		// If no more clients for this project, cleanup project resources unless one reconnects
 		if len(projectClients) == 0 {
-			log.Printf("No more clients for project %s. Cleaning up project resources.", client.Project)
+			s.lingerUnsafe(client.Project)
+		}
+	}
+	log.Printf("Successfully unregistered client: %s", clientID)
+}
 
-			// Signal shutdown to fan-out goroutine
-			if shutdownChan, ok := s.shutdownChans[client.Project]; ok {
-				close(shutdownChan)
-				delete(s.shutdownChans, client.Project)
-			}
+// lingerUnsafe cleans up the project resources after projectLinger if no client registered for
+// it in the meantime, it must be called with mutex held
+func (s *SSEAlertService) lingerUnsafe(project string) {
+	if timer, lingering := s.lingers[project]; lingering {
+		timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(projectLinger, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.lingers[project] == timer && len(s.ProjectClients[project]) == 0 {
+			s.stopProjectUnsafe(project)
+		}
+	})
+	s.lingers[project] = timer
+}
 
-			// Close project channel
-			if projectChan, ok := s.ProjectChannels[client.Project]; ok {
-				close(projectChan)
-				delete(s.ProjectChannels, client.Project)
-				log.Printf("Closed project channel for %s", client.Project)
-			}
+// stopProjectUnsafe must be called with mutex held
+func (s *SSEAlertService) stopProjectUnsafe(project string) {
+	log.Printf("No more clients for project %s. Cleaning up project resources.", project)
 
-			// Clean up an empty project map
-			delete(s.ProjectClients, client.Project)
-		}
+	// Signal shutdown to fan-out goroutine
+	if shutdownChan, ok := s.shutdownChans[project]; ok {
+		close(shutdownChan)
+		delete(s.shutdownChans, project)
 	}
-	log.Printf("Successfully unregistered client: %s", clientID)
+
+	// Close project channel
+	if projectChan, ok := s.ProjectChannels[project]; ok {
+		close(projectChan)
+		delete(s.ProjectChannels, project)
+		log.Printf("Closed project channel for %s", project)
+	}
+
+	// Clean up an empty project map
+	delete(s.ProjectClients, project)
+	delete(s.replays, project)
+	delete(s.lingers, project)
 }
 
-func (s *SSEAlertService) fanOutAlerts(project string, projectChan chan string, shutdownChan chan struct{}) {
+func (s *SSEAlertService) fanOutAlerts(project string, projectChan chan AlertEvent, shutdownChan chan struct{}) {
 	log.Printf("Fan-out goroutine started for project: %s", project)
 	defer log.Printf("Fan-out goroutine stopped for project: %s", project)
 
@@ -185,6 +197,7 @@ func (s *SSEAlertService) fanOutAlerts(project string, projectChan chan string,
 				log.Printf("Project client map for %s no longer exists, stopping fan-out", project)
 				return
 			}
+			s.replays[project].add(logEntry.ID, logEntry)
 
 			// Send it to all clients
 			for clientID, client := range clientsForProject {
@@ -193,6 +206,7 @@ func (s *SSEAlertService) fanOutAlerts(project string, projectChan chan string,
 					select {
 					case client.Channel <- logEntry:
 					default:
+						client.Dropped++
 						log.Printf("Client %s channel full for project %s, dropping log", clientID, project)
 					}
 				}
@@ -207,13 +221,14 @@ func (s *SSEAlertService) fanOutAlerts(project string, projectChan chan string,
 	}
 }
 
-func (s *SSEAlertService) BroadcastAlerts(project string, alert *string) {
+// BroadcastAlerts sends the alert, in the JSON it was published in, to the clients of the project
+func (s *SSEAlertService) BroadcastAlerts(project string, eventID string, alert *string) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	if projectChan, ok := s.ProjectChannels[project]; ok {
 		select {
-		case projectChan <- *alert:
+		case projectChan <- AlertEvent{ID: eventID, Payload: *alert}:
 			// Successfully broadcast
 		default:
 			log.Printf("Project channel for %s full, dropping log for broadcast", project)
@@ -221,7 +236,7 @@ func (s *SSEAlertService) BroadcastAlerts(project string, alert *string) {
 	}
 }
 
-func (s *SSEAlertService) GetAlertClientChannel(clientID string) (chan string, bool) {
+func (s *SSEAlertService) GetAlertClientChannel(clientID string) (chan AlertEvent, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -240,6 +255,20 @@ func (s *SSEAlertService) GetAlertClientChannel(clientID string) (chan string, b
 	return client.Channel, true
 }
 
+// Stats describes the registered clients for the live sessions endpoint
+func (s *SSEAlertService) Stats() []*dto.LiveClientStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make([]*dto.LiveClientStats, 0, len(s.Clients))
+	for clientID, client := range s.Clients {
+		client.mu.Lock()
+		stats = append(stats, client.Session.clientStats(clientID, "alerts", client.Project, len(client.Channel), cap(client.Channel), client.Dropped, client.LastSeen))
+		client.mu.Unlock()
+	}
+	return stats
+}
+
 // UpdateAlertClientActivity updates the last seen time for a client
 func (s *SSEAlertService) UpdateAlertClientActivity(clientID string) {
 	s.mu.RLock()
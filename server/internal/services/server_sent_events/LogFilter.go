@@ -0,0 +1,60 @@
+package serversentevents
+
+import (
+	"server/internal/models"
+	"server/pkg"
+	"slices"
+	"strings"
+)
+
+// StreamFilter selects the logs sent to one live stream client. Every criterion that is set
+// must match, empty criteria match every log.
+type StreamFilter struct {
+	Levels        []string   // any of these levels
+	Text          string     // case-insensitive substring of the message or stack
+	UrlPrefix     string     // prefix of the request URL
+	StatusClasses []int      // status classes by first digit, e.g. 5 for 5xx
+	Query         LogMatcher // optional parsed query, evaluated last
+}
+
+// IsEmpty reports whether the filter lets every log through
+func (f *StreamFilter) IsEmpty() bool {
+	return len(f.Levels) == 0 && f.Text == "" && f.UrlPrefix == "" && len(f.StatusClasses) == 0 && f.Query == nil
+}
+
+// Match is called once per client for every log of the project, so the cheap comparisons run
+// before the text search and the query
+func (f *StreamFilter) Match(logEntry *models.Log) bool {
+	if len(f.Levels) > 0 && !slices.Contains(f.Levels, strings.ToLower(logEntry.Level)) {
+		return false
+	}
+	if f.UrlPrefix != "" && !strings.HasPrefix(logEntry.RequestUrl, f.UrlPrefix) {
+		return false
+	}
+	if len(f.StatusClasses) > 0 {
+		code, ok := statusCode(logEntry)
+		if !ok || !slices.Contains(f.StatusClasses, code/100) {
+			return false
+		}
+	}
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		if !strings.Contains(strings.ToLower(logEntry.Message), text) && !strings.Contains(strings.ToLower(logEntry.Stack), text) {
+			return false
+		}
+	}
+	return f.Query == nil || f.Query.Match(logEntry)
+}
+
+// statusCode returns the response status of the log, read from the string sent by older clients
+// when the numeric code is missing
+func statusCode(logEntry *models.Log) (int, bool) {
+	if logEntry.StatusCode != nil {
+		return *logEntry.StatusCode, true
+	}
+	if logEntry.ResponseStatus == "" {
+		return 0, false
+	}
+	code, err := pkg.ParseStatusCode(logEntry.ResponseStatus)
+	return code, err == nil
+}
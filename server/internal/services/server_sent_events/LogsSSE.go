@@ -2,19 +2,28 @@ package serversentevents
 
 import (
 	"log"
+	"maps"
+	"server/internal/api/dto"
 	"server/internal/models"
 	"sync"
 	"time"
 )
 
+// LogMatcher decides whether a log is sent to a client, e.g. a parsed query
+type LogMatcher interface {
+	Match(logEntry *models.Log) bool
+}
+
 type Client struct {
-	ID        string // Unique client ID
-	Project   string // Project associated with the client
-	Channel   chan models.Log
-	closed    bool       // Track if a client is closed
-	mu        sync.Mutex // Protect a closed state
-	LastSeen  time.Time  // Track last activity
-	Connected bool       // Track connection state
+	ID       string     // Unique client ID, derived from the session
+	Session  *Session   // Connection the client belongs to
+	Project  string     // Project associated with the client
+	Matcher  LogMatcher // Optional filter, nil sends every log
+	Channel  chan models.Log
+	closed   bool       // Track if a client is closed
+	mu       sync.Mutex // Protect a closed state
+	LastSeen time.Time  // Track last activity
+	Dropped  uint64     // Logs dropped because the channel was full
 }
 
 type SSELogService struct {
@@ -23,6 +32,10 @@ type SSELogService struct {
 	ProjectClients  map[string]map[string]*Client // Store client pointers instead of channels
 	ProjectChannels map[string]chan models.Log
 	shutdownChans   map[string]chan struct{} // For graceful goroutine shutdown
+	replays         map[string]*replayBuffer[models.Log]
+	lingers         map[string]*time.Timer // Projects without clients waiting for a reconnect
+	onProjectChange func()                 // Optional, see OnProjectsChange
+	seed            PositionSeed           // Optional, see SeedPositions
 }
 
 func NewSSELogsService() *SSELogService {
@@ -31,45 +44,39 @@ func NewSSELogsService() *SSELogService {
 		ProjectClients:  make(map[string]map[string]*Client),
 		ProjectChannels: make(map[string]chan models.Log),
 		shutdownChans:   make(map[string]chan struct{}),
+		replays:         make(map[string]*replayBuffer[models.Log]),
+		lingers:         make(map[string]*time.Timer),
 	}
 }
 
-func (s *SSELogService) RegisterLogsClient(clientID, project string) {
+// RegisterLogsClient registers a client of the session for the project. Client IDs are unique
+// per session, so other tabs and streams of the same user are left alone.
+//
+// A reconnecting client passes the position it got with the last log it received and gets the
+// buffered logs past it that match its filter. Logs broadcast after these go to its channel, so
+// none is lost or sent twice. found is false when logs past the position are no longer
+// buffered, see replayBuffer.after.
+func (s *SSELogService) RegisterLogsClient(clientID string, session *Session, project string, matcher LogMatcher, since models.StreamPosition) (replay []models.Log, found bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// If a client already exists, unregister it first to avoid conflicts
 	if existingClient, exists := s.Clients[clientID]; exists {
-		existingClient.mu.Lock()
-		timeSinceLastSeen := time.Since(existingClient.LastSeen)
-		existingClient.mu.Unlock()
-
-		// If a client was seen recently, just reactivate instead of recreating
-		if timeSinceLastSeen < 5*time.Second && existingClient.Project == project {
-			log.Printf("Reactivating recent client: %s for project: %s", clientID, project)
-			existingClient.mu.Lock()
-			existingClient.Connected = true
-			existingClient.LastSeen = time.Now()
-			existingClient.mu.Unlock()
-			return
-		}
-
-		// Clean up an old client if it's stale or different project
-		log.Printf("Client %s exists but is stale or different project, cleaning up", clientID)
 		s.unregisterLogsClientUnsafe(clientID, existingClient)
 	}
 
 	// Create a new client
 	client := &Client{
-		ID:        clientID,
-		Project:   project,
-		Channel:   make(chan models.Log, 100),
-		closed:    false,
-		LastSeen:  time.Now(),
-		Connected: true,
+		ID:       clientID,
+		Session:  session,
+		Project:  project,
+		Matcher:  matcher,
+		Channel:  make(chan models.Log, 100),
+		closed:   false,
+		LastSeen: time.Now(),
 	}
 	s.Clients[clientID] = client
-	log.Printf("Registered new client: %s for project: %s", clientID, project)
+	log.Printf("Registered new client: %s of %s for project: %s", clientID, session.User, project)
 
 	// Add client to project's client list
 	if _, exists := s.ProjectClients[project]; !exists {
@@ -77,51 +84,66 @@ func (s *SSELogService) RegisterLogsClient(clientID, project string) {
 	}
 	s.ProjectClients[project][clientID] = client
 
+	// A client came back in time, the project keeps running
+	if timer, lingering := s.lingers[project]; lingering {
+		timer.Stop()
+		delete(s.lingers, project)
+	}
+
 	// If this is the first client for this project, start a fan-out goroutine
 	if _, exists := s.ProjectChannels[project]; !exists {
 		projectChan := make(chan models.Log, 100)
 		shutdownChan := make(chan struct{})
 		s.ProjectChannels[project] = projectChan
 		s.shutdownChans[project] = shutdownChan
+		s.replays[project] = newReplayBuffer[models.Log](ReplayBufferSize)
 		go s.fanOutLogs(project, projectChan, shutdownChan)
 		log.Printf("Started fan-out goroutine for project: %s", project)
+		if s.onProjectChange != nil {
+			s.onProjectChange()
+		}
+	}
+
+	if since == nil {
+		return nil, true
 	}
+	buffered, found := s.replays[project].after(since)
+	for i := range buffered {
+		if matcher == nil || matcher.Match(&buffered[i]) {
+			replay = append(replay, buffered[i])
+		}
+	}
+	return replay, found
 }
 
+// UnregisterLogsClient removes the client once its connection ends. A reconnecting client opens
+// a new session, so there is nothing to keep the client around for.
 func (s *SSELogService) UnregisterLogsClient(clientID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if client, exists := s.Clients[clientID]; exists {
-		client.mu.Lock()
-		client.Connected = false
-		client.mu.Unlock()
-
-		// Schedule cleanup after a delay to handle reconnections
-		go func() {
-			time.Sleep(10 * time.Second) // Wait 10 seconds before cleanup
-			s.mu.Lock()
-			defer s.mu.Unlock()
-
-			// Check if a client is still disconnected
-			if client, exists := s.Clients[clientID]; exists {
-				client.mu.Lock()
-				stillDisconnected := !client.Connected
-				client.mu.Unlock()
-
-				if stillDisconnected {
-					log.Printf("Cleaning up disconnected client after delay: %s", clientID)
-					s.unregisterLogsClientUnsafe(clientID, client)
-				}
-			}
-		}()
-
-		log.Printf("Marked client as disconnected: %s", clientID)
+		s.unregisterLogsClientUnsafe(clientID, client)
 	} else {
 		log.Printf("Client not found for unregistration: %s", clientID)
 	}
 }
 
+// SetLogsClientMatcher replaces the filter of a registered client, nil sends every log
+func (s *SSELogService) SetLogsClientMatcher(clientID string, matcher LogMatcher) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	client, exists := s.Clients[clientID]
+	if !exists {
+		return false
+	}
+	client.mu.Lock()
+	client.Matcher = matcher
+	client.mu.Unlock()
+	return true
+}
+
 // unregisterLogsClientUnsafe must be called with mutex held
 func (s *SSELogService) unregisterLogsClientUnsafe(clientID string, client *Client) {
 	log.Printf("Unregistering client: %s", clientID)
@@ -143,34 +165,94 @@ func (s *SSELogService) unregisterLogsClientUnsafe(clientID string, client *Clie
 		delete(projectClients, clientID)
 		log.Printf("Removed client %s from project %s", clientID, client.Project)
 
-		// If no more clients for this project, cleanup project resources
+		// If no more clients for this project, cleanup project resources unless one reconnects
 		if len(projectClients) == 0 {
-			log.Printf("No more clients for project %s. Cleaning up project resources.", client.Project)
+			s.lingerUnsafe(client.Project)
+		}
+	}
+	log.Printf("Successfully unregistered client: %s", clientID)
+}
 
-			// Signal shutdown to fan-out goroutine
-			if shutdownChan, ok := s.shutdownChans[client.Project]; ok {
-				close(shutdownChan)
-				delete(s.shutdownChans, client.Project)
-			}
+// lingerUnsafe cleans up the project resources after projectLinger if no client registered for
+// it in the meantime, it must be called with mutex held
+func (s *SSELogService) lingerUnsafe(project string) {
+	if timer, lingering := s.lingers[project]; lingering {
+		timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(projectLinger, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.lingers[project] == timer && len(s.ProjectClients[project]) == 0 {
+			s.stopProjectUnsafe(project)
+		}
+	})
+	s.lingers[project] = timer
+}
 
-			// Close project channel
-			if projectChan, ok := s.ProjectChannels[client.Project]; ok {
-				close(projectChan)
-				delete(s.ProjectChannels, client.Project)
-				log.Printf("Closed project channel for %s", client.Project)
-			}
+// stopProjectUnsafe must be called with mutex held
+func (s *SSELogService) stopProjectUnsafe(project string) {
+	log.Printf("No more clients for project %s. Cleaning up project resources.", project)
 
-			// Clean up an empty project map
-			delete(s.ProjectClients, client.Project)
-		}
+	// Signal shutdown to fan-out goroutine
+	if shutdownChan, ok := s.shutdownChans[project]; ok {
+		close(shutdownChan)
+		delete(s.shutdownChans, project)
+	}
+
+	// Close project channel
+	if projectChan, ok := s.ProjectChannels[project]; ok {
+		close(projectChan)
+		delete(s.ProjectChannels, project)
+		log.Printf("Closed project channel for %s", project)
+	}
+
+	// Clean up an empty project map
+	delete(s.ProjectClients, project)
+	delete(s.replays, project)
+	delete(s.lingers, project)
+	if s.onProjectChange != nil {
+		s.onProjectChange()
 	}
-	log.Printf("Successfully unregistered client: %s", clientID)
+}
+
+// OnProjectsChange registers a callback run when a project gains its first client or its
+// resources are cleaned up after losing its last one. It is called with the registry locked, so it must not block or call back into it.
+func (s *SSELogService) OnProjectsChange(callback func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onProjectChange = callback
+}
+
+// Projects returns the projects with at least one client, or that recently had one
+func (s *SSELogService) Projects() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	projects := make([]string, 0, len(s.ProjectChannels))
+	for project := range s.ProjectChannels {
+		projects = append(projects, project)
+	}
+	return projects
+}
+
+// SeedPositions registers how the fan-out of a project learns the position the stream starts
+// from, see PositionSeed
+func (s *SSELogService) SeedPositions(seed PositionSeed) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seed = seed
 }
 
 func (s *SSELogService) fanOutLogs(project string, projectChan chan models.Log, shutdownChan chan struct{}) {
 	log.Printf("Fan-out goroutine started for project: %s", project)
 	defer log.Printf("Fan-out goroutine stopped for project: %s", project)
 
+	s.mu.RLock()
+	seed := s.seed
+	s.mu.RUnlock()
+	positions := seedPositions(seed, "logs", project)
+
 	for {
 		select {
 		case logEntry, ok := <-projectChan:
@@ -179,6 +261,11 @@ func (s *SSELogService) fanOutLogs(project string, projectChan chan models.Log,
 				return
 			}
 
+			if partition, offset, err := models.ParseEventID(logEntry.EventID); err == nil {
+				positions.Advance(partition, offset)
+			}
+			logEntry.Position = maps.Clone(positions)
+
 			s.mu.RLock()
 			clientsForProject, exists := s.ProjectClients[project]
 			if !exists {
@@ -186,14 +273,16 @@ func (s *SSELogService) fanOutLogs(project string, projectChan chan models.Log,
 				log.Printf("Project client map for %s no longer exists, stopping fan-out", project)
 				return
 			}
+			s.replays[project].add(logEntry.EventID, logEntry)
 
 			// Send it to all clients
 			for clientID, client := range clientsForProject {
 				client.mu.Lock()
-				if !client.closed {
+				if !client.closed && (client.Matcher == nil || client.Matcher.Match(&logEntry)) {
 					select {
 					case client.Channel <- logEntry:
 					default:
+						client.Dropped++
 						log.Printf("Client %s channel full for project %s, dropping log", clientID, project)
 					}
 				}
@@ -240,6 +329,20 @@ func (s *SSELogService) GetLogClientChannel(clientID string) (chan models.Log, b
 	return client.Channel, true
 }
 
+// Stats describes the registered clients for the live sessions endpoint
+func (s *SSELogService) Stats() []*dto.LiveClientStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make([]*dto.LiveClientStats, 0, len(s.Clients))
+	for clientID, client := range s.Clients {
+		client.mu.Lock()
+		stats = append(stats, client.Session.clientStats(clientID, "logs", client.Project, len(client.Channel), cap(client.Channel), client.Dropped, client.LastSeen))
+		client.mu.Unlock()
+	}
+	return stats
+}
+
 // UpdateLogClientActivity updates the last seen time for a client
 func (s *SSELogService) UpdateLogClientActivity(clientID string) {
 	s.mu.RLock()
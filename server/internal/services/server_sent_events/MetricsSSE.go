@@ -2,19 +2,22 @@ package serversentevents
 
 import (
 	"log"
+	"maps"
+	"server/internal/api/dto"
 	"server/internal/models"
 	"sync"
 	"time"
 )
 
 type MetricsClient struct {
-	ID        string
-	Project   string
-	Channel   chan models.Metrics
-	closed    bool
-	mu        sync.Mutex
-	LastSeen  time.Time
-	Connected bool
+	ID       string
+	Session  *Session
+	Project  string
+	Channel  chan models.Metrics
+	closed   bool
+	mu       sync.Mutex
+	LastSeen time.Time
+	Dropped  uint64
 }
 
 type SSEMetricsService struct {
@@ -23,6 +26,10 @@ type SSEMetricsService struct {
 	ProjectClients  map[string]map[string]*MetricsClient
 	ProjectChannels map[string]chan models.Metrics
 	shutdownChans   map[string]chan struct{}
+	replays         map[string]*replayBuffer[models.Metrics]
+	lingers         map[string]*time.Timer
+	onProjectChange func()
+	seed            PositionSeed
 }
 
 func NewSSEMetricsService() *SSEMetricsService {
@@ -31,35 +38,28 @@ func NewSSEMetricsService() *SSEMetricsService {
 		ProjectClients:  make(map[string]map[string]*MetricsClient),
 		ProjectChannels: make(map[string]chan models.Metrics),
 		shutdownChans:   make(map[string]chan struct{}),
+		replays:         make(map[string]*replayBuffer[models.Metrics]),
+		lingers:         make(map[string]*time.Timer),
 	}
 }
 
-func (s *SSEMetricsService) RegisterMetricsClient(clientID, project string) {
+// RegisterMetricsClient registers a client of the session for the project and returns the
+// buffered samples past the position it resumes from, like SSELogService.RegisterLogsClient
+func (s *SSEMetricsService) RegisterMetricsClient(clientID string, session *Session, project string, since models.StreamPosition) (replay []models.Metrics, found bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if existingClient, exists := s.Clients[clientID]; exists {
-		existingClient.mu.Lock()
-		timeSincelastSeen := time.Since(existingClient.LastSeen)
-		existingClient.mu.Unlock()
-
-		if timeSincelastSeen < 5*time.Second && existingClient.Project == project {
-			existingClient.mu.Lock()
-			existingClient.Connected = true
-			existingClient.LastSeen = time.Now()
-			existingClient.mu.Unlock()
-			return
-		}
 		s.unregisterClientUnsafe(clientID, existingClient)
 	}
 
 	client := &MetricsClient{
-		ID:        clientID,
-		Project:   project,
-		Channel:   make(chan models.Metrics),
-		closed:    false,
-		LastSeen:  time.Now(),
-		Connected: true,
+		ID:       clientID,
+		Session:  session,
+		Project:  project,
+		Channel:  make(chan models.Metrics, 100),
+		closed:   false,
+		LastSeen: time.Now(),
 	}
 
 	s.Clients[clientID] = client
@@ -69,13 +69,27 @@ func (s *SSEMetricsService) RegisterMetricsClient(clientID, project string) {
 	}
 	s.ProjectClients[project][clientID] = client
 
+	if timer, lingering := s.lingers[project]; lingering {
+		timer.Stop()
+		delete(s.lingers, project)
+	}
+
 	if _, exist := s.ProjectChannels[project]; !exist {
 		projectChan := make(chan models.Metrics, 100)
 		shutdownChans := make(chan struct{})
 		s.ProjectChannels[project] = projectChan
 		s.shutdownChans[project] = shutdownChans
+		s.replays[project] = newReplayBuffer[models.Metrics](ReplayBufferSize)
 		go s.fanOutMetrics(project, projectChan, shutdownChans)
+		if s.onProjectChange != nil {
+			s.onProjectChange()
+		}
+	}
+
+	if since == nil {
+		return nil, true
 	}
+	return s.replays[project].after(since)
 }
 
 func (s *SSEMetricsService) UnRegisterMetricsClient(clientId string) {
@@ -83,26 +97,7 @@ func (s *SSEMetricsService) UnRegisterMetricsClient(clientId string) {
 	defer s.mu.Unlock()
 
 	if client, exists := s.Clients[clientId]; exists {
-		client.mu.Lock()
-		client.Connected = false
-		client.mu.Unlock()
-
-		go func() {
-			time.Sleep(10 * time.Second)
-			s.mu.Lock()
-			defer s.mu.Unlock()
-
-			if client, exists := s.Clients[clientId]; exists {
-				client.mu.Lock()
-				stillDisconnected := !client.Connected
-				client.mu.Unlock()
-
-				if stillDisconnected {
-					s.unregisterClientUnsafe(clientId, client)
-				}
-			}
-		}()
-
+		s.unregisterClientUnsafe(clientId, client)
 	}
 }
 
@@ -122,36 +117,99 @@ func (s *SSEMetricsService) unregisterClientUnsafe(clientID string, client *Metr
 		delete(projectsClients, clientID)
 
 		if len(projectsClients) == 0 {
+			s.lingerUnsafe(client.Project)
+		}
+	}
+}
 
-			if shutdownChan, ok := s.shutdownChans[client.Project]; ok {
-				close(shutdownChan)
-				delete(s.shutdownChans, client.Project)
-			}
+// lingerUnsafe cleans up the project resources after projectLinger unless a client registered
+func (s *SSEMetricsService) lingerUnsafe(project string) {
+	if timer, lingering := s.lingers[project]; lingering {
+		timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(projectLinger, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.lingers[project] == timer && len(s.ProjectClients[project]) == 0 {
+			s.stopProjectUnsafe(project)
+		}
+	})
+	s.lingers[project] = timer
+}
 
-			//close projevt ProjectChannels
-			if projectChan, ok := s.ProjectChannels[client.Project]; ok {
-				close(projectChan)
-				delete(s.ProjectChannels, client.Project)
-			}
+func (s *SSEMetricsService) stopProjectUnsafe(project string) {
+	if shutdownChan, ok := s.shutdownChans[project]; ok {
+		close(shutdownChan)
+		delete(s.shutdownChans, project)
+	}
 
-			delete(s.ProjectClients, client.Project)
-		}
+	//close projevt ProjectChannels
+	if projectChan, ok := s.ProjectChannels[project]; ok {
+		close(projectChan)
+		delete(s.ProjectChannels, project)
 	}
+
+	delete(s.ProjectClients, project)
+	delete(s.replays, project)
+	delete(s.lingers, project)
+	if s.onProjectChange != nil {
+		s.onProjectChange()
+	}
+}
+
+// OnProjectsChange registers a callback run when a project gains its first client or its
+// resources are cleaned up after losing its last one. It is called with the registry locked, so it must not block or call back into it.
+func (s *SSEMetricsService) OnProjectsChange(callback func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onProjectChange = callback
+}
+
+// Projects returns the projects with at least one client, or that recently had one
+func (s *SSEMetricsService) Projects() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	projects := make([]string, 0, len(s.ProjectChannels))
+	for project := range s.ProjectChannels {
+		projects = append(projects, project)
+	}
+	return projects
+}
+
+// SeedPositions registers how the fan-out of a project learns the position the stream starts
+// from, see PositionSeed
+func (s *SSEMetricsService) SeedPositions(seed PositionSeed) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seed = seed
 }
 
 func (s *SSEMetricsService) fanOutMetrics(project string, projectChan chan models.Metrics, shutdownChan chan struct{}) {
+	s.mu.RLock()
+	seed := s.seed
+	s.mu.RUnlock()
+	positions := seedPositions(seed, "metrics", project)
+
 	for {
 		select {
 		case metrics, ok := <-projectChan:
 			if !ok {
 				return
 			}
+			if partition, offset, err := models.ParseEventID(metrics.EventID); err == nil {
+				positions.Advance(partition, offset)
+			}
+			metrics.Position = maps.Clone(positions)
+
 			s.mu.RLock()
 			clientsForProject, exists := s.ProjectClients[project]
 			if !exists {
 				s.mu.RUnlock()
 				return
 			}
+			s.replays[project].add(metrics.EventID, metrics)
 
 			for clientID, client := range clientsForProject {
 				client.mu.Lock()
@@ -159,6 +217,7 @@ func (s *SSEMetricsService) fanOutMetrics(project string, projectChan chan model
 					select {
 					case client.Channel <- metrics:
 					default:
+						client.Dropped++
 						log.Printf("Client %s channel full for project %s, dropping log", clientID, project)
 					}
 				}
@@ -202,6 +261,20 @@ func (s *SSEMetricsService) GetMetricsClientChannel(clientId string) (chan model
 	return client.Channel, true
 }
 
+// Stats describes the registered clients for the live sessions endpoint
+func (s *SSEMetricsService) Stats() []*dto.LiveClientStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make([]*dto.LiveClientStats, 0, len(s.Clients))
+	for clientID, client := range s.Clients {
+		client.mu.Lock()
+		stats = append(stats, client.Session.clientStats(clientID, "metrics", client.Project, len(client.Channel), cap(client.Channel), client.Dropped, client.LastSeen))
+		client.mu.Unlock()
+	}
+	return stats
+}
+
 func (s *SSEMetricsService) UpdateMetricsClientActivity(clientID string) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -0,0 +1,107 @@
+package serversentevents
+
+import (
+	"log"
+	"server/internal/models"
+	"time"
+)
+
+const (
+	// ReplayBufferSize bounds the latest events kept per project for reconnecting clients
+	ReplayBufferSize = 1000
+	// projectLinger keeps the fan-out and replay buffer of a project once its last client left,
+	// so a client reconnecting after its connection dropped finds what it missed
+	projectLinger = 30 * time.Second
+)
+
+// PositionSeed returns the position of the stored events of a project per partition. The
+// fan-out of a project starts from it, so the positions it hands out cover every partition the
+// project had events in before the stream started and not only those it has seen since.
+type PositionSeed func(project string) (models.StreamPosition, error)
+
+func seedPositions(seed PositionSeed, stream string, project string) models.StreamPosition {
+	if seed != nil {
+		positions, err := seed(project)
+		if err == nil {
+			return positions
+		}
+		log.Printf("Failed to read the %s stream position of project %s: %v", stream, project, err)
+	}
+	return models.StreamPosition{}
+}
+
+// replayBuffer is a ring of the latest events of a project and their IDs. It is written by the
+// fan-out goroutine of the project with the registry read locked, and read with it locked.
+type replayBuffer[T any] struct {
+	ids    []string
+	events []T
+	next   int // oldest entry once the ring is full
+	// evicted is the last offset per partition that fell out of the ring, for IDs built by
+	// models.EventID
+	evicted models.StreamPosition
+}
+
+func newReplayBuffer[T any](size int) *replayBuffer[T] {
+	return &replayBuffer[T]{
+		ids:     make([]string, 0, size),
+		events:  make([]T, 0, size),
+		evicted: models.StreamPosition{},
+	}
+}
+
+func (b *replayBuffer[T]) add(id string, event T) {
+	if id == "" {
+		return
+	}
+	if len(b.events) < cap(b.events) {
+		b.ids = append(b.ids, id)
+		b.events = append(b.events, event)
+		return
+	}
+	if partition, offset, err := models.ParseEventID(b.ids[b.next]); err == nil {
+		b.evicted.Advance(partition, offset)
+	}
+	b.ids[b.next] = id
+	b.events[b.next] = event
+	b.next = (b.next + 1) % len(b.events)
+}
+
+// since returns the events buffered after the one with the ID, oldest first. When the ID is no
+// longer buffered every event is returned with false, the client missed more than the buffer
+// holds and the older events must be read from storage.
+func (b *replayBuffer[T]) since(id string) ([]T, bool) {
+	n := len(b.events)
+	start, found := 0, false
+	for i := n - 1; i >= 0; i-- {
+		if b.ids[(b.next+i)%n] == id {
+			start, found = i+1, true
+			break
+		}
+	}
+
+	events := make([]T, 0, n-start)
+	for i := start; i < n; i++ {
+		events = append(events, b.events[(b.next+i)%n])
+	}
+	return events, found
+}
+
+// after returns the buffered events past the position, in the order they were added. It returns
+// false with them when events past the position already fell out of the ring, those must be
+// read from storage.
+func (b *replayBuffer[T]) after(position models.StreamPosition) ([]T, bool) {
+	n := len(b.events)
+	var events []T
+	for i := 0; i < n; i++ {
+		partition, offset, err := models.ParseEventID(b.ids[(b.next+i)%n])
+		if err == nil && !position.Includes(partition, offset) {
+			events = append(events, b.events[(b.next+i)%n])
+		}
+	}
+	for partition, offset := range b.evicted {
+		if !position.Includes(partition, offset) {
+			return events, false
+		}
+	}
+	return events, true
+}
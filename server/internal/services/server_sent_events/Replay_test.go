@@ -0,0 +1,57 @@
+package serversentevents
+
+import (
+	"reflect"
+	"server/internal/models"
+	"testing"
+)
+
+func TestReplayBufferAfter(t *testing.T) {
+	// Partition 1 lags, its events are consumed with older timestamps after those of partition 0
+	buffer := newReplayBuffer[string](4)
+	for _, id := range []string{"0:10", "0:11", "1:5", "0:12", "1:6"} {
+		buffer.add(id, id)
+	}
+	// 0:10 fell out of the ring
+
+	tests := []struct {
+		name      string
+		position  models.StreamPosition
+		want      []string
+		wantFound bool
+	}{
+		{name: "up to date", position: models.StreamPosition{0: 12, 1: 6}, want: nil, wantFound: true},
+		{
+			name:      "missed the lagging partition",
+			position:  models.StreamPosition{0: 12, 1: 4},
+			want:      []string{"1:5", "1:6"},
+			wantFound: true,
+		},
+		{
+			name:      "before the evicted event",
+			position:  models.StreamPosition{0: 9, 1: 6},
+			want:      []string{"0:11", "0:12"},
+			wantFound: false,
+		},
+		{
+			name:      "partition never seen",
+			position:  models.StreamPosition{0: 11},
+			want:      []string{"1:5", "0:12", "1:6"},
+			wantFound: true,
+		},
+		{
+			name:      "evicted partition never seen",
+			position:  models.StreamPosition{1: 6},
+			want:      []string{"0:11", "0:12"},
+			wantFound: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := buffer.after(tt.position)
+			if !reflect.DeepEqual(got, tt.want) || found != tt.wantFound {
+				t.Errorf("after(%v) = %v, %v, want %v, %v", tt.position, got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
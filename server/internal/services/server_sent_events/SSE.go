@@ -1,9 +1,23 @@
 package serversentevents
 
+import "server/internal/models"
+
+// LogBroadcaster delivers consumed logs to live stream clients, SSELogService reaches the
+// clients of this instance and redis_pubsub.LiveFanOut those of every instance
+type LogBroadcaster interface {
+	BroadcastLogs(project string, logEntry *models.Log)
+}
+
+// MetricsBroadcaster delivers consumed metrics to live stream clients, like LogBroadcaster
+type MetricsBroadcaster interface {
+	BroadcastMetrics(project string, metrics *models.Metrics)
+}
+
 type SSEService struct {
 	LogSSE    *SSELogService
 	MetricSSE *SSEMetricsService
 	AlertSSE  *SSEAlertService
+	Sessions  *Sessions
 }
 
 func NewSSEService() *SSEService {
@@ -14,5 +28,6 @@ func NewSSEService() *SSEService {
 		LogSSE:    logSSE,
 		MetricSSE: metricSSE,
 		AlertSSE:  alertSSE,
+		Sessions:  NewSessions(MaxSessionsPerUser),
 	}
 }
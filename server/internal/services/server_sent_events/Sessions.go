@@ -0,0 +1,103 @@
+package serversentevents
+
+import (
+	"errors"
+	"fmt"
+	"server/internal/api/dto"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxSessionsPerUser bounds the live connections a user can hold open at once, across tabs,
+// projects and transports
+const MaxSessionsPerUser = 10
+
+var ErrTooManySessions = errors.New("too many live sessions")
+
+// Session is one live stream connection of a user: an SSE request, or a WebSocket carrying
+// several subscriptions. Registry clients belong to a session and are keyed by IDs derived from
+// it, so tabs of the same user never replace each other.
+type Session struct {
+	ID        string
+	User      string
+	Transport string // sse or websocket
+	Started   time.Time
+}
+
+// Sessions tracks the open sessions of every user on this instance. They are kept in memory,
+// so the per-user limit and the session list apply to each instance on its own.
+type Sessions struct {
+	mu     sync.Mutex
+	limit  int
+	byID   map[string]*Session
+	byUser map[string]int
+}
+
+func NewSessions(limit int) *Sessions {
+	return &Sessions{
+		limit:  limit,
+		byID:   make(map[string]*Session),
+		byUser: make(map[string]int),
+	}
+}
+
+// Open starts a session for the user, or returns ErrTooManySessions when the user already
+// holds the maximum number of sessions
+func (s *Sessions) Open(user, transport string) (*Session, error) {
+	id := uuid.NewString()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byUser[user] >= s.limit {
+		return nil, fmt.Errorf("%w: at most %d are allowed per user, close another tab or stream", ErrTooManySessions, s.limit)
+	}
+	session := &Session{
+		ID:        id,
+		User:      user,
+		Transport: transport,
+		Started:   time.Now(),
+	}
+	s.byID[id] = session
+	s.byUser[user]++
+	return session, nil
+}
+
+// Close ends the session, closing it twice is harmless
+func (s *Sessions) Close(session *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byID[session.ID]; !ok {
+		return
+	}
+	delete(s.byID, session.ID)
+	s.byUser[session.User]--
+	if s.byUser[session.User] <= 0 {
+		delete(s.byUser, session.User)
+	}
+}
+
+// Count returns the number of open sessions
+func (s *Sessions) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.byID)
+}
+
+// clientStats describes a registry client of the session, the caller holds the client's lock
+func (session *Session) clientStats(clientID, stream, project string, buffered, capacity int, dropped uint64, lastSeen time.Time) *dto.LiveClientStats {
+	return &dto.LiveClientStats{
+		ClientID:  clientID,
+		SessionID: session.ID,
+		User:      session.User,
+		Transport: session.Transport,
+		Stream:    stream,
+		Project:   project,
+		Buffered:  buffered,
+		Capacity:  capacity,
+		Dropped:   dropped,
+		Started:   session.Started,
+		LastSeen:  lastSeen,
+	}
+}
@@ -32,6 +32,14 @@ type UserClaims struct {
 	Name       string `json:"name"`
 }
 
+// AdminScope is the scope a token needs to call the admin endpoints
+const AdminScope = "Data.Admin"
+
+// HasScope reports whether the space separated scopes of the token include scope
+func (u *UserClaims) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(u.Role), scope)
+}
+
 func getKeySet(ctx context.Context) (jwk.Set, error) {
 	cfg, err := config.SetupEnv()
 	if err != nil {
@@ -194,14 +202,29 @@ func AuthMiddleware() fiber.Handler {
 		aud := fmt.Sprintf("api://%s", cfg.ApplicationClientID)
 		iss := fmt.Sprintf("https://sts.windows.net/%s/", cfg.DirectoryTenantID)
 
-		_, err := validateToken(rawToken, aud, iss)
+		user, err := validateToken(rawToken, aud, iss)
 
 		if err != nil {
 			log.Printf("Token validation failed: %v", err)
 			status, formatedErr := handleTokenError(err)
 			return fiber.NewError(status, formatedErr)
 		}
+		c.Locals("user", user)
+
+		return c.Next()
+	}
+}
 
+// RequireScope only lets requests through whose token, validated by AuthMiddleware, holds scope
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := c.Locals("user").(*UserClaims)
+		if !ok {
+			return fiber.NewError(fiber.StatusUnauthorized, "Missing authentication")
+		}
+		if !user.HasScope(scope) {
+			return fiber.NewError(fiber.StatusForbidden, "Insufficient permissions")
+		}
 		return c.Next()
 	}
 }
@@ -234,3 +257,29 @@ func SSEAuthMiddleware() fiber.Handler {
 		return c.Next()
 	}
 }
+
+// TokenValidator validates tokens sent in-band, e.g. in the first frame of a WebSocket
+// connection. The error holds the same message the middlewares respond with.
+func TokenValidator() func(token string) (*UserClaims, error) {
+
+	cfg, err := config.SetupEnv()
+	if err != nil {
+		log.Fatal("Failed to load env variables", err)
+	}
+	aud := fmt.Sprintf("api://%s", cfg.ApplicationClientID)
+	iss := fmt.Sprintf("https://sts.windows.net/%s/", cfg.DirectoryTenantID)
+
+	return func(token string) (*UserClaims, error) {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			return nil, errors.New("token is required")
+		}
+		user, err := validateToken(token, aud, iss)
+		if err != nil {
+			log.Printf("Token validation failed: %v", err)
+			_, formattedErr := handleTokenError(err)
+			return nil, errors.New(formattedErr)
+		}
+		return user, nil
+	}
+}
@@ -1,8 +1,11 @@
 package pkg
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -64,3 +67,77 @@ func ConvertEpochMillisToString(epochMillis int64) string {
 	t := time.Unix(0, epochMillis*int64(time.Millisecond))
 	return t.Format("2006-01-02-15")
 }
+
+// RetryWithBackoff calls fn until it succeeds, doubling the delay between attempts up to maxDelay.
+// It returns the last error once ctx is done.
+func RetryWithBackoff(ctx context.Context, initialDelay time.Duration, maxDelay time.Duration, fn func() error) error {
+	delay := initialDelay
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+// ParseStatusRange parses a response status filter into an inclusive range of codes. It accepts
+// an exact code ("404"), a class ("5xx") or a range ("400-499").
+func ParseStatusRange(s string) (int, int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+		class := int(s[0]-'0') * 100
+		return class, class + 99, nil
+	}
+	if from, to, ok := strings.Cut(s, "-"); ok {
+		fromCode, err1 := ParseStatusCode(from)
+		toCode, err2 := ParseStatusCode(to)
+		if err1 != nil || err2 != nil || fromCode > toCode {
+			return 0, 0, fmt.Errorf("invalid response status range %q", s)
+		}
+		return fromCode, toCode, nil
+	}
+	code, err := ParseStatusCode(s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid response status %q: use a code, a class like 5xx or a range like 400-499", s)
+	}
+	return code, code, nil
+}
+
+// ParseStatusCode parses a single HTTP status code
+func ParseStatusCode(s string) (int, error) {
+	code, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || code < 100 || code > 599 {
+		return 0, fmt.Errorf("invalid status code %q", s)
+	}
+	return code, nil
+}
+
+// ParseResponseTime parses a response time sent as a string into milliseconds. It accepts a bare
+// number of milliseconds ("12.5") or a number with a unit ("12.5 ms", "1.2s", "800us").
+func ParseResponseTime(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	value := strings.TrimRight(s, "abcdefghijklmnopqrstuvwxyzµ ")
+	unit := strings.TrimSpace(s[len(value):])
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid response time %q", s)
+	}
+	switch unit {
+	case "", "ms":
+		return n, nil
+	case "s":
+		return n * 1000, nil
+	case "us", "µs":
+		return n / 1000, nil
+	case "ns":
+		return n / 1e6, nil
+	}
+	return 0, fmt.Errorf("invalid response time unit in %q", s)
+}